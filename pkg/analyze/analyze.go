@@ -0,0 +1,374 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/steveteuber/kubectl-graph/pkg/graph"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DefaultSamples represents the default number of source nodes used to approximate the betweenness.
+	DefaultSamples int = 100
+	// DefaultMaxCycles represents the default limit of listed cycles.
+	DefaultMaxCycles int = 20
+	// DefaultTop represents the default number of nodes listed per ranking in the report.
+	DefaultTop int = 10
+)
+
+// Options represents attributes to configure the analysis.
+type Options struct {
+	Samples   int
+	MaxCycles int
+	Top       int
+}
+
+// Result contains the results of all graph algorithms.
+type Result struct {
+	Components  [][]types.UID
+	InDegree    map[types.UID]int
+	OutDegree   map[types.UID]int
+	Betweenness map[types.UID]float64
+	Cycles      [][]types.UID
+
+	graph    *graph.Graph
+	options  Options
+	nodes    []types.UID
+	children map[types.UID][]types.UID
+	parents  map[types.UID][]types.UID
+}
+
+// Analyze runs all graph algorithms on g.
+func Analyze(g *graph.Graph, o Options) *Result {
+	if o.Samples <= 0 {
+		o.Samples = DefaultSamples
+	}
+	if o.MaxCycles <= 0 {
+		o.MaxCycles = DefaultMaxCycles
+	}
+	if o.Top <= 0 {
+		o.Top = DefaultTop
+	}
+
+	r := &Result{
+		InDegree:    make(map[types.UID]int),
+		OutDegree:   make(map[types.UID]int),
+		Betweenness: make(map[types.UID]float64),
+		graph:       g,
+		options:     o,
+		children:    make(map[types.UID][]types.UID),
+		parents:     make(map[types.UID][]types.UID),
+	}
+
+	for _, node := range g.NodeList() {
+		r.nodes = append(r.nodes, node.UID)
+	}
+	for _, relationship := range g.RelationshipList() {
+		if _, ok := g.Nodes[relationship.From]; !ok {
+			continue
+		}
+		if _, ok := g.Nodes[relationship.To]; !ok {
+			continue
+		}
+		r.children[relationship.From] = append(r.children[relationship.From], relationship.To)
+		r.parents[relationship.To] = append(r.parents[relationship.To], relationship.From)
+		r.OutDegree[relationship.From]++
+		r.InDegree[relationship.To]++
+	}
+
+	r.components()
+	r.betweenness()
+	r.cycles()
+
+	return r
+}
+
+// components finds all weakly connected components, largest first.
+func (r *Result) components() {
+	visited := make(map[types.UID]bool)
+
+	for _, uid := range r.nodes {
+		if visited[uid] {
+			continue
+		}
+
+		component := []types.UID{}
+		stack := []types.UID{uid}
+		visited[uid] = true
+		for len(stack) != 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, current)
+
+			neighbours := append(append([]types.UID{}, r.children[current]...), r.parents[current]...)
+			for _, neighbour := range neighbours {
+				if !visited[neighbour] {
+					visited[neighbour] = true
+					stack = append(stack, neighbour)
+				}
+			}
+		}
+
+		r.Components = append(r.Components, component)
+	}
+
+	sort.SliceStable(r.Components, func(i, j int) bool {
+		return len(r.Components[i]) > len(r.Components[j])
+	})
+}
+
+// betweenness approximates the betweenness centrality with Brandes' algorithm
+// using evenly spaced source nodes and extrapolates the result.
+func (r *Result) betweenness() {
+	if len(r.nodes) == 0 {
+		return
+	}
+
+	step := 1
+	if len(r.nodes) > r.options.Samples {
+		step = len(r.nodes) / r.options.Samples
+	}
+
+	samples := 0
+	for i := 0; i < len(r.nodes); i += step {
+		samples++
+		source := r.nodes[i]
+
+		stack := []types.UID{}
+		predecessors := make(map[types.UID][]types.UID)
+		sigma := map[types.UID]float64{source: 1}
+		distance := map[types.UID]int{source: 0}
+
+		queue := []types.UID{source}
+		for len(queue) != 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+
+			for _, w := range r.children[v] {
+				if _, ok := distance[w]; !ok {
+					distance[w] = distance[v] + 1
+					queue = append(queue, w)
+				}
+				if distance[w] == distance[v]+1 {
+					sigma[w] += sigma[v]
+					predecessors[w] = append(predecessors[w], v)
+				}
+			}
+		}
+
+		delta := make(map[types.UID]float64)
+		for len(stack) != 0 {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			for _, v := range predecessors[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != source {
+				r.Betweenness[w] += delta[w]
+			}
+		}
+	}
+
+	scale := float64(len(r.nodes)) / float64(samples)
+	for uid := range r.Betweenness {
+		r.Betweenness[uid] *= scale
+	}
+}
+
+// cycles lists up to MaxCycles cycles, one for each strongly connected
+// component with more than one node or a self loop.
+func (r *Result) cycles() {
+	index := 0
+	indices := make(map[types.UID]int)
+	lowlink := make(map[types.UID]int)
+	onStack := make(map[types.UID]bool)
+	stack := []types.UID{}
+	components := [][]types.UID{}
+
+	var strongconnect func(v types.UID)
+	strongconnect = func(v types.UID) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range r.children[v] {
+			if _, ok := indices[w]; !ok {
+				strongconnect(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], indices[w])
+			}
+		}
+
+		if lowlink[v] == indices[v] {
+			component := []types.UID{}
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, uid := range r.nodes {
+		if _, ok := indices[uid]; !ok {
+			strongconnect(uid)
+		}
+	}
+
+	for _, component := range components {
+		if len(r.Cycles) >= r.options.MaxCycles {
+			return
+		}
+
+		members := make(map[types.UID]bool, len(component))
+		for _, uid := range component {
+			members[uid] = true
+		}
+
+		if cycle := r.cycle(component[len(component)-1], members); cycle != nil {
+			r.Cycles = append(r.Cycles, cycle)
+		}
+	}
+}
+
+// cycle returns the shortest cycle from start back to start within members.
+func (r *Result) cycle(start types.UID, members map[types.UID]bool) []types.UID {
+	previous := make(map[types.UID]types.UID)
+	queue := []types.UID{start}
+	visited := map[types.UID]bool{start: true}
+
+	for len(queue) != 0 {
+		v := queue[0]
+		queue = queue[1:]
+
+		for _, w := range r.children[v] {
+			if !members[w] {
+				continue
+			}
+			if w == start {
+				cycle := []types.UID{v}
+				for v != start {
+					v = previous[v]
+					cycle = append([]types.UID{v}, cycle...)
+				}
+				return cycle
+			}
+			if !visited[w] {
+				visited[w] = true
+				previous[w] = v
+				queue = append(queue, w)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Annotate adds the results as attributes to the nodes of the graph.
+func (r *Result) Annotate() {
+	for idx, component := range r.Components {
+		for _, uid := range component {
+			r.graph.Nodes[uid].Attribute("component", strconv.Itoa(idx+1))
+		}
+	}
+
+	for _, uid := range r.nodes {
+		node := r.graph.Nodes[uid]
+		node.Attribute("inDegree", strconv.Itoa(r.InDegree[uid]))
+		node.Attribute("outDegree", strconv.Itoa(r.OutDegree[uid]))
+		node.Attribute("betweenness", strconv.FormatFloat(r.Betweenness[uid], 'f', 2, 64))
+	}
+
+	for _, cycle := range r.Cycles {
+		for _, uid := range cycle {
+			r.graph.Nodes[uid].Attribute("cyclic", "true")
+		}
+	}
+}
+
+// WriteReport writes a text report of the results to w.
+func (r *Result) WriteReport(w io.Writer) error {
+	fmt.Fprintf(w, "Nodes: %d\n", len(r.nodes))
+	fmt.Fprintf(w, "Connected components: %d\n", len(r.Components))
+	for idx, component := range r.Components {
+		if idx >= r.options.Top {
+			fmt.Fprintf(w, "  ... %d more\n", len(r.Components)-idx)
+			break
+		}
+		fmt.Fprintf(w, "  %d. %d nodes, e.g. %s\n", idx+1, len(component), r.name(component[0]))
+	}
+
+	r.writeRanking(w, "Highest in-degree", func(uid types.UID) float64 { return float64(r.InDegree[uid]) }, "%.0f")
+	r.writeRanking(w, "Highest out-degree", func(uid types.UID) float64 { return float64(r.OutDegree[uid]) }, "%.0f")
+	r.writeRanking(w, "Highest betweenness (approximated)", func(uid types.UID) float64 { return r.Betweenness[uid] }, "%.2f")
+
+	fmt.Fprintf(w, "Cycles: %d\n", len(r.Cycles))
+	for idx, cycle := range r.Cycles {
+		fmt.Fprintf(w, "  %d.", idx+1)
+		for _, uid := range cycle {
+			fmt.Fprintf(w, " %s ->", r.name(uid))
+		}
+		fmt.Fprintf(w, " %s\n", r.name(cycle[0]))
+	}
+
+	warnings := r.graph.Warnings()
+	fmt.Fprintf(w, "Warnings: %d\n", len(warnings))
+	for idx, warning := range warnings {
+		fmt.Fprintf(w, "  %d. %s\n", idx+1, warning)
+	}
+
+	return nil
+}
+
+// writeRanking writes the top nodes ordered by the value of f.
+func (r *Result) writeRanking(w io.Writer, title string, f func(types.UID) float64, format string) {
+	ranking := append([]types.UID{}, r.nodes...)
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return f(ranking[i]) > f(ranking[j])
+	})
+
+	fmt.Fprintf(w, "%s:\n", title)
+	for idx, uid := range ranking {
+		if idx >= r.options.Top || f(uid) == 0 {
+			break
+		}
+		fmt.Fprintf(w, "  %d. %s: "+format+"\n", idx+1, r.name(uid), f(uid))
+	}
+}
+
+// name returns a human readable name of the node.
+func (r *Result) name(uid types.UID) string {
+	node := r.graph.Nodes[uid]
+	if len(node.Namespace) != 0 {
+		return fmt.Sprintf("%s[%s/%s]", node.Kind, node.Namespace, node.Name)
+	}
+
+	return fmt.Sprintf("%s[%s]", node.Kind, node.Name)
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/steveteuber/kubectl-graph/pkg/graph"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// newGraph returns a graph with one Pod per name and the edges as
+// relationships, the UID of each node is its name.
+func newGraph(names []string, edges [][2]string) *graph.Graph {
+	g := graph.NewEmptyGraph(nil, nil)
+
+	nodes := make(map[string]*graph.Node)
+	for _, name := range names {
+		nodes[name] = g.Node(
+			schema.FromAPIVersionAndKind("v1", "Pod"),
+			&metav1.ObjectMeta{UID: types.UID(name), Name: name},
+		)
+	}
+	for _, edge := range edges {
+		g.Relationship(nodes[edge[0]], "Pod", nodes[edge[1]])
+	}
+
+	return g
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name        string
+		nodes       []string
+		edges       [][2]string
+		components  []int
+		betweenness map[types.UID]float64
+		cycles      [][]types.UID
+	}{
+		{
+			name:       "empty",
+			components: nil,
+		},
+		{
+			name:        "chain",
+			nodes:       []string{"a", "b", "c"},
+			edges:       [][2]string{{"a", "b"}, {"b", "c"}},
+			components:  []int{3},
+			betweenness: map[types.UID]float64{"b": 1},
+		},
+		{
+			name:        "diamond",
+			nodes:       []string{"a", "b", "c", "d"},
+			edges:       [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}},
+			components:  []int{4},
+			betweenness: map[types.UID]float64{"b": 0.5, "c": 0.5},
+		},
+		{
+			name:        "components",
+			nodes:       []string{"a", "b", "c", "d", "e"},
+			edges:       [][2]string{{"c", "d"}, {"d", "e"}},
+			components:  []int{3, 1, 1},
+			betweenness: map[types.UID]float64{"d": 1},
+		},
+		{
+			name:        "cycle",
+			nodes:       []string{"a", "b", "c", "d"},
+			edges:       [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"c", "d"}},
+			components:  []int{4},
+			betweenness: map[types.UID]float64{"a": 1, "b": 2, "c": 3},
+			cycles:      [][]types.UID{{"a", "b", "c"}},
+		},
+		{
+			name:       "self loop",
+			nodes:      []string{"a"},
+			edges:      [][2]string{{"a", "a"}},
+			components: []int{1},
+			cycles:     [][]types.UID{{"a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Analyze(newGraph(tt.nodes, tt.edges), Options{})
+
+			var components []int
+			for _, component := range r.Components {
+				components = append(components, len(component))
+			}
+			if !reflect.DeepEqual(components, tt.components) {
+				t.Errorf("components = %v, want %v", components, tt.components)
+			}
+
+			for _, name := range tt.nodes {
+				uid := types.UID(name)
+				if got, want := r.Betweenness[uid], tt.betweenness[uid]; got != want {
+					t.Errorf("betweenness[%s] = %v, want %v", uid, got, want)
+				}
+			}
+
+			if !reflect.DeepEqual(r.Cycles, tt.cycles) {
+				t.Errorf("cycles = %v, want %v", r.Cycles, tt.cycles)
+			}
+		})
+	}
+}
+
+func TestAnalyzeDegree(t *testing.T) {
+	r := Analyze(newGraph(
+		[]string{"a", "b", "c"},
+		[][2]string{{"a", "b"}, {"a", "c"}, {"b", "c"}},
+	), Options{})
+
+	tests := []struct {
+		uid types.UID
+		in  int
+		out int
+	}{
+		{uid: "a", in: 0, out: 2},
+		{uid: "b", in: 1, out: 1},
+		{uid: "c", in: 2, out: 0},
+	}
+
+	for _, tt := range tests {
+		if got := r.InDegree[tt.uid]; got != tt.in {
+			t.Errorf("InDegree[%s] = %d, want %d", tt.uid, got, tt.in)
+		}
+		if got := r.OutDegree[tt.uid]; got != tt.out {
+			t.Errorf("OutDegree[%s] = %d, want %d", tt.uid, got, tt.out)
+		}
+	}
+}
+
+func TestAnalyzeMaxCycles(t *testing.T) {
+	r := Analyze(newGraph(
+		[]string{"a", "b", "c", "d"},
+		[][2]string{{"a", "b"}, {"b", "a"}, {"c", "d"}, {"d", "c"}},
+	), Options{MaxCycles: 1})
+
+	if len(r.Cycles) != 1 {
+		t.Errorf("len(cycles) = %d, want 1", len(r.Cycles))
+	}
+}
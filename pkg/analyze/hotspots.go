@@ -0,0 +1,107 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// structuralKinds contains kinds which are connected to nearly every node and
+// are therefore ignored when searching for hotspots.
+var structuralKinds = map[string]bool{
+	"Cluster":   true,
+	"Namespace": true,
+}
+
+// Hotspot represents a node with a high number of dependents or dependencies.
+type Hotspot struct {
+	UID    types.UID
+	FanIn  int
+	FanOut int
+}
+
+// Hotspots returns up to Top nodes with the highest fan-in and the highest
+// fan-out. Relationships from or to Cluster and Namespace nodes are ignored.
+func (r *Result) Hotspots() (fanIn []Hotspot, fanOut []Hotspot) {
+	hotspots := make(map[types.UID]*Hotspot)
+	hotspot := func(uid types.UID) *Hotspot {
+		if _, ok := hotspots[uid]; !ok {
+			hotspots[uid] = &Hotspot{UID: uid}
+		}
+		return hotspots[uid]
+	}
+
+	for from, children := range r.children {
+		if structuralKinds[r.graph.Nodes[from].Kind] {
+			continue
+		}
+		for _, to := range children {
+			if structuralKinds[r.graph.Nodes[to].Kind] {
+				continue
+			}
+			hotspot(from).FanOut++
+			hotspot(to).FanIn++
+		}
+	}
+
+	all := []Hotspot{}
+	for _, uid := range r.nodes {
+		if h, ok := hotspots[uid]; ok {
+			all = append(all, *h)
+		}
+	}
+
+	fanIn = append([]Hotspot{}, all...)
+	sort.SliceStable(fanIn, func(i, j int) bool { return fanIn[i].FanIn > fanIn[j].FanIn })
+	fanOut = append([]Hotspot{}, all...)
+	sort.SliceStable(fanOut, func(i, j int) bool { return fanOut[i].FanOut > fanOut[j].FanOut })
+
+	return top(fanIn, r.options.Top, func(h Hotspot) int { return h.FanIn }),
+		top(fanOut, r.options.Top, func(h Hotspot) int { return h.FanOut })
+}
+
+// WriteHotspots writes a text report of the hotspots to w.
+func (r *Result) WriteHotspots(w io.Writer) error {
+	fanIn, fanOut := r.Hotspots()
+
+	fmt.Fprintln(w, "Most depended-upon resources (fan-in):")
+	for idx, h := range fanIn {
+		fmt.Fprintf(w, "  %d. %s: %d dependents\n", idx+1, r.name(h.UID), h.FanIn)
+	}
+
+	fmt.Fprintln(w, "Resources with most dependencies (fan-out):")
+	for idx, h := range fanOut {
+		fmt.Fprintf(w, "  %d. %s: %d dependencies\n", idx+1, r.name(h.UID), h.FanOut)
+	}
+
+	return nil
+}
+
+// top returns up to n hotspots with a value greater than zero.
+func top(hotspots []Hotspot, n int, value func(Hotspot) int) []Hotspot {
+	result := []Hotspot{}
+	for _, h := range hotspots {
+		if len(result) >= n || value(h) == 0 {
+			break
+		}
+		result = append(result, h)
+	}
+
+	return result
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// audit enforces that only read requests are sent to the API server and
+// counts the requests per verb and endpoint.
+type audit struct {
+	mu    sync.Mutex
+	calls map[auditCall]int
+}
+
+// auditCall is a verb used on an endpoint of the API server.
+type auditCall struct {
+	verb     string
+	endpoint string
+}
+
+// newAudit creates a new audit without any recorded requests.
+func newAudit() *audit {
+	return &audit{
+		calls: make(map[auditCall]int),
+	}
+}
+
+// Wrap returns a RoundTripper which records all requests and rejects all
+// requests which are not GET or HEAD before they are sent.
+func (a *audit) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return &readOnlyRoundTripper{audit: a, delegate: rt}
+}
+
+// record counts a request.
+func (a *audit) record(req *http.Request) {
+	verb, endpoint := auditEndpoint(req)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls[auditCall{verb: verb, endpoint: endpoint}]++
+}
+
+// WriteSummary writes the number of requests per verb and endpoint to w.
+func (a *audit) WriteSummary(w io.Writer) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	calls := make([]auditCall, 0, len(a.calls))
+	for call := range a.calls {
+		calls = append(calls, call)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].endpoint != calls[j].endpoint {
+			return calls[i].endpoint < calls[j].endpoint
+		}
+		return calls[i].verb < calls[j].verb
+	})
+
+	fmt.Fprintln(w, "API requests:")
+	for _, call := range calls {
+		fmt.Fprintf(w, "  %s %s: %d\n", call.verb, call.endpoint, a.calls[call])
+	}
+
+	return nil
+}
+
+// readOnlyRoundTripper rejects all requests which could modify the cluster.
+type readOnlyRoundTripper struct {
+	audit    *audit
+	delegate http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *readOnlyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return nil, fmt.Errorf("refusing to send %s %s, kubectl-graph only reads from the cluster", req.Method, req.URL.Path)
+	}
+
+	rt.audit.record(req)
+	return rt.delegate.RoundTrip(req)
+}
+
+// auditEndpoint returns the verb of a read request and its endpoint without
+// the namespace and the name of the object, e.g. "list /apis/apps/v1/deployments".
+func auditEndpoint(req *http.Request) (string, string) {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	prefix := 0
+	switch {
+	case len(segments) >= 2 && segments[0] == "api":
+		prefix = 2
+	case len(segments) >= 3 && segments[0] == "apis":
+		prefix = 3
+	default:
+		// discovery and version endpoints
+		return "get", req.URL.Path
+	}
+
+	rest := segments[prefix:]
+	if len(rest) == 0 {
+		return "get", req.URL.Path
+	}
+
+	resource, name := rest[0], ""
+	switch {
+	case rest[0] == "namespaces" && len(rest) >= 3:
+		resource = rest[2]
+		if len(rest) >= 4 {
+			name = rest[3]
+		}
+	case len(rest) >= 2:
+		name = rest[1]
+	}
+
+	verb := "list"
+	switch {
+	case req.URL.Query().Get("watch") == "true" || req.URL.Query().Get("watch") == "1":
+		verb = "watch"
+	case len(name) != 0:
+		verb = "get"
+	}
+
+	return verb, "/" + strings.Join(append(segments[:prefix:prefix], resource), "/")
+}
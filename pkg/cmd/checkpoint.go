@@ -0,0 +1,173 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// checkpoint persists the listed objects of every completed unit of work, so an
+// interrupted run can resume without listing them again. The file contains one
+// JSON document per line, a header with the invocation followed by one entry per unit.
+type checkpoint struct {
+	path  string
+	file  *os.File
+	units map[string][]*unstructured.Unstructured
+}
+
+type checkpointHeader struct {
+	Invocation []string `json:"invocation"`
+}
+
+type checkpointEntry struct {
+	Unit  string                   `json:"unit"`
+	Items []map[string]interface{} `json:"items"`
+}
+
+// openCheckpoint opens or creates the checkpoint file at path. An existing file
+// must belong to the same invocation. A partially written last entry is discarded.
+func openCheckpoint(path string, invocation []string) (*checkpoint, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checkpoint{
+		path:  path,
+		file:  file,
+		units: make(map[string][]*unstructured.Unstructured),
+	}
+
+	offset, err := c.read(invocation)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if offset == 0 {
+		if err := c.append(checkpointHeader{Invocation: invocation}); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// read loads all complete entries and returns the offset after the last one.
+func (c *checkpoint) read(invocation []string) (int64, error) {
+	r := bufio.NewReader(c.file)
+
+	offset := int64(0)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if offset == 0 {
+			header := checkpointHeader{}
+			if err := json.Unmarshal(line, &header); err != nil {
+				return 0, fmt.Errorf("invalid checkpoint %q: %v", c.path, err)
+			}
+			if !slices.Equal(header.Invocation, invocation) {
+				return 0, fmt.Errorf("checkpoint %q belongs to a different invocation, remove it to start from scratch", c.path)
+			}
+		} else {
+			entry := checkpointEntry{}
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return offset, nil
+			}
+
+			objs := make([]*unstructured.Unstructured, 0, len(entry.Items))
+			for _, item := range entry.Items {
+				objs = append(objs, &unstructured.Unstructured{Object: item})
+			}
+			c.units[entry.Unit] = objs
+		}
+
+		offset += int64(len(line))
+	}
+}
+
+// Len returns the number of objects in the checkpoint.
+func (c *checkpoint) Len() int {
+	n := 0
+	for _, objs := range c.units {
+		n += len(objs)
+	}
+
+	return n
+}
+
+// Load returns the objects of a completed unit. The objects are released
+// by the checkpoint, so they are only returned once.
+func (c *checkpoint) Load(unit string) ([]*unstructured.Unstructured, bool) {
+	objs, ok := c.units[unit]
+	delete(c.units, unit)
+	return objs, ok
+}
+
+// Save marks the unit as completed and persists its objects, they are not kept in memory.
+func (c *checkpoint) Save(unit string, objs []*unstructured.Unstructured) error {
+	entry := checkpointEntry{
+		Unit:  unit,
+		Items: make([]map[string]interface{}, 0, len(objs)),
+	}
+	for _, obj := range objs {
+		entry.Items = append(entry.Items, obj.Object)
+	}
+
+	return c.append(entry)
+}
+
+// append writes v as single line and flushes it to disk.
+func (c *checkpoint) append(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return c.file.Sync()
+}
+
+// Close closes the checkpoint file.
+func (c *checkpoint) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func pod(name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": name},
+	}}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	invocation := []string{"kubectl-graph", "pods"}
+
+	tests := []struct {
+		name       string
+		invocation []string
+		// trailing is appended to the file after the saved units
+		trailing string
+		units    map[string]int
+		err      bool
+	}{
+		{
+			name:       "complete",
+			invocation: invocation,
+			units:      map[string]int{"pods/default": 2, "pods/kube-system": 1},
+		},
+		{
+			name:       "partial last entry",
+			invocation: invocation,
+			trailing:   `{"unit":"pods/monitoring","items":[{"kind":`,
+			units:      map[string]int{"pods/default": 2, "pods/kube-system": 1},
+		},
+		{
+			name:       "different invocation",
+			invocation: []string{"kubectl-graph", "services"},
+			err:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoint")
+
+			c, err := openCheckpoint(path, invocation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Save("pods/default", []*unstructured.Unstructured{pod("a"), pod("b")}); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Save("pods/kube-system", []*unstructured.Unstructured{pod("c")}); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(tt.trailing) != 0 {
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteString(tt.trailing)
+				f.Close()
+			}
+
+			c, err = openCheckpoint(path, tt.invocation)
+			if tt.err {
+				if err == nil {
+					c.Close()
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			total := 0
+			for _, n := range tt.units {
+				total += n
+			}
+			if got := c.Len(); got != total {
+				t.Errorf("Len() = %d, want %d", got, total)
+			}
+
+			for unit, n := range tt.units {
+				objs, ok := c.Load(unit)
+				if !ok || len(objs) != n {
+					t.Errorf("Load(%q) = %d objects, %v, want %d objects, true", unit, len(objs), ok, n)
+				}
+				if _, ok := c.Load(unit); ok {
+					t.Errorf("Load(%q) returned the unit twice", unit)
+				}
+			}
+			if _, ok := c.Load("pods/monitoring"); ok {
+				t.Errorf("Load(%q) returned an incomplete unit", "pods/monitoring")
+			}
+
+			// new entries must be appended after the last complete entry
+			if err := c.Save("pods/monitoring", []*unstructured.Unstructured{pod("d")}); err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+
+			c, err = openCheckpoint(path, tt.invocation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			if got := c.Len(); got != total+1 {
+				t.Errorf("Len() after resume = %d, want %d", got, total+1)
+			}
+		})
+	}
+}
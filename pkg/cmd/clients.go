@@ -0,0 +1,128 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	diskcached "k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/homedir"
+)
+
+// The defaults of the discovery client of the ConfigFlags.
+const (
+	discoveryBurst    = 300
+	discoveryQPS      = 50.0
+	discoveryCacheTTL = 6 * time.Hour
+)
+
+// illegalCacheDirCharacters matches the characters of a host which are
+// replaced in the name of its discovery cache directory.
+var illegalCacheDirCharacters = regexp.MustCompile(`[^(\w/.)]`)
+
+// clientGetter creates all clients from the same rest.Config, which is built
+// once from the ConfigFlags and whose transport is wrapped. The ConfigFlags
+// aren't changed, so they can be shared with other commands.
+type clientGetter struct {
+	*genericclioptions.ConfigFlags
+
+	wrap []func(rt http.RoundTripper) http.RoundTripper
+
+	configOnce sync.Once
+	config     *rest.Config
+	configErr  error
+
+	discoveryOnce sync.Once
+	discovery     discovery.CachedDiscoveryInterface
+	discoveryErr  error
+}
+
+// newClientGetter creates a clientGetter which wraps the transport of all
+// clients in the given order.
+func newClientGetter(flags *genericclioptions.ConfigFlags, wrap ...func(rt http.RoundTripper) http.RoundTripper) *clientGetter {
+	return &clientGetter{
+		ConfigFlags: flags,
+		wrap:        wrap,
+	}
+}
+
+// ToRESTConfig returns a copy of the wrapped rest.Config.
+func (g *clientGetter) ToRESTConfig() (*rest.Config, error) {
+	g.configOnce.Do(func() {
+		g.config, g.configErr = g.ConfigFlags.ToRESTConfig()
+		if g.configErr != nil {
+			return
+		}
+		for _, wrap := range g.wrap {
+			g.config.Wrap(wrap)
+		}
+	})
+	if g.configErr != nil {
+		return nil, g.configErr
+	}
+
+	return rest.CopyConfig(g.config), nil
+}
+
+// ToDiscoveryClient returns a discovery client with the same disk cache as
+// the ConfigFlags, but with the wrapped rest.Config.
+func (g *clientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	g.discoveryOnce.Do(func() {
+		config, err := g.ToRESTConfig()
+		if err != nil {
+			g.discoveryErr = err
+			return
+		}
+		config.Burst = discoveryBurst
+		config.QPS = discoveryQPS
+
+		cacheDir := filepath.Join(homedir.HomeDir(), ".kube", "cache")
+		if dir := os.Getenv("KUBECACHEDIR"); len(dir) != 0 {
+			cacheDir = dir
+		}
+		if g.CacheDir != nil && len(*g.CacheDir) != 0 {
+			cacheDir = *g.CacheDir
+		}
+
+		host := strings.TrimPrefix(strings.TrimPrefix(config.Host, "https://"), "http://")
+		discoveryCacheDir := filepath.Join(cacheDir, "discovery", illegalCacheDirCharacters.ReplaceAllString(host, "_"))
+
+		g.discovery, g.discoveryErr = diskcached.NewCachedDiscoveryClientForConfig(config, discoveryCacheDir, filepath.Join(cacheDir, "http"), discoveryCacheTTL)
+	})
+
+	return g.discovery, g.discoveryErr
+}
+
+// ToRESTMapper returns a RESTMapper which uses the wrapped discovery client.
+func (g *clientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	client, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(client)
+	return restmapper.NewShortcutExpander(mapper, client, func(string) {}), nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+)
+
+// wrapCounter counts the number of times a request passes the wrapper.
+type wrapCounter struct {
+	delegate http.RoundTripper
+}
+
+func (rt *wrapCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Add("X-Wrapped", "1")
+	return rt.delegate.RoundTrip(req)
+}
+
+func TestClientGetter(t *testing.T) {
+	wrapped := []int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wrapped = append(wrapped, len(r.Header.Values("X-Wrapped")))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"major":"1","minor":"32"}`))
+	}))
+	defer server.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	config := `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: ` + server.URL + `
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`
+	if err := os.WriteFile(kubeconfig, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := genericclioptions.NewConfigFlags(true)
+	*flags.KubeConfig = kubeconfig
+	getter := newClientGetter(flags, func(rt http.RoundTripper) http.RoundTripper {
+		return &wrapCounter{delegate: rt}
+	})
+
+	// each config is wrapped exactly once, no matter how often it is requested
+	for i := 0; i < 2; i++ {
+		config, err := getter.ToRESTConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := clientset.Discovery().ServerVersion(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(wrapped) != 2 || wrapped[0] != 1 || wrapped[1] != 1 {
+		t.Errorf("requests passed the wrapper %v times, want [1 1]", wrapped)
+	}
+	if flags.WrapConfigFn != nil {
+		t.Errorf("ConfigFlags.WrapConfigFn is set, want the ConfigFlags unchanged")
+	}
+}
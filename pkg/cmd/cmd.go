@@ -2,14 +2,25 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
+	"github.com/steveteuber/kubectl-graph/pkg/analyze"
+	"github.com/steveteuber/kubectl-graph/pkg/deploy"
 	"github.com/steveteuber/kubectl-graph/pkg/graph"
+	"github.com/steveteuber/kubectl-graph/pkg/sink"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"k8s.io/kubectl/pkg/util/templates"
 
@@ -28,30 +39,103 @@ var (
 		# Visualize all pods in cypher output format.
 		%[1]s graph deployments,replicasets,pods -o cypher | cypher-shell -u neo4j -p secret
 
+		# Generate a Neo4j Bloom perspective for the same resources, which can be imported in Neo4j Bloom.
+		%[1]s graph deployments,replicasets,pods -o bloom > perspective.json
+
 		# Visualize deployments in cypher output format, in the "v1" version of the "apps" API group:
 		%[1]s graph deployments.v1.apps -o cypher
 
 		# Visualize resources from a directory with kustomization.yaml - e.g. dir/kustomization.yaml.
 		%[1]s graph -k dir/ | dot -T svg -o kustomization.svg
 
+		# Visualize all pods in draw.io output format, which can be opened with diagrams.net.
+		%[1]s graph pods -o drawio > pods.drawio
+
 		# Visualize all pods and networkpolicies together in graphviz output format.
-		%[1]s graph networkpolicies | dot -T svg -o networkpolicies.svg`)
+		%[1]s graph networkpolicies | dot -T svg -o networkpolicies.svg
+
+		# Generate the manifests to upload all resources every hour from inside of the cluster.
+		%[1]s graph all -A -o cypher --sink s3://my-bucket/graph.cypher --print-manifests --image example.com/kubectl-graph:latest | %[1]s apply -f -
+
+		# Write one graphviz file per namespace, e.g. graph-default.dot and graph-kube-system.dot.
+		%[1]s graph all -A --split-by namespace --output-file graph.dot
+
+		# Write pages of at most 200 nodes grouped by workload, e.g. graph-1.dot, graph-2.dot and graph-index.dot.
+		%[1]s graph all -n default --page-size 200 --output-file graph.dot
+
+		# Write all resources in cypher output format to a compressed file.
+		%[1]s graph all -o cypher --output-file graph.cypher.gz
+
+		# Visualize a large cluster and resume from the checkpoint file if the run was interrupted.
+		%[1]s graph pods,services,deployments,replicasets -A --checkpoint graph.checkpoint | dot -T svg -o cluster.svg
+
+		# Serve graph requests from another process over stdin and stdout, one JSON-RPC 2.0 message per line.
+		echo '{"jsonrpc":"2.0","id":1,"method":"graph","params":{"args":["pods","-n","default","-o","graphology"]}}' | %[1]s graph --stdio
+
+		# Upload all resources in cypher output format to an S3 bucket.
+		%[1]s graph all -o cypher --sink s3://my-bucket/graph.cypher`)
 )
 
-// GraphOptions contains the input to the graph command.
+// outputFormats contains all output formats including their aliases.
+const outputFormats = "aql|arangodb|bloom|cql|cypher|dot|drawio|excalidraw|graphology|graphviz|markdown|md|mermaid|schema|tgf"
+
+// defaultParallel is the default number of namespaces which are listed in parallel.
+const defaultParallel = 4
+
+// GraphOptions contains the input to the graph command. Other commands can
+// embed graph generation with NewGraphOptions, AddFlags, Complete, Validate
+// and either Run or ToGraph, with a factory created from ToRESTClientGetter.
 type GraphOptions struct {
 	configFlags *genericclioptions.ConfigFlags
 
 	AllNamespaces     bool
+	Analyze           bool
+	Anonymize         bool
+	Audit             bool
+	Checkpoint        string
 	ChunkSize         int64
 	CmdParent         string
+	ColorBy           string
+	Containers        bool
+	ControlPlane      bool
+	Events            bool
 	ExplicitNamespace bool
 	FieldSelector     string
+	FullKinds         []string
+	Hash              string
+	HelmReleases      bool
+	Hotspots          bool
+	Inventory         string
+	Image             string
+	Invert            []string
+	JobHistoryLimit   int
 	LabelSelector     string
+	Legend            bool
+	MetadataOnly      bool
 	Namespace         string
 	Namespaces        []string
+	OutputFile        string
 	OutputFormat      string
+	PageSize          int
+	Parallel          int
+	PrintManifests    bool
+	Enrichers         []graph.Enricher
+	Resolvers         []graph.Resolver
+	Schedule          string
+	SchemaReferences  bool
+	Sink              string
+	SplitBy           string
+	Stdio             bool
+	Theme             string
+	Timestamp         bool
+	Title             string
 	Truncate          int
+	Workloads         bool
+
+	audit  *audit
+	pacing *pacing
+	sink   sink.Sink
+	theme  *graph.Theme
 
 	resource.FilenameOptions
 	genericclioptions.IOStreams
@@ -60,21 +144,36 @@ type GraphOptions struct {
 // NewGraphOptions returns a GraphOptions with default chunk size 500.
 func NewGraphOptions(parent string, flags *genericclioptions.ConfigFlags, streams genericclioptions.IOStreams) *GraphOptions {
 	return &GraphOptions{
-		configFlags: flags,
-		CmdParent:   parent,
-		IOStreams:   streams,
-		ChunkSize:   500,
-		Truncate:    graph.DefaultNodeNameLimit,
+		configFlags:     flags,
+		CmdParent:       parent,
+		IOStreams:       streams,
+		ChunkSize:       500,
+		Hash:            graph.HashSHA256,
+		FullKinds:       []string{"Application", "Ingress", "Service"},
+		JobHistoryLimit: graph.DefaultJobHistoryLimit,
+		Parallel:        defaultParallel,
+		Schedule:        deploy.DefaultSchedule,
+		Theme:           "light",
+		Truncate:        graph.DefaultNodeNameLimit,
+		audit:           newAudit(),
+		pacing:          newPacing(),
 	}
 }
 
+// ToRESTClientGetter returns a RESTClientGetter for the factory of the graph
+// operation. All requests to the API server are restricted to reads and paced
+// by throttled responses, without changing the ConfigFlags.
+func (o *GraphOptions) ToRESTClientGetter() genericclioptions.RESTClientGetter {
+	return newClientGetter(o.configFlags, o.audit.Wrap, o.pacing.Wrap)
+}
+
 // NewCmdGraph creates a command object for the "graph" action.
 func NewCmdGraph(parent string, flags *genericclioptions.ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
-	f := cmdutil.NewFactory(flags)
 	o := NewGraphOptions(parent, flags, streams)
+	f := cmdutil.NewFactory(o.ToRESTClientGetter())
 
 	cmd := &cobra.Command{
-		Use:                   fmt.Sprintf("%s graph [(-o|--output=)aql|arangodb|cql|cypher|dot|graphviz|mermaid] (TYPE[.VERSION][.GROUP] ...) [flags]", parent),
+		Use:                   fmt.Sprintf("%s graph [(-o|--output=)%s] (TYPE[.VERSION][.GROUP] ...) [flags]", parent, outputFormats),
 		DisableFlagsInUseLine: true,
 		Short:                 "Visualize one or many resources and relationships",
 		Long:                  graphLong + "\n\n" + cmdutil.SuggestAPIResources(parent),
@@ -87,16 +186,53 @@ func NewCmdGraph(parent string, flags *genericclioptions.ConfigFlags, streams ge
 	}
 
 	cmd.Flags().BoolP("help", "h", false, fmt.Sprintf("Help for %s graph", parent))
+	o.AddFlags(cmd)
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// AddFlags registers the flags of the graph command, except of the kubeconfig
+// flags, so the same flags can be embedded by other commands.
+func (o *GraphOptions) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(&o.AllNamespaces, "all-namespaces", "A", o.AllNamespaces, "If present, list the requested object(s) across all namespaces. Namespace in current context is ignored even if specified with --namespace.")
+	cmd.Flags().BoolVar(&o.Analyze, "analyze", o.Analyze, "If present, add connected components, degrees and betweenness as node attributes and print a report of the graph topology to stderr.")
+	cmd.Flags().BoolVar(&o.Hotspots, "hotspots", o.Hotspots, "If present, print the resources with the highest fan-in and fan-out to stderr.")
+	cmd.Flags().BoolVar(&o.Audit, "audit", o.Audit, "If present, print the number of API requests per verb and endpoint to stderr. Any request which is not a read is always rejected.")
+	cmd.Flags().BoolVar(&o.Anonymize, "anonymize", o.Anonymize, "If present, replace all names and namespaces with hashes and remove labels and annotations, so the graph can be shared externally.")
+	cmd.Flags().StringVar(&o.Checkpoint, "checkpoint", o.Checkpoint, "Persist the listed objects to this file, so an interrupted run resumes from it instead of listing them again. The file is removed after a successful run.")
+	cmd.Flags().BoolVar(&o.MetadataOnly, "metadata-only", o.MetadataOnly, "If present, list the metadata of the requested object(s) only, so their relationships are found by owner references. Reduces the transferred data of large clusters.")
+	cmd.Flags().StringSliceVar(&o.FullKinds, "full-kinds", o.FullKinds, "Kinds which are fetched completely with --metadata-only, because their relationships are found by their spec.")
 	cmd.Flags().Int64Var(&o.ChunkSize, "chunk-size", o.ChunkSize, "Return large lists in chunks rather than all at once. Pass 0 to disable.")
+	cmd.Flags().StringVar(&o.Hash, "hash", o.Hash, "Hash function of the generated UIDs and colors. One of: sha256|md5. Use md5 to keep the UIDs of data imported by previous versions.")
+	cmd.Flags().IntVar(&o.Parallel, "parallel", o.Parallel, "Number of namespaces which are listed in parallel, if all namespaces are graphed. Pass 0 to list all namespaces at once.")
 	cmd.Flags().IntVarP(&o.Truncate, "truncate", "t", o.Truncate, "Truncate node name to N characters. This affects graphviz and mermaid output format.")
+	cmd.Flags().BoolVar(&o.PrintManifests, "print-manifests", o.PrintManifests, "If present, print the ServiceAccount, RBAC and CronJob manifests to run this command inside of the cluster instead of the graph.")
+	cmd.Flags().StringVar(&o.Image, "image", o.Image, "Container image with kubectl-graph as entrypoint. Used with --print-manifests.")
+	cmd.Flags().StringVar(&o.Schedule, "schedule", o.Schedule, "Schedule of the CronJob in cron format. Used with --print-manifests.")
+	cmd.Flags().IntVar(&o.JobHistoryLimit, "job-history-limit", o.JobHistoryLimit, "Number of most recent Jobs per CronJob to graph, all older Jobs are collapsed into one node. Pass 0 to graph all Jobs.")
+	cmd.Flags().BoolVar(&o.Workloads, "workloads", o.Workloads, "If present, wrap Deployments, StatefulSets, DaemonSets and Rollouts with the same name under a Workload node with the summed up replicas and the most severe rollout status.")
+	cmd.Flags().BoolVar(&o.Containers, "containers", o.Containers, "If present, add the image and ports to the node of each container and init container of a Pod and link it to its image.")
+	cmd.Flags().BoolVar(&o.ControlPlane, "control-plane", o.ControlPlane, "If present, add the control plane components of the kube-system namespace like static Pods, kube-proxy, CoreDNS and CNI plugins, and the Nodes they run on.")
+	cmd.Flags().BoolVar(&o.Events, "events", o.Events, "If present, list the Events of all graphed namespaces and add the number of warnings and the last warning message to the involved objects.")
+	cmd.Flags().BoolVar(&o.HelmReleases, "helm-releases", o.HelmReleases, "If present, read the Helm release Secrets of all graphed namespaces and add a HelmRelease node with the chart and status, which manages all objects rendered by the release.")
+	cmd.Flags().StringVar(&o.Inventory, "inventory", o.Inventory, "Attach business metadata like the owner team to the nodes. A JSON or CSV file or an http(s):// URL which returns JSON, the entries are matched by namespace and label selector.")
+	cmd.Flags().BoolVar(&o.SchemaReferences, "schema-references", o.SchemaReferences, "If present, read the OpenAPI schema of the CustomResourceDefinition of each custom resource without built-in support and add relationships for the fields which refer to other objects.")
+	cmd.Flags().StringSliceVar(&o.Invert, "invert", o.Invert, "Relationship labels which are rendered in reverse direction, use '*' to invert all relationships. (e.g. --invert Pod,ReplicaSet)")
+	cmd.Flags().BoolVar(&o.Legend, "legend", o.Legend, "If present, add a legend with the color of each kind, namespace or status, depending on --color-by. This affects graphviz output format.")
+	cmd.Flags().BoolVar(&o.Timestamp, "timestamp", o.Timestamp, "If present, add the current time below the title. This affects graphviz output format.")
+	cmd.Flags().StringVar(&o.Theme, "theme", o.Theme, "Color theme of the graph. One of: light|dark or a path to a YAML theme file.")
+	cmd.Flags().StringVar(&o.ColorBy, "color-by", o.ColorBy, "Color the nodes by one of: kind|namespace|status. Overrides the value of the theme.")
+	cmd.Flags().StringVar(&o.Title, "title", o.Title, "Title of the graph. This affects graphviz output format.")
 	cmd.Flags().StringVar(&o.FieldSelector, "field-selector", o.FieldSelector, "Selector (field query) to filter on, supports '=', '==', and '!='.(e.g. --field-selector key1=value1,key2=value2). The server only supports a limited number of field queries per type.")
 	cmd.Flags().StringVarP(&o.LabelSelector, "selector", "l", o.LabelSelector, "Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
-	cmd.Flags().StringVarP(&o.OutputFormat, "output", "o", o.OutputFormat, "Output format. One of: aql|arangodb|cql|cypher|dot|graphviz|mermaid.")
+	cmd.Flags().StringVarP(&o.OutputFormat, "output", "o", o.OutputFormat, "Output format. One of: "+outputFormats+".")
+	cmd.Flags().StringVar(&o.OutputFile, "output-file", o.OutputFile, "Write the output to this file instead of stdout. The output is compressed with gzip if the file name ends with .gz.")
+	cmd.Flags().StringVar(&o.SplitBy, "split-by", o.SplitBy, "Split the output into multiple files. One of: namespace. Requires --output-file or --sink.")
+	cmd.Flags().IntVar(&o.PageSize, "page-size", o.PageSize, "Split each output with more than N nodes into numbered pages grouped by workload and an index. Requires --output-file or --sink. Pass 0 to disable.")
+	cmd.Flags().BoolVar(&o.Stdio, "stdio", o.Stdio, "If present, read one JSON-RPC 2.0 request per line from stdin and write one response per line to stdout. The \"graph\" method takes the arguments of this command as params.args and returns the output and warnings.")
+	cmd.Flags().StringVar(&o.Sink, "sink", o.Sink, "Destination of the output. One of: - (stdout), a file path, an http(s):// URL to POST to or an s3://bucket/key URL.")
 	cmdutil.AddFilenameOptionFlags(cmd, &o.FilenameOptions, "identifying the resource to get from a server.")
-	o.configFlags.AddFlags(cmd.Flags())
-
-	return cmd
 }
 
 // Complete takes the command arguments and factory and infers any remaining options.
@@ -120,6 +256,33 @@ func (o *GraphOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []st
 		o.OutputFormat = "cypher"
 	case "dot", "":
 		o.OutputFormat = "graphviz"
+	case "md":
+		o.OutputFormat = "markdown"
+	}
+
+	o.theme, err = graph.LoadTheme(o.Theme)
+	if err != nil {
+		return err
+	}
+	if len(o.ColorBy) != 0 {
+		o.theme.ColorBy = o.ColorBy
+	}
+
+	if len(o.Inventory) != 0 {
+		inventory, err := readInventory(o.Inventory)
+		if err != nil {
+			return err
+		}
+		o.Enrichers = append(o.Enrichers, inventory)
+	}
+
+	if len(o.OutputFile) != 0 {
+		o.sink = sink.NewFileSink(o.OutputFile)
+	} else {
+		o.sink, err = sink.New(o.Sink, o.Out)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -127,11 +290,35 @@ func (o *GraphOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []st
 
 // Validate checks the set of flags provided by the user.
 func (o *GraphOptions) Validate(cmd *cobra.Command, args []string) error {
-	if len(args) == 0 && cmdutil.IsFilenameSliceEmpty(o.Filenames, o.Kustomize) {
+	if len(args) == 0 && cmdutil.IsFilenameSliceEmpty(o.Filenames, o.Kustomize) && !o.Stdio {
 		return fmt.Errorf("you must specify the type of resource to graph. %s", cmdutil.SuggestAPIResources(o.CmdParent))
 	}
-	if !(o.OutputFormat == "arangodb" || o.OutputFormat == "cypher" || o.OutputFormat == "graphviz" || o.OutputFormat == "mermaid") {
-		return fmt.Errorf("invalid output format: %q, allowed formats are: %s", o.OutputFormat, "aql|arangodb|cql|cypher|dot|graphviz|mermaid")
+	if !slices.Contains(graph.Formats(), o.OutputFormat) {
+		return fmt.Errorf("invalid output format: %q, allowed formats are: %s", o.OutputFormat, outputFormats)
+	}
+	if err := graph.ValidateHash(o.Hash); err != nil {
+		return err
+	}
+	if err := o.theme.Validate(); err != nil {
+		return err
+	}
+	if len(o.OutputFile) != 0 && len(o.Sink) != 0 {
+		return fmt.Errorf("--output-file and --sink are mutually exclusive")
+	}
+	if !(o.SplitBy == "" || o.SplitBy == "namespace") {
+		return fmt.Errorf("invalid split: %q, allowed values are: namespace", o.SplitBy)
+	}
+	if _, ok := o.sink.(*sink.StdoutSink); ok && len(o.SplitBy) != 0 {
+		return fmt.Errorf("--split-by requires --output-file or --sink")
+	}
+	if o.PageSize < 0 {
+		return fmt.Errorf("--page-size must not be negative")
+	}
+	if _, ok := o.sink.(*sink.StdoutSink); ok && o.PageSize > 0 {
+		return fmt.Errorf("--page-size requires --output-file or --sink")
+	}
+	if o.PrintManifests && len(o.Image) == 0 {
+		return fmt.Errorf("--image is required when --print-manifests is set")
 	}
 
 	return nil
@@ -139,48 +326,145 @@ func (o *GraphOptions) Validate(cmd *cobra.Command, args []string) error {
 
 // Run performs the graph operation.
 func (o *GraphOptions) Run(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
-	config, err := f.ToRESTConfig()
-	if err != nil {
-		return err
+	if o.PrintManifests {
+		return o.RunPrintManifests(f, args)
 	}
 
-	fmt.Fprintf(o.ErrOut, "Please wait while retrieving data from %s\n", config.Host)
+	if o.Stdio {
+		return o.RunStdio()
+	}
 
-	clientset, err := f.KubernetesClientSet()
+	g, err := o.ToGraph(f, args)
 	if err != nil {
 		return err
 	}
+	// the report of --analyze contains the warnings as well
+	if !o.Analyze {
+		for _, warning := range g.Warnings() {
+			fmt.Fprintf(o.ErrOut, "Warning: %s\n", warning)
+		}
+	}
+
+	return o.output(g)
+}
+
+// output writes the graph to the sink and prints the reports. The checkpoint
+// is removed afterwards, because the graph is completed.
+func (o *GraphOptions) output(g *graph.Graph) error {
+	var result *analyze.Result
+	if o.Analyze || o.Hotspots {
+		result = analyze.Analyze(g, analyze.Options{})
+	}
+	if o.Analyze {
+		result.Annotate()
+	}
+
+	outputs := map[string]*graph.Graph{"": g}
+	if o.SplitBy == "namespace" {
+		outputs = g.SplitByNamespace()
+	}
 
-	objs := []*unstructured.Unstructured{}
-	for _, namespace := range o.Namespaces {
-		r := f.NewBuilder().
-			Unstructured().
-			NamespaceParam(namespace).DefaultNamespace().AllNamespaces(o.AllNamespaces).
-			FilenameParam(o.ExplicitNamespace, &o.FilenameOptions).
-			LabelSelectorParam(o.LabelSelector).
-			FieldSelectorParam(o.FieldSelector).
-			RequestChunksOf(o.ChunkSize).
-			ResourceTypeOrNameArgs(true, args...).
-			ContinueOnError().
-			Latest().
-			Flatten().
-			Do()
-
-		if err := r.Err(); err != nil {
+	for name, output := range outputs {
+		if o.PageSize > 0 && len(output.Nodes) > o.PageSize {
+			if err := o.writePages(name, output); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := o.write(name, output); err != nil {
 			return err
 		}
+	}
 
-		infos, err := r.Infos()
-		if err != nil {
+	if len(o.Checkpoint) != 0 {
+		if err := os.Remove(o.Checkpoint); err != nil {
+			return err
+		}
+	}
+
+	if o.Analyze {
+		if err := result.WriteReport(o.ErrOut); err != nil {
+			return err
+		}
+	}
+	if o.Hotspots {
+		if err := result.WriteHotspots(o.ErrOut); err != nil {
 			return err
 		}
+	}
+	if o.Audit {
+		return o.audit.WriteSummary(o.ErrOut)
+	}
+
+	return nil
+}
+
+// ToGraph lists all requested objects and returns the finalized graph, which
+// is anonymized if requested. Complete and Validate must be called before.
+func (o *GraphOptions) ToGraph(f cmdutil.Factory, args []string) (*graph.Graph, error) {
+	config, err := f.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(o.ErrOut, "Please wait while retrieving data from %s\n", config.Host)
+
+	// an unavailable aggregated API must not block the graph of all other groups
+	failed, err := o.discoveryFailed(f)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := f.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+
+	var cp *checkpoint
+	if len(o.Checkpoint) != 0 {
+		cp, err = openCheckpoint(o.Checkpoint, o.checkpointInvocation(args))
+		if err != nil {
+			return nil, err
+		}
+		defer cp.Close()
 
-		for _, info := range infos {
-			objs = append(objs, info.Object.(*unstructured.Unstructured))
+		if n := cp.Len(); n != 0 {
+			fmt.Fprintf(o.ErrOut, "Resuming with %d objects from checkpoint %s\n", n, o.Checkpoint)
 		}
 	}
 
-	bar := progressbar.NewOptions(len(objs),
+	options := graph.NewOptions()
+	if o.Truncate > 0 {
+		options.NodeNameLimit = o.Truncate
+	}
+	options.Title = o.Title
+	options.Legend = o.Legend
+	options.Theme = o.theme
+	options.JobHistoryLimit = o.JobHistoryLimit
+	options.Enrichers = o.Enrichers
+	options.Resolvers = o.Resolvers
+	options.Workloads = o.Workloads
+	options.Events = o.Events
+	options.Containers = o.Containers
+	options.ControlPlane = o.ControlPlane
+	options.HelmReleases = o.HelmReleases
+	options.Hash = o.Hash
+	options.SchemaReferences = o.SchemaReferences
+	options.Invert = make(map[string]bool, len(o.Invert))
+	for _, label := range o.Invert {
+		options.Invert[label] = true
+	}
+	if o.Timestamp {
+		options.Timestamp = time.Now()
+	}
+
+	units, err := o.units(f, clientset, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// the number of objects is unknown until the scan is completed
+	bar := progressbar.NewOptions(-1,
 		progressbar.OptionSetDescription("Processing..."),
 		progressbar.OptionSetWriter(o.ErrOut),
 		progressbar.OptionSetWidth(10+len(config.Host)),
@@ -198,14 +482,247 @@ func (o *GraphOptions) Run(f cmdutil.Factory, cmd *cobra.Command, args []string)
 		}),
 	)
 
-	graph, err := graph.NewGraph(clientset, objs, func() { bar.Add(1) })
+	g := graph.NewEmptyGraph(clientset, options)
+	g.WarnDiscoveryFailed(failed)
+
+	errs := []error{}
+	err = o.scan(f, units, cp, func(objs []*unstructured.Unstructured) {
+		if err := g.Add(objs, func() { bar.Add(1) }); err != nil {
+			errs = append(errs, err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	bar.Finish()
+
+	if err := g.Finalize(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		return nil, err
+	}
+
+	if o.Anonymize {
+		if err := g.Anonymize(); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
+// discoveryFailed returns the group versions which failed during discovery,
+// the resources of all other groups are still listed.
+func (o *GraphOptions) discoveryFailed(f cmdutil.Factory) (map[schema.GroupVersion]error, error) {
+	client, err := f.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = client.ServerGroupsAndResources()
+	if err == nil {
+		return nil, nil
+	}
+	failed, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+	if !ok {
+		return nil, err
+	}
+
+	for gv := range failed.Groups {
+		fmt.Fprintf(o.ErrOut, "Warning: skipping %s, discovery failed\n", gv)
+	}
+
+	return failed.Groups, nil
+}
+
+// checkpointInvocation returns the flags which affect the listed objects,
+// a checkpoint can only be resumed with the same invocation.
+func (o *GraphOptions) checkpointInvocation(args []string) []string {
+	result := append([]string{}, args...)
+	result = append(result, "--all-namespaces="+fmt.Sprint(o.AllNamespaces))
+	result = append(result, "--namespace="+strings.Join(o.Namespaces, ","))
+	result = append(result, "--selector="+o.LabelSelector)
+	result = append(result, "--field-selector="+o.FieldSelector)
+	result = append(result, "--parallel="+fmt.Sprint(o.Parallel))
+	if o.MetadataOnly {
+		result = append(result, "--metadata-only", "--full-kinds="+strings.Join(o.FullKinds, ","))
+	}
+	for _, filename := range o.Filenames {
+		result = append(result, "--filename="+filename)
+	}
+	if len(o.Kustomize) != 0 {
+		result = append(result, "--kustomize="+o.Kustomize)
+	}
+
+	return result
+}
+
+// write writes the graph to the named output of the sink.
+func (o *GraphOptions) write(name string, g *graph.Graph) error {
+	w, err := o.sink.Open(name)
 	if err != nil {
 		return err
 	}
 
-	if o.Truncate > 0 {
-		graph.Options.NodeNameLimit = o.Truncate
+	if err := g.Write(w, o.OutputFormat); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// writePages writes the graph as numbered pages and an index to the named
+// outputs of the sink, e.g. "kube-system-1" and "kube-system-index".
+func (o *GraphOptions) writePages(name string, g *graph.Graph) error {
+	pageName := func(page string) string {
+		if len(name) == 0 {
+			return page
+		}
+		return name + "-" + page
+	}
+
+	pages, index := g.Paginate(o.PageSize, func(page int) string {
+		return pageName(strconv.Itoa(page))
+	})
+	for i, page := range pages {
+		if err := o.write(pageName(strconv.Itoa(i+1)), page); err != nil {
+			return err
+		}
+	}
+
+	return o.write(pageName("index"), index)
+}
+
+// RunPrintManifests prints the manifests to run the graph operation inside of the cluster.
+func (o *GraphOptions) RunPrintManifests(f cmdutil.Factory, args []string) error {
+	resources, err := o.resources(f, args)
+	if err != nil {
+		return err
+	}
+
+	options := deploy.Options{
+		Namespace: o.Namespaces[0],
+		Image:     o.Image,
+		Schedule:  o.Schedule,
+		Args:      o.manifestArgs(args),
+		Resources: append(resources, graph.Dependencies...),
+	}
+	if o.HelmReleases {
+		options.Resources = append(options.Resources, schema.GroupResource{Resource: "secrets"})
+	}
+	if o.SchemaReferences {
+		options.Resources = append(options.Resources, schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"})
+	}
+	if !o.AllNamespaces {
+		options.Namespaces = o.Namespaces
+	}
+
+	objs, err := deploy.Manifests(options)
+	if err != nil {
+		return err
+	}
+
+	return deploy.Write(o.Out, objs)
+}
+
+// resources resolves the resource types of the command arguments.
+func (o *GraphOptions) resources(f cmdutil.Factory, args []string) ([]schema.GroupResource, error) {
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+	discovery, err := f.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	expander := restmapper.NewDiscoveryCategoryExpander(discovery)
+
+	types := []string{}
+	if len(args) != 0 && !strings.Contains(args[0], "/") {
+		types = strings.Split(args[0], ",")
+	} else {
+		for _, arg := range args {
+			types = append(types, strings.Split(arg, "/")[0])
+		}
+	}
+
+	resources := []schema.GroupResource{}
+	for _, t := range types {
+		if grs, ok := expander.Expand(t); ok {
+			resources = append(resources, grs...)
+			continue
+		}
+
+		gvr, err := mapper.ResourceFor(schema.ParseGroupResource(t).WithVersion(""))
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, gvr.GroupResource())
+	}
+
+	return resources, nil
+}
+
+// manifestArgs returns the arguments to run the graph operation with the current flags.
+func (o *GraphOptions) manifestArgs(args []string) []string {
+	result := append([]string{}, args...)
+	result = append(result, "--output", o.OutputFormat)
+
+	if o.AllNamespaces {
+		result = append(result, "--all-namespaces")
+	} else {
+		result = append(result, "--namespace", strings.Join(o.Namespaces, ","))
+	}
+	if len(o.LabelSelector) != 0 {
+		result = append(result, "--selector", o.LabelSelector)
+	}
+	if len(o.FieldSelector) != 0 {
+		result = append(result, "--field-selector", o.FieldSelector)
+	}
+	if len(o.Sink) != 0 {
+		result = append(result, "--sink", o.Sink)
+	}
+	if o.Hash != graph.HashSHA256 {
+		result = append(result, "--hash", o.Hash)
+	}
+	if o.Containers {
+		result = append(result, "--containers")
+	}
+	if o.ControlPlane {
+		result = append(result, "--control-plane")
+	}
+	if o.Events {
+		result = append(result, "--events")
+	}
+	if o.HelmReleases {
+		result = append(result, "--helm-releases")
+	}
+	if len(o.Inventory) != 0 {
+		result = append(result, "--inventory", o.Inventory)
+	}
+	if o.JobHistoryLimit != graph.DefaultJobHistoryLimit {
+		result = append(result, "--job-history-limit", fmt.Sprint(o.JobHistoryLimit))
+	}
+	if o.PageSize != 0 {
+		result = append(result, "--page-size", fmt.Sprint(o.PageSize))
+	}
+	if o.Parallel != defaultParallel {
+		result = append(result, "--parallel", fmt.Sprint(o.Parallel))
+	}
+	if o.MetadataOnly {
+		result = append(result, "--metadata-only", "--full-kinds", strings.Join(o.FullKinds, ","))
+	}
+	if o.SchemaReferences {
+		result = append(result, "--schema-references")
+	}
+	if o.Truncate != graph.DefaultNodeNameLimit {
+		result = append(result, "--truncate", fmt.Sprint(o.Truncate))
+	}
+	if o.Workloads {
+		result = append(result, "--workloads")
 	}
 
-	return graph.Write(o.Out, o.OutputFormat)
+	return result
 }
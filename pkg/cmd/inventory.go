@@ -0,0 +1,61 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/steveteuber/kubectl-graph/pkg/graph"
+)
+
+// inventoryTimeout limits the time to read the inventory from a URL, so a
+// stalled endpoint can't block the command forever.
+const inventoryTimeout = 30 * time.Second
+
+// readInventory reads the inventory from an http:// or https:// URL, which
+// must return a JSON array of entries, or from a JSON or CSV file.
+func readInventory(source string) (graph.Inventory, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: inventoryTimeout}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to get inventory %s: %s", source, resp.Status)
+		}
+
+		return graph.ReadInventoryJSON(resp.Body)
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(source), ".csv") {
+		return graph.ReadInventoryCSV(f)
+	}
+
+	return graph.ReadInventoryJSON(f)
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRetryAfter limits the delay requested by a single response.
+const maxRetryAfter = time.Minute
+
+// pacing delays all requests after the API server rejected a request with
+// 429 Too Many Requests, e.g. by API Priority and Fairness, until the time
+// of its Retry-After header has passed. The retry itself is done by client-go.
+type pacing struct {
+	mu        sync.Mutex
+	notBefore time.Time
+}
+
+// newPacing creates a new pacing which does not delay any request.
+func newPacing() *pacing {
+	return &pacing{}
+}
+
+// Wrap returns a RoundTripper which is paced by the Retry-After header of throttled responses.
+func (p *pacing) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return &pacingRoundTripper{pacing: p, delegate: rt}
+}
+
+// wait blocks until the requests are no longer delayed or the request is canceled,
+// e.g. by --request-timeout.
+func (p *pacing) wait(req *http.Request) error {
+	p.mu.Lock()
+	delay := time.Until(p.notBefore)
+	p.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// throttle delays all following requests by the Retry-After header of the response.
+func (p *pacing) throttle(resp *http.Response) {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		seconds = 1
+	}
+
+	delay := time.Duration(seconds) * time.Second
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until := time.Now().Add(delay); until.After(p.notBefore) {
+		p.notBefore = until
+	}
+}
+
+// pacingRoundTripper delays requests while the API server is throttling.
+type pacingRoundTripper struct {
+	pacing   *pacing
+	delegate http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *pacingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := rt.pacing.wait(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := rt.delegate.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		rt.pacing.throttle(resp)
+	}
+
+	return resp, nil
+}
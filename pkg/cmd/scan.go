@@ -0,0 +1,301 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// metadataAccept requests the metadata of objects only, with a fallback to
+// the complete objects for servers which do not support it.
+const metadataAccept = "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1," +
+	"application/json;as=PartialObjectMetadata;g=meta.k8s.io;v=v1,application/json"
+
+// unit is a part of the scan which is listed and checkpointed at once.
+type unit struct {
+	namespace     string
+	allNamespaces bool
+	args          []string
+}
+
+// key identifies the unit in a checkpoint.
+func (u unit) key() string {
+	return u.namespace + "/" + strings.Join(u.args, " ")
+}
+
+// units splits the scan into units of work. If all namespaces are graphed in
+// parallel, the namespaced resources are listed per namespace and the
+// cluster-scoped resources are listed once.
+func (o *GraphOptions) units(f cmdutil.Factory, clientset *kubernetes.Clientset, args []string) ([]unit, error) {
+	types := []string{}
+	if len(args) == 1 && !strings.Contains(args[0], "/") {
+		types = strings.Split(args[0], ",")
+	}
+
+	if !o.AllNamespaces || o.Parallel <= 0 || len(types) == 0 {
+		units := []unit{}
+		for _, namespace := range o.Namespaces {
+			if len(o.Checkpoint) == 0 || len(types) == 0 {
+				units = append(units, unit{namespace: namespace, allNamespaces: o.AllNamespaces, args: args})
+				continue
+			}
+			// each type is checkpointed separately
+			for _, t := range types {
+				units = append(units, unit{namespace: namespace, allNamespaces: o.AllNamespaces, args: []string{t}})
+			}
+		}
+
+		return units, nil
+	}
+
+	namespaced, clusterScoped, err := o.scopes(f, types)
+	if err != nil {
+		return nil, err
+	}
+
+	units := []unit{}
+	if len(clusterScoped) != 0 {
+		units = append(units, unit{allNamespaces: true, args: []string{strings.Join(clusterScoped, ",")}})
+	}
+	if len(namespaced) == 0 {
+		return units, nil
+	}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, namespace := range namespaces.Items {
+		units = append(units, unit{namespace: namespace.GetName(), args: []string{strings.Join(namespaced, ",")}})
+	}
+
+	return units, nil
+}
+
+// scopes splits the resource types into namespaced and cluster-scoped types.
+// A category like "all" is namespaced if all of its resources are namespaced.
+func (o *GraphOptions) scopes(f cmdutil.Factory, types []string) ([]string, []string, error) {
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	namespaced, clusterScoped := []string{}, []string{}
+	for _, t := range types {
+		resources, err := o.resources(f, []string{t})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		scope := meta.RESTScopeNameNamespace
+		for _, resource := range resources {
+			gvk, err := mapper.KindFor(resource.WithVersion(""))
+			if err != nil {
+				return nil, nil, err
+			}
+			mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+			if err != nil {
+				return nil, nil, err
+			}
+			if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+				scope = meta.RESTScopeNameRoot
+			}
+		}
+
+		if scope == meta.RESTScopeNameNamespace {
+			namespaced = append(namespaced, t)
+		} else {
+			clusterScoped = append(clusterScoped, t)
+		}
+	}
+
+	return namespaced, clusterScoped, nil
+}
+
+// scan lists all units with o.Parallel workers and passes the objects of
+// each unit to add, which is called from the calling goroutine only. At most
+// o.Parallel listed units are buffered, so the memory of the listed objects
+// is bounded by the largest units instead of the whole cluster.
+func (o *GraphOptions) scan(f cmdutil.Factory, units []unit, cp *checkpoint, add func([]*unstructured.Unstructured)) error {
+	pending := []unit{}
+	for _, u := range units {
+		if cp != nil {
+			if objs, ok := cp.Load(u.key()); ok {
+				add(objs)
+				continue
+			}
+		}
+		pending = append(pending, u)
+	}
+
+	workers := o.Parallel
+	if workers <= 0 {
+		workers = 1
+	}
+
+	type result struct {
+		unit unit
+		objs []*unstructured.Unstructured
+		err  error
+	}
+
+	queue := make(chan unit)
+	results := make(chan result, workers)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(queue)
+		for _, u := range pending {
+			select {
+			case queue <- u:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				objs, err := o.list(f, u)
+				select {
+				case results <- result{unit: u, objs: objs, err: err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		if r.err != nil {
+			return r.err
+		}
+		if cp != nil {
+			if err := cp.Save(r.unit.key(), r.objs); err != nil {
+				return err
+			}
+		}
+		add(r.objs)
+	}
+
+	return nil
+}
+
+// list returns all objects of the unit.
+func (o *GraphOptions) list(f cmdutil.Factory, u unit) ([]*unstructured.Unstructured, error) {
+	b := f.NewBuilder().
+		Unstructured().
+		NamespaceParam(u.namespace).DefaultNamespace().AllNamespaces(u.allNamespaces).
+		FilenameParam(o.ExplicitNamespace, &o.FilenameOptions).
+		LabelSelectorParam(o.LabelSelector).
+		FieldSelectorParam(o.FieldSelector).
+		RequestChunksOf(o.ChunkSize).
+		ResourceTypeOrNameArgs(true, u.args...).
+		ContinueOnError().
+		Latest()
+
+	if o.MetadataOnly {
+		return o.listMetadata(f, b)
+	}
+
+	r := b.Flatten().Do()
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	infos, err := r.Infos()
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]*unstructured.Unstructured, 0, len(infos))
+	for _, info := range infos {
+		objs = append(objs, info.Object.(*unstructured.Unstructured))
+	}
+
+	return objs, nil
+}
+
+// listMetadata returns the metadata of all objects of the builder. Only the
+// objects of o.FullKinds are fetched completely, because their spec is needed
+// to find their relationships.
+func (o *GraphOptions) listMetadata(f cmdutil.Factory, b *resource.Builder) ([]*unstructured.Unstructured, error) {
+	r := b.TransformRequests(func(req *rest.Request) {
+		req.SetHeader("Accept", metadataAccept)
+	}).Do()
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	infos, err := r.Infos()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := f.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	objs := []*unstructured.Unstructured{}
+	for _, info := range infos {
+		items := []runtime.Object{info.Object}
+		if meta.IsListType(info.Object) {
+			items, err = meta.ExtractList(info.Object)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, item := range items {
+			obj := item.(*unstructured.Unstructured)
+			// the items are of kind PartialObjectMetadata
+			obj.SetGroupVersionKind(info.Mapping.GroupVersionKind)
+
+			if slices.Contains(o.FullKinds, obj.GetKind()) {
+				obj, err = client.Resource(info.Mapping.Resource).Namespace(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			objs = append(objs, obj)
+		}
+	}
+
+	return objs, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/steveteuber/kubectl-graph/pkg/graph"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+// The error codes of JSON-RPC 2.0.
+const (
+	stdioParseError     = -32700
+	stdioInvalidRequest = -32600
+	stdioMethodNotFound = -32601
+	stdioInvalidParams  = -32602
+	stdioServerError    = -32000
+)
+
+// stdioMaxRequestSize limits the size of a single request line.
+const stdioMaxRequestSize = 1024 * 1024
+
+// stdioRequest is a JSON-RPC 2.0 request, a request without an ID is a
+// notification and isn't answered.
+type stdioRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// stdioResponse is a JSON-RPC 2.0 response with either a result or an error.
+type stdioResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *stdioError     `json:"error,omitempty"`
+}
+
+// stdioError is the error of a JSON-RPC 2.0 response.
+type stdioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// stdioGraphParams are the params of the "graph" method.
+type stdioGraphParams struct {
+	Args []string `json:"args"`
+}
+
+// stdioGraphResult is the result of the "graph" method.
+type stdioGraphResult struct {
+	Format   string          `json:"format"`
+	Output   string          `json:"output"`
+	Warnings []graph.Warning `json:"warnings"`
+}
+
+// stdioHandler answers a request with either a result or an error.
+type stdioHandler func(method string, params json.RawMessage) (interface{}, *stdioError)
+
+// RunStdio answers the requests read from stdin one after another, until
+// stdin is closed. The progress is still written to stderr.
+func (o *GraphOptions) RunStdio() error {
+	return serveStdio(o.In, o.Out, o.handleStdio)
+}
+
+// serveStdio reads one request per line and writes one response per line.
+func serveStdio(in io.Reader, out io.Writer, handle stdioHandler) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), stdioMaxRequestSize)
+
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		response := stdioResponse{JSONRPC: "2.0"}
+
+		req := stdioRequest{}
+		if err := json.Unmarshal(line, &req); err != nil {
+			response.Error = &stdioError{Code: stdioParseError, Message: err.Error()}
+		} else if req.JSONRPC != "2.0" || len(req.Method) == 0 {
+			response.ID = req.ID
+			response.Error = &stdioError{Code: stdioInvalidRequest, Message: "invalid request"}
+		} else {
+			response.ID = req.ID
+			response.Result, response.Error = handle(req.Method, req.Params)
+			if req.ID == nil {
+				continue
+			}
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// handleStdio dispatches a request to its method.
+func (o *GraphOptions) handleStdio(method string, params json.RawMessage) (interface{}, *stdioError) {
+	switch method {
+	case "graph":
+		p := stdioGraphParams{}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &stdioError{Code: stdioInvalidParams, Message: err.Error()}
+		}
+		result, err := o.stdioGraph(p.Args)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	default:
+		return nil, &stdioError{Code: stdioMethodNotFound, Message: fmt.Sprintf("method not found: %q", method)}
+	}
+}
+
+// stdioGraph parses the arguments like the graph command and returns the
+// graph in the requested output format. The kubeconfig, context and namespace
+// of this command are the defaults of each request.
+func (o *GraphOptions) stdioGraph(args []string) (*stdioGraphResult, *stdioError) {
+	flags := genericclioptions.NewConfigFlags(true)
+	copyFlag(flags.KubeConfig, o.configFlags.KubeConfig)
+	copyFlag(flags.Context, o.configFlags.Context)
+	copyFlag(flags.Namespace, o.configFlags.Namespace)
+
+	out := &bytes.Buffer{}
+	streams := genericclioptions.IOStreams{Out: out, ErrOut: o.ErrOut}
+	ro := NewGraphOptions(o.CmdParent, flags, streams)
+
+	cmd := &cobra.Command{}
+	ro.AddFlags(cmd)
+	flags.AddFlags(cmd.Flags())
+	if err := cmd.ParseFlags(args); err != nil {
+		return nil, &stdioError{Code: stdioInvalidParams, Message: err.Error()}
+	}
+	args = cmd.Flags().Args()
+
+	f := cmdutil.NewFactory(ro.ToRESTClientGetter())
+	if err := ro.Complete(f, cmd, args); err != nil {
+		return nil, &stdioError{Code: stdioInvalidParams, Message: err.Error()}
+	}
+	if err := ro.Validate(cmd, args); err != nil {
+		return nil, &stdioError{Code: stdioInvalidParams, Message: err.Error()}
+	}
+	if ro.Stdio || ro.PrintManifests {
+		return nil, &stdioError{Code: stdioInvalidParams, Message: "--stdio and --print-manifests are not supported in a request"}
+	}
+
+	g, err := ro.ToGraph(f, args)
+	if err != nil {
+		return nil, &stdioError{Code: stdioServerError, Message: err.Error()}
+	}
+	if err := ro.output(g); err != nil {
+		return nil, &stdioError{Code: stdioServerError, Message: err.Error()}
+	}
+
+	return &stdioGraphResult{
+		Format:   ro.OutputFormat,
+		Output:   out.String(),
+		Warnings: g.Warnings(),
+	}, nil
+}
+
+// copyFlag sets a kubeconfig flag to the value of another one, if both are set.
+func copyFlag(dst *string, src *string) {
+	if dst != nil && src != nil {
+		*dst = *src
+	}
+}
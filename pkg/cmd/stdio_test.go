@@ -0,0 +1,135 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestServeStdio(t *testing.T) {
+	handle := func(method string, params json.RawMessage) (interface{}, *stdioError) {
+		if method != "echo" {
+			return nil, &stdioError{Code: stdioMethodNotFound, Message: "method not found"}
+		}
+		return params, nil
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "result",
+			in:   `{"jsonrpc":"2.0","id":1,"method":"echo","params":{"a":"<b>"}}`,
+			want: `{"jsonrpc":"2.0","id":1,"result":{"a":"<b>"}}`,
+		},
+		{
+			name: "error",
+			in:   `{"jsonrpc":"2.0","id":"x","method":"unknown"}`,
+			want: `{"jsonrpc":"2.0","id":"x","error":{"code":-32601,"message":"method not found"}}`,
+		},
+		{
+			name: "parse error",
+			in:   `{"jsonrpc":`,
+			want: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"unexpected end of JSON input"}}`,
+		},
+		{
+			name: "invalid request",
+			in:   `{"id":2,"method":"echo"}`,
+			want: `{"jsonrpc":"2.0","id":2,"error":{"code":-32600,"message":"invalid request"}}`,
+		},
+		{
+			name: "notification",
+			in:   `{"jsonrpc":"2.0","method":"echo"}`,
+			want: ``,
+		},
+		{
+			name: "multiple requests",
+			in:   "{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"echo\",\"params\":1}\n\n{\"jsonrpc\":\"2.0\",\"id\":2,\"method\":\"echo\",\"params\":2}\n",
+			want: "{\"jsonrpc\":\"2.0\",\"id\":1,\"result\":1}\n{\"jsonrpc\":\"2.0\",\"id\":2,\"result\":2}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := serveStdio(strings.NewReader(tt.in), out, handle); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("serveStdio() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleStdio(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	config := `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:1
+contexts:
+- name: test
+  context:
+    cluster: test
+    namespace: default
+current-context: test
+`
+	if err := os.WriteFile(kubeconfig, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := genericclioptions.NewConfigFlags(true)
+	*flags.KubeConfig = kubeconfig
+	o := NewGraphOptions("kubectl", flags, genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: io.Discard, ErrOut: io.Discard})
+
+	tests := []struct {
+		name   string
+		method string
+		params string
+		code   int
+	}{
+		{name: "unknown method", method: "apply", params: `{}`, code: stdioMethodNotFound},
+		{name: "invalid params", method: "graph", params: `{"args":"pods"}`, code: stdioInvalidParams},
+		{name: "unknown flag", method: "graph", params: `{"args":["pods","--unknown"]}`, code: stdioInvalidParams},
+		{name: "no resource", method: "graph", params: `{"args":["-o","cypher"]}`, code: stdioInvalidParams},
+		{name: "invalid format", method: "graph", params: `{"args":["pods","-o","png"]}`, code: stdioInvalidParams},
+		{name: "nested stdio", method: "graph", params: `{"args":["pods","--stdio"]}`, code: stdioInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := o.handleStdio(tt.method, json.RawMessage(tt.params))
+			if err == nil {
+				t.Fatalf("handleStdio() = %v, want error %d", result, tt.code)
+			}
+			if err.Code != tt.code {
+				t.Errorf("handleStdio() error = %d %s, want %d", err.Code, err.Message, tt.code)
+			}
+		})
+	}
+}
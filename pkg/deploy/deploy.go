@@ -0,0 +1,193 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultName represents the default name of all generated resources.
+	DefaultName string = "kubectl-graph"
+	// DefaultSchedule represents the default schedule of the generated CronJob.
+	DefaultSchedule string = "0 * * * *"
+)
+
+// Options represents attributes to configure the generated manifests.
+type Options struct {
+	// Name of all generated resources.
+	Name string
+	// Namespace where the CronJob and ServiceAccount are deployed.
+	Namespace string
+	// Image which contains the kubectl-graph binary as entrypoint.
+	Image string
+	// Schedule of the CronJob in cron format.
+	Schedule string
+	// Args are passed to the kubectl-graph binary.
+	Args []string
+	// Resources which must be readable by the ServiceAccount.
+	Resources []schema.GroupResource
+	// Namespaces limits the read access to these namespaces, if empty
+	// the access is granted cluster wide.
+	Namespaces []string
+}
+
+// Manifests returns the ServiceAccount, RBAC rules and CronJob which are
+// required to run kubectl-graph periodically inside of the cluster.
+func Manifests(o Options) ([]runtime.Object, error) {
+	if len(o.Image) == 0 {
+		return nil, fmt.Errorf("an image is required to generate the manifests")
+	}
+	if len(o.Name) == 0 {
+		o.Name = DefaultName
+	}
+	if len(o.Schedule) == 0 {
+		o.Schedule = DefaultSchedule
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":       DefaultName,
+		"app.kubernetes.io/instance":   o.Name,
+		"app.kubernetes.io/managed-by": DefaultName,
+	}
+	meta := func(name string, namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}
+	}
+
+	subjects := []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      o.Name,
+		Namespace: o.Namespace,
+	}}
+	roleRef := rbacv1.RoleRef{
+		APIGroup: rbacv1.GroupName,
+		Kind:     "ClusterRole",
+		Name:     o.Name,
+	}
+
+	objs := []runtime.Object{
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta(o.Name, o.Namespace),
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: meta(o.Name, ""),
+			Rules:      PolicyRules(o.Resources),
+		},
+	}
+
+	if len(o.Namespaces) == 0 {
+		objs = append(objs, &rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: meta(o.Name, ""),
+			Subjects:   subjects,
+			RoleRef:    roleRef,
+		})
+	}
+
+	for _, namespace := range o.Namespaces {
+		objs = append(objs, &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+			ObjectMeta: meta(o.Name, namespace),
+			Subjects:   subjects,
+			RoleRef:    roleRef,
+		})
+	}
+
+	objs = append(objs, &batchv1.CronJob{
+		TypeMeta:   metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "CronJob"},
+		ObjectMeta: meta(o.Name, o.Namespace),
+		Spec: batchv1.CronJobSpec{
+			Schedule:          o.Schedule,
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: corev1.PodSpec{
+							ServiceAccountName: o.Name,
+							RestartPolicy:      corev1.RestartPolicyNever,
+							Containers: []corev1.Container{{
+								Name:  "kubectl-graph",
+								Image: o.Image,
+								Args:  o.Args,
+							}},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	return objs, nil
+}
+
+// PolicyRules returns the minimal rules to read the given resources.
+func PolicyRules(resources []schema.GroupResource) []rbacv1.PolicyRule {
+	groups := make(map[string]map[string]bool)
+	for _, gr := range resources {
+		if _, ok := groups[gr.Group]; !ok {
+			groups[gr.Group] = make(map[string]bool)
+		}
+		groups[gr.Group][gr.Resource] = true
+	}
+
+	rules := []rbacv1.PolicyRule{}
+	for group, set := range groups {
+		resources := []string{}
+		for resource := range set {
+			resources = append(resources, resource)
+		}
+		sort.Strings(resources)
+
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{group},
+			Resources: resources,
+			Verbs:     []string{"get", "list", "watch"},
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].APIGroups[0] < rules[j].APIGroups[0]
+	})
+
+	return rules
+}
+
+// Write writes all objects as multi-document YAML to w.
+func Write(w io.Writer, objs []runtime.Object) error {
+	for _, obj := range objs {
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,399 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// AdmissionregistrationV1Graph is used to graph all admissionregistration resources.
+type AdmissionregistrationV1Graph struct {
+	graph *Graph
+
+	// namespaces contains all namespaces, they are listed once to match
+	// the namespace selectors of all webhooks.
+	namespaces []corev1.Namespace
+
+	// policies contains the ValidatingAdmissionPolicies by name, because
+	// their parameter kind is needed by their bindings. A nil policy was not found.
+	policies map[string]*v1.ValidatingAdmissionPolicy
+}
+
+// NewAdmissionregistrationV1Graph creates a new AdmissionregistrationV1Graph.
+func NewAdmissionregistrationV1Graph(g *Graph) *AdmissionregistrationV1Graph {
+	return &AdmissionregistrationV1Graph{
+		graph:    g,
+		policies: make(map[string]*v1.ValidatingAdmissionPolicy),
+	}
+}
+
+// AdmissionregistrationV1 retrieves the AdmissionregistrationV1Graph.
+func (g *Graph) AdmissionregistrationV1() *AdmissionregistrationV1Graph {
+	return g.admissionregistrationV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *AdmissionregistrationV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "MutatingWebhookConfiguration":
+		obj := &v1.MutatingWebhookConfiguration{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.MutatingWebhookConfiguration(obj)
+	case "ValidatingWebhookConfiguration":
+		obj := &v1.ValidatingWebhookConfiguration{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ValidatingWebhookConfiguration(obj)
+	case "ValidatingAdmissionPolicy":
+		obj := &v1.ValidatingAdmissionPolicy{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ValidatingAdmissionPolicy(obj)
+	case "ValidatingAdmissionPolicyBinding":
+		obj := &v1.ValidatingAdmissionPolicyBinding{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ValidatingAdmissionPolicyBinding(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// MutatingWebhookConfiguration adds a v1.MutatingWebhookConfiguration resource and its webhooks to the Graph.
+func (g *AdmissionregistrationV1Graph) MutatingWebhookConfiguration(obj *v1.MutatingWebhookConfiguration) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "MutatingWebhookConfiguration"), obj)
+
+	for _, webhook := range obj.Webhooks {
+		w, err := g.Webhook(obj.GetUID(), webhook.Name, webhook.ClientConfig, webhook.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		webhookAttributes(w, webhook.FailurePolicy, webhook.SideEffects, webhook.TimeoutSeconds)
+		g.graph.Relationship(n, "Webhook", w)
+	}
+
+	return n, nil
+}
+
+// ValidatingWebhookConfiguration adds a v1.ValidatingWebhookConfiguration resource and its webhooks to the Graph.
+func (g *AdmissionregistrationV1Graph) ValidatingWebhookConfiguration(obj *v1.ValidatingWebhookConfiguration) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "ValidatingWebhookConfiguration"), obj)
+
+	for _, webhook := range obj.Webhooks {
+		w, err := g.Webhook(obj.GetUID(), webhook.Name, webhook.ClientConfig, webhook.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		webhookAttributes(w, webhook.FailurePolicy, webhook.SideEffects, webhook.TimeoutSeconds)
+		g.graph.Relationship(n, "Webhook", w)
+	}
+
+	return n, nil
+}
+
+// ValidatingAdmissionPolicy adds a v1.ValidatingAdmissionPolicy resource and
+// the resources and namespaces matched by its constraints to the Graph.
+func (g *AdmissionregistrationV1Graph) ValidatingAdmissionPolicy(obj *v1.ValidatingAdmissionPolicy) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "ValidatingAdmissionPolicy"), obj)
+	n.Attribute("validations", strconv.Itoa(len(obj.Spec.Validations)))
+	if obj.Spec.FailurePolicy != nil {
+		n.Attribute("failurePolicy", string(*obj.Spec.FailurePolicy))
+	}
+	if obj.Spec.ParamKind != nil {
+		n.Attribute("paramKind", paramKind(obj.Spec.ParamKind).String())
+	}
+	g.policies[obj.GetName()] = obj
+
+	if err := g.matchResources(n, obj.Spec.MatchConstraints); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// ValidatingAdmissionPolicyBinding adds a v1.ValidatingAdmissionPolicyBinding
+// resource, its policy, its parameter resources and the resources and
+// namespaces matched by its constraints to the Graph.
+func (g *AdmissionregistrationV1Graph) ValidatingAdmissionPolicyBinding(obj *v1.ValidatingAdmissionPolicyBinding) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "ValidatingAdmissionPolicyBinding"), obj)
+
+	policy, err := g.ValidatingAdmissionPolicyName(obj.Spec.PolicyName)
+	if err != nil {
+		return nil, err
+	}
+
+	var p *Node
+	if policy == nil {
+		p = g.graph.Node(
+			schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "ValidatingAdmissionPolicy"),
+			&metav1.ObjectMeta{
+				UID:  g.graph.ToUID("ValidatingAdmissionPolicy", obj.Spec.PolicyName),
+				Name: obj.Spec.PolicyName,
+			},
+		)
+		g.graph.Warn(WarningNotFound, p, "referenced, but does not exist")
+	} else {
+		p, err = g.ValidatingAdmissionPolicy(policy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := g.graph.Relationship(n, "ValidatingAdmissionPolicy", p)
+	for _, action := range obj.Spec.ValidationActions {
+		appendAttribute(r, "validationActions", string(action))
+	}
+
+	if obj.Spec.ParamRef != nil && policy != nil && policy.Spec.ParamKind != nil {
+		g.param(n, paramKind(policy.Spec.ParamKind), obj.Spec.ParamRef)
+	}
+
+	if err := g.matchResources(n, obj.Spec.MatchResources); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// ValidatingAdmissionPolicyName returns the v1.ValidatingAdmissionPolicy with the given name.
+// It returns nil if the ValidatingAdmissionPolicy does not exist.
+func (g *AdmissionregistrationV1Graph) ValidatingAdmissionPolicyName(name string) (*v1.ValidatingAdmissionPolicy, error) {
+	if policy, ok := g.policies[name]; ok {
+		return policy, nil
+	}
+
+	options := metav1.GetOptions{}
+	policy, err := g.graph.clientset.AdmissionregistrationV1().ValidatingAdmissionPolicies().Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		g.policies[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.policies[name] = policy
+
+	return policy, nil
+}
+
+// param adds the parameter resource of a binding to the Graph. The resource
+// itself is not read, because its kind is only known by the policy. A
+// parameter selector is added as attribute to the binding node instead.
+func (g *AdmissionregistrationV1Graph) param(n *Node, gvk schema.GroupVersionKind, ref *v1.ParamRef) {
+	if ref.Selector != nil {
+		if selector, err := metav1.LabelSelectorAsSelector(ref.Selector); err == nil {
+			n.Attribute("paramSelector", selector.String())
+		}
+	}
+	if len(ref.Name) == 0 {
+		return
+	}
+
+	p := g.graph.Node(
+		gvk,
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(ref.Namespace, gvk.Kind, ref.Name),
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		},
+	)
+	g.graph.Relationship(n, "Param", p)
+}
+
+// matchResources adds the resources and namespaces which are matched by the
+// constraints of a policy or binding to the Graph.
+func (g *AdmissionregistrationV1Graph) matchResources(n *Node, match *v1.MatchResources) error {
+	if match == nil {
+		return nil
+	}
+
+	for _, rule := range match.ResourceRules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				r := g.graph.Relationship(n, "Resource", g.Resource(group, resource))
+				for _, operation := range rule.Operations {
+					appendAttribute(r, "operations", string(operation))
+				}
+			}
+		}
+	}
+
+	return g.namespaceSelector(n, match.NamespaceSelector)
+}
+
+// Resource adds a node which represents a resource matched by admission rules to the Graph.
+func (g *AdmissionregistrationV1Graph) Resource(group string, resource string) *Node {
+	name := resource
+	if len(group) != 0 {
+		name = resource + "." + group
+	}
+
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Resource"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID("Resource", group, resource),
+			Name: name,
+		},
+	)
+}
+
+// Kind adds a node which represents a kind matched by policies to the Graph.
+// An empty group matches the kind in all groups.
+func (g *AdmissionregistrationV1Graph) Kind(group string, kind string) *Node {
+	name := kind
+	if len(group) != 0 {
+		name = kind + "." + group
+	}
+
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Kind"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID("Kind", group, kind),
+			Name: name,
+		},
+	)
+}
+
+// paramKind returns the group, version and kind of the parameter resources of a policy.
+func paramKind(kind *v1.ParamKind) schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(kind.APIVersion, kind.Kind)
+}
+
+// Webhook adds a node which represents a webhook of a configuration to the
+// Graph, including its backing Service or URL and the namespaces which are
+// matched by its namespace selector.
+func (g *AdmissionregistrationV1Graph) Webhook(uid types.UID, name string, config v1.WebhookClientConfig, namespaceSelector *metav1.LabelSelector) (*Node, error) {
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Webhook"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID(uid, name),
+			Name: name,
+		},
+	)
+
+	switch {
+	case config.Service != nil:
+		s, err := g.service(config.Service)
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(n, "Service", s)
+		if config.Service.Path != nil {
+			r.Attribute("path", *config.Service.Path)
+		}
+		if config.Service.Port != nil {
+			r.Attribute("port", strconv.Itoa(int(*config.Service.Port)))
+		}
+	case config.URL != nil:
+		if _, err := g.graph.CoreV1().URL(n, "URL", *config.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := g.namespaceSelector(n, namespaceSelector); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// service adds the v1.Service of a webhook to the Graph.
+// A missing Service is added as node without UID from the cluster.
+func (g *AdmissionregistrationV1Graph) service(ref *v1.ServiceReference) (*Node, error) {
+	options := metav1.GetOptions{}
+	service, err := g.graph.clientset.CoreV1().Services(ref.Namespace).Get(context.TODO(), ref.Name, options)
+	if err == nil {
+		return g.graph.CoreV1().Service(service)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind(corev1.GroupName, "Service"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(ref.Namespace, "Service", ref.Name),
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		},
+	)
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// namespaceSelector adds the selector as attribute to the webhook or policy node
+// and relationships to all matched namespaces. A node without selector
+// matches all namespaces, so no relationships are added.
+func (g *AdmissionregistrationV1Graph) namespaceSelector(n *Node, namespaceSelector *metav1.LabelSelector) error {
+	selector, err := metav1.LabelSelectorAsSelector(namespaceSelector)
+	if err != nil {
+		return fmt.Errorf("invalid namespace selector of %s %s: %v", n.Kind, n.GetName(), err)
+	}
+	if namespaceSelector == nil || selector.Empty() {
+		return nil
+	}
+	n.Attribute("namespaceSelector", selector.String())
+
+	if g.namespaces == nil {
+		list, err := g.graph.clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		g.namespaces = list.Items
+	}
+
+	for i := range g.namespaces {
+		if !selector.Matches(labels.Set(g.namespaces[i].GetLabels())) {
+			continue
+		}
+		ns, err := g.graph.CoreV1().Namespace(&g.namespaces[i])
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(n, "Namespace", ns)
+	}
+
+	return nil
+}
+
+// webhookAttributes adds the failure policy, side effects and timeout as attributes to the webhook node.
+func webhookAttributes(n *Node, failurePolicy *v1.FailurePolicyType, sideEffects *v1.SideEffectClass, timeoutSeconds *int32) {
+	if failurePolicy != nil {
+		n.Attribute("failurePolicy", string(*failurePolicy))
+	}
+	if sideEffects != nil {
+		n.Attribute("sideEffects", string(*sideEffects))
+	}
+	if timeoutSeconds != nil {
+		n.Attribute("timeoutSeconds", strconv.Itoa(int(*timeoutSeconds)))
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// anonymousAttributes contains the node and relationship attributes which are kept
+// when the graph is anonymized, because they do not contain any names.
+var anonymousAttributes = map[string]bool{
+	"color":      true,
+	"hostAccess": true,
+	"style":      true,
+	"weight":     true,
+}
+
+// Anonymize replaces all names, namespaces and UIDs with salted hashes and
+// removes all labels, annotations and warning messages. The kinds and the
+// structure of the graph are kept, so the result can be shared without
+// leaking internal naming.
+func (g *Graph) Anonymize() error {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	hash := func(s string) string {
+		h := hmac.New(sha256.New, salt)
+		h.Write([]byte(s))
+		return fmt.Sprintf("%x", h.Sum(nil))[:8]
+	}
+	uid := func(uid types.UID) types.UID {
+		return g.ToUID(hash(string(uid)))
+	}
+
+	nodes := make(map[types.UID]*Node, len(g.Nodes))
+	for _, node := range g.Nodes {
+		node.SetUID(uid(node.GetUID()))
+		node.SetName(fmt.Sprintf("%s-%s", strings.ToLower(node.Kind), hash(node.GetName())))
+		if len(node.GetNamespace()) != 0 {
+			node.SetNamespace(fmt.Sprintf("namespace-%s", hash(node.GetNamespace())))
+		}
+		node.SetAnnotations(nil)
+		node.SetLabels(nil)
+		for key := range node.Attr {
+			if !anonymousAttributes[key] {
+				delete(node.Attr, key)
+			}
+		}
+
+		nodes[node.GetUID()] = node
+	}
+
+	relationships := make(map[types.UID][]*Relationship, len(g.Relationships))
+	for _, relationship := range g.relationships() {
+		relationship.From = uid(relationship.From)
+		relationship.To = uid(relationship.To)
+		for key := range relationship.Attr {
+			if !anonymousAttributes[key] {
+				delete(relationship.Attr, key)
+			}
+		}
+
+		relationships[relationship.To] = append(relationships[relationship.To], relationship)
+	}
+
+	// the messages of warnings contain the names of other objects
+	g.warned = make(map[Warning]bool, len(g.warnings))
+	for i := range g.warnings {
+		w := &g.warnings[i]
+		w.Name = fmt.Sprintf("%s-%s", strings.ToLower(w.Kind), hash(w.Name))
+		if len(w.Namespace) != 0 {
+			w.Namespace = fmt.Sprintf("namespace-%s", hash(w.Namespace))
+		}
+		w.Message = ""
+		g.warned[*w] = true
+	}
+
+	g.Nodes = nodes
+	g.Relationships = relationships
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ApiextensionsV1Graph is used to graph all apiextensions resources.
+type ApiextensionsV1Graph struct {
+	graph *Graph
+
+	// definitions contains the CustomResourceDefinition nodes by the group
+	// and kind of their custom resources.
+	definitions map[schema.GroupKind]*Node
+}
+
+// NewApiextensionsV1Graph creates a new ApiextensionsV1Graph.
+func NewApiextensionsV1Graph(g *Graph) *ApiextensionsV1Graph {
+	return &ApiextensionsV1Graph{
+		graph:       g,
+		definitions: make(map[schema.GroupKind]*Node),
+	}
+}
+
+// ApiextensionsV1 retrieves the ApiextensionsV1Graph.
+func (g *Graph) ApiextensionsV1() *ApiextensionsV1Graph {
+	return g.apiextensionsV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *ApiextensionsV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "CustomResourceDefinition":
+		return g.CustomResourceDefinition(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// CustomResourceDefinition adds a CustomResourceDefinition resource to the
+// Graph. The relationships to its custom resources are added by Defines.
+func (g *ApiextensionsV1Graph) CustomResourceDefinition(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	group, _, _ := unstructured.NestedString(unstr.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(unstr.Object, "spec", "names", "kind")
+	scope, _, _ := unstructured.NestedString(unstr.Object, "spec", "scope")
+	n.Attribute("group", group)
+	n.Attribute("kind", kind)
+	n.Attribute("scope", scope)
+
+	versions, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "versions")
+	names := []string{}
+	for _, version := range versions {
+		v, ok := version.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if served, _, _ := unstructured.NestedBool(v, "served"); !served {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(v, "name"); len(name) != 0 {
+			names = append(names, name)
+		}
+	}
+	n.Attribute("versions", strings.Join(names, ","))
+
+	g.definitions[schema.GroupKind{Group: group, Kind: kind}] = n
+
+	return n, nil
+}
+
+// Defines adds a DEFINES relationship from each CustomResourceDefinition to
+// the custom resources of its kind in the Graph and the number of these
+// custom resources as attribute, so unused definitions have zero instances.
+func (g *ApiextensionsV1Graph) Defines() {
+	instances := make(map[*Node]int)
+	for _, n := range g.definitions {
+		instances[n] = 0
+	}
+
+	for _, node := range g.graph.NodeList() {
+		n, ok := g.definitions[node.GroupVersionKind().GroupKind()]
+		if !ok || !g.graph.objects[node.UID] {
+			continue
+		}
+
+		g.graph.LabeledRelationship(n, "DEFINES", node)
+		instances[n]++
+	}
+
+	for n, count := range instances {
+		n.Attribute("instances", strconv.Itoa(count))
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ApiregistrationV1Graph is used to graph all apiregistration resources.
+type ApiregistrationV1Graph struct {
+	graph *Graph
+}
+
+// NewApiregistrationV1Graph creates a new ApiregistrationV1Graph.
+func NewApiregistrationV1Graph(g *Graph) *ApiregistrationV1Graph {
+	return &ApiregistrationV1Graph{
+		graph: g,
+	}
+}
+
+// ApiregistrationV1 retrieves the ApiregistrationV1Graph.
+func (g *Graph) ApiregistrationV1() *ApiregistrationV1Graph {
+	return g.apiregistrationV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *ApiregistrationV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "APIService":
+		return g.APIService(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// APIService adds an APIService resource and the Service and Deployments of
+// an aggregated API to the Graph. APIServices which are not available are
+// flagged, because the discovery of their group fails.
+func (g *ApiregistrationV1Graph) APIService(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	group, _, _ := unstructured.NestedString(unstr.Object, "spec", "group")
+	version, _, _ := unstructured.NestedString(unstr.Object, "spec", "version")
+	n.Attribute("group", group)
+	n.Attribute("version", version)
+
+	status, reason, message := apiServiceAvailable(unstr)
+	n.Attribute("available", status)
+	if status != string(metav1.ConditionTrue) {
+		g.graph.Warn(WarningUnavailable, n, "%s: %s", reason, message)
+	}
+
+	ref, ok, _ := unstructured.NestedMap(unstr.Object, "spec", "service")
+	if !ok || ref == nil {
+		// the API is served locally by the kube-apiserver
+		n.Attribute("local", "true")
+		return n, nil
+	}
+
+	namespace, _, _ := unstructured.NestedString(ref, "namespace")
+	name, _, _ := unstructured.NestedString(ref, "name")
+
+	s, err := g.service(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	r := g.graph.Relationship(n, "Service", s)
+	if port, ok, _ := unstructured.NestedInt64(ref, "port"); ok {
+		r.Attribute("port", strconv.FormatInt(port, 10))
+	}
+
+	return n, nil
+}
+
+// service adds the v1.Service of an aggregated API and the Deployments of
+// the selected Pods to the Graph. A missing Service is added as node without
+// UID from the cluster.
+func (g *ApiregistrationV1Graph) service(namespace string, name string) (*Node, error) {
+	options := metav1.GetOptions{}
+	service, err := g.graph.clientset.CoreV1().Services(namespace).Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		n := g.graph.Node(
+			schema.FromAPIVersionAndKind(corev1.GroupName, "Service"),
+			&metav1.ObjectMeta{
+				UID:       g.graph.ToUID(namespace, "Service", name),
+				Name:      name,
+				Namespace: namespace,
+			},
+		)
+		g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+		return n, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := g.graph.CoreV1().Service(service)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil || len(service.Spec.Selector) == 0 {
+		return n, nil
+	}
+
+	deployments, err := g.graph.clientset.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	selector := labels.SelectorFromSet(service.Spec.Selector)
+	for i := range deployments.Items {
+		if !selector.Matches(labels.Set(deployments.Items[i].Spec.Template.GetLabels())) {
+			continue
+		}
+		d, err := g.graph.AppsV1().Deployment(&deployments.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Deployment", d)
+	}
+
+	return n, nil
+}
+
+// apiServiceAvailable returns the status, reason and message of the
+// Available condition of an APIService.
+func apiServiceAvailable(unstr *unstructured.Unstructured) (string, string, string) {
+	conditions, _, _ := unstructured.NestedSlice(unstr.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok || c["type"] != "Available" {
+			continue
+		}
+		return fmt.Sprint(c["status"]), fmt.Sprint(c["reason"]), fmt.Sprint(c["message"])
+	}
+
+	return string(metav1.ConditionUnknown), "NoCondition", "availability is not reported"
+}
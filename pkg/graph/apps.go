@@ -0,0 +1,297 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Rollout status values of the "status" attribute of workloads.
+const (
+	RolloutComplete    = "Complete"
+	RolloutProgressing = "Progressing"
+	RolloutFailed      = "Failed"
+	RolloutPaused      = "Paused"
+)
+
+// AppsV1Graph is used to graph all apps resources.
+type AppsV1Graph struct {
+	graph *Graph
+
+	// expanded contains the workloads whose children are already listed.
+	expanded map[types.UID]bool
+}
+
+// NewAppsV1Graph creates a new AppsV1Graph.
+func NewAppsV1Graph(g *Graph) *AppsV1Graph {
+	return &AppsV1Graph{
+		graph:    g,
+		expanded: make(map[types.UID]bool),
+	}
+}
+
+// AppsV1 retrieves the AppsV1Graph.
+func (g *Graph) AppsV1() *AppsV1Graph {
+	return g.appsV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *AppsV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Deployment":
+		obj := &v1.Deployment{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.Deployment(obj)
+	case "ReplicaSet":
+		obj := &v1.ReplicaSet{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ReplicaSet(obj)
+	case "StatefulSet":
+		obj := &v1.StatefulSet{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.StatefulSet(obj)
+	case "DaemonSet":
+		obj := &v1.DaemonSet{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.DaemonSet(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Deployment adds a v1.Deployment resource and its ReplicaSets to the Graph.
+func (g *AppsV1Graph) Deployment(obj *v1.Deployment) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "Deployment"), obj)
+	replicas(n, obj.Spec.Replicas, obj.Status.ReadyReplicas, obj.Status.AvailableReplicas)
+	n.Attribute("updatedReplicas", strconv.Itoa(int(obj.Status.UpdatedReplicas)))
+	n.Attribute("status", deploymentStatus(obj))
+	if err := g.graph.CoreV1().Images(n, obj.Spec.Template.Spec); err != nil {
+		return nil, err
+	}
+	hostAccess(n, obj.Spec.Template.Spec)
+
+	if g.expanded[obj.GetUID()] {
+		return n, nil
+	}
+	g.expanded[obj.GetUID()] = true
+
+	selector, err := metav1.LabelSelectorAsSelector(obj.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of deployment %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+	replicaSets, err := g.graph.clientset.AppsV1().ReplicaSets(obj.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range replicaSets.Items {
+		// the selectors of Deployments may overlap
+		if !metav1.IsControlledBy(&replicaSets.Items[i], obj) {
+			continue
+		}
+
+		rs, err := g.ReplicaSet(&replicaSets.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "ReplicaSet", rs)
+	}
+
+	return n, nil
+}
+
+// ReplicaSet adds a v1.ReplicaSet resource and its Pods to the Graph.
+func (g *AppsV1Graph) ReplicaSet(obj *v1.ReplicaSet) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "ReplicaSet"), obj)
+	replicas(n, obj.Spec.Replicas, obj.Status.ReadyReplicas, obj.Status.AvailableReplicas)
+
+	if _, err := g.pods(n, obj.Spec.Selector); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// StatefulSet adds a v1.StatefulSet resource, its Pods and their
+// PersistentVolumeClaims to the Graph.
+func (g *AppsV1Graph) StatefulSet(obj *v1.StatefulSet) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "StatefulSet"), obj)
+	replicas(n, obj.Spec.Replicas, obj.Status.ReadyReplicas, obj.Status.AvailableReplicas)
+	n.Attribute("updatedReplicas", strconv.Itoa(int(obj.Status.UpdatedReplicas)))
+	n.Attribute("status", statefulSetStatus(obj))
+	if err := g.graph.CoreV1().Images(n, obj.Spec.Template.Spec); err != nil {
+		return nil, err
+	}
+	hostAccess(n, obj.Spec.Template.Spec)
+
+	pods, err := g.pods(n, obj.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pod := range pods {
+		for _, template := range obj.Spec.VolumeClaimTemplates {
+			// the claims of a StatefulSet are named <template>-<pod>
+			name := template.GetName() + "-" + pod.GetName()
+
+			c, err := g.graph.CoreV1().PersistentVolumeClaimName(obj.GetNamespace(), name)
+			if err != nil {
+				return nil, err
+			}
+			if c == nil {
+				continue
+			}
+			g.graph.Relationship(pod, "PersistentVolumeClaim", c)
+		}
+	}
+
+	return n, nil
+}
+
+// DaemonSet adds a v1.DaemonSet resource and its Pods to the Graph.
+func (g *AppsV1Graph) DaemonSet(obj *v1.DaemonSet) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "DaemonSet"), obj)
+	replicas(n, &obj.Status.DesiredNumberScheduled, obj.Status.NumberReady, obj.Status.NumberAvailable)
+	n.Attribute("updatedReplicas", strconv.Itoa(int(obj.Status.UpdatedNumberScheduled)))
+	n.Attribute("status", daemonSetStatus(obj))
+	if err := g.graph.CoreV1().Images(n, obj.Spec.Template.Spec); err != nil {
+		return nil, err
+	}
+	hostAccess(n, obj.Spec.Template.Spec)
+
+	if _, err := g.pods(n, obj.Spec.Selector); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// pods adds the Pods which are selected and controlled by a workload to the
+// Graph. The Pods are only listed once per workload.
+func (g *AppsV1Graph) pods(n *Node, labelSelector *metav1.LabelSelector) ([]*Node, error) {
+	if g.expanded[n.GetUID()] {
+		return nil, nil
+	}
+	g.expanded[n.GetUID()] = true
+
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of %s %s/%s: %v", n.Kind, n.GetNamespace(), n.GetName(), err)
+	}
+
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+	pods, err := g.graph.clientset.CoreV1().Pods(n.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*Node{}
+	for i := range pods.Items {
+		// the selectors of workloads may overlap
+		if !metav1.IsControlledBy(&pods.Items[i], n) {
+			continue
+		}
+
+		p := g.graph.Node(schema.FromAPIVersionAndKind(corev1.GroupName, "Pod"), &pods.Items[i])
+		g.graph.Relationship(n, "Pod", p)
+		nodes = append(nodes, p)
+	}
+
+	return nodes, nil
+}
+
+// replicas adds the replica counts of a workload as attributes to the node.
+func replicas(n *Node, desired *int32, ready int32, available int32) {
+	if desired != nil {
+		n.Attribute("replicas", strconv.Itoa(int(*desired)))
+	}
+	n.Attribute("readyReplicas", strconv.Itoa(int(ready)))
+	n.Attribute("availableReplicas", strconv.Itoa(int(available)))
+}
+
+// deploymentStatus returns the rollout status of a v1.Deployment
+// the same way as "kubectl rollout status" does.
+func deploymentStatus(obj *v1.Deployment) string {
+	if obj.Spec.Paused {
+		return RolloutPaused
+	}
+	if obj.Generation > obj.Status.ObservedGeneration {
+		return RolloutProgressing
+	}
+
+	for _, condition := range obj.Status.Conditions {
+		if condition.Type == v1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return RolloutFailed
+		}
+	}
+
+	desired := int32(1)
+	if obj.Spec.Replicas != nil {
+		desired = *obj.Spec.Replicas
+	}
+	if obj.Status.UpdatedReplicas < desired || obj.Status.Replicas > obj.Status.UpdatedReplicas || obj.Status.AvailableReplicas < obj.Status.UpdatedReplicas {
+		return RolloutProgressing
+	}
+
+	return RolloutComplete
+}
+
+// statefulSetStatus returns the rollout status of a v1.StatefulSet.
+func statefulSetStatus(obj *v1.StatefulSet) string {
+	if obj.Generation > obj.Status.ObservedGeneration {
+		return RolloutProgressing
+	}
+
+	desired := int32(1)
+	if obj.Spec.Replicas != nil {
+		desired = *obj.Spec.Replicas
+	}
+	if obj.Status.ReadyReplicas < desired || obj.Status.UpdateRevision != obj.Status.CurrentRevision {
+		return RolloutProgressing
+	}
+
+	return RolloutComplete
+}
+
+// daemonSetStatus returns the rollout status of a v1.DaemonSet.
+func daemonSetStatus(obj *v1.DaemonSet) string {
+	if obj.Generation > obj.Status.ObservedGeneration {
+		return RolloutProgressing
+	}
+	if obj.Status.UpdatedNumberScheduled < obj.Status.DesiredNumberScheduled || obj.Status.NumberAvailable < obj.Status.DesiredNumberScheduled {
+		return RolloutProgressing
+	}
+
+	return RolloutComplete
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	v1 "github.com/openshift/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DeploymentConfigLabel is the label of a ReplicationController with the name of its DeploymentConfig.
+const DeploymentConfigLabel = "openshift.io/deployment-config.name"
+
+// AppsOpenShiftV1Graph is used to graph all OpenShift apps resources.
+type AppsOpenShiftV1Graph struct {
+	graph *Graph
+}
+
+// NewAppsOpenShiftV1Graph creates a new AppsOpenShiftV1Graph.
+func NewAppsOpenShiftV1Graph(g *Graph) *AppsOpenShiftV1Graph {
+	return &AppsOpenShiftV1Graph{
+		graph: g,
+	}
+}
+
+// AppsOpenShiftV1 retrieves the AppsOpenShiftV1Graph.
+func (g *Graph) AppsOpenShiftV1() *AppsOpenShiftV1Graph {
+	return g.appsOpenShiftV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *AppsOpenShiftV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "DeploymentConfig":
+		obj := &v1.DeploymentConfig{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.DeploymentConfig(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// DeploymentConfig adds a v1.DeploymentConfig resource, the images of its
+// image change triggers and its ReplicationControllers to the Graph.
+func (g *AppsOpenShiftV1Graph) DeploymentConfig(obj *v1.DeploymentConfig) (*Node, error) {
+	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	replicas(n, &obj.Spec.Replicas, obj.Status.ReadyReplicas, obj.Status.AvailableReplicas)
+	n.Attribute("latestVersion", strconv.FormatInt(obj.Status.LatestVersion, 10))
+	if obj.Spec.Template != nil {
+		if err := g.graph.CoreV1().Images(n, obj.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		hostAccess(n, obj.Spec.Template.Spec)
+	}
+
+	for _, trigger := range obj.Spec.Triggers {
+		if trigger.Type != v1.DeploymentTriggerOnImageChange || trigger.ImageChangeParams == nil {
+			continue
+		}
+		params := trigger.ImageChangeParams
+		r, err := g.graph.ImageV1().Reference(n, "ImageChange", params.From, obj.GetNamespace())
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			continue
+		}
+		r.Attribute("automatic", strconv.FormatBool(params.Automatic))
+		if len(params.ContainerNames) != 0 {
+			r.Attribute("containers", strings.Join(params.ContainerNames, ","))
+		}
+	}
+
+	options := metav1.ListOptions{LabelSelector: DeploymentConfigLabel + "=" + obj.GetName()}
+	controllers, err := g.graph.clientset.CoreV1().ReplicationControllers(obj.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range controllers.Items {
+		rc, err := g.graph.CoreV1().ReplicationController(&controllers.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "ReplicationController", rc)
+	}
+
+	return n, nil
+}
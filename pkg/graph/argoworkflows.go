@@ -0,0 +1,320 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// argoV1alpha1 is the group version of the Argo Workflows resources which are read from the cluster.
+var argoV1alpha1 = schema.GroupVersion{Group: "argoproj.io", Version: "v1alpha1"}
+
+const (
+	// ArgoWorkflowLabel is the label of a Pod with the name of its Workflow.
+	ArgoWorkflowLabel = "workflows.argoproj.io/workflow"
+	// ArgoNodeIDAnnotation is the annotation of a Pod with the ID of its node in the Workflow status.
+	ArgoNodeIDAnnotation = "workflows.argoproj.io/node-id"
+)
+
+// ArgoWorkflowsGraph is used to graph all Argo Workflows resources.
+type ArgoWorkflowsGraph struct {
+	graph *Graph
+
+	// templates contains the WorkflowTemplates and ClusterWorkflowTemplates
+	// by kind, namespace and name, because they are referenced by many
+	// Workflows and CronWorkflows.
+	templates map[string]*Node
+}
+
+// NewArgoWorkflowsGraph creates a new ArgoWorkflowsGraph.
+func NewArgoWorkflowsGraph(g *Graph) *ArgoWorkflowsGraph {
+	return &ArgoWorkflowsGraph{
+		graph:     g,
+		templates: make(map[string]*Node),
+	}
+}
+
+// ArgoWorkflows retrieves the ArgoWorkflowsGraph.
+func (g *Graph) ArgoWorkflows() *ArgoWorkflowsGraph {
+	return g.argoWorkflows
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *ArgoWorkflowsGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Workflow":
+		return g.Workflow(unstr)
+	case "WorkflowTemplate", "ClusterWorkflowTemplate":
+		return g.WorkflowTemplate(unstr)
+	case "CronWorkflow":
+		return g.CronWorkflow(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Workflow adds a Workflow resource, its templates and the Pods of its
+// steps and DAG tasks to the Graph. The relationships to the Pods have the
+// name of the step or task, its template and its phase as attributes.
+func (g *ArgoWorkflowsGraph) Workflow(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	argoPhase(n, unstr)
+
+	if err := g.workflowSpec(n, unstr.GetNamespace(), unstr.Object, "spec"); err != nil {
+		return nil, err
+	}
+
+	options := metav1.ListOptions{LabelSelector: ArgoWorkflowLabel + "=" + unstr.GetName()}
+	pods, err := g.graph.clientset.CoreV1().Pods(unstr.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, _, _ := unstructured.NestedMap(unstr.Object, "status", "nodes")
+	for i := range pods.Items {
+		p, err := g.graph.CoreV1().Pod(&pods.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(n, "Pod", p)
+
+		status, ok := nodes[pods.Items[i].GetAnnotations()[ArgoNodeIDAnnotation]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, field := range map[string]string{"step": "displayName", "template": "templateName", "phase": "phase"} {
+			if value, ok := status[field].(string); ok && len(value) != 0 {
+				r.Attribute(key, value)
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// WorkflowTemplate adds a WorkflowTemplate or ClusterWorkflowTemplate
+// resource and the templates referenced by its steps and DAG tasks to the Graph.
+func (g *ArgoWorkflowsGraph) WorkflowTemplate(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetKind(), unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.templates[key]; ok {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.templates[key] = n
+
+	if entrypoint, ok, _ := unstructured.NestedString(unstr.Object, "spec", "entrypoint"); ok {
+		n.Attribute("entrypoint", entrypoint)
+	}
+	templates, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "templates")
+	n.Attribute("templates", strconv.Itoa(len(templates)))
+
+	if err := g.workflowSpec(n, unstr.GetNamespace(), unstr.Object, "spec"); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// CronWorkflow adds a CronWorkflow resource, the templates of its Workflow
+// spec and its active Workflows to the Graph.
+func (g *ArgoWorkflowsGraph) CronWorkflow(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	schedules, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "schedules")
+	if schedule, ok, _ := unstructured.NestedString(unstr.Object, "spec", "schedule"); ok {
+		schedules = append([]string{schedule}, schedules...)
+	}
+	if len(schedules) != 0 {
+		n.Attribute("schedule", strings.Join(schedules, ","))
+	}
+	if policy, ok, _ := unstructured.NestedString(unstr.Object, "spec", "concurrencyPolicy"); ok {
+		n.Attribute("concurrencyPolicy", policy)
+	}
+	suspend, _, _ := unstructured.NestedBool(unstr.Object, "spec", "suspend")
+	n.Attribute("suspend", strconv.FormatBool(suspend))
+	if last, ok, _ := unstructured.NestedString(unstr.Object, "status", "lastScheduledTime"); ok {
+		n.Attribute("lastScheduledTime", last)
+	}
+
+	if err := g.workflowSpec(n, unstr.GetNamespace(), unstr.Object, "spec", "workflowSpec"); err != nil {
+		return nil, err
+	}
+
+	active, _, _ := unstructured.NestedSlice(unstr.Object, "status", "active")
+	for _, ref := range active {
+		r, ok := ref.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(r, "name")
+		if len(name) == 0 {
+			continue
+		}
+		obj, err := g.graph.CustomResource(argoV1alpha1.WithResource("workflows"), unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		// the active Workflow may already be completed and deleted
+		if obj == nil {
+			continue
+		}
+		w, err := g.Workflow(obj)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Workflow", w).Attribute("active", "true")
+	}
+
+	return n, nil
+}
+
+// WorkflowTemplateName adds the WorkflowTemplate or ClusterWorkflowTemplate
+// with the given name to the Graph. A missing template is added as node
+// without UID from the cluster.
+func (g *ArgoWorkflowsGraph) WorkflowTemplateName(kind string, namespace string, name string) (*Node, error) {
+	resource := "workflowtemplates"
+	if kind == "ClusterWorkflowTemplate" {
+		namespace, resource = "", "clusterworkflowtemplates"
+	}
+	key := path.Join(kind, namespace, name)
+	if n, ok := g.templates[key]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(argoV1alpha1.WithResource(resource), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.WorkflowTemplate(unstr)
+	}
+
+	n := g.graph.Node(
+		argoV1alpha1.WithKind(kind),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, kind, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.templates[key] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// workflowSpec adds the relationships from n to the template referenced by
+// the "workflowTemplateRef" of the Workflow spec at the given fields and to
+// the templates referenced by the "templateRef" of its steps and DAG tasks.
+func (g *ArgoWorkflowsGraph) workflowSpec(n *Node, namespace string, obj map[string]interface{}, fields ...string) error {
+	spec, _, _ := unstructured.NestedMap(obj, fields...)
+
+	if ref, ok, _ := unstructured.NestedMap(spec, "workflowTemplateRef"); ok {
+		if _, err := g.templateRef(n, namespace, ref); err != nil {
+			return err
+		}
+	}
+
+	templates, _, _ := unstructured.NestedSlice(spec, "templates")
+	for _, template := range templates {
+		t, ok := template.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// steps are a list of parallel steps, which are run one after another
+		steps := []interface{}{}
+		groups, _, _ := unstructured.NestedSlice(t, "steps")
+		for _, group := range groups {
+			if parallel, ok := group.([]interface{}); ok {
+				steps = append(steps, parallel...)
+			}
+		}
+		tasks, _, _ := unstructured.NestedSlice(t, "dag", "tasks")
+
+		for _, step := range append(steps, tasks...) {
+			s, ok := step.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			ref, ok, _ := unstructured.NestedMap(s, "templateRef")
+			if !ok {
+				continue
+			}
+			r, err := g.templateRef(n, namespace, ref)
+			if err != nil {
+				return err
+			}
+			if name, ok, _ := unstructured.NestedString(ref, "template"); ok && r != nil {
+				appendAttribute(r, "templates", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// templateRef adds a relationship from n to the WorkflowTemplate or
+// ClusterWorkflowTemplate which is referenced by ref.
+func (g *ArgoWorkflowsGraph) templateRef(n *Node, namespace string, ref map[string]interface{}) (*Relationship, error) {
+	name, _, _ := unstructured.NestedString(ref, "name")
+	if len(name) == 0 {
+		return nil, nil
+	}
+	kind := "WorkflowTemplate"
+	if clusterScope, _, _ := unstructured.NestedBool(ref, "clusterScope"); clusterScope {
+		kind = "ClusterWorkflowTemplate"
+	}
+
+	t, err := g.WorkflowTemplateName(kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.graph.Relationship(n, kind, t), nil
+}
+
+// argoPhase adds the status of a Workflow by its phase and its progress,
+// message and start and finish time as attributes.
+func argoPhase(n *Node, unstr *unstructured.Unstructured) {
+	phase, ok, _ := unstructured.NestedString(unstr.Object, "status", "phase")
+	if !ok || len(phase) == 0 {
+		return
+	}
+
+	n.Attribute("phase", phase)
+	switch phase {
+	case "Succeeded":
+		n.Attribute("status", JobComplete)
+	case "Failed", "Error":
+		n.Attribute("status", JobFailed)
+	default:
+		n.Attribute("status", JobRunning)
+	}
+
+	for _, field := range []string{"progress", "message", "startedAt", "finishedAt"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "status", field); ok && len(value) != 0 {
+			n.Attribute(field, value)
+		}
+	}
+}
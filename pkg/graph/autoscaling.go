@@ -0,0 +1,217 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AutoscalingV2Graph is used to graph all autoscaling resources.
+type AutoscalingV2Graph struct {
+	graph *Graph
+}
+
+// NewAutoscalingV2Graph creates a new AutoscalingV2Graph.
+func NewAutoscalingV2Graph(g *Graph) *AutoscalingV2Graph {
+	return &AutoscalingV2Graph{
+		graph: g,
+	}
+}
+
+// AutoscalingV2 retrieves the AutoscalingV2Graph.
+func (g *Graph) AutoscalingV2() *AutoscalingV2Graph {
+	return g.autoscalingV2
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *AutoscalingV2Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "HorizontalPodAutoscaler":
+		obj := &v2.HorizontalPodAutoscaler{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.HorizontalPodAutoscaler(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// HorizontalPodAutoscaler adds a v2.HorizontalPodAutoscaler resource, its
+// scale target and its custom and external metrics to the Graph.
+func (g *AutoscalingV2Graph) HorizontalPodAutoscaler(obj *v2.HorizontalPodAutoscaler) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v2.SchemeGroupVersion.String(), "HorizontalPodAutoscaler"), obj)
+	if obj.Spec.MinReplicas != nil {
+		n.Attribute("minReplicas", strconv.Itoa(int(*obj.Spec.MinReplicas)))
+	}
+	n.Attribute("maxReplicas", strconv.Itoa(int(obj.Spec.MaxReplicas)))
+	n.Attribute("currentReplicas", strconv.Itoa(int(obj.Status.CurrentReplicas)))
+	n.Attribute("desiredReplicas", strconv.Itoa(int(obj.Status.DesiredReplicas)))
+
+	t, err := g.ScaleTarget(obj.Spec.ScaleTargetRef, obj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	r := g.graph.Relationship(n, t.Kind, t)
+	r.Attribute("currentReplicas", strconv.Itoa(int(obj.Status.CurrentReplicas)))
+	r.Attribute("desiredReplicas", strconv.Itoa(int(obj.Status.DesiredReplicas)))
+
+	for _, metric := range obj.Spec.Metrics {
+		if err := g.metric(n, metric, obj.GetNamespace()); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// ScaleTarget adds the object referenced by a v2.CrossVersionObjectReference to the Graph.
+// Deployments, StatefulSets, ReplicaSets and ReplicationControllers are resolved
+// from the cluster, all other or missing objects are added as node without UID
+// from the cluster.
+func (g *AutoscalingV2Graph) ScaleTarget(ref v2.CrossVersionObjectReference, namespace string) (*Node, error) {
+	options := metav1.GetOptions{}
+	missing := false
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err == nil && gv.Group == corev1.GroupName && ref.Kind == "ReplicationController" {
+		rc, err := g.graph.clientset.CoreV1().ReplicationControllers(namespace).Get(context.TODO(), ref.Name, options)
+		if err == nil {
+			return g.graph.CoreV1().ReplicationController(rc)
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		missing = true
+	}
+	if err == nil && gv.Group == appsv1.GroupName {
+		switch ref.Kind {
+		case "Deployment":
+			deployment, err := g.graph.clientset.AppsV1().Deployments(namespace).Get(context.TODO(), ref.Name, options)
+			if err == nil {
+				return g.graph.AppsV1().Deployment(deployment)
+			}
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			missing = true
+		case "StatefulSet":
+			statefulSet, err := g.graph.clientset.AppsV1().StatefulSets(namespace).Get(context.TODO(), ref.Name, options)
+			if err == nil {
+				return g.graph.AppsV1().StatefulSet(statefulSet)
+			}
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			missing = true
+		case "ReplicaSet":
+			replicaSet, err := g.graph.clientset.AppsV1().ReplicaSets(namespace).Get(context.TODO(), ref.Name, options)
+			if err == nil {
+				return g.graph.AppsV1().ReplicaSet(replicaSet)
+			}
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			missing = true
+		}
+	}
+
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, ref.Kind, ref.Name),
+			Name:      ref.Name,
+			Namespace: namespace,
+		},
+	)
+	if missing {
+		g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+	}
+
+	return n, nil
+}
+
+// metric adds the source of a custom or external metric and the relationship
+// to it to the Graph. Resource metrics of the Pods themselves are skipped.
+func (g *AutoscalingV2Graph) metric(n *Node, metric v2.MetricSpec, namespace string) error {
+	var (
+		m      *Node
+		name   string
+		target v2.MetricTarget
+	)
+
+	switch {
+	case metric.Type == v2.ObjectMetricSourceType && metric.Object != nil:
+		name, target = metric.Object.Metric.Name, metric.Object.Target
+
+		o, err := g.ScaleTarget(metric.Object.DescribedObject, namespace)
+		if err != nil {
+			return err
+		}
+		m = o
+	case metric.Type == v2.PodsMetricSourceType && metric.Pods != nil:
+		name, target = metric.Pods.Metric.Name, metric.Pods.Target
+		m = g.MetricSource("CustomMetric", name, namespace)
+	case metric.Type == v2.ExternalMetricSourceType && metric.External != nil:
+		name, target = metric.External.Metric.Name, metric.External.Target
+		m = g.MetricSource("ExternalMetric", name, namespace)
+	default:
+		return nil
+	}
+
+	r := g.graph.Relationship(n, "Metric", m)
+	r.Attribute("type", string(metric.Type))
+	r.Attribute("metric", name)
+	if value := metricTarget(target); len(value) != 0 {
+		r.Attribute("target", value)
+	}
+
+	return nil
+}
+
+// MetricSource adds a node which represents a custom or external metric to the Graph.
+func (g *AutoscalingV2Graph) MetricSource(kind string, name string, namespace string) *Node {
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", kind),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, kind, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+}
+
+// metricTarget returns the target value of a metric as string.
+func metricTarget(target v2.MetricTarget) string {
+	switch {
+	case target.Value != nil:
+		return target.Value.String()
+	case target.AverageValue != nil:
+		return target.AverageValue.String()
+	case target.AverageUtilization != nil:
+		return strconv.Itoa(int(*target.AverageUtilization)) + "%"
+	}
+
+	return ""
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"strconv"
+
+	v2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// AutoscalingK8sV1Graph is used to graph all autoscaling.k8s.io resources.
+type AutoscalingK8sV1Graph struct {
+	graph *Graph
+}
+
+// NewAutoscalingK8sV1Graph creates a new AutoscalingK8sV1Graph.
+func NewAutoscalingK8sV1Graph(g *Graph) *AutoscalingK8sV1Graph {
+	return &AutoscalingK8sV1Graph{
+		graph: g,
+	}
+}
+
+// AutoscalingK8sV1 retrieves the AutoscalingK8sV1Graph.
+func (g *Graph) AutoscalingK8sV1() *AutoscalingK8sV1Graph {
+	return g.autoscalingK8sV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *AutoscalingK8sV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "VerticalPodAutoscaler":
+		return g.VerticalPodAutoscaler(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// VerticalPodAutoscaler adds a VerticalPodAutoscaler resource and its target to the Graph.
+func (g *AutoscalingK8sV1Graph) VerticalPodAutoscaler(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	// the update mode defaults to Auto, if the update policy is omitted
+	mode, _, _ := unstructured.NestedString(unstr.Object, "spec", "updatePolicy", "updateMode")
+	if len(mode) == 0 {
+		mode = "Auto"
+	}
+	n.Attribute("updateMode", mode)
+
+	recommendations, _, _ := unstructured.NestedSlice(unstr.Object, "status", "recommendation", "containerRecommendations")
+	n.Attribute("recommendation", strconv.FormatBool(len(recommendations) != 0))
+
+	ref, ok, _ := unstructured.NestedStringMap(unstr.Object, "spec", "targetRef")
+	if !ok {
+		return n, nil
+	}
+
+	t, err := g.graph.AutoscalingV2().ScaleTarget(
+		v2.CrossVersionObjectReference{
+			APIVersion: ref["apiVersion"],
+			Kind:       ref["kind"],
+			Name:       ref["name"],
+		},
+		unstr.GetNamespace(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(n, t.Kind, t).Attribute("updateMode", mode)
+
+	return n, nil
+}
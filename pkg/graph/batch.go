@@ -0,0 +1,314 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Completion status values of the "status" attribute of Jobs.
+const (
+	JobComplete  = "Complete"
+	JobFailed    = "Failed"
+	JobRunning   = "Running"
+	JobSuspended = "Suspended"
+)
+
+// BatchV1Graph is used to graph all batch resources.
+type BatchV1Graph struct {
+	graph *Graph
+
+	// jobs contains all Jobs per namespace, they are listed once to find the history of CronJobs.
+	jobs map[string][]v1.Job
+	// stale contains the Jobs which are collapsed into the history of their CronJob.
+	stale map[types.UID]bool
+	// expanded contains the Jobs whose Pods are already listed.
+	expanded map[types.UID]bool
+}
+
+// NewBatchV1Graph creates a new BatchV1Graph.
+func NewBatchV1Graph(g *Graph) *BatchV1Graph {
+	return &BatchV1Graph{
+		graph:    g,
+		jobs:     make(map[string][]v1.Job),
+		stale:    make(map[types.UID]bool),
+		expanded: make(map[types.UID]bool),
+	}
+}
+
+// BatchV1 retrieves the BatchV1Graph.
+func (g *Graph) BatchV1() *BatchV1Graph {
+	return g.batchV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *BatchV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "CronJob":
+		obj := &v1.CronJob{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.CronJob(obj)
+	case "Job":
+		obj := &v1.Job{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.Job(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// CronJob adds a v1.CronJob resource and its most recent Jobs to the Graph.
+// All older Jobs are collapsed into a single JobHistory node.
+func (g *BatchV1Graph) CronJob(obj *v1.CronJob) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "CronJob"), obj)
+	n.Attribute("schedule", obj.Spec.Schedule)
+	if obj.Spec.Suspend != nil && *obj.Spec.Suspend {
+		n.Attribute("status", JobSuspended)
+	}
+	if err := g.graph.CoreV1().Images(n, obj.Spec.JobTemplate.Spec.Template.Spec); err != nil {
+		return nil, err
+	}
+	hostAccess(n, obj.Spec.JobTemplate.Spec.Template.Spec)
+
+	jobs, stale, err := g.history(obj.GetNamespace(), obj.GetUID())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		j, err := g.Job(&jobs[i])
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(n, "Job", j)
+		r.Attribute("schedule", obj.Spec.Schedule)
+		r.Attribute("status", jobStatus(&jobs[i]))
+	}
+
+	if len(stale) != 0 {
+		h := g.JobHistory(obj, stale)
+		g.graph.Relationship(n, "JobHistory", h).Attribute("schedule", obj.Spec.Schedule)
+	}
+
+	return n, nil
+}
+
+// Job adds a v1.Job resource and its Pods to the Graph. A Job which is
+// collapsed into the history of its CronJob is not added.
+func (g *BatchV1Graph) Job(obj *v1.Job) (*Node, error) {
+	owner := metav1.GetControllerOf(obj)
+	if owner != nil && owner.Kind == "CronJob" {
+		if _, _, err := g.history(obj.GetNamespace(), owner.UID); err != nil {
+			return nil, err
+		}
+		if g.stale[obj.GetUID()] {
+			return nil, nil
+		}
+	}
+
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "Job"), obj)
+	n.Attribute("status", jobStatus(obj))
+	n.Attribute("succeeded", strconv.Itoa(int(obj.Status.Succeeded)))
+	n.Attribute("failed", strconv.Itoa(int(obj.Status.Failed)))
+	if obj.Spec.Completions != nil {
+		n.Attribute("completions", strconv.Itoa(int(*obj.Spec.Completions)))
+	}
+
+	if owner != nil && owner.Kind == "CronJob" {
+		c := g.graph.Node(
+			schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind),
+			&metav1.ObjectMeta{
+				UID:       owner.UID,
+				Name:      owner.Name,
+				Namespace: obj.GetNamespace(),
+			},
+		)
+		g.graph.Relationship(c, "Job", n).Attribute("status", jobStatus(obj))
+	} else {
+		// the images of scheduled Jobs are referenced by their CronJob
+		if err := g.graph.CoreV1().Images(n, obj.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		hostAccess(n, obj.Spec.Template.Spec)
+	}
+
+	if g.expanded[obj.GetUID()] || obj.Spec.Selector == nil {
+		return n, nil
+	}
+	g.expanded[obj.GetUID()] = true
+
+	selector, err := metav1.LabelSelectorAsSelector(obj.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of job %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+	pods, err := g.graph.clientset.CoreV1().Pods(obj.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pods.Items {
+		p := g.graph.Node(schema.FromAPIVersionAndKind(corev1.GroupName, "Pod"), &pods.Items[i])
+		g.graph.Relationship(n, "Pod", p).Attribute("status", string(pods.Items[i].Status.Phase))
+	}
+
+	return n, nil
+}
+
+// JobHistory adds a node which represents the collapsed Jobs of a v1.CronJob to the Graph.
+func (g *BatchV1Graph) JobHistory(obj *v1.CronJob, jobs []v1.Job) *Node {
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "JobHistory"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(obj.GetUID(), "JobHistory"),
+			Name:      fmt.Sprintf("%d jobs", len(jobs)),
+			Namespace: obj.GetNamespace(),
+		},
+	)
+
+	counts := make(map[string]int)
+	for i := range jobs {
+		counts[jobStatus(&jobs[i])]++
+	}
+
+	n.Attribute("jobs", strconv.Itoa(len(jobs)))
+	for _, status := range []string{JobComplete, JobFailed, JobRunning, JobSuspended} {
+		n.Attribute(strings.ToLower(status), strconv.Itoa(counts[status]))
+	}
+
+	return n
+}
+
+// Collapse removes the collapsed Jobs from the Graph, which were added by
+// the owner references of their Pods, together with all nodes which are
+// only reachable from these Jobs.
+func (g *BatchV1Graph) Collapse() {
+	removed := make(map[types.UID]bool)
+	queue := []types.UID{}
+	for uid := range g.stale {
+		if _, ok := g.graph.Nodes[uid]; ok {
+			removed[uid] = true
+			queue = append(queue, uid)
+		}
+	}
+	if len(queue) == 0 {
+		return
+	}
+
+	children := make(map[types.UID][]types.UID)
+	for _, relationship := range g.graph.relationships() {
+		children[relationship.From] = append(children[relationship.From], relationship.To)
+	}
+
+	for len(queue) != 0 {
+		uid := queue[0]
+		queue = queue[1:]
+
+		for _, child := range children[uid] {
+			if removed[child] || !g.onlyFrom(child, removed) {
+				continue
+			}
+			removed[child] = true
+			queue = append(queue, child)
+		}
+	}
+
+	g.graph.Remove(removed)
+}
+
+// onlyFrom returns true if all incoming relationships of the node are from the given nodes.
+func (g *BatchV1Graph) onlyFrom(uid types.UID, from map[types.UID]bool) bool {
+	for _, relationship := range g.graph.Relationships[uid] {
+		if !from[relationship.From] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// history returns the most recent Jobs of a CronJob and the older Jobs which
+// exceed Options.JobHistoryLimit, both ordered from newest to oldest.
+func (g *BatchV1Graph) history(namespace string, uid types.UID) ([]v1.Job, []v1.Job, error) {
+	jobs, ok := g.jobs[namespace]
+	if !ok {
+		list, err := g.graph.clientset.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		jobs = list.Items
+		g.jobs[namespace] = jobs
+	}
+
+	owned := []v1.Job{}
+	for i := range jobs {
+		if owner := metav1.GetControllerOf(&jobs[i]); owner != nil && owner.UID == uid {
+			owned = append(owned, jobs[i])
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		a, b := owned[i].GetCreationTimestamp(), owned[j].GetCreationTimestamp()
+		if !a.Equal(&b) {
+			return b.Before(&a)
+		}
+		return owned[i].GetName() > owned[j].GetName()
+	})
+
+	limit := g.graph.Options.JobHistoryLimit
+	if limit <= 0 || len(owned) <= limit {
+		return owned, nil, nil
+	}
+
+	for i := range owned[limit:] {
+		g.stale[owned[limit+i].GetUID()] = true
+	}
+
+	return owned[:limit], owned[limit:], nil
+}
+
+// jobStatus returns the completion status of a v1.Job.
+func jobStatus(obj *v1.Job) string {
+	for _, condition := range obj.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case v1.JobComplete:
+			return JobComplete
+		case v1.JobFailed:
+			return JobFailed
+		case v1.JobSuspended:
+			return JobSuspended
+		}
+	}
+
+	return JobRunning
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// BloomPerspective represents a Neo4j Bloom perspective which matches the
+// node labels and relationship types of the cypher output format.
+type BloomPerspective struct {
+	Name                    string                   `json:"name"`
+	ID                      string                   `json:"id"`
+	Categories              []*BloomCategory         `json:"categories"`
+	CategoryIndex           int                      `json:"categoryIndex"`
+	RelationshipTypes       []*BloomRelationshipType `json:"relationshipTypes"`
+	HiddenRelationshipTypes []string                 `json:"hiddenRelationshipTypes"`
+	HiddenCategories        []int                    `json:"hiddenCategories"`
+	HideUncategorisedData   bool                     `json:"hideUncategorisedData"`
+	Palette                 *BloomPalette            `json:"palette"`
+	Templates               []interface{}            `json:"templates"`
+	Version                 string                   `json:"version"`
+}
+
+// BloomCategory represents the style of a node label.
+type BloomCategory struct {
+	ID           int              `json:"id"`
+	Name         string           `json:"name"`
+	Color        string           `json:"color"`
+	Size         int              `json:"size"`
+	Icon         string           `json:"icon"`
+	Labels       []string         `json:"labels"`
+	Properties   []*BloomProperty `json:"properties"`
+	HiddenLabels []string         `json:"hiddenLabels"`
+	Caption      []string         `json:"caption"`
+	TextSize     int              `json:"textSize"`
+	TextAlign    string           `json:"textAlign"`
+}
+
+// BloomProperty represents a node property of a category.
+type BloomProperty struct {
+	Name      string `json:"name"`
+	Exclude   bool   `json:"exclude"`
+	IsCaption bool   `json:"isCaption"`
+	DataType  string `json:"dataType"`
+}
+
+// BloomRelationshipType represents the style of a relationship type.
+type BloomRelationshipType struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Size  int    `json:"size"`
+}
+
+// BloomPalette represents the colors of a perspective.
+type BloomPalette struct {
+	Colors       []string `json:"colors"`
+	CurrentIndex int      `json:"currentIndex"`
+}
+
+// Bloom returns a Neo4j Bloom perspective with one category per node kind
+// and one relationship type per relationship label.
+func (g *Graph) Bloom() *BloomPerspective {
+	perspective := &BloomPerspective{
+		Name:                    "kubectl-graph",
+		ID:                      "kubectl-graph",
+		Categories:              []*BloomCategory{},
+		RelationshipTypes:       []*BloomRelationshipType{},
+		HiddenRelationshipTypes: []string{},
+		HiddenCategories:        []int{},
+		Palette:                 &BloomPalette{Colors: []string{}},
+		Templates:               []interface{}{},
+		Version:                 "2.0",
+	}
+
+	for idx, kind := range g.Kinds() {
+		perspective.Categories = append(perspective.Categories, &BloomCategory{
+			ID:     idx + 1,
+			Name:   kind,
+			Color:  g.Options.Theme.Color(kind, g.hash),
+			Size:   1,
+			Icon:   "no-icon",
+			Labels: []string{kind},
+			Properties: []*BloomProperty{
+				{Name: "Name", IsCaption: true, DataType: "String"},
+				{Name: "Namespace", DataType: "String"},
+				{Name: "UID", DataType: "String"},
+			},
+			HiddenLabels: []string{"k8s"},
+			Caption:      []string{""},
+			TextSize:     1,
+			TextAlign:    "top",
+		})
+		perspective.Palette.Colors = append(perspective.Palette.Colors, g.Options.Theme.Color(kind, g.hash))
+	}
+	perspective.CategoryIndex = len(perspective.Categories)
+
+	labels := make(map[string]bool)
+	for _, relationship := range g.RelationshipList() {
+		labels[relationship.Label] = true
+	}
+	for _, label := range sortedKeys(labels) {
+		perspective.RelationshipTypes = append(perspective.RelationshipTypes, &BloomRelationshipType{
+			ID:    label,
+			Name:  label,
+			Color: "#9e9e9e",
+			Size:  1,
+		})
+	}
+
+	return perspective
+}
+
+// writeBloom writes the graph as Neo4j Bloom perspective to w.
+func (g *Graph) writeBloom(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(g.Bloom())
+}
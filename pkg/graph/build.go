@@ -0,0 +1,219 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	v1 "github.com/openshift/api/build/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// BuildConfigLabel is the label of a Build with the name of its BuildConfig.
+	BuildConfigLabel = "openshift.io/build-config.name"
+	// BuildPodNameAnnotation is the annotation of a Build with the name of its Pod.
+	BuildPodNameAnnotation = "openshift.io/build.pod-name"
+)
+
+// BuildV1Graph is used to graph all OpenShift build resources.
+type BuildV1Graph struct {
+	graph *Graph
+}
+
+// NewBuildV1Graph creates a new BuildV1Graph.
+func NewBuildV1Graph(g *Graph) *BuildV1Graph {
+	return &BuildV1Graph{
+		graph: g,
+	}
+}
+
+// BuildV1 retrieves the BuildV1Graph.
+func (g *Graph) BuildV1() *BuildV1Graph {
+	return g.buildV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *BuildV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "BuildConfig":
+		obj := &v1.BuildConfig{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.BuildConfig(obj)
+	case "Build":
+		obj := &v1.Build{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.Build(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// BuildConfig adds a v1.BuildConfig resource, its source, its input and
+// output images, the images of its image change triggers and its Builds to the Graph.
+func (g *BuildV1Graph) BuildConfig(obj *v1.BuildConfig) (*Node, error) {
+	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	n.Attribute("lastVersion", strconv.FormatInt(obj.Status.LastVersion, 10))
+	if len(obj.Spec.RunPolicy) != 0 {
+		n.Attribute("runPolicy", string(obj.Spec.RunPolicy))
+	}
+	if err := g.commonSpec(n, obj.GetNamespace(), obj.Spec.CommonSpec); err != nil {
+		return nil, err
+	}
+
+	triggers := []string{}
+	for _, trigger := range obj.Spec.Triggers {
+		triggers = append(triggers, string(trigger.Type))
+		if trigger.Type != v1.ImageChangeBuildTriggerType || trigger.ImageChange == nil {
+			continue
+		}
+
+		// an image change trigger without image uses the image of the strategy
+		from := trigger.ImageChange.From
+		if from == nil {
+			from = buildStrategyFrom(obj.Spec.Strategy)
+		}
+		if from == nil {
+			continue
+		}
+		if _, err := g.graph.ImageV1().Reference(n, "ImageChange", *from, obj.GetNamespace()); err != nil {
+			return nil, err
+		}
+	}
+	if len(triggers) != 0 {
+		n.Attribute("triggers", strings.Join(triggers, ","))
+	}
+
+	gvr := v1.SchemeGroupVersion.WithResource("builds")
+	builds, err := g.graph.CustomResources(gvr, obj.GetNamespace(), BuildConfigLabel+"="+obj.GetName())
+	if err != nil {
+		return nil, err
+	}
+	for i := range builds {
+		b, err := g.Unstructured(&builds[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Build", b)
+	}
+
+	return n, nil
+}
+
+// Build adds a v1.Build resource, its source, its input and output images
+// and its Pod to the Graph.
+func (g *BuildV1Graph) Build(obj *v1.Build) (*Node, error) {
+	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	n.Attribute("phase", string(obj.Status.Phase))
+	n.Attribute("status", buildStatus(obj.Status.Phase))
+	if obj.Status.StartTimestamp != nil {
+		n.Attribute("startTimestamp", obj.Status.StartTimestamp.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	if obj.Status.Duration != 0 {
+		n.Attribute("duration", obj.Status.Duration.String())
+	}
+	if len(obj.Status.OutputDockerImageReference) != 0 {
+		n.Attribute("outputDockerImageReference", obj.Status.OutputDockerImageReference)
+	}
+	if obj.Status.Output.To != nil && len(obj.Status.Output.To.ImageDigest) != 0 {
+		n.Attribute("imageDigest", obj.Status.Output.To.ImageDigest)
+	}
+	if err := g.commonSpec(n, obj.GetNamespace(), obj.Spec.CommonSpec); err != nil {
+		return nil, err
+	}
+
+	if name, ok := obj.GetAnnotations()[BuildPodNameAnnotation]; ok {
+		options := metav1.GetOptions{}
+		pod, err := g.graph.clientset.CoreV1().Pods(obj.GetNamespace()).Get(context.TODO(), name, options)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		// the Pod of a completed Build may be pruned
+		if err == nil {
+			p, err := g.graph.CoreV1().Pod(pod)
+			if err != nil {
+				return nil, err
+			}
+			g.graph.Relationship(n, "Pod", p)
+		}
+	}
+
+	return n, nil
+}
+
+// commonSpec adds the relationships from a BuildConfig or Build to the git
+// repository of its source, the image of its strategy and its output image.
+func (g *BuildV1Graph) commonSpec(n *Node, namespace string, spec v1.CommonSpec) error {
+	n.Attribute("strategy", string(spec.Strategy.Type))
+
+	if spec.Source.Git != nil && len(spec.Source.Git.URI) != 0 {
+		r, err := g.graph.CoreV1().URL(n, "Source", spec.Source.Git.URI)
+		if err != nil {
+			return err
+		}
+		if len(spec.Source.Git.Ref) != 0 {
+			r.Attribute("ref", spec.Source.Git.Ref)
+		}
+	}
+
+	if from := buildStrategyFrom(spec.Strategy); from != nil {
+		if _, err := g.graph.ImageV1().Reference(n, "From", *from, namespace); err != nil {
+			return err
+		}
+	}
+
+	if spec.Output.To != nil {
+		if _, err := g.graph.ImageV1().Reference(n, "Output", *spec.Output.To, namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildStrategyFrom returns the builder or base image of a v1.BuildStrategy or nil.
+func buildStrategyFrom(strategy v1.BuildStrategy) *corev1.ObjectReference {
+	switch {
+	case strategy.DockerStrategy != nil:
+		return strategy.DockerStrategy.From
+	case strategy.SourceStrategy != nil:
+		return &strategy.SourceStrategy.From
+	case strategy.CustomStrategy != nil:
+		return &strategy.CustomStrategy.From
+	}
+
+	return nil
+}
+
+// buildStatus returns the status of a Build by its phase.
+func buildStatus(phase v1.BuildPhase) string {
+	switch phase {
+	case v1.BuildPhaseComplete:
+		return JobComplete
+	case v1.BuildPhaseFailed, v1.BuildPhaseError, v1.BuildPhaseCancelled:
+		return JobFailed
+	}
+
+	return JobRunning
+}
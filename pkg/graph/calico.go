@@ -0,0 +1,402 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// calicoV1 is the group version of the Calico custom resources, which are
+// also served by the Calico API server as projectcalico.org/v3.
+var calicoV1 = schema.GroupVersion{Group: "crd.projectcalico.org", Version: "v1"}
+
+// CalicoGraph is used to graph all projectcalico.org resources.
+type CalicoGraph struct {
+	graph *Graph
+}
+
+// NewCalicoGraph creates a new CalicoGraph.
+func NewCalicoGraph(g *Graph) *CalicoGraph {
+	return &CalicoGraph{
+		graph: g,
+	}
+}
+
+// Calico retrieves the CalicoGraph.
+func (g *Graph) Calico() *CalicoGraph {
+	return g.calico
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *CalicoGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "NetworkPolicy", "GlobalNetworkPolicy":
+		return g.NetworkPolicy(unstr)
+	case "NetworkSet", "GlobalNetworkSet":
+		return g.NetworkSet(unstr), nil
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// NetworkPolicy adds a Calico NetworkPolicy or GlobalNetworkPolicy resource
+// to the Graph. Like a v1.NetworkPolicy, the traffic which is allowed by its
+// rules is added as ALLOWS_INGRESS_FROM and ALLOWS_EGRESS_TO relationships
+// from the workloads of the selected Pods to the workloads of the peers, which
+// may also be NetworkSets, CIDRs, domains and Services.
+func (g *CalicoGraph) NetworkPolicy(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	if order, ok, _ := unstructured.NestedFieldNoCopy(unstr.Object, "spec", "order"); ok {
+		n.Attribute("order", fmt.Sprint(order))
+	}
+	if tier, ok, _ := unstructured.NestedString(unstr.Object, "spec", "tier"); ok {
+		n.Attribute("tier", tier)
+	}
+
+	selector, _, _ := unstructured.NestedString(unstr.Object, "spec", "selector")
+	namespaceSelector, _, _ := unstructured.NestedString(unstr.Object, "spec", "namespaceSelector")
+	pods, err := g.endpoints(n, unstr.GetNamespace(), namespaceSelector, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	_, ingress, _ := unstructured.NestedSlice(unstr.Object, "spec", "ingress")
+	_, egress, _ := unstructured.NestedSlice(unstr.Object, "spec", "egress")
+
+	targets := []*Node{}
+	for i := range pods {
+		p, err := g.graph.CoreV1().Pod(&pods[i])
+		if err != nil {
+			return nil, err
+		}
+		if ingress {
+			g.graph.NetworkingV1().Relationship(p, v1.PolicyTypeIngress, n)
+		}
+		if egress {
+			g.graph.NetworkingV1().Relationship(p, v1.PolicyTypeEgress, n)
+		}
+
+		w, err := g.graph.CoreV1().PodWorkload(&pods[i])
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, w)
+	}
+
+	for _, field := range []string{"ingress", "egress"} {
+		policyType, label, peer := v1.PolicyTypeIngress, "ALLOWS_INGRESS_FROM", "source"
+		if field == "egress" {
+			policyType, label, peer = v1.PolicyTypeEgress, "ALLOWS_EGRESS_TO", "destination"
+		}
+
+		rules, _, _ := unstructured.NestedSlice(unstr.Object, "spec", field)
+		for _, rule := range rules {
+			r, ok := rule.(map[string]interface{})
+			if !ok || r["action"] != "Allow" {
+				continue
+			}
+
+			entity, _, _ := unstructured.NestedMap(r, peer)
+			peers, err := g.peers(n, unstr.GetNamespace(), policyType, entity)
+			if err != nil {
+				return nil, err
+			}
+
+			// the ports of the destination are the allowed ports in both directions
+			destination, _, _ := unstructured.NestedMap(r, "destination")
+			protocol, _, _ := unstructured.NestedFieldNoCopy(r, "protocol")
+			g.graph.NetworkingV1().Allows(n.GetName(), label, targets, peers, calicoPorts(protocol, destination))
+		}
+	}
+
+	return n, nil
+}
+
+// peers adds the peers of the source or destination of a rule to the Graph
+// and returns the workloads, NetworkSets, CIDRs, domains and Services of the
+// peers. An empty entity allows the traffic of all peers.
+func (g *CalicoGraph) peers(n *Node, namespace string, policyType v1.PolicyType, entity map[string]interface{}) ([]*Node, error) {
+	peers := []*Node{}
+	add := func(peer *Node) {
+		g.graph.NetworkingV1().Relationship(n, policyType, peer)
+		peers = append(peers, peer)
+	}
+
+	selector, hasSelector, _ := unstructured.NestedString(entity, "selector")
+	namespaceSelector, hasNamespaceSelector, _ := unstructured.NestedString(entity, "namespaceSelector")
+	if hasSelector || hasNamespaceSelector {
+		pods, err := g.endpoints(n, namespace, namespaceSelector, selector)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range pods {
+			p, err := g.graph.CoreV1().Pod(&pods[i])
+			if err != nil {
+				return nil, err
+			}
+			g.graph.NetworkingV1().Relationship(n, policyType, p)
+
+			w, err := g.graph.CoreV1().PodWorkload(&pods[i])
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, w)
+		}
+
+		sets, err := g.networkSets(namespace, namespaceSelector, selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, set := range sets {
+			add(set)
+		}
+	}
+
+	nets, _, _ := unstructured.NestedStringSlice(entity, "nets")
+	for _, cidr := range nets {
+		i, err := g.graph.NetworkingV1().IPBlock(cidr)
+		if err != nil {
+			return nil, err
+		}
+		add(i)
+	}
+
+	domains, _, _ := unstructured.NestedStringSlice(entity, "domains")
+	for _, domain := range domains {
+		h, err := g.graph.NetworkingV1().Host(domain)
+		if err != nil {
+			return nil, err
+		}
+		add(h)
+	}
+
+	if name, ok, _ := unstructured.NestedString(entity, "services", "name"); ok {
+		ns, _, _ := unstructured.NestedString(entity, "services", "namespace")
+		if len(ns) == 0 {
+			ns = namespace
+		}
+		s, err := g.graph.CoreV1().ServiceName(ns, name)
+		if err != nil {
+			return nil, err
+		}
+		add(s)
+	}
+
+	if len(peers) == 0 && !hasSelector && !hasNamespaceSelector {
+		add(g.graph.Cilium().Entity("all"))
+	}
+
+	return peers, nil
+}
+
+// endpoints returns the running Pods which are selected by the selectors of a
+// Calico policy. The endpoints of a namespaced policy are restricted to its
+// own namespace, unless a namespace selector is given.
+func (g *CalicoGraph) endpoints(n *Node, namespace string, namespaceSelector string, selector string) ([]corev1.Pod, error) {
+	podSelector, err := calicoSelector(selector)
+	if err != nil {
+		g.graph.Warn(WarningInvalid, n, "unsupported selector %q: %v", selector, err)
+		return nil, nil
+	}
+
+	var nsSelector labels.Selector
+	if strings.Contains(namespaceSelector, "global()") {
+		// global() only selects non-namespaced resources like GlobalNetworkSets
+		return nil, nil
+	}
+	if len(namespaceSelector) != 0 {
+		if nsSelector, err = calicoSelector(namespaceSelector); err != nil {
+			g.graph.Warn(WarningInvalid, n, "unsupported namespace selector %q: %v", namespaceSelector, err)
+			return nil, nil
+		}
+		namespace = ""
+	}
+
+	return g.graph.NetworkingV1().SelectedPods(namespace, nsSelector, podSelector)
+}
+
+// networkSets adds the NetworkSets and GlobalNetworkSets which are selected
+// by the selectors of a Calico policy to the Graph.
+func (g *CalicoGraph) networkSets(namespace string, namespaceSelector string, selector string) ([]*Node, error) {
+	// unsupported selectors are already reported by the selected endpoints
+	setSelector, err := calicoSelector(selector)
+	if err != nil {
+		return nil, nil
+	}
+
+	namespaces, resource := []string{namespace}, "networksets"
+	switch {
+	case strings.Contains(namespaceSelector, "global()"):
+		namespaces, resource = []string{""}, "globalnetworksets"
+	case len(namespaceSelector) != 0:
+		nsSelector, err := calicoSelector(namespaceSelector)
+		if err != nil {
+			return nil, nil
+		}
+		options := metav1.ListOptions{LabelSelector: nsSelector.String()}
+		list, err := g.graph.clientset.CoreV1().Namespaces().List(context.TODO(), options)
+		if err != nil {
+			return nil, err
+		}
+
+		namespaces = namespaces[:0]
+		for _, ns := range list.Items {
+			namespaces = append(namespaces, ns.GetName())
+		}
+	}
+
+	sets := []*Node{}
+	for _, ns := range namespaces {
+		list, err := g.graph.CustomResources(calicoV1.WithResource(resource), ns, setSelector.String())
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			sets = append(sets, g.NetworkSet(&list[i]))
+		}
+	}
+
+	// the selector of a GlobalNetworkPolicy also selects GlobalNetworkSets
+	if len(namespace) == 0 && len(namespaceSelector) == 0 {
+		list, err := g.graph.CustomResources(calicoV1.WithResource("globalnetworksets"), "", setSelector.String())
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			sets = append(sets, g.NetworkSet(&list[i]))
+		}
+	}
+
+	return sets, nil
+}
+
+// NetworkSet adds a NetworkSet or GlobalNetworkSet resource to the Graph.
+func (g *CalicoGraph) NetworkSet(unstr *unstructured.Unstructured) *Node {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	nets, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "nets")
+	if len(nets) != 0 {
+		n.Attribute("nets", strings.Join(nets, ","))
+	}
+	domains, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "allowedEgressDomains")
+	if len(domains) != 0 {
+		n.Attribute("allowedEgressDomains", strings.Join(domains, ","))
+	}
+
+	return n
+}
+
+// calicoSelector converts a Calico selector expression to a label selector.
+// Only conjunctions of the operators ==, !=, in, not in, has and !has and the
+// all() function are supported, which are used by most policies.
+func calicoSelector(expression string) (labels.Selector, error) {
+	selector := labels.NewSelector()
+
+	expression = strings.TrimSpace(expression)
+	if len(expression) == 0 || expression == "all()" {
+		return selector, nil
+	}
+	if strings.Contains(expression, "||") {
+		return nil, fmt.Errorf("disjunctions are not supported")
+	}
+
+	for _, term := range strings.Split(expression, "&&") {
+		term = strings.TrimSpace(term)
+		if term == "all()" || term == "global()" {
+			continue
+		}
+
+		key, op, values := "", selection.Exists, []string{}
+		switch {
+		case strings.HasPrefix(term, "!has(") && strings.HasSuffix(term, ")"):
+			key, op = term[5:len(term)-1], selection.DoesNotExist
+		case strings.HasPrefix(term, "has(") && strings.HasSuffix(term, ")"):
+			key = term[4 : len(term)-1]
+		default:
+			for _, candidate := range []struct {
+				token string
+				op    selection.Operator
+			}{
+				{" not in ", selection.NotIn},
+				{" in ", selection.In},
+				{"==", selection.Equals},
+				{"!=", selection.NotEquals},
+			} {
+				k, v, ok := strings.Cut(term, candidate.token)
+				if !ok {
+					continue
+				}
+				key, op = strings.TrimSpace(k), candidate.op
+				for _, value := range strings.Split(strings.Trim(strings.TrimSpace(v), "{}"), ",") {
+					values = append(values, strings.Trim(strings.TrimSpace(value), "'\"`"))
+				}
+				break
+			}
+			if len(key) == 0 {
+				return nil, fmt.Errorf("unsupported term %q", term)
+			}
+		}
+
+		// the name of a namespace is selected by a label of the Calico profile
+		key = strings.TrimSpace(key)
+		if key == "projectcalico.org/name" {
+			key = corev1.LabelMetadataName
+		}
+
+		requirement, err := labels.NewRequirement(key, op, values)
+		if err != nil {
+			return nil, err
+		}
+		selector = selector.Add(*requirement)
+	}
+
+	return selector, nil
+}
+
+// calicoPorts returns the protocol and the ports of the destination of a
+// rule, e.g. "TCP/80" or "TCP/8000-8080".
+func calicoPorts(protocol interface{}, destination map[string]interface{}) []string {
+	name := "ANY"
+	if protocol != nil {
+		name = fmt.Sprint(protocol)
+	}
+
+	ports := []string{}
+	list, _, _ := unstructured.NestedSlice(destination, "ports")
+	for _, port := range list {
+		value := fmt.Sprint(port)
+		if number, ok := port.(int64); ok {
+			value = strconv.FormatInt(number, 10)
+		}
+		ports = append(ports, name+"/"+strings.ReplaceAll(value, ":", "-"))
+	}
+	if len(ports) == 0 && protocol != nil {
+		ports = append(ports, name+"/*")
+	}
+
+	return ports
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestCalicoSelector(t *testing.T) {
+	tests := []struct {
+		expression string
+		matches    []labels.Set
+		misses     []labels.Set
+		err        bool
+	}{
+		{
+			expression: "",
+			matches:    []labels.Set{{}, {"app": "web"}},
+		},
+		{
+			expression: "all()",
+			matches:    []labels.Set{{}, {"app": "web"}},
+		},
+		{
+			expression: "app == 'web'",
+			matches:    []labels.Set{{"app": "web"}},
+			misses:     []labels.Set{{"app": "db"}, {}},
+		},
+		{
+			expression: "app != \"web\"",
+			matches:    []labels.Set{{"app": "db"}, {}},
+			misses:     []labels.Set{{"app": "web"}},
+		},
+		{
+			expression: "tier in {'frontend', 'backend'} && has(app)",
+			matches:    []labels.Set{{"tier": "frontend", "app": "web"}},
+			misses:     []labels.Set{{"tier": "frontend"}, {"tier": "cache", "app": "redis"}},
+		},
+		{
+			expression: "tier not in {'cache'} && !has(legacy)",
+			matches:    []labels.Set{{"tier": "frontend"}, {}},
+			misses:     []labels.Set{{"tier": "cache"}, {"legacy": "true"}},
+		},
+		{
+			expression: "projectcalico.org/name == 'kube-system'",
+			matches:    []labels.Set{{"kubernetes.io/metadata.name": "kube-system"}},
+			misses:     []labels.Set{{"kubernetes.io/metadata.name": "default"}},
+		},
+		{
+			expression: "global() && app == 'web'",
+			matches:    []labels.Set{{"app": "web"}},
+		},
+		{
+			expression: "app == 'web' || app == 'db'",
+			err:        true,
+		},
+		{
+			expression: "starts with(app, 'we')",
+			err:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		selector, err := calicoSelector(tt.expression)
+		if tt.err {
+			if err == nil {
+				t.Errorf("calicoSelector(%q) = %v, want error", tt.expression, selector)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("calicoSelector(%q) returned error: %v", tt.expression, err)
+			continue
+		}
+		for _, set := range tt.matches {
+			if !selector.Matches(set) {
+				t.Errorf("calicoSelector(%q) doesn't match %v", tt.expression, set)
+			}
+		}
+		for _, set := range tt.misses {
+			if selector.Matches(set) {
+				t.Errorf("calicoSelector(%q) matches %v", tt.expression, set)
+			}
+		}
+	}
+}
+
+func TestCalicoPorts(t *testing.T) {
+	tests := []struct {
+		name        string
+		protocol    interface{}
+		destination map[string]interface{}
+		want        []string
+	}{
+		{
+			name:        "any",
+			destination: map[string]interface{}{},
+			want:        []string{},
+		},
+		{
+			name:        "protocol only",
+			protocol:    "UDP",
+			destination: map[string]interface{}{},
+			want:        []string{"UDP/*"},
+		},
+		{
+			name:        "ports",
+			protocol:    "TCP",
+			destination: map[string]interface{}{"ports": []interface{}{int64(80), "8000:8080", "http"}},
+			want:        []string{"TCP/80", "TCP/8000-8080", "TCP/http"},
+		},
+		{
+			name:        "ports without protocol",
+			destination: map[string]interface{}{"ports": []interface{}{int64(53)}},
+			want:        []string{"ANY/53"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := calicoPorts(tt.protocol, tt.destination); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: calicoPorts() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// certManagerIssuerAnnotation requests a Certificate for the TLS hosts of an Ingress from an Issuer.
+	certManagerIssuerAnnotation = "cert-manager.io/issuer"
+	// certManagerClusterIssuerAnnotation requests a Certificate for the TLS hosts of an Ingress from a ClusterIssuer.
+	certManagerClusterIssuerAnnotation = "cert-manager.io/cluster-issuer"
+)
+
+var (
+	// certManagerV1 is the group version of the certificate resources.
+	certManagerV1 = schema.GroupVersion{Group: "cert-manager.io", Version: "v1"}
+	// acmeCertManagerV1 is the group version of the ACME resources.
+	acmeCertManagerV1 = schema.GroupVersion{Group: "acme.cert-manager.io", Version: "v1"}
+)
+
+// CertManagerGraph is used to graph all cert-manager.io resources.
+type CertManagerGraph struct {
+	graph *Graph
+
+	// issuers contains the nodes by kind, namespace and name, because they
+	// are referenced by many Certificates. A nil node was not found.
+	issuers map[string]*Node
+	// certificates contains the nodes by namespace and name. A nil node was not found.
+	certificates map[string]*Node
+	// lists contains the listed resources by resource and namespace, which
+	// are searched for the dependents of an owner.
+	lists map[string][]unstructured.Unstructured
+}
+
+// NewCertManagerGraph creates a new CertManagerGraph.
+func NewCertManagerGraph(g *Graph) *CertManagerGraph {
+	return &CertManagerGraph{
+		graph:        g,
+		issuers:      make(map[string]*Node),
+		certificates: make(map[string]*Node),
+		lists:        make(map[string][]unstructured.Unstructured),
+	}
+}
+
+// CertManager retrieves the CertManagerGraph.
+func (g *Graph) CertManager() *CertManagerGraph {
+	return g.certManager
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *CertManagerGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Certificate":
+		return g.Certificate(unstr)
+	case "CertificateRequest":
+		return g.CertificateRequest(unstr)
+	case "Issuer", "ClusterIssuer":
+		return g.Issuer(unstr)
+	case "Order":
+		return g.Order(unstr)
+	case "Challenge":
+		return g.Challenge(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Certificate adds a Certificate resource, its Issuer, its Secret and its
+// CertificateRequests to the Graph. The expiry of the certificate is added
+// as "notAfter" attribute.
+func (g *CertManagerGraph) Certificate(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.certificates[key]; ok && n != nil {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.certificates[key] = n
+	n.Attribute("status", readyStatus(unstr))
+	for _, field := range []string{"notAfter", "renewalTime"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "status", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+	if dnsNames, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "dnsNames"); len(dnsNames) != 0 {
+		n.Attribute("dnsNames", strings.Join(dnsNames, ","))
+	}
+
+	if err := g.issuerRef(n, unstr); err != nil {
+		return nil, err
+	}
+	if secret, ok, _ := unstructured.NestedString(unstr.Object, "spec", "secretName"); ok {
+		g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), secret))
+	}
+
+	requests, err := g.dependents(certManagerV1.WithResource("certificaterequests"), unstr.GetNamespace(), unstr.GetUID())
+	if err != nil {
+		return nil, err
+	}
+	for i := range requests {
+		r, err := g.CertificateRequest(&requests[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "CertificateRequest", r)
+	}
+
+	return n, nil
+}
+
+// CertificateName adds the Certificate with the given name to the Graph.
+// It returns nil if the Certificate does not exist.
+func (g *CertManagerGraph) CertificateName(namespace string, name string) (*Node, error) {
+	if n, ok := g.certificates[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(certManagerV1.WithResource("certificates"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr == nil {
+		g.certificates[path.Join(namespace, name)] = nil
+		return nil, nil
+	}
+
+	return g.Certificate(unstr)
+}
+
+// CertificateRequest adds a CertificateRequest resource, its Issuer and its
+// ACME Orders to the Graph.
+func (g *CertManagerGraph) CertificateRequest(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+
+	if err := g.issuerRef(n, unstr); err != nil {
+		return nil, err
+	}
+
+	orders, err := g.dependents(acmeCertManagerV1.WithResource("orders"), unstr.GetNamespace(), unstr.GetUID())
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		o, err := g.Order(&orders[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Order", o)
+	}
+
+	return n, nil
+}
+
+// Order adds an ACME Order resource and its Challenges to the Graph.
+func (g *CertManagerGraph) Order(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	if state, ok, _ := unstructured.NestedString(unstr.Object, "status", "state"); ok {
+		n.Attribute("status", state)
+	}
+
+	challenges, err := g.dependents(acmeCertManagerV1.WithResource("challenges"), unstr.GetNamespace(), unstr.GetUID())
+	if err != nil {
+		return nil, err
+	}
+	for i := range challenges {
+		c, err := g.Challenge(&challenges[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Challenge", c)
+	}
+
+	return n, nil
+}
+
+// Challenge adds an ACME Challenge resource to the Graph.
+func (g *CertManagerGraph) Challenge(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	for _, field := range []string{"type", "dnsName"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "spec", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+	if state, ok, _ := unstructured.NestedString(unstr.Object, "status", "state"); ok {
+		n.Attribute("status", state)
+	}
+
+	return n, nil
+}
+
+// Issuer adds an Issuer or ClusterIssuer resource and the Secret of its CA
+// or ACME account to the Graph. Secrets of a ClusterIssuer are in the
+// cluster resource namespace of cert-manager, which is not known.
+func (g *CertManagerGraph) Issuer(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.issuers[path.Join(unstr.GetKind(), unstr.GetNamespace(), unstr.GetName())] = n
+	n.Attribute("status", readyStatus(unstr))
+
+	spec, _, _ := unstructured.NestedMap(unstr.Object, "spec")
+	for _, issuerType := range []string{"acme", "ca", "selfSigned", "vault", "venafi"} {
+		if _, ok := spec[issuerType]; ok {
+			n.Attribute("type", issuerType)
+		}
+	}
+	if server, ok, _ := unstructured.NestedString(spec, "acme", "server"); ok {
+		n.Attribute("server", server)
+	}
+
+	secrets := []string{}
+	if secret, ok, _ := unstructured.NestedString(spec, "ca", "secretName"); ok {
+		secrets = append(secrets, secret)
+	}
+	if secret, ok, _ := unstructured.NestedString(spec, "acme", "privateKeySecretRef", "name"); ok {
+		secrets = append(secrets, secret)
+	}
+	if len(unstr.GetNamespace()) == 0 && len(secrets) != 0 {
+		n.Attribute("secretNames", strings.Join(secrets, ","))
+		return n, nil
+	}
+	for _, secret := range secrets {
+		g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), secret))
+	}
+
+	return n, nil
+}
+
+// IssuerName adds the Issuer or ClusterIssuer with the given name to the Graph.
+// A missing issuer is added as node without UID from the cluster.
+func (g *CertManagerGraph) IssuerName(kind string, namespace string, name string) (*Node, error) {
+	if kind == "ClusterIssuer" {
+		namespace = ""
+	}
+	if n, ok := g.issuers[path.Join(kind, namespace, name)]; ok {
+		return n, nil
+	}
+
+	gvr := certManagerV1.WithResource(strings.ToLower(kind) + "s")
+	unstr, err := g.graph.CustomResource(gvr, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Issuer(unstr)
+	}
+
+	n := g.graph.Node(
+		certManagerV1.WithKind(kind),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, kind, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.issuers[path.Join(kind, namespace, name)] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// issuerRef adds a relationship from n to the issuer of a Certificate or CertificateRequest.
+// Issuers of external groups are not read from the cluster.
+func (g *CertManagerGraph) issuerRef(n *Node, unstr *unstructured.Unstructured) error {
+	name, _, _ := unstructured.NestedString(unstr.Object, "spec", "issuerRef", "name")
+	kind, _, _ := unstructured.NestedString(unstr.Object, "spec", "issuerRef", "kind")
+	group, _, _ := unstructured.NestedString(unstr.Object, "spec", "issuerRef", "group")
+	if len(name) == 0 {
+		return nil
+	}
+	if len(kind) == 0 {
+		kind = "Issuer"
+	}
+
+	if len(group) != 0 && group != certManagerV1.Group {
+		i := g.graph.Node(
+			schema.GroupVersionKind{Group: group, Kind: kind},
+			&metav1.ObjectMeta{
+				UID:       g.graph.ToUID(unstr.GetNamespace(), kind, name),
+				Name:      name,
+				Namespace: unstr.GetNamespace(),
+			},
+		)
+		g.graph.Relationship(n, "Issuer", i)
+		return nil
+	}
+
+	i, err := g.IssuerName(kind, unstr.GetNamespace(), name)
+	if err != nil {
+		return err
+	}
+	g.graph.Relationship(n, "Issuer", i)
+
+	return nil
+}
+
+// IngressCertificates adds the issuer which is requested by the annotations
+// of an Ingress and the Certificates of its TLS Secrets to the Graph.
+func (g *CertManagerGraph) IngressCertificates(n *Node, namespace string, annotations map[string]string, secrets []string) error {
+	kind, name := "Issuer", annotations[certManagerIssuerAnnotation]
+	if issuer, ok := annotations[certManagerClusterIssuerAnnotation]; ok {
+		kind, name = "ClusterIssuer", issuer
+	}
+	if len(name) == 0 {
+		return nil
+	}
+
+	i, err := g.IssuerName(kind, namespace, name)
+	if err != nil {
+		return err
+	}
+	g.graph.Relationship(n, "Issuer", i)
+
+	// the Certificate has the name of the Secret
+	for _, secret := range secrets {
+		c, err := g.CertificateName(namespace, secret)
+		if err != nil {
+			return err
+		}
+		if c != nil {
+			g.graph.Relationship(n, "Certificate", c)
+		}
+	}
+
+	return nil
+}
+
+// dependents returns the listed resources in the namespace which are controlled by the owner.
+func (g *CertManagerGraph) dependents(gvr schema.GroupVersionResource, namespace string, owner types.UID) ([]unstructured.Unstructured, error) {
+	key := path.Join(gvr.String(), namespace)
+	list, ok := g.lists[key]
+	if !ok {
+		var err error
+		list, err = g.graph.CustomResources(gvr, namespace, "")
+		if err != nil {
+			return nil, err
+		}
+		g.lists[key] = list
+	}
+
+	dependents := []unstructured.Unstructured{}
+	for _, item := range list {
+		if ref := metav1.GetControllerOf(&item); ref != nil && ref.UID == owner {
+			dependents = append(dependents, item)
+		}
+	}
+
+	return dependents, nil
+}
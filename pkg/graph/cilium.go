@@ -0,0 +1,456 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ciliumNamespaceLabel selects the endpoints of a namespace by its name.
+	ciliumNamespaceLabel = "io.kubernetes.pod.namespace"
+	// ciliumNamespaceLabelsPrefix selects the endpoints of a namespace by its labels.
+	ciliumNamespaceLabelsPrefix = "io.cilium.k8s.namespace.labels."
+	// ciliumPolicyLabelsPrefix is used by labels which are derived from the
+	// endpoint, like its ServiceAccount or cluster, which can not be selected
+	// by the labels of the Pod.
+	ciliumPolicyLabelsPrefix = "io.cilium.k8s.policy."
+)
+
+// CiliumGraph is used to graph all cilium.io resources.
+type CiliumGraph struct {
+	graph *Graph
+}
+
+// NewCiliumGraph creates a new CiliumGraph.
+func NewCiliumGraph(g *Graph) *CiliumGraph {
+	return &CiliumGraph{
+		graph: g,
+	}
+}
+
+// Cilium retrieves the CiliumGraph.
+func (g *Graph) Cilium() *CiliumGraph {
+	return g.cilium
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *CiliumGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "CiliumNetworkPolicy", "CiliumClusterwideNetworkPolicy":
+		return g.NetworkPolicy(unstr)
+	case "CiliumEndpoint":
+		return g.Endpoint(unstr), nil
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// NetworkPolicy adds a CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy
+// resource to the Graph. Like a v1.NetworkPolicy, the allowed traffic is added
+// as ALLOWS_INGRESS_FROM and ALLOWS_EGRESS_TO relationships from the workloads
+// of the selected Pods or the selected Nodes to the workloads of the peers,
+// which may also be entities, CIDRs, FQDNs and Services.
+func (g *CiliumGraph) NetworkPolicy(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	rules := []interface{}{}
+	if spec, ok, _ := unstructured.NestedMap(unstr.Object, "spec"); ok {
+		rules = append(rules, spec)
+	}
+	specs, _, _ := unstructured.NestedSlice(unstr.Object, "specs")
+	rules = append(rules, specs...)
+
+	for _, rule := range rules {
+		r, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := g.rule(n, unstr.GetNamespace(), r); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// rule adds the allowed traffic of a single rule of a Cilium policy to the Graph.
+func (g *CiliumGraph) rule(n *Node, namespace string, rule map[string]interface{}) error {
+	_, ingress := rule["ingress"]
+	_, egress := rule["egress"]
+
+	targets := []*Node{}
+	if selector, ok, _ := unstructured.NestedMap(rule, "endpointSelector"); ok {
+		pods, err := g.endpoints(namespace, selector)
+		if err != nil {
+			return err
+		}
+
+		for i := range pods {
+			p, err := g.graph.CoreV1().Pod(&pods[i])
+			if err != nil {
+				return err
+			}
+			if ingress {
+				g.graph.NetworkingV1().Relationship(p, v1.PolicyTypeIngress, n)
+			}
+			if egress {
+				g.graph.NetworkingV1().Relationship(p, v1.PolicyTypeEgress, n)
+			}
+
+			w, err := g.graph.CoreV1().PodWorkload(&pods[i])
+			if err != nil {
+				return err
+			}
+			targets = append(targets, w)
+		}
+	}
+
+	// the host firewall selects the Nodes by their labels
+	selector, ok, err := NestedLabelSelector(rule, "nodeSelector")
+	if err != nil {
+		return err
+	}
+	if ok {
+		options := metav1.ListOptions{LabelSelector: selector.String()}
+		nodes, err := g.graph.clientset.CoreV1().Nodes().List(context.TODO(), options)
+		if err != nil {
+			return err
+		}
+
+		for i := range nodes.Items {
+			nodes.Items[i].SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+			node, err := g.graph.CoreV1().Node(&nodes.Items[i])
+			if err != nil {
+				return err
+			}
+			if ingress {
+				g.graph.NetworkingV1().Relationship(node, v1.PolicyTypeIngress, n)
+			}
+			if egress {
+				g.graph.NetworkingV1().Relationship(node, v1.PolicyTypeEgress, n)
+			}
+			targets = append(targets, node)
+		}
+	}
+
+	for _, field := range []string{"ingress", "egress"} {
+		policyType, label, prefix := v1.PolicyTypeIngress, "ALLOWS_INGRESS_FROM", "from"
+		if field == "egress" {
+			policyType, label, prefix = v1.PolicyTypeEgress, "ALLOWS_EGRESS_TO", "to"
+		}
+
+		list, _, _ := unstructured.NestedSlice(rule, field)
+		for _, item := range list {
+			r, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			peers, err := g.peers(n, namespace, policyType, prefix, r)
+			if err != nil {
+				return err
+			}
+			g.graph.NetworkingV1().Allows(n.GetName(), label, targets, peers, ciliumPorts(r))
+		}
+	}
+
+	return nil
+}
+
+// peers adds the peers of an ingress or egress rule to the Graph and returns
+// the workloads, entities, CIDRs, FQDNs and Services of the peers. A rule
+// without peers allows the traffic of all peers.
+func (g *CiliumGraph) peers(n *Node, namespace string, policyType v1.PolicyType, prefix string, rule map[string]interface{}) ([]*Node, error) {
+	peers := []*Node{}
+	add := func(peer *Node) {
+		g.graph.NetworkingV1().Relationship(n, policyType, peer)
+		peers = append(peers, peer)
+	}
+
+	selectors, _, _ := unstructured.NestedSlice(rule, prefix+"Endpoints")
+	for _, selector := range selectors {
+		s, ok := selector.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pods, err := g.endpoints(namespace, s)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range pods {
+			p, err := g.graph.CoreV1().Pod(&pods[i])
+			if err != nil {
+				return nil, err
+			}
+			g.graph.NetworkingV1().Relationship(n, policyType, p)
+
+			w, err := g.graph.CoreV1().PodWorkload(&pods[i])
+			if err != nil {
+				return nil, err
+			}
+			peers = append(peers, w)
+		}
+	}
+
+	entities, _, _ := unstructured.NestedStringSlice(rule, prefix+"Entities")
+	for _, entity := range entities {
+		add(g.Entity(entity))
+	}
+
+	cidrs, _, _ := unstructured.NestedStringSlice(rule, prefix+"CIDR")
+	cidrSets, _, _ := unstructured.NestedSlice(rule, prefix+"CIDRSet")
+	for _, cidrSet := range cidrSets {
+		c, ok := cidrSet.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cidr, ok, _ := unstructured.NestedString(c, "cidr"); ok {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	for _, cidr := range cidrs {
+		i, err := g.graph.NetworkingV1().IPBlock(cidr)
+		if err != nil {
+			return nil, err
+		}
+		add(i)
+	}
+
+	fqdns, _, _ := unstructured.NestedSlice(rule, prefix+"FQDNs")
+	for _, fqdn := range fqdns {
+		f, ok := fqdn.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"matchName", "matchPattern"} {
+			if name, ok, _ := unstructured.NestedString(f, field); ok {
+				h, err := g.graph.NetworkingV1().Host(name)
+				if err != nil {
+					return nil, err
+				}
+				add(h)
+			}
+		}
+	}
+
+	services, _, _ := unstructured.NestedSlice(rule, prefix+"Services")
+	for _, service := range services {
+		s, ok := service.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok, _ := unstructured.NestedString(s, "k8sService", "serviceName")
+		if !ok {
+			continue
+		}
+		ns, _, _ := unstructured.NestedString(s, "k8sService", "namespace")
+		if len(ns) == 0 {
+			ns = namespace
+		}
+
+		svc, err := g.graph.CoreV1().ServiceName(ns, name)
+		if err != nil {
+			return nil, err
+		}
+		add(svc)
+	}
+
+	if len(peers) == 0 && len(selectors) == 0 {
+		add(g.Entity("all"))
+	}
+
+	return peers, nil
+}
+
+// endpoints returns the running Pods which are selected by a Cilium endpoint
+// selector. The endpoints of a namespaced policy are restricted to its own
+// namespace, unless the selector contains a namespace label.
+func (g *CiliumGraph) endpoints(namespace string, selector map[string]interface{}) ([]corev1.Pod, error) {
+	labelSelector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selector, labelSelector); err != nil {
+		return nil, err
+	}
+
+	namespaceSelector, podSelector := ciliumSelector(labelSelector)
+	nsSelector, err := metav1.LabelSelectorAsSelector(namespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := metav1.LabelSelectorAsSelector(podSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !nsSelector.Empty():
+		namespace = ""
+	case len(namespace) == 0:
+		nsSelector = nil
+	}
+
+	return g.graph.NetworkingV1().SelectedPods(namespace, nsSelector, selected)
+}
+
+// Entity adds a node which represents a Cilium entity like "host" or
+// "cluster" to the Graph. The "world" entity is the Internet.
+func (g *CiliumGraph) Entity(name string) *Node {
+	if name == "world" {
+		return g.graph.CoreV1().Internet()
+	}
+
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Entity"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID("Entity", name),
+			Name: name,
+		},
+	)
+}
+
+// Endpoint adds a CiliumEndpoint resource to the Graph. The Pod of the
+// endpoint is its owner, its security identity, addresses and policy
+// enforcement are added as attributes.
+func (g *CiliumGraph) Endpoint(unstr *unstructured.Unstructured) *Node {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	if state, ok, _ := unstructured.NestedString(unstr.Object, "status", "state"); ok {
+		n.Attribute("state", state)
+	}
+	if id, ok, _ := unstructured.NestedInt64(unstr.Object, "status", "identity", "id"); ok {
+		n.Attribute("identity", strconv.FormatInt(id, 10))
+	}
+
+	ips := []string{}
+	addressing, _, _ := unstructured.NestedSlice(unstr.Object, "status", "networking", "addressing")
+	for _, address := range addressing {
+		a, ok := address.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"ipv4", "ipv6"} {
+			if ip, ok, _ := unstructured.NestedString(a, field); ok {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	if len(ips) != 0 {
+		n.Attribute("ips", strings.Join(ips, ","))
+	}
+
+	enforcing := []string{}
+	for _, direction := range []string{"ingress", "egress"} {
+		if ok, _, _ := unstructured.NestedBool(unstr.Object, "status", "policy", direction, "enforcing"); ok {
+			enforcing = append(enforcing, direction)
+		}
+	}
+	if len(enforcing) != 0 {
+		n.Attribute("policyEnforcement", strings.Join(enforcing, ","))
+	}
+
+	return n
+}
+
+// ciliumSelector splits a Cilium endpoint selector into the selector of the
+// namespaces and the selector of the Pods. The source prefixes of the keys
+// like "k8s:" are removed, labels derived from the endpoint are ignored.
+func ciliumSelector(selector *metav1.LabelSelector) (*metav1.LabelSelector, *metav1.LabelSelector) {
+	namespaceSelector, podSelector := &metav1.LabelSelector{}, &metav1.LabelSelector{}
+
+	target := func(key string) (*metav1.LabelSelector, string) {
+		key = strings.TrimPrefix(strings.TrimPrefix(key, "k8s:"), "any:")
+		switch {
+		case key == ciliumNamespaceLabel:
+			return namespaceSelector, corev1.LabelMetadataName
+		case strings.HasPrefix(key, ciliumNamespaceLabelsPrefix):
+			return namespaceSelector, strings.TrimPrefix(key, ciliumNamespaceLabelsPrefix)
+		case strings.HasPrefix(key, ciliumPolicyLabelsPrefix), strings.Contains(key, ":"):
+			return nil, ""
+		}
+		return podSelector, key
+	}
+
+	for key, value := range selector.MatchLabels {
+		if s, key := target(key); s != nil {
+			metav1.AddLabelToSelector(s, key, value)
+		}
+	}
+	for _, requirement := range selector.MatchExpressions {
+		if s, key := target(requirement.Key); s != nil {
+			requirement.Key = key
+			s.MatchExpressions = append(s.MatchExpressions, requirement)
+		}
+	}
+
+	return namespaceSelector, podSelector
+}
+
+// ciliumPorts returns the ports of the toPorts field of a rule, e.g.
+// "TCP/80", followed by the layer 7 protocols of its rules, e.g. "TCP/80 (http)".
+func ciliumPorts(rule map[string]interface{}) []string {
+	ports := []string{}
+
+	toPorts, _, _ := unstructured.NestedSlice(rule, "toPorts")
+	for _, toPort := range toPorts {
+		t, ok := toPort.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		protocols := []string{}
+		l7, _, _ := unstructured.NestedMap(t, "rules")
+		for protocol := range l7 {
+			protocols = append(protocols, protocol)
+		}
+		suffix := ""
+		if len(protocols) != 0 {
+			slices.Sort(protocols)
+			suffix = " (" + strings.Join(protocols, ",") + ")"
+		}
+
+		list, _, _ := unstructured.NestedSlice(t, "ports")
+		for _, port := range list {
+			p, ok := port.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			protocol, _, _ := unstructured.NestedString(p, "protocol")
+			if len(protocol) == 0 {
+				protocol = "ANY"
+			}
+			number, _, _ := unstructured.NestedString(p, "port")
+			if len(number) == 0 || number == "0" {
+				number = "*"
+			}
+			if end, ok, _ := unstructured.NestedInt64(p, "endPort"); ok {
+				number += "-" + strconv.FormatInt(end, 10)
+			}
+			ports = append(ports, protocol+"/"+number+suffix)
+		}
+	}
+
+	return ports
+}
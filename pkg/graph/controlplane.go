@@ -0,0 +1,140 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Control plane component types of the "type" attribute of ControlPlaneComponents.
+const (
+	ControlPlaneStaticPod = "StaticPod"
+	ControlPlaneAddon     = "Addon"
+	ControlPlaneCNI       = "CNI"
+)
+
+// controlPlaneAddons maps the "k8s-app" label of kube-system Pods to their component.
+var controlPlaneAddons = map[string]string{
+	"kube-dns":   "coredns",
+	"kube-proxy": "kube-proxy",
+}
+
+// cniPrefixes contains the name prefixes of the DaemonSets of common CNI plugins.
+var cniPrefixes = []string{
+	"antrea",
+	"aws-node",
+	"calico",
+	"canal",
+	"cilium",
+	"flannel",
+	"kube-flannel",
+	"kube-router",
+	"weave",
+}
+
+// ControlPlane adds a node for each control plane component in the
+// kube-system namespace to the Graph, which are static Pods like the
+// kube-apiserver, addons like kube-proxy and CoreDNS, and CNI plugins.
+// Each component is linked to its Pods and the Nodes they run on.
+func (g *CoreV1Graph) ControlPlane() error {
+	namespace := metav1.NamespaceSystem
+	pods, err := g.graph.clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	daemonSets, err := g.graph.clientset.AppsV1().DaemonSets(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	cni := map[string]bool{}
+	for _, daemonSet := range daemonSets.Items {
+		for _, prefix := range cniPrefixes {
+			if strings.HasPrefix(daemonSet.GetName(), prefix) {
+				cni[daemonSet.GetName()] = true
+				break
+			}
+		}
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		name, kind := controlPlaneComponent(pod, cni)
+		if len(name) == 0 {
+			continue
+		}
+
+		c := g.ControlPlaneComponent(name)
+		c.Attribute("type", kind)
+
+		p, err := g.Pod(pod)
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(c, "Pod", p)
+
+		if len(pod.Spec.NodeName) == 0 {
+			continue
+		}
+		node, err := g.NodeName(pod.Spec.NodeName)
+		if err != nil {
+			return err
+		}
+		if node != nil {
+			g.graph.LabeledRelationship(c, "RUNS_ON", node)
+		}
+	}
+
+	return nil
+}
+
+// ControlPlaneComponent adds a node which represents a control plane component to the Graph.
+func (g *CoreV1Graph) ControlPlaneComponent(name string) *Node {
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "ControlPlaneComponent"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(metav1.NamespaceSystem, "ControlPlaneComponent", name),
+			Name:      name,
+			Namespace: metav1.NamespaceSystem,
+		},
+	)
+}
+
+// controlPlaneComponent returns the name and type of the control plane
+// component of a kube-system Pod, or an empty name if it is none.
+func controlPlaneComponent(pod *v1.Pod, cni map[string]bool) (string, string) {
+	// the kubelet creates a mirror Pod owned by the Node for each static Pod
+	if _, ok := pod.GetAnnotations()[v1.MirrorPodAnnotationKey]; ok {
+		if component, ok := pod.GetLabels()["component"]; ok {
+			return component, ControlPlaneStaticPod
+		}
+		return strings.TrimSuffix(pod.GetName(), "-"+pod.Spec.NodeName), ControlPlaneStaticPod
+	}
+
+	if component, ok := controlPlaneAddons[pod.GetLabels()["k8s-app"]]; ok {
+		return component, ControlPlaneAddon
+	}
+
+	if ref := metav1.GetControllerOf(pod); ref != nil && ref.Kind == "DaemonSet" && cni[ref.Name] {
+		return ref.Name, ControlPlaneCNI
+	}
+
+	return "", ""
+}
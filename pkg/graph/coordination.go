@@ -0,0 +1,115 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// nodeLeaseNamespace is the namespace of the heartbeat Leases of all Nodes.
+const nodeLeaseNamespace = "kube-node-lease"
+
+// CoordinationV1Graph is used to graph all coordination resources.
+type CoordinationV1Graph struct {
+	graph *Graph
+}
+
+// NewCoordinationV1Graph creates a new CoordinationV1Graph.
+func NewCoordinationV1Graph(g *Graph) *CoordinationV1Graph {
+	return &CoordinationV1Graph{
+		graph: g,
+	}
+}
+
+// CoordinationV1 retrieves the CoordinationV1Graph.
+func (g *Graph) CoordinationV1() *CoordinationV1Graph {
+	return g.coordinationV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *CoordinationV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Lease":
+		obj := &v1.Lease{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.Lease(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Lease adds a v1.Lease resource and the Pod or Node of its holder to the Graph.
+func (g *CoordinationV1Graph) Lease(obj *v1.Lease) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "Lease"), obj)
+	if obj.Spec.LeaseDurationSeconds != nil {
+		n.Attribute("leaseDurationSeconds", strconv.Itoa(int(*obj.Spec.LeaseDurationSeconds)))
+	}
+	if obj.Spec.RenewTime != nil {
+		n.Attribute("renewTime", obj.Spec.RenewTime.Format(time.RFC3339))
+	}
+	if obj.Spec.HolderIdentity == nil || len(*obj.Spec.HolderIdentity) == 0 {
+		return n, nil
+	}
+	identity := *obj.Spec.HolderIdentity
+	n.Attribute("holderIdentity", identity)
+
+	h, err := g.Holder(obj.GetNamespace(), obj.GetName(), identity)
+	if err != nil {
+		return nil, err
+	}
+	if h != nil {
+		g.graph.Relationship(n, h.Kind, h).Attribute("holderIdentity", identity)
+	}
+
+	return n, nil
+}
+
+// Holder adds the Pod or Node which holds a Lease to the Graph. Leader
+// election identities are usually the Pod name or the Node name followed by
+// "_" and a random suffix, the Pods of control plane components are static
+// Pods named after the Lease and the Node, e.g. "kube-scheduler-node1".
+// It returns nil if the holder is not found.
+func (g *CoordinationV1Graph) Holder(namespace string, lease string, identity string) (*Node, error) {
+	name, _, _ := strings.Cut(identity, "_")
+
+	if namespace == nodeLeaseNamespace {
+		return g.graph.CoreV1().NodeName(name)
+	}
+
+	for _, podName := range []string{name, lease + "-" + name} {
+		options := metav1.GetOptions{}
+		pod, err := g.graph.clientset.CoreV1().Pods(namespace).Get(context.TODO(), podName, options)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return g.graph.CoreV1().Pod(pod)
+	}
+
+	return g.graph.CoreV1().NodeName(name)
+}
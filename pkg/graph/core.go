@@ -16,23 +16,68 @@ package graph
 
 import (
 	"context"
+	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Node status values of the "status" attribute of Nodes.
+const (
+	NodeReady              = "Ready"
+	NodeNotReady           = "NotReady"
+	NodeSchedulingDisabled = "SchedulingDisabled"
+	NodePressure           = "Pressure"
+)
+
+// LoadBalancer status values of the "status" attribute of LoadBalancers.
+const (
+	LoadBalancerPending     = "Pending"
+	LoadBalancerProvisioned = "Provisioned"
 )
 
 // CoreV1Graph is used to graph all core resources.
 type CoreV1Graph struct {
 	graph *Graph
+
+	// serviceAccounts contains the nodes by namespace and name and nodes
+	// by name, because they are referenced by many Pods. A nil node was not found.
+	serviceAccounts map[string]*Node
+	nodes           map[string]*Node
+	// services contains the nodes by namespace and name, because they are
+	// referenced by many routing resources.
+	services map[string]*Node
+	// claims contains the nodes by namespace and name, because they are
+	// referenced by the Pods of each run. A nil node was not found.
+	claims map[string]*Node
+
+	// replicaSetOwners contains the controller of each ReplicaSet, which is
+	// the workload of its Pods. A nil reference has no controller.
+	replicaSetOwners map[types.UID]*metav1.OwnerReference
+	// affinities contains the workloads of the Pods by namespace and label
+	// selector of the pod affinity terms.
+	affinities map[string][]*Node
 }
 
 // NewCoreV1Graph creates a new CoreV1Graph.
 func NewCoreV1Graph(g *Graph) *CoreV1Graph {
 	return &CoreV1Graph{
-		graph: g,
+		graph:            g,
+		serviceAccounts:  make(map[string]*Node),
+		nodes:            make(map[string]*Node),
+		services:         make(map[string]*Node),
+		claims:           make(map[string]*Node),
+		replicaSetOwners: make(map[types.UID]*metav1.OwnerReference),
+		affinities:       make(map[string][]*Node),
 	}
 }
 
@@ -56,6 +101,12 @@ func (g *CoreV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, err
 			return nil, err
 		}
 		return g.Pod(obj)
+	case "ReplicationController":
+		obj := &v1.ReplicationController{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ReplicationController(obj)
 	case "Endpoints":
 		obj := &v1.Endpoints{}
 		if err := FromUnstructured(unstr, obj); err != nil {
@@ -68,12 +119,42 @@ func (g *CoreV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, err
 			return nil, err
 		}
 		return g.Service(obj)
+	case "PersistentVolumeClaim":
+		obj := &v1.PersistentVolumeClaim{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.PersistentVolumeClaim(obj)
+	case "PersistentVolume":
+		obj := &v1.PersistentVolume{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.PersistentVolume(obj)
 	case "Node":
 		obj := &v1.Node{}
 		if err := FromUnstructured(unstr, obj); err != nil {
 			return nil, err
 		}
 		return g.Node(obj)
+	case "ServiceAccount":
+		obj := &v1.ServiceAccount{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ServiceAccount(obj)
+	case "ResourceQuota":
+		obj := &v1.ResourceQuota{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ResourceQuota(obj)
+	case "LimitRange":
+		obj := &v1.LimitRange{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.LimitRange(obj)
 	default:
 		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
 	}
@@ -86,7 +167,7 @@ func (g *CoreV1Graph) Cluster() (*Node, error) {
 	n := g.graph.Node(
 		schema.FromAPIVersionAndKind(v1.GroupName, "Cluster"),
 		&metav1.ObjectMeta{
-			UID:  ToUID("Cluster", c),
+			UID:  g.graph.ToUID("Cluster", c),
 			Name: c,
 		},
 	)
@@ -104,7 +185,7 @@ func (g *CoreV1Graph) Namespace(ns *v1.Namespace) (*Node, error) {
 	n := g.graph.Node(
 		schema.FromAPIVersionAndKind(v1.GroupName, "Namespace"),
 		&metav1.ObjectMeta{
-			UID:  ToUID(c.GetName(), ns.GetName()),
+			UID:  g.graph.ToUID(c.GetName(), ns.GetName()),
 			Name: ns.GetName(),
 		},
 	)
@@ -113,9 +194,56 @@ func (g *CoreV1Graph) Namespace(ns *v1.Namespace) (*Node, error) {
 	return n, nil
 }
 
+// ResourceQuota adds a v1.ResourceQuota resource to the Graph. The used and
+// hard amount of each resource is added as "used/hard" attribute.
+func (g *CoreV1Graph) ResourceQuota(obj *v1.ResourceQuota) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "ResourceQuota"), obj)
+	for name, hard := range obj.Spec.Hard {
+		used := obj.Status.Used[name]
+		n.Attribute(string(name), used.String()+"/"+hard.String())
+	}
+
+	ns, err := g.Namespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: obj.GetNamespace()}})
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(ns, "ResourceQuota", n)
+
+	return n, nil
+}
+
+// LimitRange adds a v1.LimitRange resource to the Graph. Each limit is
+// added as attribute named by its type, e.g. "Container.default.cpu".
+func (g *CoreV1Graph) LimitRange(obj *v1.LimitRange) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "LimitRange"), obj)
+	for _, limit := range obj.Spec.Limits {
+		limits := map[string]v1.ResourceList{
+			"min":                  limit.Min,
+			"max":                  limit.Max,
+			"default":              limit.Default,
+			"defaultRequest":       limit.DefaultRequest,
+			"maxLimitRequestRatio": limit.MaxLimitRequestRatio,
+		}
+		for field, resources := range limits {
+			for name, quantity := range resources {
+				n.Attribute(string(limit.Type)+"."+field+"."+string(name), quantity.String())
+			}
+		}
+	}
+
+	ns, err := g.Namespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: obj.GetNamespace()}})
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(ns, "LimitRange", n)
+
+	return n, nil
+}
+
 // Pod adds a v1.Pod resource to the Graph.
 func (g *CoreV1Graph) Pod(pod *v1.Pod) (*Node, error) {
 	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Pod"), pod)
+	hostAccess(n, pod.Spec)
 
 	for _, initContainer := range pod.Spec.InitContainers {
 		c, err := g.Container(pod, initContainer)
@@ -133,207 +261,1309 @@ func (g *CoreV1Graph) Pod(pod *v1.Pod) (*Node, error) {
 		g.graph.Relationship(n, "Container", c)
 	}
 
-	return n, nil
-}
+	// the images of controlled Pods are referenced by their workload
+	if metav1.GetControllerOf(pod) == nil {
+		if err := g.Images(n, pod.Spec); err != nil {
+			return nil, err
+		}
+	}
 
-// Container adds a v1.Container resource to the Graph.
-func (g *CoreV1Graph) Container(pod *v1.Pod, container v1.Container) (*Node, error) {
-	n := g.graph.Node(
-		schema.FromAPIVersionAndKind(v1.GroupName, "Container"),
-		&metav1.ObjectMeta{
-			UID:       ToUID(pod.GetUID(), container.Name),
-			Namespace: pod.GetNamespace(),
-			Name:      container.Name,
-		},
-	)
+	for _, volume := range pod.Spec.Volumes {
+		if err := g.podVolumeSources(n, pod, volume); err != nil {
+			return nil, err
+		}
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
 
-	// i, err := g.Image(container.Image)
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// g.graph.Relationship(n, "Image", i)
+		c, err := g.PersistentVolumeClaimName(pod.GetNamespace(), volume.PersistentVolumeClaim.ClaimName)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			g.graph.Warn(WarningNotFound, n, "PersistentVolumeClaim %s not found", volume.PersistentVolumeClaim.ClaimName)
+			continue
+		}
+		g.graph.Relationship(n, "PersistentVolumeClaim", c)
+	}
 
-	return n, nil
-}
+	// the admission controller sets the default ServiceAccount
+	if len(pod.Spec.ServiceAccountName) != 0 {
+		sa, err := g.ServiceAccountName(pod.GetNamespace(), pod.Spec.ServiceAccountName)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "ServiceAccount", sa)
+	}
 
-// Image adds a v1.Image resource to the Graph.
-func (g *CoreV1Graph) Image(name string) (*Node, error) {
-	registry := "docker.io"
-	image := name
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		g.graph.Relationship(n, "ImagePullSecret", g.SecretName(pod.GetNamespace(), secret.Name))
+	}
 
-	if strings.Count(image, "/") > 0 {
-		s := strings.SplitN(image, "/", 2)
-		if strings.Count(s[0], ".") > 0 {
-			registry, image = s[0], s[1]
+	if len(pod.Spec.PriorityClassName) != 0 {
+		c, err := g.graph.SchedulingV1().PriorityClassName(pod.Spec.PriorityClassName)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			g.graph.Warn(WarningNotFound, n, "PriorityClass %s not found", pod.Spec.PriorityClassName)
+		} else {
+			g.graph.Relationship(n, "PriorityClass", c)
 		}
 	}
 
-	n := g.graph.Node(
-		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Image"),
-		&metav1.ObjectMeta{
-			UID:  ToUID(registry, image),
-			Name: image,
-		},
-	)
+	if pod.Spec.RuntimeClassName != nil {
+		c, err := g.graph.NodeV1().RuntimeClassName(*pod.Spec.RuntimeClassName)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			g.graph.Warn(WarningNotFound, n, "RuntimeClass %s not found", *pod.Spec.RuntimeClassName)
+		} else {
+			g.graph.Relationship(n, "RuntimeClass", c)
+		}
+	}
 
-	r, err := g.Registry(registry)
-	if err != nil {
+	if err := g.graph.Multus().PodNetworks(n, pod); err != nil {
 		return nil, err
 	}
-	g.graph.Relationship(n, "Registry", r)
 
-	return n, nil
-}
+	if len(pod.Spec.NodeName) != 0 {
+		node, err := g.NodeName(pod.Spec.NodeName)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			g.graph.Warn(WarningNotFound, n, "Node %s not found", pod.Spec.NodeName)
+		} else {
+			g.graph.LabeledRelationship(n, "SCHEDULED_ON", node)
+		}
+	}
 
-// Registry adds a v1.Registry resource to the Graph.
-func (g *CoreV1Graph) Registry(name string) (*Node, error) {
-	n := g.graph.Node(
-		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Registry"),
-		&metav1.ObjectMeta{
-			UID:  ToUID(name),
-			Name: name,
-		},
-	)
+	if err := g.podAffinity(pod); err != nil {
+		return nil, err
+	}
 
 	return n, nil
 }
 
-// Endpoints adds a v1.Endpoints resource to the Graph.
-func (g *CoreV1Graph) Endpoints(obj *v1.Endpoints) (*Node, error) {
-	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Endpoints"), obj)
+// PodWorkload adds the workload of a v1.Pod to the Graph, which is the owner
+// of its ReplicaSet or its controller. A Pod without controller is its own workload.
+func (g *CoreV1Graph) PodWorkload(pod *v1.Pod) (*Node, error) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Pod"), pod), nil
+	}
 
-	for _, subset := range obj.Subsets {
-		for _, address := range subset.Addresses {
-			if address.TargetRef != nil {
-				t, err := g.ObjectReference(address.TargetRef)
-				if err != nil {
-					return nil, err
-				}
-				g.graph.Relationship(n, t.Kind, t)
+	if ref.Kind == "ReplicaSet" {
+		owner, ok := g.replicaSetOwners[ref.UID]
+		if !ok {
+			options := metav1.GetOptions{}
+			rs, err := g.graph.clientset.AppsV1().ReplicaSets(pod.GetNamespace()).Get(context.TODO(), ref.Name, options)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			if err == nil {
+				owner = metav1.GetControllerOf(rs)
 			}
+			g.replicaSetOwners[ref.UID] = owner
+		}
+		if owner != nil {
+			ref = owner
 		}
 	}
 
-	return n, nil
-}
-
-// ObjectReference adds a v1.ObjectReference resource to the Graph.
-func (g *CoreV1Graph) ObjectReference(obj *v1.ObjectReference) (*Node, error) {
-	n := g.graph.Node(
-		obj.GroupVersionKind(),
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind),
 		&metav1.ObjectMeta{
-			UID:       obj.UID,
-			Name:      obj.Name,
-			Namespace: obj.Namespace,
+			UID:       ref.UID,
+			Name:      ref.Name,
+			Namespace: pod.GetNamespace(),
 		},
-	)
-
-	return n, nil
+	), nil
 }
 
-// TypedLocalObjectReference adds a v1.TypedLocalObjectReference resource to the Graph.
-func (g *CoreV1Graph) TypedLocalObjectReference(obj *v1.TypedLocalObjectReference, namespace string) (*Node, error) {
-	n := g.graph.Node(
-		schema.FromAPIVersionAndKind(v1.GroupName, *obj.APIGroup),
-		&metav1.ObjectMeta{
-			UID:       ToUID(obj.APIGroup, obj.Kind, obj.Name),
-			Name:      obj.Name,
-			Namespace: namespace,
-		},
-	)
+// ReplicationController adds a v1.ReplicationController resource and its
+// Pods to the Graph, like the ReplicaSet which has replaced it.
+func (g *CoreV1Graph) ReplicationController(obj *v1.ReplicationController) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "ReplicationController"), obj)
+	replicas(n, obj.Spec.Replicas, obj.Status.ReadyReplicas, obj.Status.AvailableReplicas)
+	n.Attribute("status", replicationControllerStatus(obj))
+
+	if obj.Spec.Template != nil {
+		if err := g.Images(n, obj.Spec.Template.Spec); err != nil {
+			return nil, err
+		}
+		hostAccess(n, obj.Spec.Template.Spec)
+	}
+	if len(obj.Spec.Selector) == 0 {
+		return n, nil
+	}
+
+	if _, err := g.graph.AppsV1().pods(n, metav1.SetAsLabelSelector(obj.Spec.Selector)); err != nil {
+		return nil, err
+	}
 
 	return n, nil
 }
 
-// Service adds a v1.Service resource to the Graph.
-func (g *CoreV1Graph) Service(obj *v1.Service) (*Node, error) {
-	switch obj.Spec.Type {
-	case v1.ServiceTypeClusterIP:
-		return g.ServiceTypeClusterIP(obj)
-		// case v1.ServiceTypeNodePort:
-	case v1.ServiceTypeLoadBalancer:
-		return g.ServiceTypeLoadBalancer(obj)
-	case v1.ServiceTypeExternalName:
-		return g.ServiceTypeExternalName(obj)
+// replicationControllerStatus returns the rollout status of a v1.ReplicationController.
+func replicationControllerStatus(obj *v1.ReplicationController) string {
+	desired := int32(1)
+	if obj.Spec.Replicas != nil {
+		desired = *obj.Spec.Replicas
+	}
+	if obj.Status.ObservedGeneration >= obj.GetGeneration() && obj.Status.AvailableReplicas >= desired {
+		return RolloutComplete
 	}
 
-	return nil, nil
+	return RolloutProgressing
 }
 
-// ServiceTypeClusterIP adds a v1.Service of type ClusterIP to the Graph.
-func (g *CoreV1Graph) ServiceTypeClusterIP(obj *v1.Service) (*Node, error) {
-	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Service"), obj)
+// podAffinity adds AFFINITY and ANTI_AFFINITY relationships from the workload
+// of the Pod to the workloads of the Pods which match its pod affinity and
+// anti-affinity terms. The topology key and whether the term is required or
+// preferred are added as attributes. Terms which match the own workload, e.g.
+// to spread the replicas, are added as attribute to the workload node.
+func (g *CoreV1Graph) podAffinity(pod *v1.Pod) error {
+	affinity := pod.Spec.Affinity
+	if affinity == nil {
+		return nil
+	}
 
-	options := metav1.GetOptions{}
-	endpoints, err := g.graph.clientset.CoreV1().Endpoints(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), options)
+	type term struct {
+		label string
+		self  string
+		mode  string
+		term  v1.PodAffinityTerm
+	}
+	terms := []term{}
+	if affinity.PodAffinity != nil {
+		for _, t := range affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			terms = append(terms, term{label: "AFFINITY", self: "selfAffinity", mode: "required", term: t})
+		}
+		for _, t := range affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			terms = append(terms, term{label: "AFFINITY", self: "selfAffinity", mode: "preferred", term: t.PodAffinityTerm})
+		}
+	}
+	if affinity.PodAntiAffinity != nil {
+		for _, t := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			terms = append(terms, term{label: "ANTI_AFFINITY", self: "selfAntiAffinity", mode: "required", term: t})
+		}
+		for _, t := range affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			terms = append(terms, term{label: "ANTI_AFFINITY", self: "selfAntiAffinity", mode: "preferred", term: t.PodAffinityTerm})
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	w, err := g.PodWorkload(pod)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	e, err := g.Endpoints(endpoints)
+	for _, t := range terms {
+		workloads, err := g.affinityWorkloads(pod, t.term)
+		if err != nil {
+			return err
+		}
+
+		for _, workload := range workloads {
+			if workload.UID == w.UID {
+				w.Attribute(t.self, t.term.TopologyKey)
+				continue
+			}
+
+			r := g.graph.LabeledRelationship(w, t.label, workload)
+			appendAttribute(r, "topologyKey", t.term.TopologyKey)
+			appendAttribute(r, "mode", t.mode)
+		}
+	}
+
+	return nil
+}
+
+// affinityWorkloads returns the workloads of all running Pods which match the v1.PodAffinityTerm.
+func (g *CoreV1Graph) affinityWorkloads(pod *v1.Pod, term v1.PodAffinityTerm) ([]*Node, error) {
+	if term.LabelSelector == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
 	if err != nil {
 		return nil, err
 	}
-	g.graph.Relationship(n, "Endpoints", e)
 
-	return n, nil
+	// the namespaces of the term and the namespace selector are combined,
+	// an empty list and a nil selector refer to the namespace of the Pod
+	namespaces := append([]string{}, term.Namespaces...)
+	if term.NamespaceSelector != nil {
+		nsSelector, err := metav1.LabelSelectorAsSelector(term.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		options := metav1.ListOptions{LabelSelector: nsSelector.String()}
+		list, err := g.graph.clientset.CoreV1().Namespaces().List(context.TODO(), options)
+		if err != nil {
+			return nil, err
+		}
+		for _, namespace := range list.Items {
+			namespaces = append(namespaces, namespace.GetName())
+		}
+	} else if len(namespaces) == 0 {
+		namespaces = append(namespaces, pod.GetNamespace())
+	}
+
+	workloads := []*Node{}
+	for _, namespace := range namespaces {
+		// the replicas of a workload share their terms
+		key := namespace + "/" + selector.String()
+		cached, ok := g.affinities[key]
+		if !ok {
+			options := metav1.ListOptions{LabelSelector: selector.String(), FieldSelector: "status.phase=Running"}
+			pods, err := g.graph.clientset.CoreV1().Pods(namespace).List(context.TODO(), options)
+			if err != nil {
+				return nil, err
+			}
+			uids := make(map[types.UID]bool)
+			for i := range pods.Items {
+				w, err := g.PodWorkload(&pods.Items[i])
+				if err != nil {
+					return nil, err
+				}
+				if !uids[w.UID] {
+					uids[w.UID] = true
+					cached = append(cached, w)
+				}
+			}
+			g.affinities[key] = cached
+		}
+		workloads = append(workloads, cached...)
+	}
+
+	return workloads, nil
 }
 
-// ServiceTypeLoadBalancer adds a v1.Service of type LoadBalancer to the Graph.
-func (g *CoreV1Graph) ServiceTypeLoadBalancer(obj *v1.Service) (*Node, error) {
-	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Service"), obj)
+// podVolumeSources adds relationships from the Pod node to the sources of
+// a projected volume and to the SecretProviderClass of a Secrets Store CSI volume.
+func (g *CoreV1Graph) podVolumeSources(n *Node, pod *v1.Pod, volume v1.Volume) error {
+	if volume.Projected != nil {
+		for _, source := range volume.Projected.Sources {
+			switch {
+			case source.Secret != nil:
+				g.graph.Relationship(n, "Secret", g.SecretName(pod.GetNamespace(), source.Secret.Name))
+			case source.ConfigMap != nil:
+				g.graph.Relationship(n, "ConfigMap", g.ConfigMapName(pod.GetNamespace(), source.ConfigMap.Name))
+			case source.ServiceAccountToken != nil && len(pod.Spec.ServiceAccountName) != 0:
+				sa, err := g.ServiceAccountName(pod.GetNamespace(), pod.Spec.ServiceAccountName)
+				if err != nil {
+					return err
+				}
+				appendAttribute(g.graph.Relationship(n, "ServiceAccount", sa), "audience", source.ServiceAccountToken.Audience)
+			}
+		}
+	}
 
-	options := metav1.GetOptions{}
-	endpoints, err := g.graph.clientset.CoreV1().Endpoints(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), options)
-	if err != nil {
-		return nil, err
+	if volume.CSI != nil && volume.CSI.Driver == secretsStoreDriver {
+		name := volume.CSI.VolumeAttributes["secretProviderClass"]
+		if len(name) == 0 {
+			return nil
+		}
+		c, err := g.graph.SecretsStoreCSIV1().SecretProviderClassName(pod.GetNamespace(), name)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			g.graph.Warn(WarningNotFound, n, "SecretProviderClass %s not found", name)
+			return nil
+		}
+		g.graph.Relationship(n, "SecretProviderClass", c)
 	}
 
-	e, err := g.Endpoints(endpoints)
-	if err != nil {
-		return nil, err
+	return nil
+}
+
+// ServiceAccount adds a v1.ServiceAccount resource and the Secrets of its
+// tokens and image pull secrets to the Graph.
+func (g *CoreV1Graph) ServiceAccount(obj *v1.ServiceAccount) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "ServiceAccount"), obj)
+	g.serviceAccounts[obj.GetNamespace()+"/"+obj.GetName()] = n
+
+	for _, secret := range obj.Secrets {
+		g.graph.Relationship(n, "Secret", g.SecretName(obj.GetNamespace(), secret.Name))
+	}
+	for _, secret := range obj.ImagePullSecrets {
+		g.graph.Relationship(n, "ImagePullSecret", g.SecretName(obj.GetNamespace(), secret.Name))
 	}
-	g.graph.Relationship(n, "Endpoints", e)
 
 	return n, nil
 }
 
-// ServiceTypeExternalName adds a v1.Service of type ExternalName to the Graph.
-func (g *CoreV1Graph) ServiceTypeExternalName(obj *v1.Service) (*Node, error) {
-	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Service"), obj)
+// ServiceAccountName adds the v1.ServiceAccount with the given name to the Graph.
+// A missing ServiceAccount is added as node without UID from the cluster.
+func (g *CoreV1Graph) ServiceAccountName(namespace string, name string) (*Node, error) {
+	if n, ok := g.serviceAccounts[namespace+"/"+name]; ok {
+		return n, nil
+	}
 
-	e := g.graph.Node(
-		schema.FromAPIVersionAndKind(v1.GroupName, "ExternalName"),
+	options := metav1.GetOptions{}
+	obj, err := g.graph.clientset.CoreV1().ServiceAccounts(namespace).Get(context.TODO(), name, options)
+	if err == nil {
+		return g.ServiceAccount(obj)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind(v1.GroupName, "ServiceAccount"),
 		&metav1.ObjectMeta{
-			UID:  ToUID(obj.Spec.ExternalName),
-			Name: obj.Spec.ExternalName,
+			UID:       g.graph.ToUID(namespace, "ServiceAccount", name),
+			Name:      name,
+			Namespace: namespace,
 		},
 	)
-	g.graph.Relationship(n, "ExternalName", e)
+	g.serviceAccounts[namespace+"/"+name] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
 
 	return n, nil
 }
 
-// Node adds a v1.Node resource to the Graph.
-func (g *CoreV1Graph) Node(obj *v1.Node) (*Node, error) {
-	n := g.graph.Node(obj.GroupVersionKind(), obj)
-
-	infos := map[string]string{
-		"Architecture": obj.Status.NodeInfo.Architecture,
-		"Runtime":      obj.Status.NodeInfo.ContainerRuntimeVersion,
-		"Kernel":       obj.Status.NodeInfo.KernelVersion,
-		"OSImage":      obj.Status.NodeInfo.OSImage,
+// ServiceName adds the v1.Service with the given name to the Graph.
+// A missing Service is added as node without UID from the cluster.
+func (g *CoreV1Graph) ServiceName(namespace string, name string) (*Node, error) {
+	if n, ok := g.services[namespace+"/"+name]; ok {
+		return n, nil
 	}
-	for kind, info := range infos {
+
+	options := metav1.GetOptions{}
+	obj, err := g.graph.clientset.CoreV1().Services(namespace).Get(context.TODO(), name, options)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var n *Node
+	if err == nil {
+		n, err = g.Service(obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if n == nil {
+		n = g.graph.Node(
+			schema.FromAPIVersionAndKind(v1.GroupName, "Service"),
+			&metav1.ObjectMeta{
+				UID:       g.graph.ToUID(namespace, "Service", name),
+				Name:      name,
+				Namespace: namespace,
+			},
+		)
+		g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+	}
+	g.services[namespace+"/"+name] = n
+
+	return n, nil
+}
+
+// SecretName adds a node for the v1.Secret with the given name to the Graph.
+// Secrets are never read, so their data does not leave the cluster.
+func (g *CoreV1Graph) SecretName(namespace string, name string) *Node {
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind(v1.GroupName, "Secret"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "Secret", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+}
+
+// ConfigMapName adds a node for the v1.ConfigMap with the given name to the Graph.
+// ConfigMaps are not read, like Secrets they may contain sensitive data.
+func (g *CoreV1Graph) ConfigMapName(namespace string, name string) *Node {
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind(v1.GroupName, "ConfigMap"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "ConfigMap", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+}
+
+// Container adds a v1.Container resource to the Graph. With Options.Containers
+// the image and ports are added as attributes and the image as node.
+func (g *CoreV1Graph) Container(pod *v1.Pod, container v1.Container) (*Node, error) {
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind(v1.GroupName, "Container"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(pod.GetUID(), container.Name),
+			Namespace: pod.GetNamespace(),
+			Name:      container.Name,
+		},
+	)
+	if !g.graph.Options.Containers {
+		return n, nil
+	}
+
+	n.Attribute("image", container.Image)
+	if len(container.Ports) != 0 {
+		ports := []string{}
+		for _, port := range container.Ports {
+			ports = append(ports, containerPortName(port))
+		}
+		n.Attribute("ports", strings.Join(ports, ","))
+	}
+
+	if _, err := g.image(n, "Image", container.Image); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Images adds RUNS_IMAGE relationships from the workload node to the images
+// of all containers and init containers of the v1.PodSpec. The names of the
+// containers are added as attribute to the relationships.
+func (g *CoreV1Graph) Images(n *Node, spec v1.PodSpec) error {
+	for _, container := range append(spec.InitContainers, spec.Containers...) {
+		r, err := g.image(n, "RUNS_IMAGE", container.Image)
+		if err != nil {
+			return err
+		}
+		appendAttribute(r, "containers", container.Name)
+	}
+
+	return nil
+}
+
+// image adds the image and a relationship from n to it to the Graph. The
+// image is passed to the resolvers first, which may replace the Image node.
+func (g *CoreV1Graph) image(n *Node, label string, name string) (*Relationship, error) {
+	i, err := g.graph.Resolve(ExternalRef{Type: ExternalRefImage, Value: name, From: n}, label)
+	if err != nil {
+		return nil, err
+	}
+	if i == nil {
+		i, err = g.Image(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return g.graph.LabeledRelationship(n, label, i), nil
+}
+
+// containerPortName returns the name, number and protocol of the v1.ContainerPort, e.g. "http:8080/TCP".
+func containerPortName(port v1.ContainerPort) string {
+	protocol := port.Protocol
+	if len(protocol) == 0 {
+		protocol = v1.ProtocolTCP
+	}
+
+	name := strconv.Itoa(int(port.ContainerPort)) + "/" + string(protocol)
+	if len(port.Name) != 0 {
+		name = port.Name + ":" + name
+	}
+
+	return name
+}
+
+// Image adds a node for a container image and its registry to the Graph.
+// The node is named by the repository and the tag or digest, the registry
+// defaults to "docker.io", e.g. "nginx:1.27" results in "library/nginx:1.27".
+func (g *CoreV1Graph) Image(name string) (*Node, error) {
+	registry, repository, tag, digest := parseImage(name)
+
+	image := repository
+	switch {
+	case len(digest) != 0:
+		image += "@" + digest
+	case len(tag) != 0:
+		image += ":" + tag
+	}
+
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Image"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID(registry, image),
+			Name: image,
+		},
+	)
+	n.Attribute("repository", repository)
+	if len(tag) != 0 {
+		n.Attribute("tag", tag)
+	}
+	if len(digest) != 0 {
+		n.Attribute("digest", digest)
+	}
+
+	r, err := g.Registry(registry)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(n, "Registry", r)
+
+	return n, nil
+}
+
+// parseImage splits an image reference into registry, repository, tag and
+// digest. Images without tag and digest are tagged "latest".
+func parseImage(name string) (string, string, string, string) {
+	registry, repository := "docker.io", name
+
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		registry, repository = first, rest
+	}
+
+	repository, digest, _ := strings.Cut(repository, "@")
+	tag := ""
+	if i := strings.LastIndex(repository, ":"); i != -1 {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	if len(tag) == 0 && len(digest) == 0 {
+		tag = "latest"
+	}
+	if registry == "docker.io" && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+
+	return registry, repository, tag, digest
+}
+
+// Registry adds a node for a container registry to the Graph.
+func (g *CoreV1Graph) Registry(name string) (*Node, error) {
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Registry"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID(name),
+			Name: name,
+		},
+	)
+
+	return n, nil
+}
+
+// Endpoints adds a v1.Endpoints resource to the Graph.
+func (g *CoreV1Graph) Endpoints(obj *v1.Endpoints) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Endpoints"), obj)
+
+	for _, subset := range obj.Subsets {
+		for _, address := range subset.Addresses {
+			if address.TargetRef != nil {
+				t, err := g.ObjectReference(address.TargetRef)
+				if err != nil {
+					return nil, err
+				}
+				g.graph.Relationship(n, t.Kind, t)
+				continue
+			}
+			// manually managed endpoints usually point outside of the cluster
+			if _, err := g.External(n, "External", address.IP); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// ObjectReference adds a v1.ObjectReference resource to the Graph.
+func (g *CoreV1Graph) ObjectReference(obj *v1.ObjectReference) (*Node, error) {
+	n := g.graph.Node(
+		obj.GroupVersionKind(),
+		&metav1.ObjectMeta{
+			UID:       obj.UID,
+			Name:      obj.Name,
+			Namespace: obj.Namespace,
+		},
+	)
+
+	return n, nil
+}
+
+// TypedLocalObjectReference adds a v1.TypedLocalObjectReference resource to the Graph.
+func (g *CoreV1Graph) TypedLocalObjectReference(obj *v1.TypedLocalObjectReference, namespace string) (*Node, error) {
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind(v1.GroupName, *obj.APIGroup),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(obj.APIGroup, obj.Kind, obj.Name),
+			Name:      obj.Name,
+			Namespace: namespace,
+		},
+	)
+
+	return n, nil
+}
+
+// Service adds a v1.Service resource to the Graph.
+func (g *CoreV1Graph) Service(obj *v1.Service) (*Node, error) {
+	switch obj.Spec.Type {
+	case v1.ServiceTypeClusterIP:
+		return g.ServiceTypeClusterIP(obj)
+	case v1.ServiceTypeNodePort:
+		return g.ServiceTypeNodePort(obj)
+	case v1.ServiceTypeLoadBalancer:
+		return g.ServiceTypeLoadBalancer(obj)
+	case v1.ServiceTypeExternalName:
+		return g.ServiceTypeExternalName(obj)
+	}
+
+	return nil, nil
+}
+
+// ServiceTypeClusterIP adds a v1.Service of type ClusterIP to the Graph.
+func (g *CoreV1Graph) ServiceTypeClusterIP(obj *v1.Service) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Service"), obj)
+
+	options := metav1.GetOptions{}
+	endpoints, err := g.graph.clientset.CoreV1().Endpoints(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := g.Endpoints(endpoints)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(n, "Endpoints", e)
+
+	if err := g.ServiceSelector(n, obj); err != nil {
+		return nil, err
+	}
+	if err := g.graph.DiscoveryV1().ServiceEndpointSlices(n, obj); err != nil {
+		return nil, err
+	}
+	if err := g.ServiceExternalIPs(n, obj); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// ServiceTypeNodePort adds a v1.Service of type NodePort to the Graph.
+// It is graphed like a ClusterIP service, which is additionally exposed on all nodes.
+func (g *CoreV1Graph) ServiceTypeNodePort(obj *v1.Service) (*Node, error) {
+	return g.ServiceTypeClusterIP(obj)
+}
+
+// ServiceTypeLoadBalancer adds a v1.Service of type LoadBalancer to the Graph.
+func (g *CoreV1Graph) ServiceTypeLoadBalancer(obj *v1.Service) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Service"), obj)
+
+	options := metav1.GetOptions{}
+	endpoints, err := g.graph.clientset.CoreV1().Endpoints(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := g.Endpoints(endpoints)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(n, "Endpoints", e)
+
+	if err := g.ServiceSelector(n, obj); err != nil {
+		return nil, err
+	}
+	if err := g.graph.DiscoveryV1().ServiceEndpointSlices(n, obj); err != nil {
+		return nil, err
+	}
+	if err := g.ServiceExternalIPs(n, obj); err != nil {
+		return nil, err
+	}
+	if err := g.LoadBalancer(n, obj); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// LoadBalancer adds a node which represents the cloud load balancer of a
+// v1.Service of type LoadBalancer to the Graph, including its addresses and
+// the external traffic from the Internet to it.
+func (g *CoreV1Graph) LoadBalancer(n *Node, obj *v1.Service) error {
+	name := obj.GetName()
+	for _, ingress := range obj.Status.LoadBalancer.Ingress {
+		if len(ingress.Hostname) != 0 {
+			name = ingress.Hostname
+			break
+		}
+		if len(ingress.IP) != 0 {
+			name = ingress.IP
+			break
+		}
+	}
+
+	lb := g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "LoadBalancer"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(obj.GetUID(), "LoadBalancer"),
+			Name:      name,
+			Namespace: obj.GetNamespace(),
+		},
+	)
+	lb.Attribute("status", LoadBalancerPending)
+	if len(obj.Status.LoadBalancer.Ingress) != 0 {
+		lb.Attribute("status", LoadBalancerProvisioned)
+	}
+	if obj.Spec.LoadBalancerClass != nil {
+		lb.Attribute("loadBalancerClass", *obj.Spec.LoadBalancerClass)
+	}
+	if len(obj.Spec.ExternalTrafficPolicy) != 0 {
+		lb.Attribute("externalTrafficPolicy", string(obj.Spec.ExternalTrafficPolicy))
+	}
+
+	r := g.graph.Relationship(g.Internet(), "LoadBalancer", lb)
+	if len(obj.Spec.LoadBalancerSourceRanges) != 0 {
+		r.Attribute("sourceRanges", strings.Join(obj.Spec.LoadBalancerSourceRanges, ","))
+	}
+
+	r = g.graph.Relationship(lb, "Service", n)
+	for _, port := range obj.Spec.Ports {
+		value := strconv.Itoa(int(port.Port))
+		if port.NodePort != 0 {
+			value += ":" + strconv.Itoa(int(port.NodePort))
+		}
+		value += "/" + string(port.Protocol)
+		appendAttribute(r, "ports", value)
+	}
+
+	for _, ingress := range obj.Status.LoadBalancer.Ingress {
+		for _, address := range []string{ingress.IP, ingress.Hostname} {
+			if len(address) == 0 {
+				continue
+			}
+			if _, err := g.External(lb, "Address", address); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Internet adds a node which represents all clients outside of the cluster to the Graph.
+func (g *CoreV1Graph) Internet() *Node {
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Internet"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID("Internet"),
+			Name: "Internet",
+		},
+	)
+}
+
+// ServiceSelector adds the Pods which are selected by the label selector of
+// the v1.Service to the Graph. The relationship to a ready Pod is labeled
+// ROUTES_TO, because it receives traffic, to all other Pods SELECTS. The
+// traffic policies and the matched ports of the Service are added as
+// attributes to the relationships.
+func (g *CoreV1Graph) ServiceSelector(n *Node, obj *v1.Service) error {
+	if len(obj.Spec.Selector) == 0 {
+		return nil
+	}
+
+	selector := labels.SelectorFromSet(obj.Spec.Selector)
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+	pods, err := g.graph.clientset.CoreV1().Pods(obj.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		p := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Pod"), &pods.Items[i])
+		label := "SELECTS"
+		if podReady(&pods.Items[i]) {
+			label = "ROUTES_TO"
+		}
+		r := g.graph.Relationship(n, label, p)
+		trafficPolicies(r, obj)
+		for _, port := range obj.Spec.Ports {
+			if target, ok := containerPort(&pods.Items[i], port); ok {
+				servicePort(r, port, target)
+			}
+		}
+	}
+
+	return nil
+}
+
+// containerPort returns the number of the container port of the v1.Pod
+// which is the target of the v1.ServicePort.
+func containerPort(pod *v1.Pod, port v1.ServicePort) (int32, bool) {
+	if port.TargetPort.Type == intstr.Int {
+		if port.TargetPort.IntVal == 0 {
+			return port.Port, true
+		}
+		return port.TargetPort.IntVal, true
+	}
+
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.Name == port.TargetPort.StrVal {
+				return p.ContainerPort, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// servicePort adds the v1.ServicePort and its target port number as
+// attributes to a relationship from the Service to one of its Pods.
+func servicePort(r *Relationship, port v1.ServicePort, target int32) {
+	appendAttribute(r, "portName", port.Name)
+	appendAttribute(r, "port", strconv.Itoa(int(port.Port)))
+	appendAttribute(r, "targetPort", strconv.Itoa(int(target)))
+	appendAttribute(r, "protocol", string(port.Protocol))
+}
+
+// trafficPolicies adds the traffic policies and the topology aware routing
+// configuration of a v1.Service as attributes to a relationship to its Pods.
+func trafficPolicies(r *Relationship, obj *v1.Service) {
+	if obj.Spec.InternalTrafficPolicy != nil {
+		r.Attribute("internalTrafficPolicy", string(*obj.Spec.InternalTrafficPolicy))
+	}
+	if len(obj.Spec.ExternalTrafficPolicy) != 0 {
+		r.Attribute("externalTrafficPolicy", string(obj.Spec.ExternalTrafficPolicy))
+	}
+	if obj.Spec.TrafficDistribution != nil {
+		r.Attribute("trafficDistribution", *obj.Spec.TrafficDistribution)
+	}
+
+	mode, ok := obj.GetAnnotations()[v1.AnnotationTopologyMode]
+	if !ok {
+		mode, ok = obj.GetAnnotations()[v1.DeprecatedAnnotationTopologyAwareHints]
+	}
+	if ok {
+		r.Attribute("topologyMode", mode)
+	}
+}
+
+// podReady returns true if the v1.Pod has the condition Ready.
+func podReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// ServiceTypeExternalName adds a v1.Service of type ExternalName to the Graph.
+func (g *CoreV1Graph) ServiceTypeExternalName(obj *v1.Service) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Service"), obj)
+
+	if _, err := g.External(n, "ExternalName", obj.Spec.ExternalName); err != nil {
+		return nil, err
+	}
+	if err := g.ServiceExternalIPs(n, obj); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// ServiceExternalIPs adds relationships from the Service node to the external
+// IPs of the v1.Service. The addresses of a load balancer are added by LoadBalancer.
+func (g *CoreV1Graph) ServiceExternalIPs(n *Node, obj *v1.Service) error {
+	for _, ip := range obj.Spec.ExternalIPs {
+		if _, err := g.External(n, "ExternalIP", ip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// External adds a node which represents an IP address or a DNS name outside
+// of the cluster and a relationship from n to it to the Graph. The address is
+// passed to the resolvers first, which may replace the generic ExternalIP or
+// ExternalName node.
+func (g *CoreV1Graph) External(n *Node, label string, address string) (*Relationship, error) {
+	kind, refType := "ExternalName", ExternalRefHost
+	if net.ParseIP(address) != nil {
+		kind, refType = "ExternalIP", ExternalRefIP
+	}
+
+	ref := ExternalRef{Type: refType, Value: address, From: n}
+	r, err := g.graph.Resolve(ref, label)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		return g.graph.Relationship(n, label, r), nil
+	}
+
+	e := g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", kind),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID(address),
+			Name: address,
+		},
+	)
+
+	return g.graph.Relationship(n, label, e), nil
+}
+
+// URL adds a node which represents a URL outside of the cluster and a
+// relationship from n to it to the Graph. The URL is passed to the resolvers
+// first, which may replace the generic URL node.
+func (g *CoreV1Graph) URL(n *Node, label string, url string) (*Relationship, error) {
+	ref := ExternalRef{Type: ExternalRefURL, Value: url, From: n}
+	r, err := g.graph.Resolve(ref, label)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		return g.graph.Relationship(n, label, r), nil
+	}
+
+	u := g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "URL"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID(url),
+			Name: url,
+		},
+	)
+
+	return g.graph.Relationship(n, label, u), nil
+}
+
+// PersistentVolumeClaim adds a v1.PersistentVolumeClaim resource and its PersistentVolume to the Graph.
+func (g *CoreV1Graph) PersistentVolumeClaim(obj *v1.PersistentVolumeClaim) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "PersistentVolumeClaim"), obj)
+	g.claims[obj.GetNamespace()+"/"+obj.GetName()] = n
+	n.Attribute("status", string(obj.Status.Phase))
+	n.Attribute("accessModes", accessModes(obj.Spec.AccessModes))
+
+	capacity := obj.Status.Capacity
+	if len(capacity) == 0 {
+		capacity = obj.Spec.Resources.Requests
+	}
+	if storage, ok := capacity[v1.ResourceStorage]; ok {
+		n.Attribute("capacity", storage.String())
+	}
+
+	if len(obj.Spec.VolumeName) == 0 {
+		if obj.Spec.StorageClassName == nil || len(*obj.Spec.StorageClassName) == 0 {
+			return n, nil
+		}
+
+		// the claim is not bound yet, but waits for a volume of its storage class
+		s, err := g.graph.StorageV1().StorageClassName(*obj.Spec.StorageClassName)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			g.graph.Warn(WarningNotFound, n, "StorageClass %s not found", *obj.Spec.StorageClassName)
+		} else {
+			g.graph.Relationship(n, "StorageClass", s)
+		}
+
+		return n, nil
+	}
+
+	options := metav1.GetOptions{}
+	volume, err := g.graph.clientset.CoreV1().PersistentVolumes().Get(context.TODO(), obj.Spec.VolumeName, options)
+	if apierrors.IsNotFound(err) {
+		g.graph.Warn(WarningNotFound, n, "PersistentVolume %s not found", obj.Spec.VolumeName)
+		return n, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := g.PersistentVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(n, "PersistentVolume", v)
+
+	return n, nil
+}
+
+// PersistentVolumeClaimName adds the v1.PersistentVolumeClaim with the given
+// name to the Graph. It returns nil if the PersistentVolumeClaim does not exist.
+func (g *CoreV1Graph) PersistentVolumeClaimName(namespace string, name string) (*Node, error) {
+	if n, ok := g.claims[namespace+"/"+name]; ok {
+		return n, nil
+	}
+
+	options := metav1.GetOptions{}
+	obj, err := g.graph.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		g.claims[namespace+"/"+name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return g.PersistentVolumeClaim(obj)
+}
+
+// PersistentVolume adds a v1.PersistentVolume resource, its StorageClass and CSIDriver to the Graph.
+// A volume without claim is orphaned and can be found by its status "Available" or "Released".
+func (g *CoreV1Graph) PersistentVolume(obj *v1.PersistentVolume) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "PersistentVolume"), obj)
+	n.Attribute("status", string(obj.Status.Phase))
+	n.Attribute("accessModes", accessModes(obj.Spec.AccessModes))
+	n.Attribute("reclaimPolicy", string(obj.Spec.PersistentVolumeReclaimPolicy))
+	if storage, ok := obj.Spec.Capacity[v1.ResourceStorage]; ok {
+		n.Attribute("capacity", storage.String())
+	}
+
+	if obj.Status.Phase == v1.VolumeBound && obj.Spec.ClaimRef != nil {
+		c, err := g.ObjectReference(obj.Spec.ClaimRef)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(c, "PersistentVolume", n)
+	}
+
+	if len(obj.Spec.StorageClassName) != 0 {
+		s, err := g.graph.StorageV1().StorageClassName(obj.Spec.StorageClassName)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			g.graph.Warn(WarningNotFound, n, "StorageClass %s not found", obj.Spec.StorageClassName)
+		} else {
+			g.graph.Relationship(n, "StorageClass", s)
+		}
+	}
+
+	if obj.Spec.CSI != nil {
+		d, err := g.graph.StorageV1().CSIDriverName(obj.Spec.CSI.Driver)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			g.graph.Relationship(n, "CSIDriver", d)
+		}
+
+		if obj.Spec.CSI.Driver == LonghornDriver {
+			v, err := g.graph.Longhorn().VolumeName(obj.Spec.CSI.VolumeHandle)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				g.graph.Relationship(n, "Volume", v)
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// accessModes returns the access modes as comma separated list.
+func accessModes(modes []v1.PersistentVolumeAccessMode) string {
+	s := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		s = append(s, string(mode))
+	}
+
+	return strings.Join(s, ",")
+}
+
+// Node adds a v1.Node resource to the Graph, grouped under the
+// zone and region of its topology labels.
+func (g *CoreV1Graph) Node(obj *v1.Node) (*Node, error) {
+	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	n.Attribute("status", nodeStatus(obj))
+	n.Attribute("kubeletVersion", obj.Status.NodeInfo.KubeletVersion)
+	if instanceType, ok := obj.GetLabels()[v1.LabelInstanceTypeStable]; ok {
+		n.Attribute("instanceType", instanceType)
+	}
+	for _, condition := range obj.Status.Conditions {
+		n.Attribute(lowerFirst(string(condition.Type)), string(condition.Status))
+	}
+	for _, resource := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourcePods} {
+		if quantity, ok := obj.Status.Allocatable[resource]; ok {
+			n.Attribute("allocatable."+string(resource), quantity.String())
+		}
+		if quantity, ok := obj.Status.Capacity[resource]; ok {
+			n.Attribute("capacity."+string(resource), quantity.String())
+		}
+	}
+
+	infos := map[string]string{
+		"Architecture": obj.Status.NodeInfo.Architecture,
+		"Runtime":      obj.Status.NodeInfo.ContainerRuntimeVersion,
+		"Kernel":       obj.Status.NodeInfo.KernelVersion,
+		"OSImage":      obj.Status.NodeInfo.OSImage,
+	}
+	for kind, info := range infos {
 		i := g.graph.Node(
 			schema.FromAPIVersionAndKind("kubectl-graph/v1", kind),
 			&metav1.ObjectMeta{
-				UID:  ToUID(info),
+				UID:  g.graph.ToUID(info),
 				Name: info,
 			},
 		)
 		g.graph.Relationship(n, kind, i)
 	}
+	g.nodes[obj.GetName()] = n
+
+	// nodes are grouped by the well-known topology labels
+	region, zone := obj.GetLabels()[v1.LabelTopologyRegion], obj.GetLabels()[v1.LabelTopologyZone]
+	parent := n
+	if len(zone) != 0 {
+		z := g.graph.Node(
+			schema.FromAPIVersionAndKind("kubectl-graph/v1", "Zone"),
+			&metav1.ObjectMeta{
+				UID:  g.graph.ToUID("Zone", region, zone),
+				Name: zone,
+			},
+		)
+		g.graph.Relationship(z, "Node", n)
+		parent = z
+	}
+	if len(region) != 0 {
+		r := g.graph.Node(
+			schema.FromAPIVersionAndKind("kubectl-graph/v1", "Region"),
+			&metav1.ObjectMeta{
+				UID:  g.graph.ToUID("Region", region),
+				Name: region,
+			},
+		)
+		g.graph.Relationship(r, parent.Kind, parent)
+	}
 
 	return n, nil
 }
+
+// nodeStatus returns the status of a v1.Node. A ready Node with a pressure
+// condition or which is cordoned is not fully available.
+func nodeStatus(obj *v1.Node) string {
+	status := NodeNotReady
+	pressure := false
+	for _, condition := range obj.Status.Conditions {
+		switch condition.Type {
+		case v1.NodeReady:
+			if condition.Status == v1.ConditionTrue {
+				status = NodeReady
+			}
+		case v1.NodeMemoryPressure, v1.NodeDiskPressure, v1.NodePIDPressure:
+			pressure = pressure || condition.Status == v1.ConditionTrue
+		}
+	}
+
+	switch {
+	case status != NodeReady:
+		return status
+	case obj.Spec.Unschedulable:
+		return NodeSchedulingDisabled
+	case pressure:
+		return NodePressure
+	}
+
+	return status
+}
+
+// lowerFirst returns s with a lower case first letter, e.g. "MemoryPressure" results in "memoryPressure".
+func lowerFirst(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// NodeName adds the v1.Node with the given name to the Graph.
+// It returns nil if the Node does not exist.
+func (g *CoreV1Graph) NodeName(name string) (*Node, error) {
+	if n, ok := g.nodes[name]; ok {
+		return n, nil
+	}
+
+	options := metav1.GetOptions{}
+	obj, err := g.graph.clientset.CoreV1().Nodes().Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		g.nodes[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	obj.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Node"))
+
+	return g.Node(obj)
+}
+
+// Events adds the number of warning events and the last warning message as
+// attributes to the nodes of the involved objects. The events are listed in
+// all namespaces of the Graph, events of cluster-scoped objects are only
+// found if the default namespace is part of the Graph.
+func (g *CoreV1Graph) Events() error {
+	last := make(map[types.UID]time.Time)
+
+	for _, namespace := range g.graph.Namespaces() {
+		if len(namespace) == 0 {
+			continue
+		}
+
+		events, err := g.graph.clientset.CoreV1().Events(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events.Items {
+			n, ok := g.graph.Nodes[event.InvolvedObject.UID]
+			if !ok || event.Type != v1.EventTypeWarning {
+				continue
+			}
+
+			count, _ := strconv.Atoi(n.Attr["warningEvents"])
+			n.Attribute("warningEvents", strconv.Itoa(count+int(max(event.Count, 1))))
+
+			timestamp := eventTime(&event)
+			if timestamp.Before(last[n.UID]) {
+				continue
+			}
+			last[n.UID] = timestamp
+			n.Attribute("lastWarning", event.Reason+": "+strings.TrimSpace(event.Message))
+		}
+	}
+
+	return nil
+}
+
+// eventTime returns the time when the v1.Event was last observed.
+func eventTime(event *v1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+
+	return event.FirstTimestamp.Time
+}
+
+// serviceHost returns the namespace and name of the Service of a host name.
+// Short names are interpreted relative to the namespace of the referencing
+// resource, all other names must be cluster-local and may contain a port.
+func serviceHost(namespace string, host string) (string, string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if len(host) == 0 || strings.Contains(host, "*") {
+		return "", "", false
+	}
+
+	parts := strings.Split(host, ".")
+	switch {
+	case len(parts) == 1:
+		return namespace, host, true
+	case len(parts) >= 3 && parts[2] == "svc":
+		return parts[1], parts[0], true
+	}
+
+	return "", "", false
+}
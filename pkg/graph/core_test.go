@@ -0,0 +1,71 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestPersistentVolumeClaimName(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/namespaces/default/persistentvolumeclaims/data" {
+			w.Write([]byte(`{"kind":"PersistentVolumeClaim","apiVersion":"v1","metadata":{"name":"data","namespace":"default","uid":"data"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+	}))
+	defer server.Close()
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewEmptyGraph(clientset, nil)
+
+	tests := []struct {
+		name  string
+		found bool
+	}{
+		{name: "data", found: true},
+		{name: "missing", found: false},
+	}
+
+	for _, tt := range tests {
+		// the second lookup of each claim is answered from the cache
+		for i := 0; i < 2; i++ {
+			n, err := g.CoreV1().PersistentVolumeClaimName("default", tt.name)
+			if err != nil {
+				t.Fatalf("PersistentVolumeClaimName(%s) returned error: %v", tt.name, err)
+			}
+			if (n != nil) != tt.found {
+				t.Errorf("PersistentVolumeClaimName(%s) = %v, want found %v", tt.name, n, tt.found)
+			}
+		}
+
+		path := "/api/v1/namespaces/default/persistentvolumeclaims/" + tt.name
+		if requests[path] != 1 {
+			t.Errorf("PersistentVolumeClaimName(%s) sent %d requests, want 1", tt.name, requests[path])
+		}
+	}
+}
@@ -0,0 +1,542 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// crossplaneApiextensionsV1 is the group version of the Crossplane
+	// CompositeResourceDefinitions and Compositions.
+	crossplaneApiextensionsV1 = schema.GroupVersion{Group: "apiextensions.crossplane.io", Version: "v1"}
+	// crossplanePkgV1 is the group version of the Crossplane packages.
+	crossplanePkgV1 = schema.GroupVersion{Group: "pkg.crossplane.io", Version: "v1"}
+)
+
+// crossplaneExternalNameAnnotation contains the name of the external resource of a managed resource.
+const crossplaneExternalNameAnnotation = "crossplane.io/external-name"
+
+// CrossplaneGraph is used to graph all crossplane.io resources and the resources defined by them.
+type CrossplaneGraph struct {
+	graph *Graph
+
+	// resources contains the Claims, Composite Resources, managed resources
+	// and ProviderConfigs by group, kind, namespace and name.
+	resources map[string]*Node
+	// references are resolved by Resolve, when all requested resources are graphed.
+	references []crossplaneReference
+	// plurals contains the resource names of the Claims and Composite
+	// Resources which are defined by CompositeResourceDefinitions.
+	plurals map[schema.GroupKind]string
+	// packages contains the Compositions and Functions by kind and name.
+	packages map[string]*Node
+	// providers contains the Providers by the groups of their CustomResourceDefinitions.
+	providers map[string]*Node
+}
+
+// crossplaneReference is a reference to a Crossplane resource which may not be graphed yet.
+type crossplaneReference struct {
+	from      *Node
+	label     string
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// NewCrossplaneGraph creates a new CrossplaneGraph.
+func NewCrossplaneGraph(g *Graph) *CrossplaneGraph {
+	return &CrossplaneGraph{
+		graph:     g,
+		resources: make(map[string]*Node),
+		packages:  make(map[string]*Node),
+		providers: make(map[string]*Node),
+	}
+}
+
+// Crossplane retrieves the CrossplaneGraph.
+func (g *Graph) Crossplane() *CrossplaneGraph {
+	return g.crossplane
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *CrossplaneGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "CompositeResourceDefinition":
+		return g.CompositeResourceDefinition(unstr), nil
+	case "Composition":
+		return g.Composition(unstr)
+	case "Provider":
+		return g.Provider(unstr)
+	case "Function":
+		return g.Function(unstr), nil
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// IsCrossplaneResource returns true if the unstructured object is a Claim, a
+// Composite Resource, a managed resource or a ProviderConfig, which are defined
+// in the groups of the Providers and CompositeResourceDefinitions.
+func IsCrossplaneResource(unstr *unstructured.Unstructured) bool {
+	for _, field := range []string{"resourceRef", "resourceRefs", "crossplane", "compositionRef", "compositionSelector", "forProvider", "providerConfigRef"} {
+		if _, ok, _ := unstructured.NestedFieldNoCopy(unstr.Object, "spec", field); ok {
+			return true
+		}
+	}
+
+	kind := unstr.GetKind()
+	_, ok, _ := unstructured.NestedFieldNoCopy(unstr.Object, "spec", "credentials")
+	return ok && (kind == "ProviderConfig" || kind == "ClusterProviderConfig")
+}
+
+// Resource adds a Claim, a Composite Resource, a managed resource or a
+// ProviderConfig to the Graph. The references to other Crossplane resources
+// are resolved by Resolve.
+func (g *CrossplaneGraph) Resource(unstr *unstructured.Unstructured) (*Node, error) {
+	gvk := unstr.GroupVersionKind()
+	n := g.graph.Node(gvk, unstr)
+	g.resources[crossplaneKey(gvk.GroupKind(), unstr.GetNamespace(), unstr.GetName())] = n
+
+	if _, ok, _ := unstructured.NestedFieldNoCopy(unstr.Object, "spec", "credentials"); ok {
+		if source, ok, _ := unstructured.NestedString(unstr.Object, "spec", "credentials", "source"); ok {
+			n.Attribute("credentials", source)
+		}
+		if name, ok, _ := unstructured.NestedString(unstr.Object, "spec", "credentials", "secretRef", "name"); ok {
+			namespace, _, _ := unstructured.NestedString(unstr.Object, "spec", "credentials", "secretRef", "namespace")
+			g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(namespace, name))
+		}
+		return n, nil
+	}
+
+	n.Attribute("status", readyStatus(unstr))
+	if synced := crossplaneCondition(unstr, "Synced"); len(synced) != 0 {
+		n.Attribute("synced", synced)
+	}
+
+	// Composite Resources of Crossplane v2 have all Crossplane fields below spec.crossplane
+	spec := []string{"spec"}
+	if _, ok, _ := unstructured.NestedMap(unstr.Object, "spec", "crossplane"); ok {
+		spec = append(spec, "crossplane")
+	}
+
+	if ref, ok, _ := unstructured.NestedMap(unstr.Object, append(spec, "resourceRef")...); ok {
+		// the Composite Resource of a Claim is cluster-scoped
+		g.reference(n, "Composite", ref, "")
+	}
+
+	refs, _, _ := unstructured.NestedSlice(unstr.Object, append(spec, "resourceRefs")...)
+	for _, ref := range refs {
+		if r, ok := ref.(map[string]interface{}); ok {
+			g.reference(n, "", r, unstr.GetNamespace())
+		}
+	}
+
+	if name, ok, _ := unstructured.NestedString(unstr.Object, append(spec, "compositionRef", "name")...); ok {
+		c, err := g.CompositionName(name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Composition", c)
+	}
+	if name, ok, _ := unstructured.NestedString(unstr.Object, append(spec, "writeConnectionSecretToRef", "name")...); ok {
+		namespace, _, _ := unstructured.NestedString(unstr.Object, append(spec, "writeConnectionSecretToRef", "namespace")...)
+		if len(namespace) == 0 {
+			namespace = unstr.GetNamespace()
+		}
+		g.graph.Relationship(n, "ConnectionSecret", g.graph.CoreV1().SecretName(namespace, name))
+	}
+
+	if ref, ok, _ := unstructured.NestedMap(unstr.Object, "spec", "providerConfigRef"); ok {
+		kind, _, _ := unstructured.NestedString(ref, "kind")
+		if len(kind) == 0 {
+			kind = "ProviderConfig"
+		}
+		name, _, _ := unstructured.NestedString(ref, "name")
+		namespace := unstr.GetNamespace()
+		if kind == "ClusterProviderConfig" {
+			namespace = ""
+		}
+		g.references = append(g.references, crossplaneReference{
+			from:      n,
+			label:     "ProviderConfig",
+			gvk:       gvk.GroupVersion().WithKind(kind),
+			namespace: namespace,
+			name:      name,
+		})
+	}
+	if name, ok := unstr.GetAnnotations()[crossplaneExternalNameAnnotation]; ok && name != unstr.GetName() {
+		n.Attribute("externalName", name)
+	}
+	if policy, ok, _ := unstructured.NestedString(unstr.Object, "spec", "deletionPolicy"); ok {
+		n.Attribute("deletionPolicy", policy)
+	}
+
+	return n, nil
+}
+
+// Resolve adds the relationships of all references between Crossplane
+// resources to the Graph. Composite Resources which are not graphed are read
+// from the cluster, all other resources are added as node without UID from
+// the cluster. It must be called before the nodes are linked to their
+// namespace, so the resources of a Claim are linked to the namespace of the Claim.
+func (g *CrossplaneGraph) Resolve() error {
+	for len(g.references) != 0 {
+		ref := g.references[0]
+		g.references = g.references[1:]
+
+		if ref.label == "ProviderConfig" {
+			g.graph.Relationship(ref.from, ref.label, g.providerConfig(ref))
+			continue
+		}
+
+		n, err := g.ResourceName(ref.gvk, ref.namespace, ref.name)
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(ref.from, ref.label, n)
+	}
+
+	for _, n := range g.resources {
+		if n.Kind != "ProviderConfig" && n.Kind != "ClusterProviderConfig" {
+			continue
+		}
+		if p, ok := g.providers[n.GroupVersionKind().Group]; ok {
+			g.graph.Relationship(p, n.Kind, n)
+		}
+	}
+
+	return nil
+}
+
+// ResourceName adds the Crossplane resource with the given name to the Graph.
+// Composite Resources which are defined by a CompositeResourceDefinition are
+// read from the cluster.
+func (g *CrossplaneGraph) ResourceName(gvk schema.GroupVersionKind, namespace string, name string) (*Node, error) {
+	key := crossplaneKey(gvk.GroupKind(), namespace, name)
+	if n, ok := g.resources[key]; ok {
+		return n, nil
+	}
+
+	plural, err := g.plural(gvk.GroupKind())
+	if err != nil {
+		return nil, err
+	}
+	if len(plural) != 0 {
+		unstr, err := g.graph.CustomResource(gvk.GroupVersion().WithResource(plural), namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if unstr != nil {
+			return g.Resource(unstr)
+		}
+	}
+
+	n := g.graph.Node(
+		gvk,
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, gvk.Kind, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.resources[key] = n
+	if len(plural) != 0 {
+		g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+	}
+
+	return n, nil
+}
+
+// CompositeResourceDefinition adds a CompositeResourceDefinition resource to the Graph.
+func (g *CrossplaneGraph) CompositeResourceDefinition(unstr *unstructured.Unstructured) *Node {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.packages[path.Join("CompositeResourceDefinition", unstr.GetName())] = n
+
+	if group, ok, _ := unstructured.NestedString(unstr.Object, "spec", "group"); ok {
+		n.Attribute("group", group)
+	}
+	for _, field := range []string{"names", "claimNames"} {
+		if kind, ok, _ := unstructured.NestedString(unstr.Object, "spec", field, "kind"); ok {
+			n.Attribute(strings.TrimSuffix(field, "Names")+"Kind", kind)
+		}
+	}
+	if established := crossplaneCondition(unstr, "Established"); len(established) != 0 {
+		n.Attribute("established", established)
+	}
+
+	return n
+}
+
+// Composition adds a Composition resource, its CompositeResourceDefinition
+// and the Functions of its pipeline to the Graph.
+func (g *CrossplaneGraph) Composition(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join("Composition", unstr.GetName())
+	if n, ok := g.packages[key]; ok {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.packages[key] = n
+
+	if mode, ok, _ := unstructured.NestedString(unstr.Object, "spec", "mode"); ok {
+		n.Attribute("mode", mode)
+	}
+
+	apiVersion, _, _ := unstructured.NestedString(unstr.Object, "spec", "compositeTypeRef", "apiVersion")
+	kind, _, _ := unstructured.NestedString(unstr.Object, "spec", "compositeTypeRef", "kind")
+	if len(kind) != 0 {
+		gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+		n.Attribute("compositeType", gvk.GroupKind().String())
+
+		plural, err := g.plural(gvk.GroupKind())
+		if err != nil {
+			return nil, err
+		}
+		if len(plural) != 0 {
+			gvr := crossplaneApiextensionsV1.WithResource("compositeresourcedefinitions")
+			d, err := g.packageName("CompositeResourceDefinition", gvr, plural+"."+gvk.Group)
+			if err != nil {
+				return nil, err
+			}
+			g.graph.Relationship(d, "Composition", n)
+		}
+	}
+
+	steps, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "pipeline")
+	for _, step := range steps {
+		s, ok := step.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok, _ := unstructured.NestedString(s, "functionRef", "name")
+		if !ok {
+			continue
+		}
+
+		f, err := g.FunctionName(name)
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(n, "Function", f)
+		if step, ok, _ := unstructured.NestedString(s, "step"); ok {
+			appendAttribute(r, "steps", step)
+		}
+	}
+
+	return n, nil
+}
+
+// CompositionName adds the Composition with the given name to the Graph.
+// A missing Composition is added as node without UID from the cluster.
+func (g *CrossplaneGraph) CompositionName(name string) (*Node, error) {
+	return g.packageName("Composition", crossplaneApiextensionsV1.WithResource("compositions"), name)
+}
+
+// Provider adds a Provider resource to the Graph, and records the groups of
+// the CustomResourceDefinitions of its current revision, so its ProviderConfigs
+// can be linked to it.
+func (g *CrossplaneGraph) Provider(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	crossplanePackageAttributes(n, unstr)
+
+	revision, ok, _ := unstructured.NestedString(unstr.Object, "status", "currentRevision")
+	if !ok {
+		return n, nil
+	}
+	rev, err := g.graph.CustomResource(crossplanePkgV1.WithResource("providerrevisions"), "", revision)
+	if err != nil || rev == nil {
+		return n, err
+	}
+
+	refs, _, _ := unstructured.NestedSlice(rev.Object, "status", "objectRefs")
+	for _, ref := range refs {
+		r, ok := ref.(map[string]interface{})
+		if !ok || r["kind"] != "CustomResourceDefinition" {
+			continue
+		}
+		if name, ok := r["name"].(string); ok && strings.Contains(name, ".") {
+			g.providers[strings.SplitN(name, ".", 2)[1]] = n
+		}
+	}
+
+	return n, nil
+}
+
+// Function adds a composition Function resource to the Graph.
+func (g *CrossplaneGraph) Function(unstr *unstructured.Unstructured) *Node {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.packages[path.Join("Function", unstr.GetName())] = n
+	crossplanePackageAttributes(n, unstr)
+
+	return n
+}
+
+// FunctionName adds the Function with the given name to the Graph.
+// A missing Function is added as node without UID from the cluster.
+func (g *CrossplaneGraph) FunctionName(name string) (*Node, error) {
+	return g.packageName("Function", crossplanePkgV1.WithResource("functions"), name)
+}
+
+// packageName adds the cluster-scoped Crossplane resource with the given kind and name to the Graph.
+func (g *CrossplaneGraph) packageName(kind string, gvr schema.GroupVersionResource, name string) (*Node, error) {
+	key := path.Join(kind, name)
+	if n, ok := g.packages[key]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(gvr, "", name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Unstructured(unstr)
+	}
+
+	n := g.graph.Node(
+		gvr.GroupVersion().WithKind(kind),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID("", kind, name),
+			Name: name,
+		},
+	)
+	g.packages[key] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// reference records a reference from n to the Crossplane resource of an object reference.
+// A reference without namespace refers to a resource in the given namespace.
+func (g *CrossplaneGraph) reference(n *Node, label string, ref map[string]interface{}, namespace string) {
+	apiVersion, _, _ := unstructured.NestedString(ref, "apiVersion")
+	kind, _, _ := unstructured.NestedString(ref, "kind")
+	name, _, _ := unstructured.NestedString(ref, "name")
+	if ns, ok, _ := unstructured.NestedString(ref, "namespace"); ok && len(ns) != 0 {
+		namespace = ns
+	}
+	if len(kind) == 0 || len(name) == 0 {
+		return
+	}
+	if len(label) == 0 {
+		label = kind
+	}
+
+	g.references = append(g.references, crossplaneReference{
+		from:      n,
+		label:     label,
+		gvk:       schema.FromAPIVersionAndKind(apiVersion, kind),
+		namespace: namespace,
+		name:      name,
+	})
+}
+
+// providerConfig returns the ProviderConfig of a managed resource. The
+// ProviderConfigs are usually defined in the parent group of the managed
+// resources, e.g. aws.upbound.io for s3.aws.upbound.io.
+func (g *CrossplaneGraph) providerConfig(ref crossplaneReference) *Node {
+	group := ref.gvk.Group
+	for len(group) != 0 {
+		if n, ok := g.resources[crossplaneKey(schema.GroupKind{Group: group, Kind: ref.gvk.Kind}, ref.namespace, ref.name)]; ok {
+			return n
+		}
+
+		_, parent, ok := strings.Cut(group, ".")
+		if !ok || !strings.Contains(parent, ".") {
+			break
+		}
+		group = parent
+	}
+
+	n := g.graph.Node(
+		ref.gvk,
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(ref.namespace, ref.gvk.Kind, ref.name),
+			Name:      ref.name,
+			Namespace: ref.namespace,
+		},
+	)
+	g.resources[crossplaneKey(ref.gvk.GroupKind(), ref.namespace, ref.name)] = n
+
+	return n
+}
+
+// plural returns the resource name of a Claim or Composite Resource kind.
+// The CompositeResourceDefinitions are listed once from the cluster. It
+// returns an empty string for all other kinds.
+func (g *CrossplaneGraph) plural(gk schema.GroupKind) (string, error) {
+	if g.plurals == nil {
+		g.plurals = make(map[schema.GroupKind]string)
+
+		gvr := crossplaneApiextensionsV1.WithResource("compositeresourcedefinitions")
+		definitions, err := g.graph.CustomResources(gvr, "", "")
+		if err != nil {
+			return "", err
+		}
+
+		for _, definition := range definitions {
+			group, _, _ := unstructured.NestedString(definition.Object, "spec", "group")
+			for _, field := range []string{"names", "claimNames"} {
+				kind, _, _ := unstructured.NestedString(definition.Object, "spec", field, "kind")
+				plural, _, _ := unstructured.NestedString(definition.Object, "spec", field, "plural")
+				if len(kind) != 0 && len(plural) != 0 {
+					g.plurals[schema.GroupKind{Group: group, Kind: kind}] = plural
+				}
+			}
+		}
+	}
+
+	return g.plurals[gk], nil
+}
+
+// crossplaneKey returns the key of a Crossplane resource.
+func crossplaneKey(gk schema.GroupKind, namespace string, name string) string {
+	return path.Join(gk.String(), namespace, name)
+}
+
+// crossplanePackageAttributes adds the package and its Installed and Healthy
+// conditions as attributes to a Provider or Function.
+func crossplanePackageAttributes(n *Node, unstr *unstructured.Unstructured) {
+	if pkg, ok, _ := unstructured.NestedString(unstr.Object, "spec", "package"); ok {
+		n.Attribute("package", pkg)
+	}
+	for _, condition := range []string{"Installed", "Healthy"} {
+		if status := crossplaneCondition(unstr, condition); len(status) != 0 {
+			n.Attribute(strings.ToLower(condition), status)
+		}
+	}
+}
+
+// crossplaneCondition returns the status of the condition with the given
+// type, or an empty string if the condition does not exist.
+func crossplaneCondition(unstr *unstructured.Unstructured, conditionType string) string {
+	conditions, _, _ := unstructured.NestedSlice(unstr.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok || c["type"] != conditionType {
+			continue
+		}
+		if status, ok := c["status"].(string); ok {
+			return status
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,161 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DiscoveryV1Graph is used to graph all discovery resources.
+type DiscoveryV1Graph struct {
+	graph *Graph
+}
+
+// NewDiscoveryV1Graph creates a new DiscoveryV1Graph.
+func NewDiscoveryV1Graph(g *Graph) *DiscoveryV1Graph {
+	return &DiscoveryV1Graph{
+		graph: g,
+	}
+}
+
+// DiscoveryV1 retrieves the DiscoveryV1Graph.
+func (g *Graph) DiscoveryV1() *DiscoveryV1Graph {
+	return g.discoveryV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *DiscoveryV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "EndpointSlice":
+		obj := &v1.EndpointSlice{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.EndpointSlice(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// EndpointSlice adds a v1.EndpointSlice resource and its Pods to the Graph.
+func (g *DiscoveryV1Graph) EndpointSlice(obj *v1.EndpointSlice) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "EndpointSlice"), obj)
+	n.Attribute("addressType", string(obj.AddressType))
+
+	for _, endpoint := range obj.Endpoints {
+		if _, err := g.Endpoint(n, endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// Endpoint adds a relationship from the node to the Pod of the v1.Endpoint,
+// the conditions and topology hints of the endpoint are added as attributes
+// to the relationship. Like for the selector of a Service, the relationship
+// to a ready Pod is labeled ROUTES_TO and to all other Pods SELECTS. An
+// endpoint without target points outside of the cluster, a relationship to
+// each of its addresses is returned. It returns no relationships if the
+// endpoint targets another object than a Pod.
+func (g *DiscoveryV1Graph) Endpoint(n *Node, endpoint v1.Endpoint) ([]*Relationship, error) {
+	rs := []*Relationship{}
+
+	switch {
+	case endpoint.TargetRef == nil:
+		for _, address := range endpoint.Addresses {
+			r, err := g.graph.CoreV1().External(n, "External", address)
+			if err != nil {
+				return nil, err
+			}
+			rs = append(rs, r)
+		}
+	case endpoint.TargetRef.Kind == "Pod":
+		p, err := g.graph.CoreV1().ObjectReference(endpoint.TargetRef)
+		if err != nil {
+			return nil, err
+		}
+		label := "ROUTES_TO"
+		if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+			label = "SELECTS"
+		}
+		rs = append(rs, g.graph.LabeledRelationship(n, label, p))
+	}
+
+	for _, r := range rs {
+		// a nil condition is interpreted as true, except for terminating
+		r.Attribute("ready", strconv.FormatBool(endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready))
+		r.Attribute("serving", strconv.FormatBool(endpoint.Conditions.Serving == nil || *endpoint.Conditions.Serving))
+		r.Attribute("terminating", strconv.FormatBool(endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating))
+		if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+			r.Attribute("style", "dashed")
+		}
+		if endpoint.Zone != nil {
+			r.Attribute("zone", *endpoint.Zone)
+		}
+		if endpoint.Hints != nil {
+			for _, zone := range endpoint.Hints.ForZones {
+				appendAttribute(r, "forZones", zone.Name)
+			}
+		}
+	}
+
+	return rs, nil
+}
+
+// ServiceEndpointSlices adds relationships from the Service node to the Pods
+// of all EndpointSlices of the v1.Service. In contrast to the selector, this
+// includes the backends of headless and externally managed services. The
+// traffic policies and the ports of the Service are added as attributes to
+// the relationships.
+func (g *DiscoveryV1Graph) ServiceEndpointSlices(n *Node, obj *corev1.Service) error {
+	selector := labels.SelectorFromSet(labels.Set{v1.LabelServiceName: obj.GetName()})
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+	slices, err := g.graph.clientset.DiscoveryV1().EndpointSlices(obj.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return err
+	}
+
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			rs, err := g.Endpoint(n, endpoint)
+			if err != nil {
+				return err
+			}
+
+			for _, r := range rs {
+				trafficPolicies(r, obj)
+				for _, port := range slice.Ports {
+					for _, p := range obj.Spec.Ports {
+						// the ports of a slice are named after the ports of the service
+						if port.Name != nil && *port.Name == p.Name && port.Port != nil {
+							servicePort(r, p, *port.Port)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
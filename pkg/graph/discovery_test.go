@@ -0,0 +1,92 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestEndpoint(t *testing.T) {
+	ready, notReady := true, false
+	zone := "eu-central-1a"
+
+	tests := []struct {
+		name     string
+		endpoint v1.Endpoint
+		labels   []string
+		ready    string
+	}{
+		{
+			name:     "external addresses",
+			endpoint: v1.Endpoint{Addresses: []string{"10.0.0.1", "10.0.0.2", "db.example.com"}, Conditions: v1.EndpointConditions{Ready: &ready}},
+			labels:   []string{"External", "External", "External"},
+			ready:    "true",
+		},
+		{
+			name:     "not ready external address",
+			endpoint: v1.Endpoint{Addresses: []string{"10.0.0.1"}, Conditions: v1.EndpointConditions{Ready: &notReady}, Zone: &zone},
+			labels:   []string{"External"},
+			ready:    "false",
+		},
+		{
+			name:     "ready pod",
+			endpoint: v1.Endpoint{Addresses: []string{"10.1.0.1"}, TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "db-0", UID: "db-0"}, Conditions: v1.EndpointConditions{Ready: &ready}},
+			labels:   []string{"ROUTES_TO"},
+			ready:    "true",
+		},
+		{
+			name:     "not ready pod",
+			endpoint: v1.Endpoint{Addresses: []string{"10.1.0.2"}, TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "db-1", UID: "db-1"}, Conditions: v1.EndpointConditions{Ready: &notReady}},
+			labels:   []string{"SELECTS"},
+			ready:    "false",
+		},
+		{
+			name:     "other target",
+			endpoint: v1.Endpoint{Addresses: []string{"10.0.0.1"}, TargetRef: &corev1.ObjectReference{Kind: "Node", Name: "worker"}},
+			labels:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewEmptyGraph(nil, nil)
+			n := g.Node(schema.FromAPIVersionAndKind("v1", "Service"), &metav1.ObjectMeta{UID: "service", Name: "db"})
+
+			rs, err := g.DiscoveryV1().Endpoint(n, tt.endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rs) != len(tt.labels) {
+				t.Fatalf("Endpoint() = %d relationships, want %d", len(rs), len(tt.labels))
+			}
+			for i, r := range rs {
+				if r.Label != tt.labels[i] {
+					t.Errorf("relationship %d label = %q, want %q", i, r.Label, tt.labels[i])
+				}
+				if got := r.Attr["ready"]; got != tt.ready {
+					t.Errorf("relationship %d ready = %q, want %q", i, got, tt.ready)
+				}
+				if tt.endpoint.Zone != nil && r.Attr["zone"] != *tt.endpoint.Zone {
+					t.Errorf("relationship %d zone = %q, want %q", i, r.Attr["zone"], *tt.endpoint.Zone)
+				}
+			}
+		})
+	}
+}
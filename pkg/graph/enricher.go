@@ -0,0 +1,154 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Enricher returns additional attributes of a node, so business metadata
+// of external systems like a CMDB can be attached to the Graph.
+type Enricher interface {
+	// Enrich returns the attributes which are added to the node or nil if
+	// the node is not known by this enricher.
+	Enrich(n *Node) (map[string]string, error)
+}
+
+// EnricherFunc is an adapter to use an ordinary function as Enricher.
+type EnricherFunc func(n *Node) (map[string]string, error)
+
+// Enrich calls f(n).
+func (f EnricherFunc) Enrich(n *Node) (map[string]string, error) {
+	return f(n)
+}
+
+// Enrich passes all nodes to the enrichers of Options.Enrichers in order and
+// adds the returned attributes to the nodes. Later enrichers override the
+// attributes of earlier ones.
+func (g *Graph) Enrich() error {
+	for _, node := range g.NodeList() {
+		for _, enricher := range g.Options.Enrichers {
+			attributes, err := enricher.Enrich(node)
+			if err != nil {
+				return err
+			}
+			for key, value := range attributes {
+				node.Attribute(key, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// InventoryEntry contains the attributes of all nodes in a namespace which
+// match a label selector, e.g. the owner team, cost center and tier.
+type InventoryEntry struct {
+	// Namespace of the nodes, an empty namespace matches all namespaces.
+	// The Namespace node itself is matched as well.
+	Namespace string `json:"namespace,omitempty"`
+	// Selector is a label query, an empty selector matches all nodes.
+	Selector string `json:"selector,omitempty"`
+	// Attributes are added to all matching nodes.
+	Attributes map[string]string `json:"attributes"`
+
+	selector labels.Selector
+}
+
+// Inventory is an Enricher which adds the attributes of all matching entries
+// in order, so more specific entries should be listed last.
+type Inventory []InventoryEntry
+
+// ReadInventoryJSON reads an Inventory from a JSON array of entries.
+func ReadInventoryJSON(r io.Reader) (Inventory, error) {
+	inventory := Inventory{}
+	if err := json.NewDecoder(r).Decode(&inventory); err != nil {
+		return nil, fmt.Errorf("invalid inventory: %v", err)
+	}
+
+	return inventory, inventory.parse()
+}
+
+// ReadInventoryCSV reads an Inventory from CSV. The header must start with
+// the columns "namespace" and "selector", all other columns are attributes,
+// empty values are skipped.
+func ReadInventoryCSV(r io.Reader) (Inventory, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid inventory: %v", err)
+	}
+	if len(records) == 0 || len(records[0]) < 2 || records[0][0] != "namespace" || records[0][1] != "selector" {
+		return nil, fmt.Errorf("invalid inventory: the header must start with namespace,selector")
+	}
+
+	header := records[0]
+	inventory := Inventory{}
+	for _, record := range records[1:] {
+		entry := InventoryEntry{
+			Namespace:  record[0],
+			Selector:   record[1],
+			Attributes: make(map[string]string),
+		}
+		for i, value := range record[2:] {
+			if len(value) != 0 {
+				entry.Attributes[header[i+2]] = value
+			}
+		}
+		inventory = append(inventory, entry)
+	}
+
+	return inventory, inventory.parse()
+}
+
+// parse parses the label selectors of all entries.
+func (inv Inventory) parse() error {
+	for i := range inv {
+		selector, err := labels.Parse(inv[i].Selector)
+		if err != nil {
+			return fmt.Errorf("invalid inventory selector %q: %v", inv[i].Selector, err)
+		}
+		inv[i].selector = selector
+	}
+
+	return nil
+}
+
+// Enrich returns the attributes of all entries which match the node.
+func (inv Inventory) Enrich(n *Node) (map[string]string, error) {
+	var attributes map[string]string
+
+	for _, entry := range inv {
+		if len(entry.Namespace) != 0 && entry.Namespace != n.Namespace && !(n.Kind == "Namespace" && entry.Namespace == n.Name) {
+			continue
+		}
+		if entry.selector != nil && !entry.selector.Matches(labels.Set(n.GetLabels())) {
+			continue
+		}
+
+		if attributes == nil {
+			attributes = make(map[string]string)
+		}
+		for key, value := range entry.Attributes {
+			attributes[key] = value
+		}
+	}
+
+	return attributes, nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	excalidrawNodeWidth  = 160
+	excalidrawNodeHeight = 50
+	excalidrawSpacing    = 40
+)
+
+// ExcalidrawScene represents an Excalidraw scene file.
+type ExcalidrawScene struct {
+	Type     string                 `json:"type"`
+	Version  int                    `json:"version"`
+	Source   string                 `json:"source"`
+	Elements []*ExcalidrawElement   `json:"elements"`
+	AppState map[string]interface{} `json:"appState"`
+	Files    map[string]interface{} `json:"files"`
+}
+
+// ExcalidrawElement represents a single element of an Excalidraw scene.
+type ExcalidrawElement struct {
+	ID              string               `json:"id"`
+	Type            string               `json:"type"`
+	X               int                  `json:"x"`
+	Y               int                  `json:"y"`
+	Width           int                  `json:"width"`
+	Height          int                  `json:"height"`
+	Angle           int                  `json:"angle"`
+	StrokeColor     string               `json:"strokeColor"`
+	BackgroundColor string               `json:"backgroundColor"`
+	FillStyle       string               `json:"fillStyle"`
+	StrokeWidth     int                  `json:"strokeWidth"`
+	StrokeStyle     string               `json:"strokeStyle"`
+	Roughness       int                  `json:"roughness"`
+	Opacity         int                  `json:"opacity"`
+	GroupIDs        []string             `json:"groupIds"`
+	Roundness       *ExcalidrawRoundness `json:"roundness"`
+	Seed            uint32               `json:"seed"`
+	Version         int                  `json:"version"`
+	VersionNonce    uint32               `json:"versionNonce"`
+	IsDeleted       bool                 `json:"isDeleted"`
+	BoundElements   []*ExcalidrawBinding `json:"boundElements"`
+	Link            *string              `json:"link"`
+	Locked          bool                 `json:"locked"`
+
+	// Text elements only.
+	Text          string  `json:"text,omitempty"`
+	OriginalText  string  `json:"originalText,omitempty"`
+	FontSize      int     `json:"fontSize,omitempty"`
+	FontFamily    int     `json:"fontFamily,omitempty"`
+	TextAlign     string  `json:"textAlign,omitempty"`
+	VerticalAlign string  `json:"verticalAlign,omitempty"`
+	ContainerID   *string `json:"containerId,omitempty"`
+	LineHeight    float64 `json:"lineHeight,omitempty"`
+
+	// Arrow elements only.
+	Points         [][2]int                `json:"points,omitempty"`
+	StartBinding   *ExcalidrawArrowBinding `json:"startBinding,omitempty"`
+	EndBinding     *ExcalidrawArrowBinding `json:"endBinding,omitempty"`
+	EndArrowhead   string                  `json:"endArrowhead,omitempty"`
+	StartArrowhead *string                 `json:"startArrowhead,omitempty"`
+}
+
+// ExcalidrawRoundness represents the corner style of an element.
+type ExcalidrawRoundness struct {
+	Type int `json:"type"`
+}
+
+// ExcalidrawBinding represents an element bound to another element.
+type ExcalidrawBinding struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// ExcalidrawArrowBinding represents the start or end of an arrow bound to an element.
+type ExcalidrawArrowBinding struct {
+	ElementID string  `json:"elementId"`
+	Focus     float64 `json:"focus"`
+	Gap       int     `json:"gap"`
+}
+
+// Excalidraw returns the graph as an Excalidraw scene. Nodes are grouped by
+// namespace, within a namespace each kind is placed in its own row.
+func (g *Graph) Excalidraw() *ExcalidrawScene {
+	scene := &ExcalidrawScene{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   "kubectl-graph",
+		Elements: []*ExcalidrawElement{},
+		AppState: map[string]interface{}{"viewBackgroundColor": g.Options.Theme.Background, "gridSize": nil},
+		Files:    map[string]interface{}{},
+	}
+
+	namespaces := make(map[string]map[string][]*Node)
+	for _, node := range g.NodeList() {
+		if _, ok := namespaces[node.Namespace]; !ok {
+			namespaces[node.Namespace] = make(map[string][]*Node)
+		}
+		namespaces[node.Namespace][node.Kind] = append(namespaces[node.Namespace][node.Kind], node)
+	}
+
+	rectangles := make(map[types.UID]*ExcalidrawElement)
+	x := 0
+	for _, namespace := range sortedKeys(namespaces) {
+		kinds := namespaces[namespace]
+		group := fmt.Sprintf("namespace-%s", namespace)
+		title := namespace
+		if len(title) == 0 {
+			title = "cluster-scoped"
+		}
+
+		header := newExcalidrawElement("text", "title-"+group, x, 0, excalidrawNodeWidth, 25)
+		header.StrokeColor = g.Options.Theme.Foreground
+		header.GroupIDs = []string{group}
+		header.setText(title, 20, "left", "top")
+		scene.Elements = append(scene.Elements, header)
+
+		columns := 0
+		for row, kind := range sortedKeys(kinds) {
+			nodes := kinds[kind]
+			sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+			for column, node := range nodes {
+				rect := newExcalidrawElement(
+					"rectangle",
+					string(node.UID),
+					x+column*(excalidrawNodeWidth+excalidrawSpacing),
+					50+row*(excalidrawNodeHeight+excalidrawSpacing),
+					excalidrawNodeWidth,
+					excalidrawNodeHeight,
+				)
+				rect.BackgroundColor = g.Color(node)
+				rect.GroupIDs = []string{group}
+				rect.Roundness = &ExcalidrawRoundness{Type: 3}
+
+				text := newExcalidrawElement("text", "text-"+string(node.UID), rect.X, rect.Y, rect.Width, rect.Height)
+				text.GroupIDs = []string{group}
+				text.setText(fmt.Sprintf("%s\n%s", node.Kind, node.Name), 14, "center", "middle")
+				text.ContainerID = &rect.ID
+				text.StrokeColor = g.Options.Theme.Foreground
+				rect.StrokeColor = g.Options.Theme.Foreground
+				rect.BoundElements = append(rect.BoundElements, &ExcalidrawBinding{ID: text.ID, Type: "text"})
+
+				rectangles[node.UID] = rect
+				scene.Elements = append(scene.Elements, rect, text)
+			}
+			if len(nodes) > columns {
+				columns = len(nodes)
+			}
+		}
+
+		x += columns*(excalidrawNodeWidth+excalidrawSpacing) + 2*excalidrawSpacing
+	}
+
+	for idx, relationship := range g.RelationshipList() {
+		from, to := rectangles[relationship.From], rectangles[relationship.To]
+		if from == nil || to == nil {
+			continue
+		}
+
+		startX, startY := from.X+from.Width/2, from.Y+from.Height
+		endX, endY := to.X+to.Width/2, to.Y
+		if to.Y <= from.Y {
+			endY = to.Y + to.Height
+			startY = from.Y
+		}
+
+		arrow := newExcalidrawElement("arrow", fmt.Sprintf("arrow-%d", idx), startX, startY, abs(endX-startX), abs(endY-startY))
+		arrow.Points = [][2]int{{0, 0}, {endX - startX, endY - startY}}
+		arrow.StartBinding = &ExcalidrawArrowBinding{ElementID: from.ID, Gap: 4}
+		arrow.EndBinding = &ExcalidrawArrowBinding{ElementID: to.ID, Gap: 4}
+		arrow.EndArrowhead = "arrow"
+		arrow.Roundness = &ExcalidrawRoundness{Type: 2}
+		arrow.StrokeColor = g.Options.Theme.Edge
+		if value, ok := relationship.Attr["color"]; ok {
+			arrow.StrokeColor = value
+		}
+		if relationship.Attr["style"] == "dashed" {
+			arrow.StrokeStyle = "dashed"
+		}
+
+		from.BoundElements = append(from.BoundElements, &ExcalidrawBinding{ID: arrow.ID, Type: "arrow"})
+		to.BoundElements = append(to.BoundElements, &ExcalidrawBinding{ID: arrow.ID, Type: "arrow"})
+		scene.Elements = append(scene.Elements, arrow)
+	}
+
+	return scene
+}
+
+// writeExcalidraw writes the graph as Excalidraw scene to w.
+func (g *Graph) writeExcalidraw(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(g.Excalidraw())
+}
+
+func newExcalidrawElement(kind string, id string, x int, y int, width int, height int) *ExcalidrawElement {
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+
+	return &ExcalidrawElement{
+		ID:              id,
+		Type:            kind,
+		X:               x,
+		Y:               y,
+		Width:           width,
+		Height:          height,
+		StrokeColor:     "#1e1e1e",
+		BackgroundColor: "transparent",
+		FillStyle:       "solid",
+		StrokeWidth:     1,
+		StrokeStyle:     "solid",
+		Roughness:       1,
+		Opacity:         100,
+		GroupIDs:        []string{},
+		Seed:            hash.Sum32(),
+		Version:         1,
+		BoundElements:   []*ExcalidrawBinding{},
+	}
+}
+
+func (e *ExcalidrawElement) setText(text string, size int, align string, verticalAlign string) {
+	e.Text = text
+	e.OriginalText = text
+	e.FontSize = size
+	e.FontFamily = 1
+	e.TextAlign = align
+	e.VerticalAlign = verticalAlign
+	e.LineHeight = 1.25
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
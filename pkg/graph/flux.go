@@ -0,0 +1,323 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fluxSourceV1 is the group version of the Flux sources which are read from the cluster.
+var fluxSourceV1 = schema.GroupVersion{Group: "source.toolkit.fluxcd.io", Version: "v1"}
+
+// fluxSources maps the kind of a Flux source to its group version and resource.
+var fluxSources = map[string]schema.GroupVersionResource{
+	"Bucket":         fluxSourceV1.WithResource("buckets"),
+	"GitRepository":  fluxSourceV1.WithResource("gitrepositories"),
+	"HelmChart":      fluxSourceV1.WithResource("helmcharts"),
+	"HelmRepository": fluxSourceV1.WithResource("helmrepositories"),
+	"OCIRepository":  {Group: fluxSourceV1.Group, Version: "v1beta2", Resource: "ocirepositories"},
+}
+
+// fluxManagedLabels maps the kind of a Flux reconciler to the labels which
+// it adds to all of its managed objects.
+var fluxManagedLabels = map[string][2]string{
+	"Kustomization": {"kustomize.toolkit.fluxcd.io/name", "kustomize.toolkit.fluxcd.io/namespace"},
+	"HelmRelease":   {"helm.toolkit.fluxcd.io/name", "helm.toolkit.fluxcd.io/namespace"},
+}
+
+// FluxGraph is used to graph all toolkit.fluxcd.io resources.
+type FluxGraph struct {
+	graph *Graph
+
+	// sources contains the nodes by kind, namespace and name, because they
+	// are referenced by many Kustomizations and HelmReleases.
+	sources map[string]*Node
+	// reconcilers contains the Kustomizations and HelmReleases by kind,
+	// namespace and name, which are linked to their managed objects.
+	reconcilers map[string]*Node
+	// dependencies contains the dependencies of the reconcilers, they are
+	// linked once all reconcilers are added.
+	dependencies []fluxDependency
+}
+
+// fluxDependency is a Kustomization or HelmRelease which must be reconciled before another one.
+type fluxDependency struct {
+	from      *Node
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// NewFluxGraph creates a new FluxGraph.
+func NewFluxGraph(g *Graph) *FluxGraph {
+	return &FluxGraph{
+		graph:       g,
+		sources:     make(map[string]*Node),
+		reconcilers: make(map[string]*Node),
+	}
+}
+
+// Flux retrieves the FluxGraph.
+func (g *Graph) Flux() *FluxGraph {
+	return g.flux
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *FluxGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Kustomization":
+		return g.Kustomization(unstr)
+	case "HelmRelease":
+		return g.HelmRelease(unstr)
+	default:
+		if _, ok := fluxSources[unstr.GetKind()]; ok {
+			return g.Source(unstr)
+		}
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Source adds a Flux source resource like a GitRepository to the Graph,
+// including its URL and the revision of its last artifact.
+func (g *FluxGraph) Source(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.sources[path.Join(unstr.GetKind(), unstr.GetNamespace(), unstr.GetName())] = n
+	fluxAttributes(n, unstr)
+
+	if url, ok, _ := unstructured.NestedString(unstr.Object, "spec", "url"); ok {
+		n.Attribute("url", url)
+	}
+	if secret, ok, _ := unstructured.NestedString(unstr.Object, "spec", "secretRef", "name"); ok {
+		g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), secret))
+	}
+
+	// a HelmChart is built from another source
+	if _, err := g.sourceRef(n, unstr.GetNamespace(), unstr.Object, "spec", "sourceRef"); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// SourceName adds the Flux source with the given kind and name to the Graph.
+// A missing source is added as node without UID from the cluster.
+func (g *FluxGraph) SourceName(kind string, namespace string, name string) (*Node, error) {
+	if n, ok := g.sources[path.Join(kind, namespace, name)]; ok {
+		return n, nil
+	}
+
+	gvr, ok := fluxSources[kind]
+	if ok {
+		unstr, err := g.graph.CustomResource(gvr, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if unstr != nil {
+			return g.Source(unstr)
+		}
+	}
+
+	n := g.graph.Node(
+		gvr.GroupVersion().WithKind(kind),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, kind, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.sources[path.Join(kind, namespace, name)] = n
+	if ok {
+		g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+	}
+
+	return n, nil
+}
+
+// Kustomization adds a Kustomization resource and its source to the Graph.
+// The dependencies and the managed objects are linked by Managed.
+func (g *FluxGraph) Kustomization(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.reconcilers[path.Join(unstr.GetKind(), unstr.GetNamespace(), unstr.GetName())] = n
+	fluxAttributes(n, unstr)
+
+	if p, ok, _ := unstructured.NestedString(unstr.Object, "spec", "path"); ok {
+		n.Attribute("path", p)
+	}
+	if revision, ok, _ := unstructured.NestedString(unstr.Object, "status", "lastAppliedRevision"); ok {
+		n.Attribute("lastAppliedRevision", revision)
+	}
+
+	if _, err := g.sourceRef(n, unstr.GetNamespace(), unstr.Object, "spec", "sourceRef"); err != nil {
+		return nil, err
+	}
+	g.dependsOn(n, unstr)
+
+	return n, nil
+}
+
+// HelmRelease adds a HelmRelease resource, its chart source and its values
+// to the Graph. The dependencies and the managed objects are linked by Managed.
+func (g *FluxGraph) HelmRelease(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.reconcilers[path.Join(unstr.GetKind(), unstr.GetNamespace(), unstr.GetName())] = n
+	fluxAttributes(n, unstr)
+
+	if chart, ok, _ := unstructured.NestedString(unstr.Object, "spec", "chart", "spec", "chart"); ok {
+		n.Attribute("chart", chart)
+	}
+	if revision, ok, _ := unstructured.NestedString(unstr.Object, "status", "lastAttemptedRevision"); ok {
+		n.Attribute("lastAttemptedRevision", revision)
+	}
+
+	if _, err := g.sourceRef(n, unstr.GetNamespace(), unstr.Object, "spec", "chart", "spec", "sourceRef"); err != nil {
+		return nil, err
+	}
+	if _, err := g.sourceRef(n, unstr.GetNamespace(), unstr.Object, "spec", "chartRef"); err != nil {
+		return nil, err
+	}
+
+	valuesFrom, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "valuesFrom")
+	for _, values := range valuesFrom {
+		v, ok := values.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(v, "kind")
+		name, _, _ := unstructured.NestedString(v, "name")
+		switch kind {
+		case "ConfigMap":
+			g.graph.Relationship(n, "Values", g.graph.CoreV1().ConfigMapName(unstr.GetNamespace(), name))
+		case "Secret":
+			g.graph.Relationship(n, "Values", g.graph.CoreV1().SecretName(unstr.GetNamespace(), name))
+		}
+	}
+	g.dependsOn(n, unstr)
+
+	return n, nil
+}
+
+// Managed adds relationships from all Kustomizations and HelmReleases to
+// the objects of the Graph which have their labels and to their
+// dependencies. The number of managed objects is added as attribute to the
+// Kustomizations and HelmReleases. Dependencies which are not part of the
+// Graph are added as bare metadata.
+func (g *FluxGraph) Managed() {
+	for _, dependency := range g.dependencies {
+		dep, ok := g.reconcilers[path.Join(dependency.gvk.Kind, dependency.namespace, dependency.name)]
+		if !ok {
+			dep = g.graph.Node(
+				dependency.gvk,
+				&metav1.ObjectMeta{
+					UID:       g.graph.ToUID(dependency.namespace, dependency.gvk.Kind, dependency.name),
+					Name:      dependency.name,
+					Namespace: dependency.namespace,
+				},
+			)
+		}
+		g.graph.Relationship(dependency.from, "DependsOn", dep)
+	}
+
+	managed := make(map[*Node]int)
+	for _, n := range g.reconcilers {
+		managed[n] = 0
+	}
+
+	for _, node := range g.graph.NodeList() {
+		if !g.graph.objects[node.UID] {
+			continue
+		}
+
+		for kind, keys := range fluxManagedLabels {
+			name, ok := node.GetLabels()[keys[0]]
+			if !ok {
+				continue
+			}
+			n, ok := g.reconcilers[path.Join(kind, node.GetLabels()[keys[1]], name)]
+			if !ok || n == node {
+				continue
+			}
+
+			g.graph.LabeledRelationship(n, "MANAGES", node)
+			managed[n]++
+		}
+	}
+
+	for n, count := range managed {
+		n.Attribute("managed", strconv.Itoa(count))
+	}
+}
+
+// sourceRef adds a relationship from n to the Flux source at the path of fields.
+func (g *FluxGraph) sourceRef(n *Node, namespace string, obj map[string]interface{}, fields ...string) (*Relationship, error) {
+	ref, ok, _ := unstructured.NestedMap(obj, fields...)
+	if !ok {
+		return nil, nil
+	}
+
+	kind, _, _ := unstructured.NestedString(ref, "kind")
+	name, _, _ := unstructured.NestedString(ref, "name")
+	if ns, _, _ := unstructured.NestedString(ref, "namespace"); len(ns) != 0 {
+		namespace = ns
+	}
+	if len(kind) == 0 || len(name) == 0 {
+		return nil, nil
+	}
+
+	s, err := g.SourceName(kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.graph.Relationship(n, "Source", s), nil
+}
+
+// dependsOn records the Kustomizations or HelmReleases which n depends on.
+func (g *FluxGraph) dependsOn(n *Node, unstr *unstructured.Unstructured) {
+	dependencies, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "dependsOn")
+	for _, dependency := range dependencies {
+		d, ok := dependency.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(d, "name")
+		namespace, _, _ := unstructured.NestedString(d, "namespace")
+		if len(namespace) == 0 {
+			namespace = unstr.GetNamespace()
+		}
+
+		g.dependencies = append(g.dependencies, fluxDependency{
+			from:      n,
+			gvk:       unstr.GroupVersionKind(),
+			namespace: namespace,
+			name:      name,
+		})
+	}
+}
+
+// fluxAttributes adds the status and the suspension of a Flux resource as attributes.
+func fluxAttributes(n *Node, unstr *unstructured.Unstructured) {
+	n.Attribute("status", readyStatus(unstr))
+	if suspend, _, _ := unstructured.NestedBool(unstr.Object, "spec", "suspend"); suspend {
+		n.Attribute("suspended", "true")
+	}
+	if revision, ok, _ := unstructured.NestedString(unstr.Object, "status", "artifact", "revision"); ok {
+		n.Attribute("revision", revision)
+	}
+}
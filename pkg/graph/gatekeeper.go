@@ -0,0 +1,243 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	// gatekeeperConstraintsV1beta1 is the group version of all Gatekeeper constraints.
+	gatekeeperConstraintsV1beta1 = schema.GroupVersion{Group: "constraints.gatekeeper.sh", Version: "v1beta1"}
+	// gatekeeperTemplatesV1 is the group version of the Gatekeeper ConstraintTemplates.
+	gatekeeperTemplatesV1 = schema.GroupVersion{Group: "templates.gatekeeper.sh", Version: "v1"}
+)
+
+// GatekeeperGraph is used to graph all gatekeeper.sh resources.
+type GatekeeperGraph struct {
+	graph *Graph
+
+	// templates contains the ConstraintTemplates by name, which is the
+	// lowercase kind of their constraints.
+	templates map[string]*Node
+	// constraints contains the constraints by kind and name, because they
+	// are listed by their ConstraintTemplate.
+	constraints map[string]*Node
+}
+
+// NewGatekeeperGraph creates a new GatekeeperGraph.
+func NewGatekeeperGraph(g *Graph) *GatekeeperGraph {
+	return &GatekeeperGraph{
+		graph:       g,
+		templates:   make(map[string]*Node),
+		constraints: make(map[string]*Node),
+	}
+}
+
+// Gatekeeper retrieves the GatekeeperGraph.
+func (g *Graph) Gatekeeper() *GatekeeperGraph {
+	return g.gatekeeper
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *GatekeeperGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch {
+	case unstr.GetKind() == "ConstraintTemplate":
+		return g.ConstraintTemplate(unstr)
+	case unstr.GroupVersionKind().Group == gatekeeperConstraintsV1beta1.Group:
+		return g.Constraint(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// ConstraintTemplate adds a ConstraintTemplate resource and all constraints of its kind to the Graph.
+func (g *GatekeeperGraph) ConstraintTemplate(unstr *unstructured.Unstructured) (*Node, error) {
+	if n, ok := g.templates[unstr.GetName()]; ok && n.GetUID() == unstr.GetUID() {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.templates[unstr.GetName()] = n
+
+	kind, _, _ := unstructured.NestedString(unstr.Object, "spec", "crd", "spec", "names", "kind")
+	if len(kind) == 0 {
+		return n, nil
+	}
+	n.Attribute("constraintKind", kind)
+	if created, ok, _ := unstructured.NestedBool(unstr.Object, "status", "created"); ok {
+		n.Attribute("created", strconv.FormatBool(created))
+	}
+
+	constraints, err := g.graph.CustomResources(gatekeeperConstraintsV1beta1.WithResource(strings.ToLower(kind)), "", "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range constraints {
+		if _, err := g.Constraint(&constraints[i]); err != nil {
+			return nil, err
+		}
+	}
+	n.Attribute("constraints", strconv.Itoa(len(constraints)))
+
+	return n, nil
+}
+
+// Constraint adds a constraint, its ConstraintTemplate, the kinds and
+// namespaces which are matched by it and the resources which violate it in
+// the last audit to the Graph.
+func (g *GatekeeperGraph) Constraint(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetKind(), unstr.GetName())
+	if n, ok := g.constraints[key]; ok {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.constraints[key] = n
+
+	t, err := g.ConstraintTemplateName(strings.ToLower(unstr.GetKind()))
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(t, "Constraint", n)
+
+	action, ok, _ := unstructured.NestedString(unstr.Object, "spec", "enforcementAction")
+	if !ok {
+		action = "deny"
+	}
+	n.Attribute("enforcementAction", action)
+
+	if err := g.match(n, unstr); err != nil {
+		return nil, err
+	}
+
+	if total, ok, _ := unstructured.NestedInt64(unstr.Object, "status", "totalViolations"); ok {
+		n.Attribute("totalViolations", strconv.FormatInt(total, 10))
+	}
+	if timestamp, ok, _ := unstructured.NestedString(unstr.Object, "status", "auditTimestamp"); ok {
+		n.Attribute("auditTimestamp", timestamp)
+	}
+
+	// the audit only reports a limited number of violations per constraint
+	violations, _, _ := unstructured.NestedSlice(unstr.Object, "status", "violations")
+	for _, violation := range violations {
+		v, ok := violation.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(v, "group")
+		version, _, _ := unstructured.NestedString(v, "version")
+		kind, _, _ := unstructured.NestedString(v, "kind")
+		namespace, _, _ := unstructured.NestedString(v, "namespace")
+		name, _, _ := unstructured.NestedString(v, "name")
+
+		gvk := schema.GroupVersionKind{Group: group, Version: version, Kind: kind}
+		r := g.graph.Violation(n, gvk, namespace, name, g.uid(gvk, namespace, name))
+		if message, ok, _ := unstructured.NestedString(v, "message"); ok {
+			r.Attribute("message", message)
+		}
+	}
+
+	return n, nil
+}
+
+// ConstraintTemplateName adds the ConstraintTemplate with the given name to the Graph.
+// A missing ConstraintTemplate is added as node without UID from the cluster.
+func (g *GatekeeperGraph) ConstraintTemplateName(name string) (*Node, error) {
+	if n, ok := g.templates[name]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(gatekeeperTemplatesV1.WithResource("constrainttemplates"), "", name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+		g.templates[name] = n
+		return n, nil
+	}
+
+	n := g.graph.Node(
+		gatekeeperTemplatesV1.WithKind("ConstraintTemplate"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID("", "ConstraintTemplate", name),
+			Name: name,
+		},
+	)
+	g.templates[name] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// match adds relationships from a constraint to the kinds and namespaces
+// which are matched by it. Namespaces with wildcards are added as attribute.
+func (g *GatekeeperGraph) match(n *Node, unstr *unstructured.Unstructured) error {
+	kinds, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "match", "kinds")
+	for _, kind := range kinds {
+		k, ok := kind.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		groups, _, _ := unstructured.NestedStringSlice(k, "apiGroups")
+		names, _, _ := unstructured.NestedStringSlice(k, "kinds")
+		for _, group := range groups {
+			for _, name := range names {
+				g.graph.Relationship(n, "Kind", g.graph.AdmissionregistrationV1().Kind(group, name))
+			}
+		}
+	}
+
+	for _, field := range []string{"namespaces", "excludedNamespaces"} {
+		namespaces, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "match", field)
+		if len(namespaces) != 0 {
+			n.Attribute(field, strings.Join(namespaces, ","))
+		}
+	}
+	if scope, ok, _ := unstructured.NestedString(unstr.Object, "spec", "match", "scope"); ok {
+		n.Attribute("scope", scope)
+	}
+
+	if selector, ok, _ := unstructured.NestedMap(unstr.Object, "spec", "match", "namespaceSelector"); ok {
+		namespaceSelector := &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selector, namespaceSelector); err != nil {
+			return err
+		}
+		return g.graph.AdmissionregistrationV1().namespaceSelector(n, namespaceSelector)
+	}
+
+	return nil
+}
+
+// uid returns the UID of the node of a violating resource, if it is graphed.
+// The violations of the audit do not contain the UID of the resource.
+func (g *GatekeeperGraph) uid(gvk schema.GroupVersionKind, namespace string, name string) types.UID {
+	for _, node := range g.graph.Nodes {
+		if node.Kind == gvk.Kind && node.GetNamespace() == namespace && node.GetName() == name && node.GroupVersionKind().Group == gvk.Group {
+			return node.GetUID()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,221 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// grafanaV1beta1 is the group version of all Grafana Operator resources.
+var grafanaV1beta1 = schema.GroupVersion{Group: "grafana.integreatly.org", Version: "v1beta1"}
+
+// GrafanaGraph is used to graph all grafana.integreatly.org resources.
+type GrafanaGraph struct {
+	graph *Graph
+
+	// instances contains the Grafana nodes by namespace and name, because
+	// they are selected by all other resources.
+	instances map[string]*Node
+}
+
+// NewGrafanaGraph creates a new GrafanaGraph.
+func NewGrafanaGraph(g *Graph) *GrafanaGraph {
+	return &GrafanaGraph{
+		graph:     g,
+		instances: make(map[string]*Node),
+	}
+}
+
+// Grafana retrieves the GrafanaGraph.
+func (g *Graph) Grafana() *GrafanaGraph {
+	return g.grafana
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *GrafanaGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Grafana":
+		return g.Grafana(unstr)
+	case "GrafanaDashboard":
+		return g.Dashboard(unstr)
+	case "GrafanaDatasource":
+		return g.Datasource(unstr)
+	case "GrafanaFolder", "GrafanaAlertRuleGroup", "GrafanaContactPoint", "GrafanaNotificationPolicy":
+		n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+		if err := g.instanceSelector(n, unstr); err != nil {
+			return nil, err
+		}
+		return n, nil
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Grafana adds a Grafana instance and the Secrets of the admin credentials of
+// an external instance to the Graph. The Deployment and Service of a managed
+// instance are owned by it.
+func (g *GrafanaGraph) Grafana(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.instances[key]; ok {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.instances[key] = n
+
+	for _, field := range []string{"stage", "stageStatus", "version", "adminUrl"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "status", field); ok && len(value) != 0 {
+			n.Attribute(field, value)
+		}
+	}
+
+	if url, ok, _ := unstructured.NestedString(unstr.Object, "spec", "external", "url"); ok {
+		n.Attribute("external", url)
+		for _, field := range []string{"adminUser", "adminPassword"} {
+			if name, ok, _ := unstructured.NestedString(unstr.Object, "spec", "external", field, "name"); ok {
+				g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), name))
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Dashboard adds a GrafanaDashboard resource, its Grafana instances and the
+// ConfigMaps and Secrets of its JSON model and environment to the Graph.
+func (g *GrafanaGraph) Dashboard(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	if err := g.instanceSelector(n, unstr); err != nil {
+		return nil, err
+	}
+
+	if name, ok, _ := unstructured.NestedString(unstr.Object, "spec", "configMapRef", "name"); ok {
+		r := g.graph.Relationship(n, "ConfigMap", g.graph.CoreV1().ConfigMapName(unstr.GetNamespace(), name))
+		if key, ok, _ := unstructured.NestedString(unstr.Object, "spec", "configMapRef", "key"); ok {
+			r.Attribute("key", key)
+		}
+	}
+	if id, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "grafanaCom", "id"); ok {
+		n.Attribute("grafanaCom", strconv.FormatInt(id, 10))
+	}
+	if url, ok, _ := unstructured.NestedString(unstr.Object, "spec", "url"); ok {
+		if _, err := g.graph.CoreV1().URL(n, "URL", url); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range []string{"folder", "folderRef", "folderUID"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "spec", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+	if uid, ok, _ := unstructured.NestedString(unstr.Object, "status", "uid"); ok {
+		n.Attribute("uid", uid)
+	}
+
+	envFrom, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "envFrom")
+	for _, env := range envFrom {
+		if e, ok := env.(map[string]interface{}); ok {
+			g.valueFrom(n, unstr.GetNamespace(), e)
+		}
+	}
+
+	return n, nil
+}
+
+// Datasource adds a GrafanaDatasource resource, its Grafana instances and the
+// ConfigMaps and Secrets of its values to the Graph.
+func (g *GrafanaGraph) Datasource(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	if err := g.instanceSelector(n, unstr); err != nil {
+		return nil, err
+	}
+
+	if kind, ok, _ := unstructured.NestedString(unstr.Object, "spec", "datasource", "type"); ok {
+		n.Attribute("type", kind)
+	}
+	if url, ok, _ := unstructured.NestedString(unstr.Object, "spec", "datasource", "url"); ok && len(url) != 0 {
+		if _, err := g.graph.CoreV1().URL(n, "URL", url); err != nil {
+			return nil, err
+		}
+	}
+
+	valuesFrom, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "valuesFrom")
+	for _, value := range valuesFrom {
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if from, ok, _ := unstructured.NestedMap(v, "valueFrom"); ok {
+			g.valueFrom(n, unstr.GetNamespace(), from)
+		}
+	}
+
+	return n, nil
+}
+
+// instanceSelector adds relationships from n to all Grafana instances which
+// are matched by its instance selector. Instances of other namespaces are only
+// matched if cross namespace import is allowed.
+func (g *GrafanaGraph) instanceSelector(n *Node, unstr *unstructured.Unstructured) error {
+	selector, ok, err := NestedLabelSelector(unstr.Object, "spec", "instanceSelector")
+	if err != nil || !ok {
+		return err
+	}
+
+	namespace := unstr.GetNamespace()
+	if allow, _, _ := unstructured.NestedBool(unstr.Object, "spec", "allowCrossNamespaceImport"); allow {
+		namespace = ""
+	}
+
+	instances, err := g.graph.CustomResources(grafanaV1beta1.WithResource("grafanas"), namespace, selector.String())
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		g.graph.Warn(WarningNotFound, n, "no Grafana instance matches the instance selector %s", selector)
+	}
+
+	for i := range instances {
+		m, err := g.Grafana(&instances[i])
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(n, "Grafana", m)
+	}
+
+	return nil
+}
+
+// valueFrom adds a relationship from n to the ConfigMap or Secret of a key reference.
+func (g *GrafanaGraph) valueFrom(n *Node, namespace string, obj map[string]interface{}) {
+	if name, ok, _ := unstructured.NestedString(obj, "configMapKeyRef", "name"); ok {
+		r := g.graph.Relationship(n, "ConfigMap", g.graph.CoreV1().ConfigMapName(namespace, name))
+		if key, ok, _ := unstructured.NestedString(obj, "configMapKeyRef", "key"); ok {
+			appendAttribute(r, "keys", key)
+		}
+	}
+	if name, ok, _ := unstructured.NestedString(obj, "secretKeyRef", "name"); ok {
+		r := g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(namespace, name))
+		if key, ok, _ := unstructured.NestedString(obj, "secretKeyRef", "key"); ok {
+			appendAttribute(r, "keys", key)
+		}
+	}
+}
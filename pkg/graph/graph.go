@@ -16,18 +16,26 @@ package graph
 
 import (
 	"bytes"
-	"crypto/md5"
+	"context"
 	"embed"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
+	"unicode"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -39,12 +47,114 @@ import (
 const (
 	// DefaultNodeNameLimit represents the default limit to truncate the node name to N characters.
 	DefaultNodeNameLimit int = 12
+	// OwnershipWeight represents the weight of relationships from an owner to its dependents.
+	OwnershipWeight int = 10
+	// ReferenceWeight represents the default weight of all other relationships.
+	ReferenceWeight int = 1
+	// DefaultJobHistoryLimit represents the default number of Jobs per CronJob which are not collapsed.
+	DefaultJobHistoryLimit int = 5
+)
+
+// Status values of the "status" attribute of custom resources, which are
+// derived from their Ready condition.
+const (
+	ConditionReady    = "Ready"
+	ConditionNotReady = "NotReady"
+	ConditionUnknown  = "Unknown"
 )
 
 var (
+	// Dependencies contains the resources which are read by the graphers
+	// in addition to the requested resources.
+	Dependencies = []schema.GroupResource{
+		{Group: "", Resource: "endpoints"},
+		{Group: "", Resource: "events"},
+		{Group: "", Resource: "namespaces"},
+		{Group: "", Resource: "nodes"},
+		{Group: "", Resource: "persistentvolumeclaims"},
+		{Group: "", Resource: "persistentvolumes"},
+		{Group: "", Resource: "pods"},
+		{Group: "", Resource: "replicationcontrollers"},
+		{Group: "", Resource: "serviceaccounts"},
+		{Group: "", Resource: "services"},
+		{Group: "acme.cert-manager.io", Resource: "challenges"},
+		{Group: "acme.cert-manager.io", Resource: "orders"},
+		{Group: "apiextensions.crossplane.io", Resource: "compositeresourcedefinitions"},
+		{Group: "apiextensions.crossplane.io", Resource: "compositions"},
+		{Group: "apps", Resource: "daemonsets"},
+		{Group: "apps", Resource: "deployments"},
+		{Group: "apps", Resource: "replicasets"},
+		{Group: "apps", Resource: "statefulsets"},
+		{Group: "argoproj.io", Resource: "clusterworkflowtemplates"},
+		{Group: "argoproj.io", Resource: "workflows"},
+		{Group: "argoproj.io", Resource: "workflowtemplates"},
+		{Group: "batch", Resource: "jobs"},
+		{Group: "build.openshift.io", Resource: "builds"},
+		{Group: "cert-manager.io", Resource: "certificaterequests"},
+		{Group: "cert-manager.io", Resource: "certificates"},
+		{Group: "cert-manager.io", Resource: "clusterissuers"},
+		{Group: "cert-manager.io", Resource: "issuers"},
+		{Group: "constraints.gatekeeper.sh", Resource: "*"},
+		{Group: "crd.projectcalico.org", Resource: "globalnetworksets"},
+		{Group: "crd.projectcalico.org", Resource: "networksets"},
+		{Group: "discovery.k8s.io", Resource: "endpointslices"},
+		{Group: "eventing.knative.dev", Resource: "brokers"},
+		{Group: "grafana.integreatly.org", Resource: "grafanas"},
+		{Group: "image.openshift.io", Resource: "imagestreams"},
+		{Group: "k8s.cni.cncf.io", Resource: "network-attachment-definitions"},
+		{Group: "kafka.strimzi.io", Resource: "kafkaconnectors"},
+		{Group: "kafka.strimzi.io", Resource: "kafkanodepools"},
+		{Group: "kafka.strimzi.io", Resource: "kafkas"},
+		{Group: "kafka.strimzi.io", Resource: "kafkatopics"},
+		{Group: "longhorn.io", Resource: "engines"},
+		{Group: "longhorn.io", Resource: "replicas"},
+		{Group: "longhorn.io", Resource: "volumes"},
+		{Group: "messaging.knative.dev", Resource: "channels"},
+		{Group: "networking.istio.io", Resource: "gateways"},
+		{Group: "networking.k8s.io", Resource: "ingressclasses"},
+		{Group: "node.k8s.io", Resource: "runtimeclasses"},
+		{Group: "operator.openshift.io", Resource: "ingresscontrollers"},
+		{Group: "operators.coreos.com", Resource: "catalogsources"},
+		{Group: "operators.coreos.com", Resource: "clusterserviceversions"},
+		{Group: "operators.coreos.com", Resource: "installplans"},
+		{Group: "pkg.crossplane.io", Resource: "functions"},
+		{Group: "pkg.crossplane.io", Resource: "providerrevisions"},
+		{Group: "policy.linkerd.io", Resource: "servers"},
+		{Group: "postgresql.cnpg.io", Resource: "clusters"},
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+		{Group: "rbac.authorization.k8s.io", Resource: "roles"},
+		{Group: "scheduling.k8s.io", Resource: "priorityclasses"},
+		{Group: "secrets-store.csi.x-k8s.io", Resource: "secretproviderclasses"},
+		{Group: "serving.knative.dev", Resource: "configurations"},
+		{Group: "serving.knative.dev", Resource: "revisions"},
+		{Group: "serving.knative.dev", Resource: "routes"},
+		{Group: "source.toolkit.fluxcd.io", Resource: "buckets"},
+		{Group: "source.toolkit.fluxcd.io", Resource: "gitrepositories"},
+		{Group: "source.toolkit.fluxcd.io", Resource: "helmcharts"},
+		{Group: "source.toolkit.fluxcd.io", Resource: "helmrepositories"},
+		{Group: "source.toolkit.fluxcd.io", Resource: "ocirepositories"},
+		{Group: "storage.k8s.io", Resource: "csidrivers"},
+		{Group: "storage.k8s.io", Resource: "storageclasses"},
+		{Group: "templates.gatekeeper.sh", Resource: "constrainttemplates"},
+		{Group: "tekton.dev", Resource: "clustertasks"},
+		{Group: "tekton.dev", Resource: "pipelines"},
+		{Group: "tekton.dev", Resource: "taskruns"},
+		{Group: "tekton.dev", Resource: "tasks"},
+	}
+
 	//go:embed templates/*.tmpl
 	templateFiles embed.FS
 	templates     *template.Template
+
+	// writers contains all output formats which are not rendered by a template.
+	writers = map[string]func(*Graph, io.Writer) error{
+		"bloom":      (*Graph).writeBloom,
+		"excalidraw": (*Graph).writeExcalidraw,
+		"graphology": (*Graph).writeGraphology,
+		"markdown":   (*Graph).writeMarkdown,
+		"schema":     (*Graph).writeSchema,
+		"tgf":        (*Graph).writeTGF,
+	}
 )
 
 func init() {
@@ -63,13 +173,24 @@ func init() {
 			}
 			return strings.Trim(string(b), "\n")
 		},
-		"underscore": func(s string) string {
-			re := regexp.MustCompile(`[^A-Za-z0-9]+`)
-			return re.ReplaceAllString(strings.ToLower(s), "_")
+		"xml": func(s string) string {
+			b := &strings.Builder{}
+			if err := xml.EscapeText(b, []byte(s)); err != nil {
+				return err.Error()
+			}
+			return b.String()
+		},
+		"dot":        dotString,
+		"cypher":     cypherString,
+		"identifier": cypherIdentifier,
+		"underscore": underscore,
+		"dotAttribute": func(key string) bool {
+			return dotAttributes[key]
 		},
+		// color is kept for custom templates, it ignores the theme and
+		// Options.Hash, use $.Color instead.
 		"color": func(s string) string {
-			hash := md5.Sum([]byte(s))
-			return fmt.Sprintf("#%x", hash[:3])
+			return fmt.Sprintf("#%x", hashes[HashMD5]([]byte(s))[:3])
 		},
 		"truncate": func(s string, max int) string {
 			if max < 3 {
@@ -85,6 +206,83 @@ func init() {
 	template.Must(templates.ParseFS(templateFiles, "templates/*.tmpl"))
 }
 
+// dotString returns s as a quoted DOT string. Quotes and backslashes are
+// escaped and line breaks are replaced by the DOT escape sequence, so the
+// value can't break the statement.
+func dotString(s interface{}) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return `"` + replacer.Replace(fmt.Sprint(s)) + `"`
+}
+
+// dotAttributes contains the attributes of relationships which are set to
+// style them and rendered as graphviz attributes. All other attributes are
+// added to the tooltip, so they can't change the rendering.
+var dotAttributes = map[string]bool{
+	"color": true,
+	"style": true,
+}
+
+// cypherString returns s as a quoted Cypher string literal. Quotes, backslashes
+// and control characters are escaped, so the value can't break the statement.
+func cypherString(s interface{}) string {
+	b := &strings.Builder{}
+	b.WriteByte('"')
+	for _, r := range fmt.Sprint(s) {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if unicode.IsControl(r) || r == '\u2028' || r == '\u2029' {
+				fmt.Fprintf(b, `\u%04x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// cypherIdentifier returns s as a quoted Cypher identifier, e.g. a label or a
+// relationship type. Backticks are escaped by doubling them.
+func cypherIdentifier(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// underscoreRegexp matches all characters which are not allowed in property names.
+var underscoreRegexp = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// underscore returns s in lower case with all other characters than letters
+// and digits replaced by "_", e.g. "app.kubernetes.io/name" results in "app_kubernetes_io_name".
+func underscore(s string) string {
+	return underscoreRegexp.ReplaceAllString(strings.ToLower(s), "_")
+}
+
+// Formats returns the names of all supported output formats.
+func Formats() []string {
+	formats := []string{}
+	for _, t := range templates.Templates() {
+		if strings.HasSuffix(t.Name(), ".tmpl") {
+			formats = append(formats, strings.TrimSuffix(t.Name(), ".tmpl"))
+		}
+	}
+	for format := range writers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	return formats
+}
+
 // Graph stores nodes and relationships between them.
 type Graph struct {
 	Nodes         map[types.UID]*Node
@@ -93,15 +291,70 @@ type Graph struct {
 
 	clientset *kubernetes.Clientset
 
-	coreV1       *CoreV1Graph
-	networkingV1 *NetworkingV1Graph
-	routeV1      *RouteV1Graph
+	// schemas contains the reference fields of the custom resources by
+	// their kind, because the definition is read once per kind.
+	schemas map[schema.GroupVersionKind][]schemaReference
+	// references contains the references of the custom resources which are
+	// resolved after all nodes are added.
+	references []pendingReference
+
+	admissionregistrationV1 *AdmissionregistrationV1Graph
+	apiextensionsV1         *ApiextensionsV1Graph
+	apiregistrationV1       *ApiregistrationV1Graph
+	appsV1                  *AppsV1Graph
+	appsOpenShiftV1         *AppsOpenShiftV1Graph
+	argoWorkflows           *ArgoWorkflowsGraph
+	autoscalingV2           *AutoscalingV2Graph
+	autoscalingK8sV1        *AutoscalingK8sV1Graph
+	batchV1                 *BatchV1Graph
+	buildV1                 *BuildV1Graph
+	calico                  *CalicoGraph
+	certManager             *CertManagerGraph
+	cilium                  *CiliumGraph
+	coordinationV1          *CoordinationV1Graph
+	coreV1                  *CoreV1Graph
+	crossplane              *CrossplaneGraph
+	discoveryV1             *DiscoveryV1Graph
+	flux                    *FluxGraph
+	gatekeeper              *GatekeeperGraph
+	grafana                 *GrafanaGraph
+	imageV1                 *ImageV1Graph
+	istio                   *IstioGraph
+	knativeEventing         *KnativeEventingGraph
+	knativeServingV1        *KnativeServingV1Graph
+	kyverno                 *KyvernoGraph
+	linkerd                 *LinkerdGraph
+	longhorn                *LonghornGraph
+	multus                  *MultusGraph
+	networkingV1            *NetworkingV1Graph
+	nodeV1                  *NodeV1Graph
+	olm                     *OLMGraph
+	postgres                *PostgresGraph
+	rbacV1                  *RbacV1Graph
+	routeV1                 *RouteV1Graph
+	schedulingV1            *SchedulingV1Graph
+	secretsStoreCSIV1       *SecretsStoreCSIV1Graph
+	storageV1               *StorageV1Graph
+	strimzi                 *StrimziGraph
+	tekton                  *TektonGraph
+
+	// objects and objectKinds contain the nodes and kinds which are added
+	// from objects instead of references, owners contains the owner
+	// references of each node. They are used to find unresolved owners.
+	objects     map[types.UID]bool
+	objectKinds map[string]bool
+	owners      map[types.UID][]types.UID
+	// warnings contains the warnings in the order they were found, warned
+	// contains the same warnings to ignore duplicates.
+	warnings []Warning
+	warned   map[Warning]bool
 }
 
 // Node represents a node in the graph.
 type Node struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	Attr              map[string]string `json:"attributes,omitempty"`
 }
 
 // Relationship represents a relationship between nodes in the graph.
@@ -115,24 +368,67 @@ type Relationship struct {
 // Options represents attributes to configure the graph.
 type Options struct {
 	NodeNameLimit int
+	Title         string
+	Timestamp     time.Time
+	Legend        bool
+	Theme         *Theme
+	// JobHistoryLimit is the number of most recent Jobs per CronJob which
+	// are graphed, all older Jobs are collapsed into one node. Zero keeps all Jobs.
+	JobHistoryLimit int
+	// Resolvers are asked in order to turn external references into nodes.
+	Resolvers []Resolver
+	// Enrichers are asked in order for additional attributes of each node.
+	Enrichers []Enricher
+	// Containers adds the image and ports to the node of each container and
+	// init container of a Pod and links the container to its image.
+	Containers bool
+	// ControlPlane adds the control plane components of the kube-system namespace.
+	ControlPlane bool
+	// Events adds the warning events as attributes to the involved objects.
+	Events bool
+	// HelmReleases decodes the Helm release Secrets and adds a node per release.
+	HelmReleases bool
+	// Workloads wraps all workloads under a generic Workload node.
+	Workloads bool
+	// Invert contains the relationship labels which are rendered in reverse
+	// direction, the label "*" inverts all relationships.
+	Invert map[string]bool
+	// SchemaReferences adds the references of custom resources without
+	// built-in support, which are found in the OpenAPI schema of their definition.
+	SchemaReferences bool
+	// Hash is the name of the hash function for the UIDs of virtual nodes
+	// and the colors. One of HashSHA256 or HashMD5.
+	Hash string
 }
 
 // ToUID converts all params to MD5 and returns this as types.UID.
+//
+// Deprecated: Use Graph.ToUID, which uses the hash function of Options.Hash.
 func ToUID(params ...interface{}) types.UID {
+	return toUID(hashes[HashMD5], params...)
+}
+
+// ToUID converts all params to a hash and returns this as types.UID.
+func (g *Graph) ToUID(params ...interface{}) types.UID {
+	return toUID(g.hash, params...)
+}
+
+// toUID converts all params to a hash by the hash function and returns this as types.UID.
+func toUID(hash func([]byte) []byte, params ...interface{}) types.UID {
 	input := make([]string, len(params))
 	for _, param := range params {
 		input = append(input, fmt.Sprint(param))
 	}
 
 	bytes := []byte(strings.Join(input, "-"))
-	md5sum := fmt.Sprintf("%x", md5.Sum(bytes))
+	sum := fmt.Sprintf("%x", hash(bytes))
 
 	slice := []string{
-		md5sum[:8],
-		md5sum[8:12],
-		md5sum[12:16],
-		md5sum[16:20],
-		md5sum[20:],
+		sum[:8],
+		sum[8:12],
+		sum[12:16],
+		sum[16:20],
+		sum[20:],
 	}
 
 	return types.UID(strings.Join(slice, "-"))
@@ -160,21 +456,236 @@ func FromUnstructured(unstr *unstructured.Unstructured, obj runtime.Object) erro
 	return nil
 }
 
-// NewGraph returns a new initialized a Graph.
-func NewGraph(clientset *kubernetes.Clientset, objs []*unstructured.Unstructured, processed func()) (*Graph, error) {
+// NestedLabelSelector returns the label selector of an unstructured object at the
+// path of fields. It returns false if the field does not exist.
+func NestedLabelSelector(obj map[string]interface{}, fields ...string) (labels.Selector, bool, error) {
+	m, ok, err := unstructured.NestedMap(obj, fields...)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	labelSelector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, labelSelector); err != nil {
+		return nil, false, fmt.Errorf("failed to convert %s to label selector: %v", strings.Join(fields, "."), err)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return selector, true, nil
+}
+
+// readyStatus returns the status of a custom resource by its Ready condition,
+// which is used by many operators like Knative and cert-manager.
+func readyStatus(unstr *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(unstr.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok || c["type"] != "Ready" {
+			continue
+		}
+
+		switch c["status"] {
+		case "True":
+			return ConditionReady
+		case "False":
+			return ConditionNotReady
+		}
+	}
+
+	return ConditionUnknown
+}
+
+// Violation adds a relationship from a policy or a report to the object which
+// violates it, and the number of violations as attribute to the object. An
+// object with a known UID is merged with its node, if it is graphed.
+func (g *Graph) Violation(from *Node, gvk schema.GroupVersionKind, namespace string, name string, uid types.UID) *Relationship {
+	if len(uid) == 0 {
+		uid = g.ToUID(namespace, gvk.Kind, name)
+	}
+
+	n, ok := g.Nodes[uid]
+	if !ok && len(gvk.Group) == 0 && gvk.Kind == "Namespace" {
+		// namespaces are always added with the UID of their name and cluster
+		ns, err := g.CoreV1().Namespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		n, ok = ns, err == nil
+	}
+	if !ok {
+		n = g.Node(
+			gvk,
+			&metav1.ObjectMeta{
+				UID:       uid,
+				Name:      name,
+				Namespace: namespace,
+			},
+		)
+	}
+
+	count, _ := strconv.Atoi(n.Attr["violations"])
+	n.Attribute("violations", strconv.Itoa(count+1))
+
+	return g.Relationship(from, "Violation", n)
+}
+
+// CustomResource reads the custom resource with the given name from the cluster,
+// because the typed clientset has no client for custom resources. An empty
+// namespace reads a cluster-scoped resource. It returns nil if the resource
+// does not exist.
+func (g *Graph) CustomResource(gvr schema.GroupVersionResource, namespace string, name string) (*unstructured.Unstructured, error) {
+	segments := []string{"/apis", gvr.Group, gvr.Version}
+	if len(namespace) != 0 {
+		segments = append(segments, "namespaces", namespace)
+	}
+	segments = append(segments, gvr.Resource, name)
+
+	raw, err := g.clientset.CoreV1().RESTClient().Get().AbsPath(segments...).Do(context.TODO()).Raw()
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	unstr := &unstructured.Unstructured{}
+	if err := unstr.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+
+	return unstr, nil
+}
+
+// CustomResources lists the custom resources which match the label selector
+// from the cluster. An empty namespace lists the resources of all namespaces.
+func (g *Graph) CustomResources(gvr schema.GroupVersionResource, namespace string, selector string) ([]unstructured.Unstructured, error) {
+	segments := []string{"/apis", gvr.Group, gvr.Version}
+	if len(namespace) != 0 {
+		segments = append(segments, "namespaces", namespace)
+	}
+	segments = append(segments, gvr.Resource)
+
+	raw, err := g.clientset.CoreV1().RESTClient().Get().AbsPath(segments...).Param("labelSelector", selector).Do(context.TODO()).Raw()
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	if err := list.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// NewOptions returns the default Options.
+func NewOptions() *Options {
+	return &Options{
+		NodeNameLimit:   DefaultNodeNameLimit,
+		JobHistoryLimit: DefaultJobHistoryLimit,
+		Theme:           DefaultTheme(),
+		Hash:            HashSHA256,
+	}
+}
+
+// NewGraph returns a new initialized a Graph. If options is nil, the default Options are used.
+func NewGraph(clientset *kubernetes.Clientset, objs []*unstructured.Unstructured, options *Options, processed func()) (*Graph, error) {
+	g := NewEmptyGraph(clientset, options)
+
+	errs := []error{}
+
+	if err := g.Add(objs, processed); err != nil {
+		errs = append(errs, err)
+	}
+
+	err := g.Finalize()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return g, errors.NewAggregate(errs)
+}
+
+// NewEmptyGraph returns a new initialized Graph without any nodes. The objects
+// are added with Add and the Graph must be finalized after the last one.
+func NewEmptyGraph(clientset *kubernetes.Clientset, options *Options) *Graph {
+	if options == nil {
+		options = NewOptions()
+	}
+
 	g := &Graph{
 		clientset:     clientset,
 		Nodes:         make(map[types.UID]*Node),
 		Relationships: make(map[types.UID][]*Relationship),
-		Options: &Options{
-			NodeNameLimit: DefaultNodeNameLimit,
-		},
+		Options:       options,
+		objects:       make(map[types.UID]bool),
+		objectKinds:   make(map[string]bool),
+		owners:        make(map[types.UID][]types.UID),
+		warned:        make(map[Warning]bool),
+		schemas:       make(map[schema.GroupVersionKind][]schemaReference),
 	}
 
+	g.admissionregistrationV1 = NewAdmissionregistrationV1Graph(g)
+	g.apiextensionsV1 = NewApiextensionsV1Graph(g)
+	g.apiregistrationV1 = NewApiregistrationV1Graph(g)
+	g.appsV1 = NewAppsV1Graph(g)
+	g.appsOpenShiftV1 = NewAppsOpenShiftV1Graph(g)
+	g.argoWorkflows = NewArgoWorkflowsGraph(g)
+	g.autoscalingV2 = NewAutoscalingV2Graph(g)
+	g.autoscalingK8sV1 = NewAutoscalingK8sV1Graph(g)
+	g.batchV1 = NewBatchV1Graph(g)
+	g.buildV1 = NewBuildV1Graph(g)
+	g.calico = NewCalicoGraph(g)
+	g.certManager = NewCertManagerGraph(g)
+	g.cilium = NewCiliumGraph(g)
+	g.coordinationV1 = NewCoordinationV1Graph(g)
 	g.coreV1 = NewCoreV1Graph(g)
+	g.crossplane = NewCrossplaneGraph(g)
+	g.discoveryV1 = NewDiscoveryV1Graph(g)
+	g.flux = NewFluxGraph(g)
+	g.gatekeeper = NewGatekeeperGraph(g)
+	g.grafana = NewGrafanaGraph(g)
+	g.imageV1 = NewImageV1Graph(g)
+	g.istio = NewIstioGraph(g)
+	g.knativeEventing = NewKnativeEventingGraph(g)
+	g.knativeServingV1 = NewKnativeServingV1Graph(g)
+	g.kyverno = NewKyvernoGraph(g)
+	g.linkerd = NewLinkerdGraph(g)
+	g.longhorn = NewLonghornGraph(g)
+	g.multus = NewMultusGraph(g)
 	g.networkingV1 = NewNetworkingV1Graph(g)
+	g.nodeV1 = NewNodeV1Graph(g)
+	g.olm = NewOLMGraph(g)
+	g.postgres = NewPostgresGraph(g)
+	g.rbacV1 = NewRbacV1Graph(g)
 	g.routeV1 = NewRouteV1Graph(g)
+	g.schedulingV1 = NewSchedulingV1Graph(g)
+	g.secretsStoreCSIV1 = NewSecretsStoreCSIV1Graph(g)
+	g.storageV1 = NewStorageV1Graph(g)
+	g.strimzi = NewStrimziGraph(g)
+	g.tekton = NewTektonGraph(g)
+
+	return g
+}
 
+// metadataOnly returns true if the object has no other fields than its
+// type and metadata, e.g. if it was listed as PartialObjectMetadata.
+func metadataOnly(unstr *unstructured.Unstructured) bool {
+	for key := range unstr.Object {
+		if key != "apiVersion" && key != "kind" && key != "metadata" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add adds all objects to the Graph and calls processed after each of them.
+// It is not safe for concurrent use.
+func (g *Graph) Add(objs []*unstructured.Unstructured, processed func()) error {
 	errs := []error{}
 
 	for _, obj := range objs {
@@ -185,25 +696,106 @@ func NewGraph(clientset *kubernetes.Clientset, objs []*unstructured.Unstructured
 		processed()
 	}
 
-	err := g.Finalize()
-	if err != nil {
-		errs = append(errs, err)
-	}
-
-	return g, errors.NewAggregate(errs)
+	return errors.NewAggregate(errs)
 }
 
-// Unstructured adds an unstructured node to the Graph.
+// Unstructured adds an unstructured node to the Graph. An object which
+// contains its metadata only is added with its owner references only.
 func (g *Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	if metadataOnly(unstr) {
+		return g.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+
 	switch unstr.GetAPIVersion() {
 	case "v1":
 		return g.CoreV1().Unstructured(unstr)
+	case "acid.zalan.do/v1", "postgresql.cnpg.io/v1":
+		return g.Postgres().Unstructured(unstr)
+	case "acme.cert-manager.io/v1", "cert-manager.io/v1":
+		return g.CertManager().Unstructured(unstr)
+	case "admissionregistration.k8s.io/v1":
+		return g.AdmissionregistrationV1().Unstructured(unstr)
+	case "apiextensions.crossplane.io/v1", "pkg.crossplane.io/v1":
+		return g.Crossplane().Unstructured(unstr)
+	case "apiextensions.k8s.io/v1":
+		return g.ApiextensionsV1().Unstructured(unstr)
+	case "apiregistration.k8s.io/v1":
+		return g.ApiregistrationV1().Unstructured(unstr)
+	case "apps/v1":
+		return g.AppsV1().Unstructured(unstr)
+	case "apps.openshift.io/v1":
+		return g.AppsOpenShiftV1().Unstructured(unstr)
+	case "argoproj.io/v1alpha1":
+		return g.ArgoWorkflows().Unstructured(unstr)
+	case "autoscaling/v2":
+		return g.AutoscalingV2().Unstructured(unstr)
+	case "autoscaling.k8s.io/v1":
+		return g.AutoscalingK8sV1().Unstructured(unstr)
+	case "batch/v1":
+		return g.BatchV1().Unstructured(unstr)
+	case "build.openshift.io/v1":
+		return g.BuildV1().Unstructured(unstr)
+	case "crd.projectcalico.org/v1", "projectcalico.org/v3":
+		return g.Calico().Unstructured(unstr)
+	case "cilium.io/v2":
+		return g.Cilium().Unstructured(unstr)
+	case "constraints.gatekeeper.sh/v1beta1", "templates.gatekeeper.sh/v1", "templates.gatekeeper.sh/v1beta1":
+		return g.Gatekeeper().Unstructured(unstr)
+	case "coordination.k8s.io/v1":
+		return g.CoordinationV1().Unstructured(unstr)
+	case "discovery.k8s.io/v1":
+		return g.DiscoveryV1().Unstructured(unstr)
+	case "eventing.knative.dev/v1", "messaging.knative.dev/v1", "sources.knative.dev/v1":
+		return g.KnativeEventing().Unstructured(unstr)
+	case "grafana.integreatly.org/v1beta1":
+		return g.Grafana().Unstructured(unstr)
+	case "helm.toolkit.fluxcd.io/v2", "helm.toolkit.fluxcd.io/v2beta2", "kustomize.toolkit.fluxcd.io/v1", "source.toolkit.fluxcd.io/v1", "source.toolkit.fluxcd.io/v1beta2":
+		return g.Flux().Unstructured(unstr)
+	case "image.openshift.io/v1":
+		return g.ImageV1().Unstructured(unstr)
+	case "k8s.cni.cncf.io/v1":
+		return g.Multus().Unstructured(unstr)
+	case "kafka.strimzi.io/v1beta2":
+		return g.Strimzi().Unstructured(unstr)
+	case "kyverno.io/v1", "kyverno.io/v2beta1", "wgpolicyk8s.io/v1alpha2":
+		return g.Kyverno().Unstructured(unstr)
+	case "linkerd.io/v1alpha2", "policy.linkerd.io/v1alpha1", "policy.linkerd.io/v1beta1", "policy.linkerd.io/v1beta2", "policy.linkerd.io/v1beta3":
+		return g.Linkerd().Unstructured(unstr)
+	case "longhorn.io/v1beta1", "longhorn.io/v1beta2":
+		return g.Longhorn().Unstructured(unstr)
+	case "networking.istio.io/v1", "networking.istio.io/v1beta1", "networking.istio.io/v1alpha3":
+		return g.Istio().Unstructured(unstr)
 	case "networking.k8s.io/v1":
 		return g.NetworkingV1().Unstructured(unstr)
+	case "node.k8s.io/v1":
+		return g.NodeV1().Unstructured(unstr)
+	case "operators.coreos.com/v1", "operators.coreos.com/v1alpha1":
+		return g.OLM().Unstructured(unstr)
+	case "rbac.authorization.k8s.io/v1":
+		return g.RbacV1().Unstructured(unstr)
 	case "route.openshift.io/v1":
 		return g.RouteV1().Unstructured(unstr)
+	case "scheduling.k8s.io/v1":
+		return g.SchedulingV1().Unstructured(unstr)
+	case "secrets-store.csi.x-k8s.io/v1":
+		return g.SecretsStoreCSIV1().Unstructured(unstr)
+	case "serving.knative.dev/v1":
+		return g.KnativeServingV1().Unstructured(unstr)
+	case "storage.k8s.io/v1":
+		return g.StorageV1().Unstructured(unstr)
+	case "tekton.dev/v1", "tekton.dev/v1beta1":
+		return g.Tekton().Unstructured(unstr)
 	default:
-		return g.Node(unstr.GroupVersionKind(), unstr), nil
+		if IsCrossplaneResource(unstr) {
+			return g.Crossplane().Resource(unstr)
+		}
+		n := g.Node(unstr.GroupVersionKind(), unstr)
+		if g.Options.SchemaReferences {
+			if err := g.References(n, unstr); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
 	}
 }
 
@@ -224,9 +816,11 @@ func (g *Graph) Node(gvk schema.GroupVersionKind, obj metav1.Object) *Node {
 			}),
 			Labels: obj.GetLabels(),
 		},
+		Attr: make(map[string]string),
 	}
 
 	if n, ok := g.Nodes[obj.GetUID()]; ok {
+		node.Attr = n.Attr
 		if len(n.GetAnnotations()) != 0 {
 			node.SetAnnotations(n.GetAnnotations())
 		}
@@ -237,6 +831,12 @@ func (g *Graph) Node(gvk schema.GroupVersionKind, obj metav1.Object) *Node {
 
 	g.Nodes[obj.GetUID()] = node
 
+	// references are added as bare metadata
+	if _, ok := obj.(*metav1.ObjectMeta); !ok {
+		g.objects[obj.GetUID()] = true
+		g.objectKinds[apiVersion+"/"+kind] = true
+	}
+
 	for _, ownerRef := range obj.GetOwnerReferences() {
 		owner := g.Node(
 			schema.FromAPIVersionAndKind(ownerRef.APIVersion, ownerRef.Kind),
@@ -246,7 +846,10 @@ func (g *Graph) Node(gvk schema.GroupVersionKind, obj metav1.Object) *Node {
 				Namespace: obj.GetNamespace(),
 			},
 		)
-		g.Relationship(owner, kind, node)
+		g.Relationship(owner, kind, node).Attribute("weight", strconv.Itoa(OwnershipWeight))
+		if !slices.Contains(g.owners[node.UID], owner.UID) {
+			g.owners[node.UID] = append(g.owners[node.UID], owner.UID)
+		}
 	}
 
 	return node
@@ -254,6 +857,32 @@ func (g *Graph) Node(gvk schema.GroupVersionKind, obj metav1.Object) *Node {
 
 // Finalize adds missing relationships to the Graph.
 func (g *Graph) Finalize() error {
+	if g.Options.ControlPlane {
+		if err := g.CoreV1().ControlPlane(); err != nil {
+			return err
+		}
+	}
+	g.BatchV1().Collapse()
+	if g.Options.Workloads {
+		g.Workloads()
+	}
+	g.HostAccess()
+	if g.Options.Events {
+		if err := g.CoreV1().Events(); err != nil {
+			return err
+		}
+	}
+	if g.Options.HelmReleases {
+		if err := g.HelmReleases(); err != nil {
+			return err
+		}
+	}
+	if err := g.Crossplane().Resolve(); err != nil {
+		return err
+	}
+	g.ResolveReferences()
+	g.warnUnresolvedOwners()
+
 	for _, node := range g.Nodes {
 		if node.Kind == "Cluster" || node.Kind == "Namespace" {
 			continue
@@ -281,25 +910,64 @@ func (g *Graph) Finalize() error {
 		g.Relationship(namespace, node.Kind, node)
 	}
 
-	return nil
+	// custom resources must be linked to their namespace or the cluster
+	// before they get an incoming relationship from their definition
+	g.ApiextensionsV1().Defines()
+	g.Flux().Managed()
+	g.HelmManaged()
+
+	return g.Enrich()
 }
 
-// NodeList returns a list of all nodes.
+// NodeList returns a list of all nodes sorted by kind, namespace and name.
 func (g *Graph) NodeList() []*Node {
 	nodes := []*Node{}
 
 	for _, node := range g.Nodes {
 		nodes = append(nodes, node)
 	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return g.less(nodes[i], nodes[j])
+	})
 
 	return nodes
 }
 
-// Relationship creates a new relationship between two nodes.
+// less orders nodes by kind, namespace, name and UID.
+func (g *Graph) less(a *Node, b *Node) bool {
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.UID < b.UID
+}
+
+// Relationship creates a new relationship between two nodes. If the nodes
+// are already related, the existing relationship is returned regardless of
+// its label.
 func (g *Graph) Relationship(from *Node, label string, to *Node) *Relationship {
+	return g.relationship(from, label, to, false)
+}
+
+// LabeledRelationship creates a new relationship with the given label between
+// two nodes. If the nodes are already related with another label, an
+// additional relationship is created, e.g. a Service which SELECTS a Pod and
+// ROUTES_TO it.
+func (g *Graph) LabeledRelationship(from *Node, label string, to *Node) *Relationship {
+	return g.relationship(from, label, to, true)
+}
+
+// relationship returns the existing relationship between two nodes, if
+// labeled is true only with the same label, or creates a new relationship.
+func (g *Graph) relationship(from *Node, label string, to *Node, labeled bool) *Relationship {
 	if rs, ok := g.Relationships[to.GetUID()]; ok {
 		for _, r := range rs {
-			if r.From == from.GetUID() {
+			if r.From == from.GetUID() && (!labeled || r.Label == label) {
 				return r
 			}
 		}
@@ -316,23 +984,143 @@ func (g *Graph) Relationship(from *Node, label string, to *Node) *Relationship {
 	return relationship
 }
 
-// RelationshipList returns a list of all relationships.
+// Remove deletes the nodes and all of their relationships from the Graph.
+func (g *Graph) Remove(uids map[types.UID]bool) {
+	for uid := range uids {
+		delete(g.Nodes, uid)
+		delete(g.Relationships, uid)
+	}
+
+	for to, rs := range g.Relationships {
+		kept := rs[:0]
+		for _, r := range rs {
+			if !uids[r.From] {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == 0 {
+			delete(g.Relationships, to)
+			continue
+		}
+		g.Relationships[to] = kept
+	}
+}
+
+// RelationshipList returns a list of all relationships sorted by their
+// source node, target node and label. Relationships with a label listed in
+// Options.Invert are returned in reverse direction.
 func (g *Graph) RelationshipList() []*Relationship {
 	relationships := []*Relationship{}
 
-	for _, relationship := range g.Relationships {
-		relationships = append(relationships, relationship...)
+	for _, rs := range g.Relationships {
+		for _, relationship := range rs {
+			if g.Options.Invert[relationship.Label] || g.Options.Invert["*"] {
+				relationship = &Relationship{
+					From:  relationship.To,
+					Label: relationship.Label,
+					To:    relationship.From,
+					Attr:  relationship.Attr,
+				}
+			}
+			relationships = append(relationships, relationship)
+		}
+	}
+	sort.Slice(relationships, func(i, j int) bool {
+		a, b := relationships[i], relationships[j]
+		if a.From != b.From {
+			return g.lessUID(a.From, b.From)
+		}
+		if a.To != b.To {
+			return g.lessUID(a.To, b.To)
+		}
+		return a.Label < b.Label
+	})
+
+	return relationships
+}
+
+// relationships returns an unsorted list of all relationships as stored in the graph.
+func (g *Graph) relationships() []*Relationship {
+	relationships := []*Relationship{}
+
+	for _, rs := range g.Relationships {
+		relationships = append(relationships, rs...)
 	}
 
 	return relationships
 }
 
+// lessUID orders the nodes with the given UIDs, unknown nodes are ordered by UID.
+func (g *Graph) lessUID(a types.UID, b types.UID) bool {
+	x, okx := g.Nodes[a]
+	y, oky := g.Nodes[b]
+	if !okx || !oky {
+		return a < b
+	}
+	return g.less(x, y)
+}
+
+// Kinds returns a sorted list of all node kinds.
+func (g *Graph) Kinds() []string {
+	set := make(map[string]bool)
+	for _, node := range g.Nodes {
+		set[node.Kind] = true
+	}
+
+	return sortedKeys(set)
+}
+
+// Title returns the configured title followed by the timestamp, if any.
+func (g *Graph) Title() string {
+	lines := []string{}
+	if len(g.Options.Title) != 0 {
+		lines = append(lines, g.Options.Title)
+	}
+	if !g.Options.Timestamp.IsZero() {
+		lines = append(lines, g.Options.Timestamp.Format(time.RFC3339))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Attribute adds an attribute to a node.
+func (n *Node) Attribute(key string, value string) *Node {
+	n.Attr[key] = value
+	return n
+}
+
 // Attribute adds an attribute to a relationship.
 func (r *Relationship) Attribute(key string, value string) *Relationship {
 	r.Attr[key] = value
 	return r
 }
 
+// appendAttribute adds value to the comma separated list of the relationship
+// attribute, because a relationship may represent multiple rules.
+func appendAttribute(r *Relationship, key string, value string) {
+	if len(value) == 0 {
+		return
+	}
+
+	values := []string{}
+	if len(r.Attr[key]) != 0 {
+		values = strings.Split(r.Attr[key], ",")
+	}
+	if !slices.Contains(values, value) {
+		r.Attribute(key, strings.Join(append(values, value), ","))
+	}
+}
+
+// Weight returns the weight of the relationship, which is used to rank the
+// layout. Relationships without a "weight" attribute have the ReferenceWeight.
+func (r *Relationship) Weight() int {
+	if weight, err := strconv.Atoi(r.Attr["weight"]); err == nil {
+		return weight
+	}
+
+	return ReferenceWeight
+}
+
 // String returns the graph in requested format.
 func (g *Graph) String(format string) string {
 	b := &bytes.Buffer{}
@@ -343,5 +1131,9 @@ func (g *Graph) String(format string) string {
 
 // Write formats according to the requested format and writes to w.
 func (g *Graph) Write(w io.Writer, format string) error {
+	if writer, ok := writers[format]; ok {
+		return writer(g, w)
+	}
+
 	return templates.ExecuteTemplate(w, format+".tmpl", g)
 }
@@ -0,0 +1,138 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCypherString(t *testing.T) {
+	tests := []struct {
+		s    interface{}
+		want string
+	}{
+		{s: "nginx", want: `"nginx"`},
+		{s: 42, want: `"42"`},
+		{s: `say "hi"`, want: `"say \"hi\""`},
+		{s: `C:\temp`, want: `"C:\\temp"`},
+		{s: "a\nb\r\tc", want: `"a\nb\r\tc"`},
+		{s: "bell\a", want: `"bell\u0007"`},
+		{s: "line\u2028separator", want: `"line\u2028separator"`},
+		{s: "\"}) DETACH DELETE n //", want: `"\"}) DETACH DELETE n //"`},
+	}
+
+	for _, tt := range tests {
+		if got := cypherString(tt.s); got != tt.want {
+			t.Errorf("cypherString(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestCypherIdentifier(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "Pod", want: "`Pod`"},
+		{s: "RUNS_ON", want: "`RUNS_ON`"},
+		{s: "a`b", want: "`a``b`"},
+	}
+
+	for _, tt := range tests {
+		if got := cypherIdentifier(tt.s); got != tt.want {
+			t.Errorf("cypherIdentifier(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestDotString(t *testing.T) {
+	tests := []struct {
+		s    interface{}
+		want string
+	}{
+		{s: "nginx", want: `"nginx"`},
+		{s: 3, want: `"3"`},
+		{s: `say "hi"`, want: `"say \"hi\""`},
+		{s: `C:\temp`, want: `"C:\\temp"`},
+		{s: "a\nb\r\nc\rd", want: `"a\nb\nc\nd"`},
+		{s: `x" color="red`, want: `"x\" color=\"red"`},
+	}
+
+	for _, tt := range tests {
+		if got := dotString(tt.s); got != tt.want {
+			t.Errorf("dotString(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRelationship(t *testing.T) {
+	tests := []struct {
+		name    string
+		labeled bool
+		labels  []string
+		want    []string
+	}{
+		{
+			name:   "same label",
+			labels: []string{"SELECTS", "SELECTS"},
+			want:   []string{"SELECTS"},
+		},
+		{
+			name:   "other label",
+			labels: []string{"SELECTS", "ROUTES_TO"},
+			want:   []string{"SELECTS"},
+		},
+		{
+			name:    "labeled same label",
+			labeled: true,
+			labels:  []string{"SELECTS", "SELECTS"},
+			want:    []string{"SELECTS"},
+		},
+		{
+			name:    "labeled other label",
+			labeled: true,
+			labels:  []string{"SELECTS", "ROUTES_TO", "SELECTS"},
+			want:    []string{"SELECTS", "ROUTES_TO"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewEmptyGraph(nil, nil)
+			service := g.Node(schema.FromAPIVersionAndKind("v1", "Service"), &metav1.ObjectMeta{UID: "service", Name: "web"})
+			pod := g.Node(schema.FromAPIVersionAndKind("v1", "Pod"), &metav1.ObjectMeta{UID: "pod", Name: "web"})
+
+			for _, label := range tt.labels {
+				if tt.labeled {
+					g.LabeledRelationship(service, label, pod)
+				} else {
+					g.Relationship(service, label, pod)
+				}
+			}
+
+			got := []string{}
+			for _, r := range g.Relationships[pod.UID] {
+				got = append(got, r.Label)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("labels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// GraphologyGraph represents the serialized form of a graphology graph.
+type GraphologyGraph struct {
+	Attributes map[string]interface{} `json:"attributes"`
+	Options    GraphologyOptions      `json:"options"`
+	Nodes      []*GraphologyNode      `json:"nodes"`
+	Edges      []*GraphologyEdge      `json:"edges"`
+}
+
+// GraphologyOptions represents the type of a graphology graph.
+type GraphologyOptions struct {
+	Type           string `json:"type"`
+	Multi          bool   `json:"multi"`
+	AllowSelfLoops bool   `json:"allowSelfLoops"`
+}
+
+// GraphologyNode represents a serialized graphology node.
+type GraphologyNode struct {
+	Key        string                 `json:"key"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// GraphologyEdge represents a serialized graphology edge.
+type GraphologyEdge struct {
+	Key        string                 `json:"key"`
+	Source     string                 `json:"source"`
+	Target     string                 `json:"target"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// Graphology returns the graph in the graphology serialization format. The
+// node attributes contain the position, color and label used by sigma.js, the
+// edge attributes contain the weight, which force layouts like ForceAtlas2 use
+// as attraction strength, so owners and their dependents are kept together.
+func (g *Graph) Graphology() *GraphologyGraph {
+	graph := &GraphologyGraph{
+		Attributes: map[string]interface{}{"name": "kubectl-graph"},
+		Options:    GraphologyOptions{Type: "directed", Multi: true, AllowSelfLoops: true},
+		Nodes:      []*GraphologyNode{},
+		Edges:      []*GraphologyEdge{},
+	}
+	graph.Attributes["background"] = g.Options.Theme.Background
+	if title := g.Title(); len(title) != 0 {
+		graph.Attributes["title"] = title
+	}
+	if warnings := g.Warnings(); len(warnings) != 0 {
+		graph.Attributes["warnings"] = warnings
+	}
+
+	layout := g.Layout(10, 10)
+	for _, node := range g.NodeList() {
+		attributes := map[string]interface{}{
+			"label": node.Name,
+			"kind":  node.Kind,
+			"color": g.Color(node),
+			"size":  5,
+			"x":     layout[node.UID].X,
+			"y":     layout[node.UID].Y,
+		}
+		if len(node.Namespace) != 0 {
+			attributes["namespace"] = node.Namespace
+		}
+		if len(node.Labels) != 0 {
+			attributes["labels"] = node.Labels
+		}
+		if len(node.Annotations) != 0 {
+			attributes["annotations"] = node.Annotations
+		}
+		for key, value := range node.Attr {
+			attributes[key] = value
+		}
+
+		graph.Nodes = append(graph.Nodes, &GraphologyNode{
+			Key:        string(node.UID),
+			Attributes: attributes,
+		})
+	}
+
+	for idx, relationship := range g.RelationshipList() {
+		attributes := map[string]interface{}{
+			"label": relationship.Label,
+		}
+		for key, value := range relationship.Attr {
+			attributes[key] = value
+		}
+		// force layouts expect a numeric weight
+		attributes["weight"] = relationship.Weight()
+
+		graph.Edges = append(graph.Edges, &GraphologyEdge{
+			Key:        fmt.Sprintf("e%d", idx),
+			Source:     string(relationship.From),
+			Target:     string(relationship.To),
+			Attributes: attributes,
+		})
+	}
+
+	return graph
+}
+
+// writeGraphology writes the graph as graphology JSON to w.
+func (g *Graph) writeGraphology(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(g.Graphology())
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+const (
+	// HashSHA256 is the default hash function, it is truncated to 128 bits.
+	HashSHA256 string = "sha256"
+	// HashMD5 is the hash function of previous versions, it keeps the UIDs
+	// of existing data stable but is not available in FIPS mode.
+	HashMD5 string = "md5"
+)
+
+// hashes contains all hash functions which can be used for the UIDs and colors.
+var hashes = map[string]func([]byte) []byte{
+	HashSHA256: func(b []byte) []byte {
+		sum := sha256.Sum256(b)
+		return sum[:16]
+	},
+	HashMD5: func(b []byte) []byte {
+		sum := md5.Sum(b)
+		return sum[:]
+	},
+}
+
+// ValidateHash checks the name of a hash function for Options.Hash.
+func ValidateHash(name string) error {
+	if _, ok := hashes[name]; !ok {
+		return fmt.Errorf("invalid hash: %q, allowed values are: %s", name, strings.Join(sortedKeys(hashes), "|"))
+	}
+
+	return nil
+}
+
+// hash returns the sum of b by the hash function of Options.Hash, which is
+// used for the UIDs of virtual nodes and the colors. An unknown name falls
+// back to the default hash function.
+func (g *Graph) hash(b []byte) []byte {
+	h, ok := hashes[g.Options.Hash]
+	if !ok {
+		h = hashes[HashSHA256]
+	}
+
+	return h(b)
+}
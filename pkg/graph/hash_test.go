@@ -0,0 +1,120 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestValidateHash(t *testing.T) {
+	tests := []struct {
+		name string
+		err  bool
+	}{
+		{name: HashSHA256},
+		{name: HashMD5},
+		{name: "sha1", err: true},
+		{name: "", err: true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateHash(tt.name); (err != nil) != tt.err {
+			t.Errorf("ValidateHash(%q) = %v, want error %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestToUID(t *testing.T) {
+	tests := []struct {
+		hash string
+		want string
+	}{
+		{hash: HashSHA256, want: HashSHA256},
+		{hash: HashMD5, want: HashMD5},
+		// an unknown hash falls back to the default
+		{hash: "unknown", want: HashSHA256},
+	}
+
+	for _, tt := range tests {
+		options := NewOptions()
+		options.Hash = tt.hash
+		g := NewEmptyGraph(nil, options)
+
+		want := NewOptions()
+		want.Hash = tt.want
+		expected := NewEmptyGraph(nil, want).ToUID("Image", "nginx")
+
+		uid := g.ToUID("Image", "nginx")
+		if uid != expected {
+			t.Errorf("ToUID() with %s = %s, want %s", tt.hash, uid, expected)
+		}
+		if len(uid) != 36 {
+			t.Errorf("ToUID() with %s = %s, want 36 characters", tt.hash, uid)
+		}
+		if uid == g.ToUID("Image", "redis") {
+			t.Errorf("ToUID() with %s returns the same UID for different params", tt.hash)
+		}
+	}
+
+	// the hash is an option of each graph
+	sha256, md5 := NewOptions(), NewOptions()
+	md5.Hash = HashMD5
+	if NewEmptyGraph(nil, sha256).ToUID("Image", "nginx") == NewEmptyGraph(nil, md5).ToUID("Image", "nginx") {
+		t.Errorf("ToUID() returns the same UID for %s and %s", HashSHA256, HashMD5)
+	}
+}
+
+func TestToUIDCompat(t *testing.T) {
+	options := NewOptions()
+	options.Hash = HashMD5
+	g := NewEmptyGraph(nil, options)
+
+	tests := [][]interface{}{
+		{"Image", "nginx"},
+		{"ExternalName", "example.com"},
+		{"Page", 1},
+	}
+
+	for _, params := range tests {
+		if got, want := ToUID(params...), g.ToUID(params...); got != want {
+			t.Errorf("ToUID(%v) = %s, want %s", params, got, want)
+		}
+	}
+}
+
+func TestColorFunc(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "Pod", want: fmt.Sprintf("#%x", md5.Sum([]byte("Pod")))[:7]},
+		{key: "Service", want: fmt.Sprintf("#%x", md5.Sum([]byte("Service")))[:7]},
+	}
+
+	for _, tt := range tests {
+		b := &strings.Builder{}
+		tmpl := template.Must(templates.Clone()).New("color")
+		if err := template.Must(tmpl.Parse(`{{ color . }}`)).Execute(b, tt.key); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("color %q = %s, want %s", tt.key, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"path"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// helmReleaseType is the type of the Secrets which are used by Helm 3 to store its releases.
+	helmReleaseType = "helm.sh/release.v1"
+	// helmReleaseNameAnnotation and helmReleaseNamespaceAnnotation are added
+	// by Helm 3 to all objects which are rendered by a release.
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	// helmManagedByLabel and helmInstanceLabel are added by most charts to
+	// the objects which they render, e.g. by the default chart of helm create.
+	helmManagedByLabel = "app.kubernetes.io/managed-by"
+	helmInstanceLabel  = "app.kubernetes.io/instance"
+)
+
+// helmRelease contains the fields of a decoded Helm release which are graphed.
+// The values and the rendered manifest are never decoded, because they may
+// contain sensitive data.
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status       string `json:"status"`
+		LastDeployed string `json:"last_deployed"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// HelmReleases lists the Helm release Secrets of all graphed namespaces and
+// adds a node for the latest revision of each release to the Graph.
+func (g *Graph) HelmReleases() error {
+	for _, namespace := range g.Namespaces() {
+		if len(namespace) == 0 {
+			continue
+		}
+
+		options := metav1.ListOptions{LabelSelector: "owner=helm"}
+		secrets, err := g.clientset.CoreV1().Secrets(namespace).List(context.TODO(), options)
+		if err != nil {
+			return err
+		}
+
+		latest := make(map[string]*v1.Secret)
+		for i := range secrets.Items {
+			secret := &secrets.Items[i]
+			if secret.Type != helmReleaseType {
+				continue
+			}
+
+			name := secret.GetLabels()["name"]
+			version, _ := strconv.Atoi(secret.GetLabels()["version"])
+			if s, ok := latest[name]; ok {
+				if v, _ := strconv.Atoi(s.GetLabels()["version"]); v >= version {
+					continue
+				}
+			}
+			latest[name] = secret
+		}
+
+		for _, secret := range latest {
+			g.HelmRelease(secret)
+		}
+	}
+
+	return nil
+}
+
+// HelmRelease adds a node for the Helm release which is stored in the v1.Secret to the Graph.
+// Only the metadata of the chart and the status of the release are added as attributes.
+func (g *Graph) HelmRelease(secret *v1.Secret) {
+	release, err := decodeHelmRelease(secret.Data["release"])
+	if err != nil {
+		// releases of other Helm versions or corrupted releases are skipped
+		return
+	}
+
+	n := g.HelmReleaseName(release.Namespace, release.Name)
+	n.Attribute("revision", strconv.Itoa(release.Version))
+	n.Attribute("status", release.Info.Status)
+	if len(release.Info.LastDeployed) != 0 {
+		n.Attribute("lastDeployed", release.Info.LastDeployed)
+	}
+	n.Attribute("chart", release.Chart.Metadata.Name+"-"+release.Chart.Metadata.Version)
+	if len(release.Chart.Metadata.AppVersion) != 0 {
+		n.Attribute("appVersion", release.Chart.Metadata.AppVersion)
+	}
+}
+
+// HelmReleaseName adds a node which represents a Helm release to the Graph.
+func (g *Graph) HelmReleaseName(namespace string, name string) *Node {
+	return g.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "HelmRelease"),
+		&metav1.ObjectMeta{
+			UID:       g.ToUID(namespace, "HelmRelease", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+}
+
+// HelmManaged adds a relationship from each Helm release to the objects which
+// are rendered by it, by the annotations of Helm or by the labels of the chart,
+// and the number of these objects as attribute.
+func (g *Graph) HelmManaged() {
+	releases := make(map[string]*Node)
+	for _, node := range g.Nodes {
+		if node.APIVersion == "kubectl-graph/v1" && node.Kind == "HelmRelease" {
+			releases[path.Join(node.GetNamespace(), node.GetName())] = node
+		}
+	}
+	if len(releases) == 0 {
+		return
+	}
+
+	managed := make(map[*Node]int)
+	for _, node := range g.NodeList() {
+		if !g.objects[node.UID] {
+			continue
+		}
+
+		namespace, name := node.GetAnnotations()[helmReleaseNamespaceAnnotation], node.GetAnnotations()[helmReleaseNameAnnotation]
+		if len(name) == 0 && node.GetLabels()[helmManagedByLabel] == "Helm" {
+			namespace, name = node.GetNamespace(), node.GetLabels()[helmInstanceLabel]
+		}
+		if len(name) == 0 {
+			continue
+		}
+
+		n, ok := releases[path.Join(namespace, name)]
+		if !ok {
+			continue
+		}
+
+		g.LabeledRelationship(n, "MANAGES", node)
+		managed[n]++
+	}
+
+	for _, n := range releases {
+		n.Attribute("managed", strconv.Itoa(managed[n]))
+	}
+}
+
+// decodeHelmRelease decodes a Helm release, which is stored base64 encoded
+// and usually gzip compressed in the data of its Secret.
+func decodeHelmRelease(data []byte) (*helmRelease, error) {
+	b, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b, 0x08}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		if b, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+
+	release := &helmRelease{}
+	if err := json.Unmarshal(b, release); err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
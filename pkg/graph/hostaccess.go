@@ -0,0 +1,100 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// HostNetwork is set if a Pod uses the network namespace of the host.
+	HostNetwork string = "hostNetwork"
+	// HostPID is set if a Pod uses the process namespace of the host.
+	HostPID string = "hostPID"
+	// HostIPC is set if a Pod uses the IPC namespace of the host.
+	HostIPC string = "hostIPC"
+	// HostPath is set if a Pod mounts a path of the host.
+	HostPath string = "hostPath"
+
+	// hostAccessColor is the color of nodes and relationships with access to the host.
+	hostAccessColor = "#ea4335"
+)
+
+// hostAccess sets the "hostAccess" attribute of the node of a Pod or its
+// workload if the v1.PodSpec shares a namespace with the host or mounts a
+// path of the host. The mounted paths are added as "hostPaths" attribute.
+func hostAccess(n *Node, spec v1.PodSpec) {
+	access := []string{}
+	if spec.HostNetwork {
+		access = append(access, HostNetwork)
+	}
+	if spec.HostPID {
+		access = append(access, HostPID)
+	}
+	if spec.HostIPC {
+		access = append(access, HostIPC)
+	}
+
+	paths := []string{}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil && !slices.Contains(paths, volume.HostPath.Path) {
+			paths = append(paths, volume.HostPath.Path)
+		}
+	}
+	if len(paths) != 0 {
+		access = append(access, HostPath)
+		n.Attribute("hostPaths", strings.Join(paths, ","))
+	}
+
+	if len(access) != 0 {
+		n.Attribute("hostAccess", strings.Join(access, ","))
+		n.Attribute("color", hostAccessColor)
+	}
+}
+
+// HostAccess highlights the relationships of Pods with access to the host to
+// the Nodes they are scheduled on, and Workload nodes which wrap a workload
+// with access to the host.
+func (g *Graph) HostAccess() {
+	for _, rs := range g.Relationships {
+		for _, r := range rs {
+			from, to := g.Nodes[r.From], g.Nodes[r.To]
+			if from == nil || to == nil {
+				continue
+			}
+
+			switch {
+			case to.Kind == "Node" && len(from.Attr["hostAccess"]) != 0:
+				r.Attribute("hostAccess", from.Attr["hostAccess"])
+				r.Attribute("color", hostAccessColor)
+			case from.Kind == "Workload" && len(to.Attr["hostAccess"]) != 0:
+				access := []string{}
+				if len(from.Attr["hostAccess"]) != 0 {
+					access = strings.Split(from.Attr["hostAccess"], ",")
+				}
+				for _, value := range strings.Split(to.Attr["hostAccess"], ",") {
+					if !slices.Contains(access, value) {
+						access = append(access, value)
+					}
+				}
+				from.Attribute("hostAccess", strings.Join(access, ","))
+				from.Attribute("color", hostAccessColor)
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	v1 "github.com/openshift/api/image/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ImageV1Graph is used to graph all OpenShift image resources.
+type ImageV1Graph struct {
+	graph *Graph
+
+	// imageStreams contains the nodes by namespace and name, because they
+	// are referenced by the tags of many builds and deployments.
+	imageStreams map[string]*Node
+}
+
+// NewImageV1Graph creates a new ImageV1Graph.
+func NewImageV1Graph(g *Graph) *ImageV1Graph {
+	return &ImageV1Graph{
+		graph:        g,
+		imageStreams: make(map[string]*Node),
+	}
+}
+
+// ImageV1 retrieves the ImageV1Graph.
+func (g *Graph) ImageV1() *ImageV1Graph {
+	return g.imageV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *ImageV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "ImageStream":
+		obj := &v1.ImageStream{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ImageStream(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// ImageStream adds a v1.ImageStream resource and its tags to the Graph.
+// The tags have the image they currently point to as attribute and a
+// relationship to the image or tag they are tracking.
+func (g *ImageV1Graph) ImageStream(obj *v1.ImageStream) (*Node, error) {
+	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	g.imageStreams[path.Join(obj.GetNamespace(), obj.GetName())] = n
+
+	if len(obj.Status.DockerImageRepository) != 0 {
+		n.Attribute("dockerImageRepository", obj.Status.DockerImageRepository)
+	}
+	n.Attribute("tags", strconv.Itoa(len(obj.Status.Tags)))
+
+	for _, tag := range obj.Status.Tags {
+		t := g.tag(n, "ImageStreamTag", obj.GetNamespace(), obj.GetName()+":"+tag.Tag)
+		if len(tag.Items) != 0 {
+			t.Attribute("image", tag.Items[0].Image)
+			t.Attribute("created", tag.Items[0].Created.UTC().Format("2006-01-02T15:04:05Z"))
+		}
+	}
+
+	for _, tag := range obj.Spec.Tags {
+		if tag.From == nil {
+			continue
+		}
+		t := g.tag(n, "ImageStreamTag", obj.GetNamespace(), obj.GetName()+":"+tag.Name)
+		if _, err := g.Reference(t, "From", *tag.From, obj.GetNamespace()); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// ImageStreamName adds the ImageStream with the given name to the Graph.
+// A missing ImageStream is added as node without UID from the cluster.
+func (g *ImageV1Graph) ImageStreamName(namespace string, name string) (*Node, error) {
+	if n, ok := g.imageStreams[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(v1.SchemeGroupVersion.WithResource("imagestreams"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Unstructured(unstr)
+	}
+
+	n := g.graph.Node(
+		v1.SchemeGroupVersion.WithKind("ImageStream"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "ImageStream", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.imageStreams[path.Join(namespace, name)] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// Reference adds a relationship from n to the image referenced by an
+// ImageStreamTag, ImageStreamImage or DockerImage v1.ObjectReference, which
+// is used by builds, image stream tags and deployment triggers. The tags are
+// added with a relationship from their ImageStream.
+func (g *ImageV1Graph) Reference(n *Node, label string, ref corev1.ObjectReference, namespace string) (*Relationship, error) {
+	if len(ref.Namespace) != 0 {
+		namespace = ref.Namespace
+	}
+
+	switch ref.Kind {
+	case "ImageStreamTag", "ImageStreamImage":
+		separator := ":"
+		if ref.Kind == "ImageStreamImage" {
+			separator = "@"
+		}
+		name, _, _ := strings.Cut(ref.Name, separator)
+		s, err := g.ImageStreamName(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return g.graph.Relationship(n, label, g.tag(s, ref.Kind, namespace, ref.Name)), nil
+	case "DockerImage":
+		return g.graph.CoreV1().image(n, label, ref.Name)
+	}
+
+	return nil, nil
+}
+
+// tag adds an ImageStreamTag or ImageStreamImage of the ImageStream s to the Graph.
+func (g *ImageV1Graph) tag(s *Node, kind string, namespace string, name string) *Node {
+	t := g.graph.Node(
+		v1.SchemeGroupVersion.WithKind(kind),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, kind, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.graph.Relationship(s, kind, t)
+
+	return t
+}
@@ -0,0 +1,443 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// istioRouteTypes contains the route types of a VirtualService.
+var istioRouteTypes = []string{"http", "tcp", "tls"}
+
+// IstioGraph is used to graph all networking.istio.io resources.
+type IstioGraph struct {
+	graph *Graph
+
+	// gateways contains the nodes by namespace and name, because they are
+	// referenced by many VirtualServices. A nil node was not found.
+	gateways map[string]*Node
+	// selectors contains the Pods by namespace and label selector of the
+	// Gateways and Sidecars.
+	selectors map[string][]*Node
+}
+
+// NewIstioGraph creates a new IstioGraph.
+func NewIstioGraph(g *Graph) *IstioGraph {
+	return &IstioGraph{
+		graph:     g,
+		gateways:  make(map[string]*Node),
+		selectors: make(map[string][]*Node),
+	}
+}
+
+// Istio retrieves the IstioGraph.
+func (g *Graph) Istio() *IstioGraph {
+	return g.istio
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *IstioGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "VirtualService":
+		return g.VirtualService(unstr)
+	case "Gateway":
+		return g.Gateway(unstr)
+	case "DestinationRule":
+		return g.DestinationRule(unstr)
+	case "ServiceEntry":
+		return g.ServiceEntry(unstr)
+	case "Sidecar":
+		return g.Sidecar(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// VirtualService adds a VirtualService resource, its Gateways and the
+// destinations of its routes to the Graph. The weight of a route is added
+// as "percent" attribute to the relationship to its destination.
+func (g *IstioGraph) VirtualService(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	hosts, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "hosts")
+	if len(hosts) != 0 {
+		n.Attribute("hosts", strings.Join(hosts, ","))
+	}
+
+	gateways, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "gateways")
+	for _, gateway := range gateways {
+		// the reserved name mesh applies the routes to all sidecars
+		if gateway == "mesh" {
+			n.Attribute("mesh", "true")
+			continue
+		}
+
+		namespace, name := unstr.GetNamespace(), gateway
+		if i := strings.Index(gateway, "/"); i != -1 {
+			namespace, name = gateway[:i], gateway[i+1:]
+		}
+		gw, err := g.GatewayName(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Gateway", gw)
+	}
+
+	for _, routeType := range istioRouteTypes {
+		routes, _, _ := unstructured.NestedSlice(unstr.Object, "spec", routeType)
+		for _, route := range routes {
+			r, ok := route.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			destinations, _, _ := unstructured.NestedSlice(r, "route")
+			for _, destination := range destinations {
+				d, ok := destination.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				rel, err := g.destination(n, unstr.GetNamespace(), d)
+				if err != nil {
+					return nil, err
+				}
+				if rel == nil {
+					continue
+				}
+				appendAttribute(rel, "protocols", routeType)
+				if weight, ok, _ := unstructured.NestedInt64(d, "weight"); ok {
+					appendAttribute(rel, "percent", strconv.FormatInt(weight, 10))
+				}
+			}
+
+			if mirror, ok, _ := unstructured.NestedMap(r, "mirror"); ok {
+				rel, err := g.destination(n, unstr.GetNamespace(), map[string]interface{}{"destination": mirror})
+				if err != nil {
+					return nil, err
+				}
+				if rel != nil {
+					rel.Attribute("mirror", "true")
+				}
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// destination adds a relationship from n to the destination of a route,
+// which is the subset of a Service if the destination has a subset.
+func (g *IstioGraph) destination(n *Node, namespace string, route map[string]interface{}) (*Relationship, error) {
+	host, _, _ := unstructured.NestedString(route, "destination", "host")
+	if len(host) == 0 {
+		return nil, nil
+	}
+
+	subset, _, _ := unstructured.NestedString(route, "destination", "subset")
+	if serviceNamespace, service, ok := serviceHost(namespace, host); ok && len(subset) != 0 {
+		s, err := g.Subset(serviceNamespace, service, subset)
+		if err != nil {
+			return nil, err
+		}
+		return g.graph.Relationship(n, "Subset", s), nil
+	}
+
+	r, err := g.host(n, "Service", namespace, host)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if port, ok, _ := unstructured.NestedInt64(route, "destination", "port", "number"); ok {
+		appendAttribute(r, "ports", strconv.FormatInt(port, 10))
+	}
+
+	return r, nil
+}
+
+// host adds a relationship from n to the host of an Istio resource. Short
+// names and cluster-local names are resolved to the Service, all other hosts
+// are added as external node, like the hosts of a ServiceEntry. Wildcard
+// hosts are skipped.
+func (g *IstioGraph) host(n *Node, label string, namespace string, host string) (*Relationship, error) {
+	if strings.Contains(host, "*") {
+		return nil, nil
+	}
+
+	if serviceNamespace, service, ok := serviceHost(namespace, host); ok {
+		s, err := g.graph.CoreV1().ServiceName(serviceNamespace, service)
+		if err != nil {
+			return nil, err
+		}
+		return g.graph.Relationship(n, label, s), nil
+	}
+
+	return g.graph.CoreV1().External(n, label, host)
+}
+
+// Gateway adds a Gateway resource and the gateway Pods selected by it to the Graph.
+func (g *IstioGraph) Gateway(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.gateways[path.Join(unstr.GetNamespace(), unstr.GetName())] = n
+
+	hosts, ports := []string{}, []string{}
+	servers, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "servers")
+	for _, server := range servers {
+		s, ok := server.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		serverHosts, _, _ := unstructured.NestedStringSlice(s, "hosts")
+		hosts = append(hosts, serverHosts...)
+		if port, ok, _ := unstructured.NestedInt64(s, "port", "number"); ok {
+			protocol, _, _ := unstructured.NestedString(s, "port", "protocol")
+			ports = append(ports, strconv.FormatInt(port, 10)+"/"+protocol)
+		}
+	}
+	if len(hosts) != 0 {
+		n.Attribute("hosts", strings.Join(hosts, ","))
+	}
+	if len(ports) != 0 {
+		n.Attribute("ports", strings.Join(ports, ","))
+	}
+
+	// the selector of a Gateway matches the gateway Pods in all namespaces
+	selector, _, _ := unstructured.NestedStringMap(unstr.Object, "spec", "selector")
+	pods, err := g.pods(metav1.NamespaceAll, selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pods {
+		g.graph.Relationship(n, "Pod", p)
+	}
+
+	return n, nil
+}
+
+// GatewayName adds the Gateway with the given name to the Graph.
+// A missing Gateway is added as node without UID from the cluster.
+func (g *IstioGraph) GatewayName(namespace string, name string) (*Node, error) {
+	if n, ok := g.gateways[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	gvr := schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "gateways"}
+	unstr, err := g.graph.CustomResource(gvr, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Gateway(unstr)
+	}
+
+	n := g.graph.Node(
+		gvr.GroupVersion().WithKind("Gateway"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "Gateway", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.gateways[path.Join(namespace, name)] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// DestinationRule adds a DestinationRule resource, its host and its subsets to the Graph.
+func (g *IstioGraph) DestinationRule(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	if lb, ok, _ := unstructured.NestedString(unstr.Object, "spec", "trafficPolicy", "loadBalancer", "simple"); ok {
+		n.Attribute("loadBalancer", lb)
+	}
+	if mode, ok, _ := unstructured.NestedString(unstr.Object, "spec", "trafficPolicy", "tls", "mode"); ok {
+		n.Attribute("tls", mode)
+	}
+
+	host, _, _ := unstructured.NestedString(unstr.Object, "spec", "host")
+	if _, err := g.host(n, "Host", unstr.GetNamespace(), host); err != nil {
+		return nil, err
+	}
+
+	namespace, service, ok := serviceHost(unstr.GetNamespace(), host)
+	if !ok {
+		return n, nil
+	}
+
+	subsets, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "subsets")
+	for _, subset := range subsets {
+		s, ok := subset.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(s, "name")
+		if len(name) == 0 {
+			continue
+		}
+
+		sub, err := g.Subset(namespace, service, name)
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(n, "Subset", sub)
+		if selector, _, _ := unstructured.NestedStringMap(s, "labels"); len(selector) != 0 {
+			r.Attribute("labels", labels.SelectorFromSet(selector).String())
+		}
+	}
+
+	return n, nil
+}
+
+// Subset adds a node which represents a subset of a Service to the Graph.
+func (g *IstioGraph) Subset(namespace string, service string, name string) (*Node, error) {
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Subset"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "Subset", service, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+
+	s, err := g.graph.CoreV1().ServiceName(namespace, service)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(n, "Service", s)
+
+	return n, nil
+}
+
+// ServiceEntry adds a ServiceEntry resource, its hosts and its static
+// endpoints to the Graph.
+func (g *IstioGraph) ServiceEntry(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	for _, field := range []string{"location", "resolution"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "spec", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+
+	hosts, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "hosts")
+	for _, host := range hosts {
+		if _, err := g.host(n, "Host", unstr.GetNamespace(), host); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoints, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "endpoints")
+	for _, endpoint := range endpoints {
+		e, ok := endpoint.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if address, _, _ := unstructured.NestedString(e, "address"); len(address) != 0 {
+			if _, err := g.graph.CoreV1().External(n, "Endpoint", address); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Sidecar adds a Sidecar resource to the Graph. A Sidecar with workload
+// selector is linked to the selected Pods, all other Sidecars apply to their
+// namespace. The namespaces of the egress hosts are linked as well.
+func (g *IstioGraph) Sidecar(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	selector, ok, _ := unstructured.NestedStringMap(unstr.Object, "spec", "workloadSelector", "labels")
+	if ok && len(selector) != 0 {
+		n.Attribute("scope", "workload")
+		pods, err := g.pods(unstr.GetNamespace(), selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pods {
+			g.graph.Relationship(n, "Pod", p)
+		}
+	} else {
+		n.Attribute("scope", "namespace")
+	}
+
+	hosts := []string{}
+	egress, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "egress")
+	for _, listener := range egress {
+		l, ok := listener.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		listenerHosts, _, _ := unstructured.NestedStringSlice(l, "hosts")
+		hosts = append(hosts, listenerHosts...)
+	}
+	if len(hosts) != 0 {
+		n.Attribute("egressHosts", strings.Join(hosts, ","))
+	}
+
+	for _, host := range hosts {
+		namespace, _, ok := strings.Cut(host, "/")
+		if !ok || namespace == "*" || namespace == "~" {
+			continue
+		}
+		if namespace == "." {
+			namespace = unstr.GetNamespace()
+		}
+
+		ns, err := g.graph.CoreV1().Namespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Egress", ns)
+	}
+
+	return n, nil
+}
+
+// pods returns the nodes of the Pods which are selected by the labels.
+// An empty selector does not select any Pod.
+func (g *IstioGraph) pods(namespace string, selector map[string]string) ([]*Node, error) {
+	if len(selector) == 0 {
+		return nil, nil
+	}
+
+	s := labels.SelectorFromSet(selector).String()
+	if nodes, ok := g.selectors[namespace+"/"+s]; ok {
+		return nodes, nil
+	}
+
+	options := metav1.ListOptions{LabelSelector: s}
+	pods, err := g.graph.clientset.CoreV1().Pods(namespace).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*Node{}
+	for i := range pods.Items {
+		nodes = append(nodes, g.graph.Node(schema.FromAPIVersionAndKind(v1.GroupName, "Pod"), &pods.Items[i]))
+	}
+	g.selectors[namespace+"/"+s] = nodes
+
+	return nodes, nil
+}
@@ -0,0 +1,254 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KnativeEventingGraph is used to graph all Knative eventing resources.
+type KnativeEventingGraph struct {
+	graph *Graph
+
+	// references contains the nodes of referenced Knative resources by
+	// group, kind, namespace and name, because many Triggers and
+	// Subscriptions reference the same Broker or Channel.
+	references map[string]*Node
+}
+
+// NewKnativeEventingGraph creates a new KnativeEventingGraph.
+func NewKnativeEventingGraph(g *Graph) *KnativeEventingGraph {
+	return &KnativeEventingGraph{
+		graph:      g,
+		references: make(map[string]*Node),
+	}
+}
+
+// KnativeEventing retrieves the KnativeEventingGraph.
+func (g *Graph) KnativeEventing() *KnativeEventingGraph {
+	return g.knativeEventing
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *KnativeEventingGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Broker":
+		return g.Broker(unstr)
+	case "Trigger":
+		return g.Trigger(unstr)
+	case "Subscription":
+		return g.Subscription(unstr)
+	default:
+		// Channels and sources share the same fields
+		return g.Addressable(unstr)
+	}
+}
+
+// Broker adds a Broker resource, its config and its dead letter sink to the Graph.
+func (g *KnativeEventingGraph) Broker(unstr *unstructured.Unstructured) (*Node, error) {
+	n, err := g.Addressable(unstr)
+	if err != nil {
+		return nil, err
+	}
+	if class, ok := unstr.GetAnnotations()["eventing.knative.dev/broker.class"]; ok {
+		n.Attribute("class", class)
+	}
+
+	kind, _, _ := unstructured.NestedString(unstr.Object, "spec", "config", "kind")
+	name, _, _ := unstructured.NestedString(unstr.Object, "spec", "config", "name")
+	if kind == "ConfigMap" && len(name) != 0 {
+		namespace, _, _ := unstructured.NestedString(unstr.Object, "spec", "config", "namespace")
+		if len(namespace) == 0 {
+			namespace = unstr.GetNamespace()
+		}
+		g.graph.Relationship(n, "ConfigMap", g.graph.CoreV1().ConfigMapName(namespace, name))
+	}
+
+	return n, nil
+}
+
+// Trigger adds a Trigger resource, its Broker and its subscriber to the Graph.
+// The filter of the Trigger is added as attribute to the relationship from the Broker.
+func (g *KnativeEventingGraph) Trigger(unstr *unstructured.Unstructured) (*Node, error) {
+	n, err := g.Addressable(unstr)
+	if err != nil {
+		return nil, err
+	}
+
+	if broker, ok, _ := unstructured.NestedString(unstr.Object, "spec", "broker"); ok {
+		gv := schema.GroupVersion{Group: "eventing.knative.dev", Version: "v1"}
+		b, err := g.Reference(gv.String(), "Broker", unstr.GetNamespace(), broker)
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(b, "Trigger", n)
+		if attributes, _, _ := unstructured.NestedStringMap(unstr.Object, "spec", "filter", "attributes"); len(attributes) != 0 {
+			r.Attribute("filter", labels.SelectorFromSet(attributes).String())
+		}
+	}
+
+	if _, err := g.Destination(n, "Subscriber", unstr.GetNamespace(), unstr.Object, "spec", "subscriber"); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Subscription adds a Subscription resource, its Channel, its subscriber
+// and its reply destination to the Graph.
+func (g *KnativeEventingGraph) Subscription(unstr *unstructured.Unstructured) (*Node, error) {
+	n, err := g.Addressable(unstr)
+	if err != nil {
+		return nil, err
+	}
+
+	apiVersion, _, _ := unstructured.NestedString(unstr.Object, "spec", "channel", "apiVersion")
+	kind, _, _ := unstructured.NestedString(unstr.Object, "spec", "channel", "kind")
+	name, _, _ := unstructured.NestedString(unstr.Object, "spec", "channel", "name")
+	if len(kind) != 0 && len(name) != 0 {
+		c, err := g.Reference(apiVersion, kind, unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(c, "Subscription", n)
+	}
+
+	if _, err := g.Destination(n, "Subscriber", unstr.GetNamespace(), unstr.Object, "spec", "subscriber"); err != nil {
+		return nil, err
+	}
+	if _, err := g.Destination(n, "Reply", unstr.GetNamespace(), unstr.Object, "spec", "reply"); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Addressable adds a Knative eventing resource like a Channel or a source
+// to the Graph, including its status, its address, its sink and its dead
+// letter sink.
+func (g *KnativeEventingGraph) Addressable(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.references[knativeReferenceKey(unstr.GroupVersionKind().GroupKind(), unstr.GetNamespace(), unstr.GetName())] = n
+
+	n.Attribute("status", readyStatus(unstr))
+	if url, ok, _ := unstructured.NestedString(unstr.Object, "status", "address", "url"); ok {
+		n.Attribute("url", url)
+	}
+
+	if _, err := g.Destination(n, "Sink", unstr.GetNamespace(), unstr.Object, "spec", "sink"); err != nil {
+		return nil, err
+	}
+	if _, err := g.Destination(n, "DeadLetterSink", unstr.GetNamespace(), unstr.Object, "spec", "delivery", "deadLetterSink"); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Destination adds a relationship from n to the Knative destination at the
+// path of fields, which is either a reference to an object or a URI.
+// It returns nil if the destination does not exist.
+func (g *KnativeEventingGraph) Destination(n *Node, label string, namespace string, obj map[string]interface{}, fields ...string) (*Relationship, error) {
+	destination, ok, _ := unstructured.NestedMap(obj, fields...)
+	if !ok {
+		return nil, nil
+	}
+
+	kind, _, _ := unstructured.NestedString(destination, "ref", "kind")
+	name, _, _ := unstructured.NestedString(destination, "ref", "name")
+	if len(kind) != 0 && len(name) != 0 {
+		apiVersion, _, _ := unstructured.NestedString(destination, "ref", "apiVersion")
+		if ns, _, _ := unstructured.NestedString(destination, "ref", "namespace"); len(ns) != 0 {
+			namespace = ns
+		}
+
+		d, err := g.Reference(apiVersion, kind, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(n, label, d)
+		if uri, _, _ := unstructured.NestedString(destination, "uri"); len(uri) != 0 {
+			r.Attribute("path", uri)
+		}
+
+		return r, nil
+	}
+
+	if uri, _, _ := unstructured.NestedString(destination, "uri"); len(uri) != 0 {
+		return g.graph.CoreV1().URL(n, label, uri)
+	}
+
+	return nil, nil
+}
+
+// Reference adds the object referenced by a Knative destination or a
+// Trigger to the Graph. Services and all Knative resources are read from the
+// cluster, all other or missing objects are added as node without UID from
+// the cluster.
+func (g *KnativeEventingGraph) Reference(apiVersion string, kind string, namespace string, name string) (*Node, error) {
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+	if gvk.Group == v1.GroupName && kind == "Service" {
+		return g.graph.CoreV1().ServiceName(namespace, name)
+	}
+
+	key := knativeReferenceKey(gvk.GroupKind(), namespace, name)
+	if n, ok := g.references[key]; ok {
+		return n, nil
+	}
+
+	// the resources of all Knative groups are named by the lowercase plural of their kind
+	if strings.HasSuffix(gvk.Group, ".knative.dev") {
+		gvr := gvk.GroupVersion().WithResource(strings.ToLower(kind) + "s")
+		unstr, err := g.graph.CustomResource(gvr, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if unstr != nil {
+			n, err := g.graph.Unstructured(unstr)
+			if err != nil {
+				return nil, err
+			}
+			g.references[key] = n
+			return n, nil
+		}
+	}
+
+	n := g.graph.Node(
+		gvk,
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, kind, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.references[key] = n
+	if strings.HasSuffix(gvk.Group, ".knative.dev") {
+		g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+	}
+
+	return n, nil
+}
+
+// knativeReferenceKey returns the key of a referenced object.
+func knativeReferenceKey(gk schema.GroupKind, namespace string, name string) string {
+	return path.Join(gk.String(), namespace, name)
+}
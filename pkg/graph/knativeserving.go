@@ -0,0 +1,281 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// knativeServingV1 is the group version of all Knative Serving resources.
+var knativeServingV1 = schema.GroupVersion{Group: "serving.knative.dev", Version: "v1"}
+
+// KnativeServingV1Graph is used to graph all serving.knative.dev resources.
+type KnativeServingV1Graph struct {
+	graph *Graph
+
+	// revisions contains the nodes by namespace and name, because they are
+	// referenced by Configurations and Routes. A nil node was not found.
+	revisions map[string]*Node
+	// configurations contains the nodes by namespace and name, because
+	// they are referenced by Services and Routes. A nil node was not found.
+	configurations map[string]*Node
+}
+
+// NewKnativeServingV1Graph creates a new KnativeServingV1Graph.
+func NewKnativeServingV1Graph(g *Graph) *KnativeServingV1Graph {
+	return &KnativeServingV1Graph{
+		graph:          g,
+		revisions:      make(map[string]*Node),
+		configurations: make(map[string]*Node),
+	}
+}
+
+// KnativeServingV1 retrieves the KnativeServingV1Graph.
+func (g *Graph) KnativeServingV1() *KnativeServingV1Graph {
+	return g.knativeServingV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *KnativeServingV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Service":
+		return g.Service(unstr)
+	case "Configuration":
+		return g.Configuration(unstr)
+	case "Revision":
+		return g.Revision(unstr)
+	case "Route":
+		return g.Route(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Service adds a Knative Service resource and its Configuration and Route,
+// which have the same name, to the Graph.
+func (g *KnativeServingV1Graph) Service(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+	if url, ok, _ := unstructured.NestedString(unstr.Object, "status", "url"); ok {
+		n.Attribute("url", url)
+	}
+
+	c, err := g.ConfigurationName(unstr.GetNamespace(), unstr.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		g.graph.Relationship(n, "Configuration", c)
+	}
+
+	gvr := knativeServingV1.WithResource("routes")
+	route, err := g.graph.CustomResource(gvr, unstr.GetNamespace(), unstr.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if route != nil {
+		r, err := g.Route(route)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Route", r)
+	}
+
+	return n, nil
+}
+
+// Configuration adds a Configuration resource and all of its Revisions to the Graph.
+func (g *KnativeServingV1Graph) Configuration(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.configurations[key]; ok && n != nil {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.configurations[key] = n
+	n.Attribute("status", readyStatus(unstr))
+	for _, field := range []string{"latestCreatedRevisionName", "latestReadyRevisionName"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "status", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{"serving.knative.dev/configuration": unstr.GetName()})
+	revisions, err := g.graph.CustomResources(knativeServingV1.WithResource("revisions"), unstr.GetNamespace(), selector.String())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range revisions {
+		r, err := g.Revision(&revisions[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Revision", r)
+	}
+
+	return n, nil
+}
+
+// ConfigurationName adds the Configuration with the given name to the Graph.
+// It returns nil if the Configuration does not exist.
+func (g *KnativeServingV1Graph) ConfigurationName(namespace string, name string) (*Node, error) {
+	if n, ok := g.configurations[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(knativeServingV1.WithResource("configurations"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr == nil {
+		g.configurations[path.Join(namespace, name)] = nil
+		return nil, nil
+	}
+
+	return g.Configuration(unstr)
+}
+
+// Revision adds a Revision resource and its Deployment to the Graph.
+func (g *KnativeServingV1Graph) Revision(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.revisions[key]; ok && n != nil {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.revisions[key] = n
+	n.Attribute("status", readyStatus(unstr))
+	if concurrency, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "containerConcurrency"); ok {
+		n.Attribute("containerConcurrency", fmt.Sprint(concurrency))
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{"serving.knative.dev/revision": unstr.GetName()})
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+	deployments, err := g.graph.clientset.AppsV1().Deployments(unstr.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range deployments.Items {
+		d, err := g.graph.AppsV1().Deployment(&deployments.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Deployment", d)
+	}
+
+	return n, nil
+}
+
+// RevisionName adds the Revision with the given name to the Graph.
+// A missing Revision is added as node without UID from the cluster.
+func (g *KnativeServingV1Graph) RevisionName(namespace string, name string) (*Node, error) {
+	if n, ok := g.revisions[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	gvr := knativeServingV1.WithResource("revisions")
+	unstr, err := g.graph.CustomResource(gvr, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Revision(unstr)
+	}
+
+	n := g.graph.Node(
+		knativeServingV1.WithKind("Revision"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "Revision", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.revisions[path.Join(namespace, name)] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// Route adds a Route resource and the Revisions or Configurations of its
+// traffic targets to the Graph. The traffic split is added as "percent"
+// attribute to the relationships. The resolved targets of the status are
+// preferred over the targets of the spec.
+func (g *KnativeServingV1Graph) Route(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+	if url, ok, _ := unstructured.NestedString(unstr.Object, "status", "url"); ok {
+		n.Attribute("url", url)
+	}
+
+	traffic, ok, _ := unstructured.NestedSlice(unstr.Object, "status", "traffic")
+	if !ok {
+		traffic, _, _ = unstructured.NestedSlice(unstr.Object, "spec", "traffic")
+	}
+
+	for _, target := range traffic {
+		t, ok := target.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var (
+			r   *Relationship
+			err error
+		)
+		revision, _, _ := unstructured.NestedString(t, "revisionName")
+		configuration, _, _ := unstructured.NestedString(t, "configurationName")
+		switch {
+		case len(revision) != 0:
+			var rev *Node
+			rev, err = g.RevisionName(unstr.GetNamespace(), revision)
+			if err == nil {
+				r = g.graph.Relationship(n, "Revision", rev)
+			}
+		case len(configuration) != 0:
+			var c *Node
+			c, err = g.ConfigurationName(unstr.GetNamespace(), configuration)
+			if err == nil && c != nil {
+				r = g.graph.Relationship(n, "Configuration", c)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			continue
+		}
+
+		if percent, ok, _ := unstructured.NestedInt64(t, "percent"); ok {
+			r.Attribute("percent", fmt.Sprint(percent))
+		}
+		if tag, ok, _ := unstructured.NestedString(t, "tag"); ok {
+			appendAttribute(r, "tags", tag)
+		}
+		if latest, ok, _ := unstructured.NestedBool(t, "latestRevision"); ok && latest {
+			r.Attribute("latestRevision", "true")
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// kyvernoRuleTypes contains the fields of a Kyverno rule which define its type.
+var kyvernoRuleTypes = []string{"validate", "mutate", "generate", "verifyImages"}
+
+// KyvernoGraph is used to graph all kyverno.io resources.
+type KyvernoGraph struct {
+	graph *Graph
+}
+
+// NewKyvernoGraph creates a new KyvernoGraph.
+func NewKyvernoGraph(g *Graph) *KyvernoGraph {
+	return &KyvernoGraph{
+		graph: g,
+	}
+}
+
+// Kyverno retrieves the KyvernoGraph.
+func (g *Graph) Kyverno() *KyvernoGraph {
+	return g.kyverno
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *KyvernoGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "ClusterPolicy", "Policy":
+		return g.Policy(unstr)
+	case "ClusterPolicyReport", "PolicyReport":
+		return g.PolicyReport(unstr), nil
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Policy adds a ClusterPolicy or Policy resource and the kinds and namespaces
+// which are matched by its rules to the Graph.
+func (g *KyvernoGraph) Policy(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+	if action, ok, _ := unstructured.NestedString(unstr.Object, "spec", "validationFailureAction"); ok {
+		n.Attribute("validationFailureAction", action)
+	}
+	if background, ok, _ := unstructured.NestedBool(unstr.Object, "spec", "background"); ok {
+		n.Attribute("background", strconv.FormatBool(background))
+	}
+
+	rules, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "rules")
+	n.Attribute("rules", strconv.Itoa(len(rules)))
+
+	for _, rule := range rules {
+		r, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(r, "name")
+
+		ruleType := ""
+		for _, field := range kyvernoRuleTypes {
+			if _, ok := r[field]; ok {
+				ruleType = field
+			}
+		}
+
+		// the resource filters are either the legacy resources field or a list of any or all filters
+		filters := []map[string]interface{}{}
+		if resources, ok, _ := unstructured.NestedMap(r, "match", "resources"); ok {
+			filters = append(filters, resources)
+		}
+		for _, field := range []string{"any", "all"} {
+			list, _, _ := unstructured.NestedSlice(r, "match", field)
+			for _, item := range list {
+				i, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if resources, ok, _ := unstructured.NestedMap(i, "resources"); ok {
+					filters = append(filters, resources)
+				}
+			}
+		}
+
+		for _, filter := range filters {
+			if err := g.match(n, unstr.GetNamespace(), name, ruleType, filter); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// match adds relationships from a policy to the kinds and namespaces which are
+// matched by the resource filter of a rule. Namespaces with wildcards are added
+// as attribute.
+func (g *KyvernoGraph) match(n *Node, namespace string, rule string, ruleType string, filter map[string]interface{}) error {
+	kinds, _, _ := unstructured.NestedStringSlice(filter, "kinds")
+	for _, kind := range kinds {
+		group, kind := kyvernoKind(kind)
+		r := g.graph.Relationship(n, "Kind", g.graph.AdmissionregistrationV1().Kind(group, kind))
+		appendAttribute(r, "rules", rule)
+		appendAttribute(r, "types", ruleType)
+	}
+
+	// a namespaced Policy only matches resources of its own namespace
+	if len(namespace) != 0 {
+		return nil
+	}
+
+	patterns := []string{}
+	if len(n.Attr["namespaces"]) != 0 {
+		patterns = strings.Split(n.Attr["namespaces"], ",")
+	}
+
+	namespaces, _, _ := unstructured.NestedStringSlice(filter, "namespaces")
+	for _, name := range namespaces {
+		if strings.ContainsAny(name, "*?") {
+			if !slices.Contains(patterns, name) {
+				patterns = append(patterns, name)
+				n.Attribute("namespaces", strings.Join(patterns, ","))
+			}
+			continue
+		}
+
+		ns, err := g.graph.CoreV1().Namespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		if err != nil {
+			return err
+		}
+		appendAttribute(g.graph.Relationship(n, "Namespace", ns), "rules", rule)
+	}
+
+	if selector, ok, _ := unstructured.NestedMap(filter, "namespaceSelector"); ok {
+		namespaceSelector := &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selector, namespaceSelector); err != nil {
+			return err
+		}
+		return g.graph.AdmissionregistrationV1().namespaceSelector(n, namespaceSelector)
+	}
+
+	return nil
+}
+
+// PolicyReport adds a ClusterPolicyReport or PolicyReport resource, the number
+// of results by status and the resources which violate the policies to the Graph.
+func (g *KyvernoGraph) PolicyReport(unstr *unstructured.Unstructured) *Node {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	for _, field := range []string{"pass", "fail", "warn", "error", "skip"} {
+		if count, ok, _ := unstructured.NestedInt64(unstr.Object, "summary", field); ok {
+			n.Attribute(field, strconv.FormatInt(count, 10))
+		}
+	}
+
+	// the reports of Kyverno 1.10 and later are created per resource, which is the scope of the report
+	scope, hasScope, _ := unstructured.NestedMap(unstr.Object, "scope")
+
+	results, _, _ := unstructured.NestedSlice(unstr.Object, "results")
+	for _, result := range results {
+		r, ok := result.(map[string]interface{})
+		if !ok || (r["result"] != "fail" && r["result"] != "error") {
+			continue
+		}
+
+		resources, _, _ := unstructured.NestedSlice(r, "resources")
+		if hasScope && len(resources) == 0 {
+			resources = append(resources, scope)
+		}
+
+		for _, resource := range resources {
+			ref, ok := resource.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			apiVersion, _, _ := unstructured.NestedString(ref, "apiVersion")
+			kind, _, _ := unstructured.NestedString(ref, "kind")
+			namespace, _, _ := unstructured.NestedString(ref, "namespace")
+			name, _, _ := unstructured.NestedString(ref, "name")
+			uid, _, _ := unstructured.NestedString(ref, "uid")
+
+			v := g.graph.Violation(n, schema.FromAPIVersionAndKind(apiVersion, kind), namespace, name, types.UID(uid))
+			if policy, ok := r["policy"].(string); ok {
+				appendAttribute(v, "policies", policy)
+			}
+			if rule, ok := r["rule"].(string); ok {
+				appendAttribute(v, "rules", rule)
+			}
+		}
+	}
+
+	return n
+}
+
+// kyvernoKind returns the group and kind of a kind in a Kyverno resource
+// filter, which is either "Kind", "Version/Kind", "Group/Version/Kind" or
+// one of these followed by a subresource.
+func kyvernoKind(kind string) (string, string) {
+	parts := strings.Split(kind, "/")
+	switch {
+	case len(parts) >= 3 && strings.HasPrefix(parts[1], "v") && !strings.HasPrefix(parts[0], "v"):
+		return parts[0], parts[2]
+	case len(parts) >= 2:
+		return "", parts[1]
+	}
+
+	return "", kind
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Position represents the coordinates of a node.
+type Position struct {
+	X int
+	Y int
+}
+
+// Layout arranges all nodes in layers, starting with the nodes without any
+// incoming relationship, and returns the position of each node. The spacing
+// between two layers and two nodes within a layer is given by dx and dy.
+func (g *Graph) Layout(dx int, dy int) map[types.UID]Position {
+	children := make(map[types.UID][]types.UID)
+	parents := make(map[types.UID]bool)
+	for _, relationship := range g.RelationshipList() {
+		children[relationship.From] = append(children[relationship.From], relationship.To)
+		parents[relationship.To] = true
+	}
+
+	layers := make(map[types.UID]int)
+	queue := []types.UID{}
+	for uid := range g.Nodes {
+		if !parents[uid] {
+			layers[uid] = 0
+			queue = append(queue, uid)
+		}
+	}
+
+	for len(queue) != 0 {
+		uid := queue[0]
+		queue = queue[1:]
+
+		for _, child := range children[uid] {
+			if _, ok := layers[child]; !ok {
+				layers[child] = layers[uid] + 1
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	rows := make(map[int][]*Node)
+	for uid, node := range g.Nodes {
+		rows[layers[uid]] = append(rows[layers[uid]], node)
+	}
+
+	positions := make(map[types.UID]Position, len(g.Nodes))
+	for layer, nodes := range rows {
+		sort.Slice(nodes, func(i, j int) bool {
+			if nodes[i].Kind != nodes[j].Kind {
+				return nodes[i].Kind < nodes[j].Kind
+			}
+			return nodes[i].Name < nodes[j].Name
+		})
+		for i, node := range nodes {
+			positions[node.UID] = Position{X: i * dx, Y: layer * dy}
+		}
+	}
+
+	return positions
+}
@@ -0,0 +1,307 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// LinkerdGraph is used to graph all linkerd.io resources.
+type LinkerdGraph struct {
+	graph *Graph
+
+	// servers contains the nodes by namespace and name, because they are
+	// referenced by many authorizations and routes. A nil node was not found.
+	servers map[string]*Node
+}
+
+// NewLinkerdGraph creates a new LinkerdGraph.
+func NewLinkerdGraph(g *Graph) *LinkerdGraph {
+	return &LinkerdGraph{
+		graph:   g,
+		servers: make(map[string]*Node),
+	}
+}
+
+// Linkerd retrieves the LinkerdGraph.
+func (g *Graph) Linkerd() *LinkerdGraph {
+	return g.linkerd
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *LinkerdGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "ServiceProfile":
+		return g.ServiceProfile(unstr)
+	case "Server":
+		return g.Server(unstr)
+	case "ServerAuthorization":
+		return g.ServerAuthorization(unstr)
+	case "HTTPRoute":
+		return g.HTTPRoute(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// ServiceProfile adds a ServiceProfile resource, its Service and the
+// Services of its destination overrides to the Graph.
+func (g *LinkerdGraph) ServiceProfile(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	routes, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "routes")
+	n.Attribute("routes", fmt.Sprint(len(routes)))
+
+	// the name of a ServiceProfile is the FQDN of its Service
+	if namespace, name, ok := serviceHost(unstr.GetNamespace(), unstr.GetName()); ok {
+		s, err := g.graph.CoreV1().ServiceName(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Service", s)
+	}
+
+	overrides, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "dstOverrides")
+	for _, override := range overrides {
+		o, ok := override.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		authority, _, _ := unstructured.NestedString(o, "authority")
+		namespace, name, ok := serviceHost(unstr.GetNamespace(), authority)
+		if !ok {
+			continue
+		}
+
+		s, err := g.graph.CoreV1().ServiceName(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(n, "DstOverride", s)
+		if weight, ok := o["weight"]; ok {
+			r.Attribute("trafficWeight", fmt.Sprint(weight))
+		}
+	}
+
+	return n, nil
+}
+
+// Server adds a Server resource and the workloads of the Pods selected by it to the Graph.
+func (g *LinkerdGraph) Server(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.servers[path.Join(unstr.GetNamespace(), unstr.GetName())] = n
+
+	// the port is either a number or the name of a container port
+	if port, ok, _ := unstructured.NestedFieldNoCopy(unstr.Object, "spec", "port"); ok {
+		n.Attribute("port", fmt.Sprint(port))
+	}
+	if protocol, ok, _ := unstructured.NestedString(unstr.Object, "spec", "proxyProtocol"); ok {
+		n.Attribute("proxyProtocol", protocol)
+	}
+
+	selector, ok, err := NestedLabelSelector(unstr.Object, "spec", "podSelector")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod selector of Server %s: %v", unstr.GetName(), err)
+	}
+	if !ok {
+		return n, nil
+	}
+
+	options := metav1.ListOptions{LabelSelector: selector.String()}
+	pods, err := g.graph.clientset.CoreV1().Pods(unstr.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[types.UID]bool)
+	for i := range pods.Items {
+		w, err := g.graph.CoreV1().PodWorkload(&pods.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		if seen[w.GetUID()] {
+			continue
+		}
+		seen[w.GetUID()] = true
+		g.graph.Relationship(n, w.Kind, w)
+	}
+
+	return n, nil
+}
+
+// ServerName adds the Server with the given name to the Graph.
+// A missing Server is added as node without UID from the cluster.
+func (g *LinkerdGraph) ServerName(namespace string, name string) (*Node, error) {
+	if n, ok := g.servers[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	gvr := schema.GroupVersionResource{Group: "policy.linkerd.io", Version: "v1beta1", Resource: "servers"}
+	unstr, err := g.graph.CustomResource(gvr, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Server(unstr)
+	}
+
+	n := g.graph.Node(
+		gvr.GroupVersion().WithKind("Server"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "Server", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.servers[path.Join(namespace, name)] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// ServerAuthorization adds a ServerAuthorization resource, its Server and
+// the ServiceAccounts of its authorized clients to the Graph.
+func (g *LinkerdGraph) ServerAuthorization(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	if name, ok, _ := unstructured.NestedString(unstr.Object, "spec", "server", "name"); ok {
+		s, err := g.ServerName(unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Server", s)
+	}
+	if selector, ok, err := NestedLabelSelector(unstr.Object, "spec", "server", "selector"); err == nil && ok {
+		n.Attribute("serverSelector", selector.String())
+	}
+
+	if unauthenticated, ok, _ := unstructured.NestedBool(unstr.Object, "spec", "client", "unauthenticated"); ok {
+		n.Attribute("unauthenticated", fmt.Sprint(unauthenticated))
+	}
+
+	networks := []string{}
+	cidrs, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "client", "networks")
+	for _, cidr := range cidrs {
+		if c, ok := cidr.(map[string]interface{}); ok {
+			if value, _, _ := unstructured.NestedString(c, "cidr"); len(value) != 0 {
+				networks = append(networks, value)
+			}
+		}
+	}
+	if len(networks) != 0 {
+		n.Attribute("networks", strings.Join(networks, ","))
+	}
+
+	serviceAccounts, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "client", "meshTLS", "serviceAccounts")
+	for _, serviceAccount := range serviceAccounts {
+		sa, ok := serviceAccount.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(sa, "name")
+		namespace, _, _ := unstructured.NestedString(sa, "namespace")
+		if len(namespace) == 0 {
+			namespace = unstr.GetNamespace()
+		}
+
+		s, err := g.graph.CoreV1().ServiceAccountName(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "ServiceAccount", s)
+	}
+
+	return n, nil
+}
+
+// HTTPRoute adds a HTTPRoute resource, its parent Servers or Services and
+// the Services of its backends to the Graph.
+func (g *LinkerdGraph) HTTPRoute(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	parentRefs, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "parentRefs")
+	for _, parentRef := range parentRefs {
+		ref, ok := parentRef.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(ref, "kind")
+		name, _, _ := unstructured.NestedString(ref, "name")
+		namespace, _, _ := unstructured.NestedString(ref, "namespace")
+		if len(namespace) == 0 {
+			namespace = unstr.GetNamespace()
+		}
+
+		var (
+			p   *Node
+			err error
+		)
+		switch kind {
+		case "Server":
+			p, err = g.ServerName(namespace, name)
+		case "Service":
+			p, err = g.graph.CoreV1().ServiceName(namespace, name)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, kind, p)
+	}
+
+	rules, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "rules")
+	for _, rule := range rules {
+		r, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		backendRefs, _, _ := unstructured.NestedSlice(r, "backendRefs")
+		for _, backendRef := range backendRefs {
+			ref, ok := backendRef.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(ref, "name")
+			namespace, _, _ := unstructured.NestedString(ref, "namespace")
+			if len(namespace) == 0 {
+				namespace = unstr.GetNamespace()
+			}
+
+			s, err := g.graph.CoreV1().ServiceName(namespace, name)
+			if err != nil {
+				return nil, err
+			}
+			rel := g.graph.Relationship(n, "Backend", s)
+			if port, ok, _ := unstructured.NestedInt64(ref, "port"); ok {
+				appendAttribute(rel, "ports", fmt.Sprint(port))
+			}
+			if weight, ok, _ := unstructured.NestedInt64(ref, "weight"); ok {
+				rel.Attribute("trafficWeight", fmt.Sprint(weight))
+			}
+		}
+	}
+
+	return n, nil
+}
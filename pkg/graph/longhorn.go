@@ -0,0 +1,261 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"path"
+	"strconv"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// LonghornDriver is the name of the CSI driver of Longhorn, the handle of its
+// PersistentVolumes is the name of the Longhorn Volume.
+const LonghornDriver = "driver.longhorn.io"
+
+// longhornV1beta2 is the group version of all Longhorn resources.
+var longhornV1beta2 = schema.GroupVersion{Group: "longhorn.io", Version: "v1beta2"}
+
+// LonghornGraph is used to graph all longhorn.io resources.
+type LonghornGraph struct {
+	graph *Graph
+
+	// volumes contains the Volumes by name, because they are referenced
+	// by PersistentVolumes without namespace.
+	volumes map[string]*Node
+	// listed contains the Volumes of all namespaces, which are listed once
+	// if a Volume is referenced before it is graphed.
+	listed []unstructured.Unstructured
+	// replicas contains the Replicas by namespace and name, because they
+	// are referenced by the replica mode map of the Engines.
+	replicas map[string]*Node
+}
+
+// NewLonghornGraph creates a new LonghornGraph.
+func NewLonghornGraph(g *Graph) *LonghornGraph {
+	return &LonghornGraph{
+		graph:    g,
+		volumes:  make(map[string]*Node),
+		replicas: make(map[string]*Node),
+	}
+}
+
+// Longhorn retrieves the LonghornGraph.
+func (g *Graph) Longhorn() *LonghornGraph {
+	return g.longhorn
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *LonghornGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Volume":
+		return g.Volume(unstr)
+	case "Replica":
+		return g.Replica(unstr)
+	case "Engine":
+		return g.Engine(unstr)
+	case "Node":
+		return g.Node(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Volume adds a Longhorn Volume resource, its Engines and Replicas and its
+// PersistentVolume to the Graph. The robustness of the Volume is added as status.
+func (g *LonghornGraph) Volume(unstr *unstructured.Unstructured) (*Node, error) {
+	if n, ok := g.volumes[unstr.GetName()]; ok && n != nil {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.volumes[unstr.GetName()] = n
+
+	if robustness, ok, _ := unstructured.NestedString(unstr.Object, "status", "robustness"); ok {
+		n.Attribute("status", robustness)
+	}
+	if state, ok, _ := unstructured.NestedString(unstr.Object, "status", "state"); ok {
+		n.Attribute("state", state)
+	}
+	if size, ok, _ := unstructured.NestedString(unstr.Object, "spec", "size"); ok {
+		if bytes, err := strconv.ParseInt(size, 10, 64); err == nil {
+			n.Attribute("size", resource.NewQuantity(bytes, resource.BinarySI).String())
+		}
+	}
+	if replicas, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "numberOfReplicas"); ok {
+		n.Attribute("numberOfReplicas", strconv.FormatInt(replicas, 10))
+	}
+	for _, field := range []string{"frontend", "dataLocality", "accessMode"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "spec", field); ok && len(value) != 0 {
+			n.Attribute(field, value)
+		}
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{"longhornvolume": unstr.GetName()}).String()
+	for _, resource := range []string{"replicas", "engines"} {
+		list, err := g.graph.CustomResources(longhornV1beta2.WithResource(resource), unstr.GetNamespace(), selector)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			m, err := g.Unstructured(&list[i])
+			if err != nil {
+				return nil, err
+			}
+			g.graph.Relationship(n, m.Kind, m)
+		}
+	}
+
+	name, _, _ := unstructured.NestedString(unstr.Object, "status", "kubernetesStatus", "pvName")
+	if len(name) == 0 {
+		return n, nil
+	}
+
+	options := metav1.GetOptions{}
+	pv, err := g.graph.clientset.CoreV1().PersistentVolumes().Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		g.graph.Warn(WarningNotFound, n, "PersistentVolume %s not found", name)
+		return n, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := g.graph.CoreV1().PersistentVolume(pv)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(p, "Volume", n)
+
+	return n, nil
+}
+
+// VolumeName adds the Longhorn Volume with the given name to the Graph.
+// The Volumes of all namespaces are listed once from the cluster, if the
+// Volume is not graphed yet. It returns nil if the Volume does not exist.
+func (g *LonghornGraph) VolumeName(name string) (*Node, error) {
+	if n, ok := g.volumes[name]; ok {
+		return n, nil
+	}
+
+	if g.listed == nil {
+		volumes, err := g.graph.CustomResources(longhornV1beta2.WithResource("volumes"), "", "")
+		if err != nil {
+			return nil, err
+		}
+		g.listed = append([]unstructured.Unstructured{}, volumes...)
+	}
+
+	for i := range g.listed {
+		if g.listed[i].GetName() == name {
+			return g.Volume(&g.listed[i])
+		}
+	}
+	g.volumes[name] = nil
+
+	return nil, nil
+}
+
+// Replica adds a Longhorn Replica resource and the Node it runs on to the Graph.
+func (g *LonghornGraph) Replica(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.replicas[path.Join(unstr.GetNamespace(), unstr.GetName())] = n
+
+	if failedAt, ok, _ := unstructured.NestedString(unstr.Object, "spec", "failedAt"); ok && len(failedAt) != 0 {
+		n.Attribute("failedAt", failedAt)
+	}
+	if disk, ok, _ := unstructured.NestedString(unstr.Object, "spec", "diskPath"); ok && len(disk) != 0 {
+		n.Attribute("diskPath", disk)
+	}
+
+	if err := g.instance(n, unstr); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Engine adds a Longhorn Engine resource, the Node it runs on and the mode of
+// its Replicas to the Graph.
+func (g *LonghornGraph) Engine(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+
+	if err := g.instance(n, unstr); err != nil {
+		return nil, err
+	}
+
+	modes, _, _ := unstructured.NestedStringMap(unstr.Object, "status", "replicaModeMap")
+	for name, mode := range modes {
+		if r, ok := g.replicas[path.Join(unstr.GetNamespace(), name)]; ok {
+			g.graph.Relationship(n, "Replica", r).Attribute("mode", mode)
+		}
+	}
+
+	return n, nil
+}
+
+// Node adds a Longhorn Node resource and the Kubernetes Node with the same name to the Graph.
+func (g *LonghornGraph) Node(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+	if allow, ok, _ := unstructured.NestedBool(unstr.Object, "spec", "allowScheduling"); ok {
+		n.Attribute("allowScheduling", strconv.FormatBool(allow))
+	}
+	disks, _, _ := unstructured.NestedMap(unstr.Object, "spec", "disks")
+	n.Attribute("disks", strconv.Itoa(len(disks)))
+
+	node, err := g.graph.CoreV1().NodeName(unstr.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		g.graph.Warn(WarningNotFound, n, "Node %s not found", unstr.GetName())
+	} else {
+		g.graph.Relationship(n, "Node", node)
+	}
+
+	return n, nil
+}
+
+// instance adds the state of an Engine or Replica and a relationship to the
+// Node it runs on to the Graph.
+func (g *LonghornGraph) instance(n *Node, unstr *unstructured.Unstructured) error {
+	if state, ok, _ := unstructured.NestedString(unstr.Object, "status", "currentState"); ok {
+		n.Attribute("state", state)
+	}
+
+	name, _, _ := unstructured.NestedString(unstr.Object, "spec", "nodeID")
+	if len(name) == 0 {
+		return nil
+	}
+
+	node, err := g.graph.CoreV1().NodeName(name)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		g.graph.Warn(WarningNotFound, n, "Node %s not found", name)
+		return nil
+	}
+	g.graph.LabeledRelationship(n, "RUNS_ON", node)
+
+	return nil
+}
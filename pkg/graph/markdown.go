@@ -0,0 +1,139 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// writeMarkdown writes the graph as Markdown report to w. The report contains
+// one section per namespace with a table of all resources and a tree of all
+// relationships between them, followed by the warnings.
+func (g *Graph) writeMarkdown(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	namespaces := make(map[string][]*Node)
+	for _, node := range g.NodeList() {
+		namespaces[node.Namespace] = append(namespaces[node.Namespace], node)
+	}
+
+	children := make(map[types.UID][]*Relationship)
+	parents := make(map[types.UID][]*Relationship)
+	for _, relationship := range g.RelationshipList() {
+		children[relationship.From] = append(children[relationship.From], relationship)
+		parents[relationship.To] = append(parents[relationship.To], relationship)
+	}
+	for _, relationships := range children {
+		sort.Slice(relationships, func(i, j int) bool {
+			return g.less(g.Nodes[relationships[i].To], g.Nodes[relationships[j].To])
+		})
+	}
+
+	fmt.Fprintln(b, "# Resources")
+
+	for _, namespace := range sortedKeys(namespaces) {
+		nodes := namespaces[namespace]
+		sort.Slice(nodes, func(i, j int) bool { return g.less(nodes[i], nodes[j]) })
+
+		if len(namespace) == 0 {
+			fmt.Fprint(b, "\n## Cluster\n\n")
+		} else {
+			fmt.Fprintf(b, "\n## Namespace `%s`\n\n", namespace)
+		}
+
+		fmt.Fprintln(b, "| Kind | Name | Labels |")
+		fmt.Fprintln(b, "| ---- | ---- | ------ |")
+		for _, node := range nodes {
+			labels := []string{}
+			for _, key := range sortedKeys(node.Labels) {
+				labels = append(labels, fmt.Sprintf("`%s=%s`", key, node.Labels[key]))
+			}
+			fmt.Fprintf(b, "| %s | %s | %s |\n", markdownCell(node.Kind), markdownCell(node.Name), markdownCell(strings.Join(labels, " ")))
+		}
+
+		fmt.Fprint(b, "\n### Dependencies\n\n")
+
+		visited := make(map[types.UID]bool)
+		for _, node := range nodes {
+			if g.hasParentIn(node, namespace, parents[node.UID]) {
+				continue
+			}
+			g.writeMarkdownTree(b, node, namespace, "", 0, children, visited)
+		}
+	}
+
+	if warnings := g.Warnings(); len(warnings) != 0 {
+		fmt.Fprint(b, "\n# Warnings\n\n")
+		fmt.Fprintln(b, "| Reason | Kind | Namespace | Name | Message |")
+		fmt.Fprintln(b, "| ------ | ---- | --------- | ---- | ------- |")
+		for _, w := range warnings {
+			fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n", markdownCell(w.Reason), markdownCell(w.Kind), markdownCell(w.Namespace), markdownCell(w.Name), markdownCell(w.Message))
+		}
+	}
+
+	return b.Flush()
+}
+
+// writeMarkdownTree writes node and all of its descendants within namespace as bulleted list.
+func (g *Graph) writeMarkdownTree(w io.Writer, node *Node, namespace string, label string, depth int, children map[types.UID][]*Relationship, visited map[types.UID]bool) {
+	indent := strings.Repeat("  ", depth)
+	suffix := ""
+	if len(label) != 0 && label != node.Kind {
+		suffix = fmt.Sprintf(" _(%s)_", label)
+	}
+
+	if node.Namespace != namespace {
+		fmt.Fprintf(w, "%s- %s `%s`%s _(namespace %s)_\n", indent, node.Kind, node.Name, suffix, node.Namespace)
+		return
+	}
+	if visited[node.UID] {
+		fmt.Fprintf(w, "%s- %s `%s`%s _(see above)_\n", indent, node.Kind, node.Name, suffix)
+		return
+	}
+	visited[node.UID] = true
+
+	fmt.Fprintf(w, "%s- %s `%s`%s\n", indent, node.Kind, node.Name, suffix)
+	for _, relationship := range children[node.UID] {
+		child, ok := g.Nodes[relationship.To]
+		if !ok || (node.Kind == "Namespace" && child.Namespace == node.Name) {
+			// the members of a namespace are listed in its own section
+			continue
+		}
+		g.writeMarkdownTree(w, child, namespace, relationship.Label, depth+1, children, visited)
+	}
+}
+
+// hasParentIn returns true if one of the incoming relationships of node is from a node in namespace.
+func (g *Graph) hasParentIn(node *Node, namespace string, relationships []*Relationship) bool {
+	for _, relationship := range relationships {
+		if parent, ok := g.Nodes[relationship.From]; ok && parent.Namespace == namespace && parent.UID != node.UID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markdownCell escapes s to be used inside of a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
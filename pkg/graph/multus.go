@@ -0,0 +1,238 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// MultusNetworksAnnotation requests the secondary networks of a Pod.
+	MultusNetworksAnnotation = "k8s.v1.cni.cncf.io/networks"
+	// MultusNetworkStatusAnnotation contains the attached networks of a Pod.
+	MultusNetworkStatusAnnotation = "k8s.v1.cni.cncf.io/network-status"
+	// MultusResourceNameAnnotation contains the device plugin resource of a network, e.g. for SR-IOV.
+	MultusResourceNameAnnotation = "k8s.v1.cni.cncf.io/resourceName"
+)
+
+// multusV1 is the group version of the NetworkAttachmentDefinitions.
+var multusV1 = schema.GroupVersion{Group: "k8s.cni.cncf.io", Version: "v1"}
+
+// multusNetwork is a secondary network of a Pod.
+type multusNetwork struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips"`
+}
+
+// multusConfig contains the fields of a CNI configuration which are graphed.
+type multusConfig struct {
+	Type    string `json:"type"`
+	Master  string `json:"master"`
+	Plugins []struct {
+		Type string `json:"type"`
+	} `json:"plugins"`
+	IPAM struct {
+		Type string `json:"type"`
+	} `json:"ipam"`
+}
+
+// MultusGraph is used to graph all k8s.cni.cncf.io resources.
+type MultusGraph struct {
+	graph *Graph
+
+	// networks contains the NetworkAttachmentDefinitions by namespace and
+	// name, because they are referenced by many Pods.
+	networks map[string]*Node
+}
+
+// NewMultusGraph creates a new MultusGraph.
+func NewMultusGraph(g *Graph) *MultusGraph {
+	return &MultusGraph{
+		graph:    g,
+		networks: make(map[string]*Node),
+	}
+}
+
+// Multus retrieves the MultusGraph.
+func (g *Graph) Multus() *MultusGraph {
+	return g.multus
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *MultusGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "NetworkAttachmentDefinition":
+		return g.NetworkAttachmentDefinition(unstr), nil
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// NetworkAttachmentDefinition adds a NetworkAttachmentDefinition resource to
+// the Graph. The type, master interface and IPAM of its CNI configuration are
+// added as attributes.
+func (g *MultusGraph) NetworkAttachmentDefinition(unstr *unstructured.Unstructured) *Node {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.networks[path.Join(unstr.GetNamespace(), unstr.GetName())] = n
+
+	if resourceName, ok := unstr.GetAnnotations()[MultusResourceNameAnnotation]; ok {
+		n.Attribute("resourceName", resourceName)
+	}
+
+	config, _, _ := unstructured.NestedString(unstr.Object, "spec", "config")
+	c := &multusConfig{}
+	if err := json.Unmarshal([]byte(config), c); err != nil {
+		// the configuration is read from a file on the nodes
+		return n
+	}
+
+	types := []string{}
+	if len(c.Type) != 0 {
+		types = append(types, c.Type)
+	}
+	for _, plugin := range c.Plugins {
+		types = append(types, plugin.Type)
+	}
+	if len(types) != 0 {
+		n.Attribute("type", strings.Join(types, ","))
+	}
+	if len(c.Master) != 0 {
+		n.Attribute("master", c.Master)
+	}
+	if len(c.IPAM.Type) != 0 {
+		n.Attribute("ipam", c.IPAM.Type)
+	}
+
+	return n
+}
+
+// NetworkAttachmentDefinitionName adds the NetworkAttachmentDefinition with the given name to the Graph.
+// A missing NetworkAttachmentDefinition is added as node without UID from the cluster.
+func (g *MultusGraph) NetworkAttachmentDefinitionName(namespace string, name string) (*Node, error) {
+	key := path.Join(namespace, name)
+	if n, ok := g.networks[key]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(multusV1.WithResource("network-attachment-definitions"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.NetworkAttachmentDefinition(unstr), nil
+	}
+
+	n := g.graph.Node(
+		multusV1.WithKind("NetworkAttachmentDefinition"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "NetworkAttachmentDefinition", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.networks[key] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// PodNetworks adds a relationship from each NetworkAttachmentDefinition which
+// is requested by the annotation of a Pod to the Pod. The interface and the
+// IPs of the attached network are added as attributes.
+func (g *MultusGraph) PodNetworks(n *Node, pod *v1.Pod) error {
+	annotation, ok := pod.GetAnnotations()[MultusNetworksAnnotation]
+	if !ok {
+		return nil
+	}
+
+	networks, err := multusNetworks(annotation)
+	if err != nil {
+		g.graph.Warn(WarningInvalid, n, "invalid annotation %s: %v", MultusNetworksAnnotation, err)
+		return nil
+	}
+
+	// the status contains the networks by namespace and name, including the default network
+	status := []multusNetwork{}
+	if annotation, ok := pod.GetAnnotations()[MultusNetworkStatusAnnotation]; ok {
+		_ = json.Unmarshal([]byte(annotation), &status)
+	}
+
+	for _, network := range networks {
+		namespace := network.Namespace
+		if len(namespace) == 0 {
+			namespace = pod.GetNamespace()
+		}
+
+		nad, err := g.NetworkAttachmentDefinitionName(namespace, network.Name)
+		if err != nil {
+			return err
+		}
+
+		r := g.graph.Relationship(nad, "Pod", n)
+		if len(network.Interface) != 0 {
+			r.Attribute("interface", network.Interface)
+		}
+		for _, s := range status {
+			if s.Name != path.Join(namespace, network.Name) || (len(network.Interface) != 0 && s.Interface != network.Interface) {
+				continue
+			}
+			r.Attribute("interface", s.Interface)
+			if len(s.IPs) != 0 {
+				r.Attribute("ips", strings.Join(s.IPs, ","))
+			}
+		}
+	}
+
+	return nil
+}
+
+// multusNetworks parses the networks annotation of a Pod, which is either a
+// JSON list or a comma separated list of "namespace/name@interface" entries.
+func multusNetworks(annotation string) ([]multusNetwork, error) {
+	networks := []multusNetwork{}
+	if strings.HasPrefix(strings.TrimSpace(annotation), "[") {
+		if err := json.Unmarshal([]byte(annotation), &networks); err != nil {
+			return nil, err
+		}
+		return networks, nil
+	}
+
+	for _, entry := range strings.Split(annotation, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		network := multusNetwork{}
+		entry, network.Interface, _ = strings.Cut(entry, "@")
+		if namespace, name, ok := strings.Cut(entry, "/"); ok {
+			network.Namespace, network.Name = namespace, name
+		} else {
+			network.Name = entry
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
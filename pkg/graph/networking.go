@@ -17,22 +17,41 @@ package graph
 import (
 	"context"
 	"fmt"
+	"strconv"
 
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// ingressControllers maps the controller of well-known ingress classes to
+// the "app.kubernetes.io/name" label of the controller Deployment.
+var ingressControllers = map[string]string{
+	"k8s.io/ingress-nginx":                   "ingress-nginx",
+	"traefik.io/ingress-controller":          "traefik",
+	"haproxy.org/ingress-controller/haproxy": "kubernetes-ingress",
+	"ingress-controllers.konghq.com/kong":    "kong",
+	"ingress.k8s.aws/alb":                    "aws-load-balancer-controller",
+	"projectcontour.io/ingress-controller":   "contour",
+}
+
 // NetworkingV1Graph is used to graph all networking resources.
 type NetworkingV1Graph struct {
 	graph *Graph
+
+	// ingressClasses contains the nodes by name, the default class has
+	// an empty name. A nil node was not found.
+	ingressClasses map[string]*Node
 }
 
 // NewNetworkingV1Graph creates a new NetworkingV1Graph.
 func NewNetworkingV1Graph(g *Graph) *NetworkingV1Graph {
 	return &NetworkingV1Graph{
-		graph: g,
+		graph:          g,
+		ingressClasses: make(map[string]*Node),
 	}
 }
 
@@ -50,6 +69,12 @@ func (g *NetworkingV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Nod
 			return nil, err
 		}
 		return g.Ingress(obj)
+	case "IngressClass":
+		obj := &v1.IngressClass{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.IngressClass(obj)
 	case "NetworkPolicy":
 		obj := &v1.NetworkPolicy{}
 		if err := FromUnstructured(unstr, obj); err != nil {
@@ -79,6 +104,30 @@ func (g *NetworkingV1Graph) Relationship(from *Node, policyType v1.PolicyType, t
 func (g *NetworkingV1Graph) Ingress(obj *v1.Ingress) (*Node, error) {
 	n := g.graph.Node(obj.GroupVersionKind(), obj)
 
+	name := obj.GetAnnotations()["kubernetes.io/ingress.class"]
+	if obj.Spec.IngressClassName != nil {
+		name = *obj.Spec.IngressClassName
+	}
+	c, err := g.IngressClassName(name)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		g.graph.Relationship(n, "IngressClass", c)
+	} else if len(name) != 0 {
+		g.graph.Warn(WarningNotFound, n, "IngressClass %s not found", name)
+	}
+
+	if obj.Spec.DefaultBackend != nil {
+		b, err := g.IngressBackend(obj, *obj.Spec.DefaultBackend)
+		if err != nil {
+			return nil, err
+		}
+		r := g.Relationship(b, v1.PolicyTypeIngress, n)
+		appendAttribute(r, "path", "*")
+		appendAttribute(r, "port", ingressBackendPort(*obj.Spec.DefaultBackend))
+	}
+
 	for _, rule := range obj.Spec.Rules {
 		if rule.HTTP != nil {
 			for _, path := range rule.HTTP.Paths {
@@ -86,7 +135,10 @@ func (g *NetworkingV1Graph) Ingress(obj *v1.Ingress) (*Node, error) {
 				if err != nil {
 					return nil, err
 				}
-				g.Relationship(b, v1.PolicyTypeIngress, n)
+				r := g.Relationship(b, v1.PolicyTypeIngress, n)
+				appendAttribute(r, "host", rule.Host)
+				appendAttribute(r, "path", path.Path)
+				appendAttribute(r, "port", ingressBackendPort(path.Backend))
 			}
 		}
 
@@ -97,20 +149,109 @@ func (g *NetworkingV1Graph) Ingress(obj *v1.Ingress) (*Node, error) {
 		g.Relationship(n, v1.PolicyTypeIngress, h)
 	}
 
+	secrets := []string{}
+	for _, tls := range obj.Spec.TLS {
+		if len(tls.SecretName) != 0 {
+			secrets = append(secrets, tls.SecretName)
+		}
+	}
+	if err := g.graph.CertManager().IngressCertificates(n, obj.GetNamespace(), obj.GetAnnotations(), secrets); err != nil {
+		return nil, err
+	}
+
 	return n, nil
 }
 
-// IngressBackend adds a v1.IngressBackend resource to the Graph.
-func (g *NetworkingV1Graph) IngressBackend(obj *v1.Ingress, backend v1.IngressBackend) (*Node, error) {
-	switch {
-	case backend.Service != nil:
-		options := metav1.GetOptions{}
-		service, err := g.graph.clientset.CoreV1().Services(obj.GetNamespace()).Get(context.TODO(), backend.Service.Name, options)
+// IngressClass adds a v1.IngressClass resource and its controller Deployments to the Graph.
+func (g *NetworkingV1Graph) IngressClass(obj *v1.IngressClass) (*Node, error) {
+	if n, ok := g.ingressClasses[obj.GetName()]; ok && n != nil {
+		return n, nil
+	}
+
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "IngressClass"), obj)
+	n.Attribute("controller", obj.Spec.Controller)
+	g.ingressClasses[obj.GetName()] = n
+
+	// the labels of a class are usually the same as of its controller,
+	// otherwise the controller is looked up by its well-known name
+	set := labels.Set{}
+	for _, key := range []string{"app.kubernetes.io/name", "app.kubernetes.io/instance", "app.kubernetes.io/component"} {
+		if value, ok := obj.GetLabels()[key]; ok {
+			set[key] = value
+		}
+	}
+	if len(set) == 0 {
+		name, ok := ingressControllers[obj.Spec.Controller]
+		if !ok {
+			return n, nil
+		}
+		set["app.kubernetes.io/name"] = name
+	}
+
+	options := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(set).String()}
+	deployments, err := g.graph.clientset.AppsV1().Deployments(metav1.NamespaceAll).List(context.TODO(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range deployments.Items {
+		d, err := g.graph.AppsV1().Deployment(&deployments.Items[i])
 		if err != nil {
 			return nil, err
 		}
+		g.graph.Relationship(n, "Deployment", d)
+	}
+
+	return n, nil
+}
+
+// IngressClassName adds the v1.IngressClass with the given name to the Graph.
+// An empty name refers to the default class. It returns nil if the class does not exist.
+func (g *NetworkingV1Graph) IngressClassName(name string) (*Node, error) {
+	if n, ok := g.ingressClasses[name]; ok {
+		return n, nil
+	}
+
+	classes, err := g.graph.clientset.NetworkingV1().IngressClasses().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	g.ingressClasses[name] = nil
+	for i := range classes.Items {
+		class := &classes.Items[i]
+		if class.GetName() == name || (len(name) == 0 && class.GetAnnotations()[v1.AnnotationIsDefaultIngressClass] == "true") {
+			n, err := g.IngressClass(class)
+			if err != nil {
+				return nil, err
+			}
+			if d := g.ingressClasses[name]; d != nil && len(name) == 0 {
+				g.graph.Warn(WarningConflict, n, "multiple default IngressClasses, %s is used instead of %s", n.Name, d.Name)
+			}
+			g.ingressClasses[name] = n
+		}
+	}
+
+	return g.ingressClasses[name], nil
+}
+
+// ingressBackendPort returns the port number or name of a service backend.
+func ingressBackendPort(backend v1.IngressBackend) string {
+	if backend.Service == nil {
+		return ""
+	}
+	if len(backend.Service.Port.Name) != 0 {
+		return backend.Service.Port.Name
+	}
+
+	return strconv.Itoa(int(backend.Service.Port.Number))
+}
 
-		return g.graph.CoreV1().Service(service)
+// IngressBackend adds a v1.IngressBackend resource to the Graph.
+func (g *NetworkingV1Graph) IngressBackend(obj *v1.Ingress, backend v1.IngressBackend) (*Node, error) {
+	switch {
+	case backend.Service != nil:
+		return g.graph.CoreV1().ServiceName(obj.GetNamespace(), backend.Service.Name)
 	case backend.Resource != nil:
 		return g.graph.CoreV1().TypedLocalObjectReference(backend.Resource, obj.GetNamespace())
 	}
@@ -123,7 +264,7 @@ func (g *NetworkingV1Graph) Host(name string) (*Node, error) {
 	n := g.graph.Node(
 		schema.FromAPIVersionAndKind(v1.GroupName, "Host"),
 		&metav1.ObjectMeta{
-			UID:  ToUID(name),
+			UID:  g.graph.ToUID(name),
 			Name: name,
 		},
 	)
@@ -131,7 +272,9 @@ func (g *NetworkingV1Graph) Host(name string) (*Node, error) {
 	return n, nil
 }
 
-// NetworkPolicy adds a v1.NetworkPolicy resource to the Graph.
+// NetworkPolicy adds a v1.NetworkPolicy resource to the Graph. The allowed
+// traffic is added as ALLOWS_INGRESS_FROM and ALLOWS_EGRESS_TO relationships
+// from the workloads of the selected Pods to the workloads of the peers.
 func (g *NetworkingV1Graph) NetworkPolicy(obj *v1.NetworkPolicy) (*Node, error) {
 	n := g.graph.Node(obj.GroupVersionKind(), obj)
 
@@ -146,48 +289,137 @@ func (g *NetworkingV1Graph) NetworkPolicy(obj *v1.NetworkPolicy) (*Node, error)
 		return nil, err
 	}
 
+	targets := []*Node{}
 	for _, pod := range pods.Items {
-		p, err := g.graph.CoreV1().Pod(&pod)
-		if err != nil {
-			return nil, err
-		}
+		p := g.peerPod(&pod)
 		if len(obj.Spec.Ingress) != 0 {
 			g.Relationship(p, v1.PolicyTypeIngress, n)
 		}
 		if len(obj.Spec.Egress) != 0 {
 			g.Relationship(p, v1.PolicyTypeEgress, n)
 		}
+
+		w, err := g.graph.CoreV1().PodWorkload(&pod)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, w)
 	}
 
 	for _, rule := range obj.Spec.Ingress {
 		if len(rule.From) == 0 {
-			rule.From = append(rule.From, v1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{}})
+			g.allows(obj, "ALLOWS_INGRESS_FROM", targets, g.NetworkPolicyPeerAll(obj, v1.PolicyTypeIngress), rule.Ports)
+			continue
 		}
 		for _, peer := range rule.From {
-			_, err := g.NetworkPolicyPeer(obj, v1.PolicyTypeIngress, peer)
+			peers, err := g.NetworkPolicyPeer(obj, v1.PolicyTypeIngress, peer)
 			if err != nil {
 				return nil, err
 			}
+			g.allows(obj, "ALLOWS_INGRESS_FROM", targets, peers, rule.Ports)
 		}
 	}
 
 	for _, rule := range obj.Spec.Egress {
 		if len(rule.To) == 0 {
-			rule.To = append(rule.To, v1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{}})
+			g.allows(obj, "ALLOWS_EGRESS_TO", targets, g.NetworkPolicyPeerAll(obj, v1.PolicyTypeEgress), rule.Ports)
+			continue
 		}
 		for _, peer := range rule.To {
-			_, err := g.NetworkPolicyPeer(obj, v1.PolicyTypeEgress, peer)
+			peers, err := g.NetworkPolicyPeer(obj, v1.PolicyTypeEgress, peer)
 			if err != nil {
 				return nil, err
 			}
+			g.allows(obj, "ALLOWS_EGRESS_TO", targets, peers, rule.Ports)
 		}
 	}
 
 	return n, nil
 }
 
-// NetworkPolicyPeer adds a v1.NetworkPolicyPeer resource to the Graph.
-func (g *NetworkingV1Graph) NetworkPolicyPeer(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) (*Node, error) {
+// allows adds a relationship with the given label from each target to each
+// peer, including the names of the policies and the allowed ports.
+func (g *NetworkingV1Graph) allows(obj *v1.NetworkPolicy, label string, targets []*Node, peers []*Node, ports []v1.NetworkPolicyPort) {
+	allowed := []string{}
+	for _, port := range ports {
+		allowed = append(allowed, networkPolicyPort(port))
+	}
+
+	g.Allows(obj.GetName(), label, targets, peers, allowed)
+}
+
+// Allows adds a relationship with the given label from each target to each
+// peer, including the name of the policy and the allowed ports. No ports
+// allow all ports. It is used by the policies of all network plugins.
+func (g *NetworkingV1Graph) Allows(policy string, label string, targets []*Node, peers []*Node, ports []string) {
+	for _, target := range targets {
+		for _, peer := range peers {
+			if target.UID == peer.UID {
+				continue
+			}
+
+			r := g.graph.LabeledRelationship(target, label, peer)
+			appendAttribute(r, "policy", policy)
+			if len(ports) == 0 {
+				appendAttribute(r, "ports", "*")
+			}
+			for _, port := range ports {
+				appendAttribute(r, "ports", port)
+			}
+		}
+	}
+}
+
+// SelectedPods lists the running Pods which match the pod selector in the
+// namespace. An empty namespace lists the Pods of all namespaces which match
+// the namespace selector, a nil namespace selector matches all namespaces.
+func (g *NetworkingV1Graph) SelectedPods(namespace string, namespaceSelector labels.Selector, podSelector labels.Selector) ([]corev1.Pod, error) {
+	namespaces := []string{namespace}
+	if len(namespace) == 0 && namespaceSelector != nil {
+		options := metav1.ListOptions{LabelSelector: namespaceSelector.String()}
+		list, err := g.graph.clientset.CoreV1().Namespaces().List(context.TODO(), options)
+		if err != nil {
+			return nil, err
+		}
+
+		namespaces = namespaces[:0]
+		for _, ns := range list.Items {
+			namespaces = append(namespaces, ns.GetName())
+		}
+	}
+
+	pods := []corev1.Pod{}
+	for _, ns := range namespaces {
+		options := metav1.ListOptions{LabelSelector: podSelector.String(), FieldSelector: "status.phase=Running"}
+		list, err := g.graph.clientset.CoreV1().Pods(ns).List(context.TODO(), options)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, list.Items...)
+	}
+
+	return pods, nil
+}
+
+// networkPolicyPort returns the protocol and port or port range, e.g. "TCP/8080".
+func networkPolicyPort(port v1.NetworkPolicyPort) string {
+	protocol := "TCP"
+	if port.Protocol != nil {
+		protocol = string(*port.Protocol)
+	}
+	if port.Port == nil {
+		return protocol + "/*"
+	}
+	if port.EndPort != nil {
+		return fmt.Sprintf("%s/%s-%d", protocol, port.Port.String(), *port.EndPort)
+	}
+
+	return protocol + "/" + port.Port.String()
+}
+
+// NetworkPolicyPeer adds a v1.NetworkPolicyPeer resource to the Graph and
+// returns the workloads, namespaces or IP blocks of the peer.
+func (g *NetworkingV1Graph) NetworkPolicyPeer(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) ([]*Node, error) {
 	switch {
 	case peer.NamespaceSelector != nil && peer.PodSelector != nil:
 		return g.NetworkPolicyPeerNamespaceAndPodSelector(obj, policyType, peer)
@@ -202,9 +434,22 @@ func (g *NetworkingV1Graph) NetworkPolicyPeer(obj *v1.NetworkPolicy, policyType
 	return nil, nil
 }
 
+// NetworkPolicyPeerAll adds the peer of a rule without peers to the Graph,
+// which allows all sources or destinations in all namespaces and outside of
+// the cluster. It is the same node as the "all" entity of Cilium and Calico.
+func (g *NetworkingV1Graph) NetworkPolicyPeerAll(obj *v1.NetworkPolicy, policyType v1.PolicyType) []*Node {
+	n := g.graph.Node(obj.GroupVersionKind(), obj)
+
+	all := g.graph.Cilium().Entity("all")
+	g.Relationship(n, policyType, all)
+
+	return []*Node{all}
+}
+
 // NetworkPolicyPeerNamespaceAndPodSelector adds a v1.NetworkPolicyPeer of type NamespaceAndPodSelector to the Graph.
-func (g *NetworkingV1Graph) NetworkPolicyPeerNamespaceAndPodSelector(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) (*Node, error) {
+func (g *NetworkingV1Graph) NetworkPolicyPeerNamespaceAndPodSelector(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) ([]*Node, error) {
 	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	peers := []*Node{}
 
 	selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
 	if err != nil {
@@ -230,20 +475,24 @@ func (g *NetworkingV1Graph) NetworkPolicyPeerNamespaceAndPodSelector(obj *v1.Net
 		}
 
 		for _, pod := range pods.Items {
-			p, err := g.graph.CoreV1().Pod(&pod)
+			p := g.peerPod(&pod)
+			g.Relationship(n, policyType, p)
+
+			w, err := g.graph.CoreV1().PodWorkload(&pod)
 			if err != nil {
 				return nil, err
 			}
-			g.Relationship(n, policyType, p)
+			peers = append(peers, w)
 		}
 	}
 
-	return n, nil
+	return peers, nil
 }
 
 // NetworkPolicyPeerNamespaceSelector adds a v1.NetworkPolicyPeer of type NamespaceSelector to the Graph.
-func (g *NetworkingV1Graph) NetworkPolicyPeerNamespaceSelector(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) (*Node, error) {
+func (g *NetworkingV1Graph) NetworkPolicyPeerNamespaceSelector(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) ([]*Node, error) {
 	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	peers := []*Node{}
 
 	selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
 	if err != nil {
@@ -262,14 +511,16 @@ func (g *NetworkingV1Graph) NetworkPolicyPeerNamespaceSelector(obj *v1.NetworkPo
 			return nil, err
 		}
 		g.Relationship(n, policyType, ns)
+		peers = append(peers, ns)
 	}
 
-	return n, nil
+	return peers, nil
 }
 
 // NetworkPolicyPeerPodSelector adds a v1.NetworkPolicyPeer of type PodSelector to the Graph.
-func (g *NetworkingV1Graph) NetworkPolicyPeerPodSelector(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) (*Node, error) {
+func (g *NetworkingV1Graph) NetworkPolicyPeerPodSelector(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) ([]*Node, error) {
 	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	peers := []*Node{}
 
 	selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
 	if err != nil {
@@ -283,18 +534,32 @@ func (g *NetworkingV1Graph) NetworkPolicyPeerPodSelector(obj *v1.NetworkPolicy,
 	}
 
 	for _, pod := range pods.Items {
-		p, err := g.graph.CoreV1().Pod(&pod)
+		p := g.peerPod(&pod)
+		g.Relationship(n, policyType, p)
+
+		w, err := g.graph.CoreV1().PodWorkload(&pod)
 		if err != nil {
 			return nil, err
 		}
-		g.Relationship(n, policyType, p)
+		peers = append(peers, w)
 	}
 
-	return n, nil
+	return peers, nil
+}
+
+// peerPod returns the node of a Pod which is selected by a NetworkPolicy or
+// one of its peers. The Pod is not graphed with its own relationships, it is only added as node if it is
+// not part of the Graph yet.
+func (g *NetworkingV1Graph) peerPod(pod *corev1.Pod) *Node {
+	if n, ok := g.graph.Nodes[pod.GetUID()]; ok {
+		return n
+	}
+
+	return g.graph.Node(schema.FromAPIVersionAndKind(corev1.GroupName, "Pod"), pod)
 }
 
 // NetworkPolicyPeerIPBlock adds a v1.NetworkPolicyPeer of type IPBlock to the Graph.
-func (g *NetworkingV1Graph) NetworkPolicyPeerIPBlock(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) (*Node, error) {
+func (g *NetworkingV1Graph) NetworkPolicyPeerIPBlock(obj *v1.NetworkPolicy, policyType v1.PolicyType, peer v1.NetworkPolicyPeer) ([]*Node, error) {
 	n := g.graph.Node(obj.GroupVersionKind(), obj)
 
 	i, err := g.IPBlock(peer.IPBlock.CIDR)
@@ -303,7 +568,7 @@ func (g *NetworkingV1Graph) NetworkPolicyPeerIPBlock(obj *v1.NetworkPolicy, poli
 	}
 	g.Relationship(n, policyType, i)
 
-	return n, nil
+	return []*Node{i}, nil
 }
 
 // IPBlock adds a v1.IPBlock resource to the Graph.
@@ -311,7 +576,7 @@ func (g *NetworkingV1Graph) IPBlock(cidr string) (*Node, error) {
 	n := g.graph.Node(
 		schema.FromAPIVersionAndKind(v1.GroupName, "IPBlock"),
 		&metav1.ObjectMeta{
-			UID:  ToUID(cidr),
+			UID:  g.graph.ToUID(cidr),
 			Name: cidr,
 		},
 	)
@@ -0,0 +1,57 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestIngressBackendNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+	}))
+	defer server.Close()
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewEmptyGraph(clientset, nil)
+
+	ingress := &v1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	backend := v1.IngressBackend{Service: &v1.IngressServiceBackend{Name: "missing"}}
+
+	n, err := g.NetworkingV1().IngressBackend(ingress, backend)
+	if err != nil {
+		t.Fatalf("IngressBackend() returned error: %v", err)
+	}
+	if n.Kind != "Service" || n.Namespace != "default" || n.Name != "missing" {
+		t.Errorf("IngressBackend() = %s %s/%s, want Service default/missing", n.Kind, n.Namespace, n.Name)
+	}
+
+	warnings := g.Warnings()
+	if len(warnings) != 1 || warnings[0].Reason != WarningNotFound {
+		t.Errorf("Warnings() = %v, want one %s warning", warnings, WarningNotFound)
+	}
+}
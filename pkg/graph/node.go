@@ -0,0 +1,90 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+
+	v1 "k8s.io/api/node/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// NodeV1Graph is used to graph all node resources.
+type NodeV1Graph struct {
+	graph *Graph
+
+	// runtimeClasses contains the nodes by name, because they are
+	// referenced by many Pods. A nil node was not found.
+	runtimeClasses map[string]*Node
+}
+
+// NewNodeV1Graph creates a new NodeV1Graph.
+func NewNodeV1Graph(g *Graph) *NodeV1Graph {
+	return &NodeV1Graph{
+		graph:          g,
+		runtimeClasses: make(map[string]*Node),
+	}
+}
+
+// NodeV1 retrieves the NodeV1Graph.
+func (g *Graph) NodeV1() *NodeV1Graph {
+	return g.nodeV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *NodeV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "RuntimeClass":
+		obj := &v1.RuntimeClass{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.RuntimeClass(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// RuntimeClass adds a v1.RuntimeClass resource to the Graph.
+func (g *NodeV1Graph) RuntimeClass(obj *v1.RuntimeClass) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "RuntimeClass"), obj)
+	n.Attribute("handler", obj.Handler)
+	g.runtimeClasses[obj.GetName()] = n
+
+	return n, nil
+}
+
+// RuntimeClassName adds the v1.RuntimeClass with the given name to the Graph.
+// It returns nil if the RuntimeClass does not exist.
+func (g *NodeV1Graph) RuntimeClassName(name string) (*Node, error) {
+	if n, ok := g.runtimeClasses[name]; ok {
+		return n, nil
+	}
+
+	options := metav1.GetOptions{}
+	obj, err := g.graph.clientset.NodeV1().RuntimeClasses().Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		g.runtimeClasses[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return g.RuntimeClass(obj)
+}
@@ -0,0 +1,366 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"path"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// olmV1alpha1 is the group version of the Operator Lifecycle Manager resources which are read from the cluster.
+var olmV1alpha1 = schema.GroupVersion{Group: "operators.coreos.com", Version: "v1alpha1"}
+
+// OLMGraph is used to graph all operators.coreos.com resources.
+type OLMGraph struct {
+	graph *Graph
+
+	// catalogSources contains the nodes by namespace and name, because they
+	// are referenced by many Subscriptions.
+	catalogSources map[string]*Node
+	// clusterServiceVersions contains the nodes by namespace and name,
+	// because they are referenced by Subscriptions and InstallPlans. A nil
+	// node was not found.
+	clusterServiceVersions map[string]*Node
+}
+
+// NewOLMGraph creates a new OLMGraph.
+func NewOLMGraph(g *Graph) *OLMGraph {
+	return &OLMGraph{
+		graph:                  g,
+		catalogSources:         make(map[string]*Node),
+		clusterServiceVersions: make(map[string]*Node),
+	}
+}
+
+// OLM retrieves the OLMGraph.
+func (g *Graph) OLM() *OLMGraph {
+	return g.olm
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *OLMGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Subscription":
+		return g.Subscription(unstr)
+	case "InstallPlan":
+		return g.InstallPlan(unstr)
+	case "ClusterServiceVersion":
+		return g.ClusterServiceVersion(unstr)
+	case "CatalogSource":
+		return g.CatalogSource(unstr)
+	case "OperatorGroup":
+		return g.OperatorGroup(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Subscription adds a Subscription resource, its CatalogSource, its current
+// InstallPlan and its installed ClusterServiceVersion to the Graph.
+func (g *OLMGraph) Subscription(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	for key, field := range map[string]string{"package": "name", "channel": "channel", "installPlanApproval": "installPlanApproval"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "spec", field); ok {
+			n.Attribute(key, value)
+		}
+	}
+	for _, field := range []string{"state", "currentCSV", "installedCSV"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "status", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+
+	if source, ok, _ := unstructured.NestedString(unstr.Object, "spec", "source"); ok {
+		namespace, _, _ := unstructured.NestedString(unstr.Object, "spec", "sourceNamespace")
+		if len(namespace) == 0 {
+			namespace = unstr.GetNamespace()
+		}
+		c, err := g.CatalogSourceName(namespace, source)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "CatalogSource", c)
+	}
+
+	if name, ok, _ := unstructured.NestedString(unstr.Object, "status", "installPlanRef", "name"); ok {
+		namespace, _, _ := unstructured.NestedString(unstr.Object, "status", "installPlanRef", "namespace")
+		if len(namespace) == 0 {
+			namespace = unstr.GetNamespace()
+		}
+		obj, err := g.graph.CustomResource(olmV1alpha1.WithResource("installplans"), namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		// completed InstallPlans may be garbage collected
+		if obj != nil {
+			p, err := g.InstallPlan(obj)
+			if err != nil {
+				return nil, err
+			}
+			g.graph.Relationship(n, "InstallPlan", p)
+		}
+	}
+
+	if name, ok, _ := unstructured.NestedString(unstr.Object, "status", "installedCSV"); ok {
+		c, err := g.ClusterServiceVersionName(unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			g.graph.Warn(WarningNotFound, n, "ClusterServiceVersion %s not found", name)
+		} else {
+			g.graph.Relationship(n, "ClusterServiceVersion", c)
+		}
+	}
+
+	return n, nil
+}
+
+// InstallPlan adds an InstallPlan resource and the ClusterServiceVersions
+// which are installed by it to the Graph.
+func (g *OLMGraph) InstallPlan(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	if approval, ok, _ := unstructured.NestedString(unstr.Object, "spec", "approval"); ok {
+		n.Attribute("approval", approval)
+	}
+	if approved, ok, _ := unstructured.NestedBool(unstr.Object, "spec", "approved"); ok {
+		n.Attribute("approved", strconv.FormatBool(approved))
+	}
+	if phase, ok, _ := unstructured.NestedString(unstr.Object, "status", "phase"); ok {
+		n.Attribute("phase", phase)
+		switch phase {
+		case "Complete":
+			n.Attribute("status", JobComplete)
+		case "Failed":
+			n.Attribute("status", JobFailed)
+		default:
+			n.Attribute("status", JobRunning)
+		}
+	}
+
+	names, _, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "clusterServiceVersionNames")
+	for _, name := range names {
+		c, err := g.ClusterServiceVersionName(unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		// a pending InstallPlan references ClusterServiceVersions which are not created yet
+		if c != nil {
+			g.graph.Relationship(n, "ClusterServiceVersion", c)
+		}
+	}
+
+	return n, nil
+}
+
+// ClusterServiceVersion adds a ClusterServiceVersion resource, the
+// Deployments of its operator and the CustomResourceDefinitions it owns to
+// the Graph. The copies of a ClusterServiceVersion in the target namespaces
+// of its OperatorGroup are added without their Deployments.
+func (g *OLMGraph) ClusterServiceVersion(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.clusterServiceVersions[key]; ok && n != nil {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.clusterServiceVersions[key] = n
+
+	if version, ok, _ := unstructured.NestedString(unstr.Object, "spec", "version"); ok {
+		n.Attribute("version", version)
+	}
+	if phase, ok, _ := unstructured.NestedString(unstr.Object, "status", "phase"); ok {
+		n.Attribute("phase", phase)
+		switch phase {
+		case "Succeeded":
+			n.Attribute("status", ConditionReady)
+		case "Failed":
+			n.Attribute("status", ConditionNotReady)
+		}
+	}
+
+	if reason, _, _ := unstructured.NestedString(unstr.Object, "status", "reason"); reason == "Copied" {
+		n.Attribute("copied", "true")
+		return n, nil
+	}
+
+	deployments, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "install", "spec", "deployments")
+	for _, deployment := range deployments {
+		d, ok := deployment.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(d, "name")
+		if len(name) == 0 {
+			continue
+		}
+
+		options := metav1.GetOptions{}
+		obj, err := g.graph.clientset.AppsV1().Deployments(unstr.GetNamespace()).Get(context.TODO(), name, options)
+		if apierrors.IsNotFound(err) {
+			g.graph.Warn(WarningNotFound, n, "Deployment %s not found", name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		w, err := g.graph.AppsV1().Deployment(obj)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Deployment", w)
+	}
+
+	owned, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "customresourcedefinitions", "owned")
+	for _, crd := range owned {
+		c, ok := crd.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(c, "name")
+		if len(name) == 0 {
+			continue
+		}
+
+		gvr := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+		obj, err := g.graph.CustomResource(gvr, "", name)
+		if err != nil {
+			return nil, err
+		}
+		// the definitions of a failed installation may be missing
+		if obj == nil {
+			continue
+		}
+		d, err := g.graph.ApiextensionsV1().CustomResourceDefinition(obj)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "CustomResourceDefinition", d)
+	}
+
+	return n, nil
+}
+
+// ClusterServiceVersionName adds the ClusterServiceVersion with the given
+// name to the Graph. It returns nil if the ClusterServiceVersion does not exist.
+func (g *OLMGraph) ClusterServiceVersionName(namespace string, name string) (*Node, error) {
+	if n, ok := g.clusterServiceVersions[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(olmV1alpha1.WithResource("clusterserviceversions"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr == nil {
+		g.clusterServiceVersions[path.Join(namespace, name)] = nil
+		return nil, nil
+	}
+
+	return g.ClusterServiceVersion(unstr)
+}
+
+// CatalogSource adds a CatalogSource resource and the image of its index to the Graph.
+func (g *OLMGraph) CatalogSource(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.catalogSources[path.Join(unstr.GetNamespace(), unstr.GetName())] = n
+
+	for _, field := range []string{"sourceType", "displayName", "publisher"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "spec", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+	if state, ok, _ := unstructured.NestedString(unstr.Object, "status", "connectionState", "lastObservedState"); ok {
+		n.Attribute("state", state)
+		if state == "READY" {
+			n.Attribute("status", ConditionReady)
+		} else {
+			n.Attribute("status", ConditionNotReady)
+		}
+	}
+
+	if image, ok, _ := unstructured.NestedString(unstr.Object, "spec", "image"); ok && len(image) != 0 {
+		if _, err := g.graph.CoreV1().image(n, "Image", image); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// CatalogSourceName adds the CatalogSource with the given name to the Graph.
+// A missing CatalogSource is added as node without UID from the cluster.
+func (g *OLMGraph) CatalogSourceName(namespace string, name string) (*Node, error) {
+	if n, ok := g.catalogSources[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(olmV1alpha1.WithResource("catalogsources"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.CatalogSource(unstr)
+	}
+
+	n := g.graph.Node(
+		olmV1alpha1.WithKind("CatalogSource"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "CatalogSource", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.catalogSources[path.Join(namespace, name)] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// OperatorGroup adds an OperatorGroup resource and the namespaces which are
+// watched by the operators of its namespace to the Graph. An OperatorGroup
+// for all namespaces has the "allNamespaces" attribute instead.
+func (g *OLMGraph) OperatorGroup(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	if account, ok, _ := unstructured.NestedString(unstr.Object, "spec", "serviceAccountName"); ok {
+		n.Attribute("serviceAccountName", account)
+	}
+	if upgrade, ok, _ := unstructured.NestedString(unstr.Object, "spec", "upgradeStrategy", "name"); ok {
+		n.Attribute("upgradeStrategy", upgrade)
+	}
+
+	namespaces, _, _ := unstructured.NestedStringSlice(unstr.Object, "status", "namespaces")
+	for _, name := range namespaces {
+		if len(name) == 0 {
+			n.Attribute("allNamespaces", "true")
+			continue
+		}
+		ns, err := g.graph.CoreV1().Namespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "TargetNamespace", ns)
+	}
+
+	return n, nil
+}
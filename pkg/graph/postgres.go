@@ -0,0 +1,352 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// cnpgV1 is the group version of the CloudNativePG resources which are read from the cluster.
+var cnpgV1 = schema.GroupVersion{Group: "postgresql.cnpg.io", Version: "v1"}
+
+const (
+	// CNPGClusterLabel is the label with the name of the CloudNativePG Cluster of a resource.
+	CNPGClusterLabel = "cnpg.io/cluster"
+	// CNPGInstanceRoleLabel is the label with the role of a CloudNativePG instance.
+	CNPGInstanceRoleLabel = "cnpg.io/instanceRole"
+	// SpiloClusterLabel is the label with the name of the Zalando postgresql cluster of a resource.
+	SpiloClusterLabel = "cluster-name"
+	// SpiloRoleLabel is the label with the role of a Zalando postgresql instance.
+	SpiloRoleLabel = "spilo-role"
+)
+
+// PostgresGraph is used to graph all PostgreSQL operator resources.
+type PostgresGraph struct {
+	graph *Graph
+
+	// clusters contains the CloudNativePG Clusters by namespace and name,
+	// because they are referenced by backups and poolers.
+	clusters map[string]*Node
+}
+
+// NewPostgresGraph creates a new PostgresGraph.
+func NewPostgresGraph(g *Graph) *PostgresGraph {
+	return &PostgresGraph{
+		graph:    g,
+		clusters: make(map[string]*Node),
+	}
+}
+
+// Postgres retrieves the PostgresGraph.
+func (g *Graph) Postgres() *PostgresGraph {
+	return g.postgres
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *PostgresGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GroupVersionKind().GroupKind() {
+	case cnpgV1.WithKind("Cluster").GroupKind():
+		return g.Cluster(unstr)
+	case cnpgV1.WithKind("Backup").GroupKind(), cnpgV1.WithKind("ScheduledBackup").GroupKind():
+		return g.Backup(unstr)
+	case cnpgV1.WithKind("Pooler").GroupKind():
+		return g.Pooler(unstr)
+	case schema.GroupKind{Group: "acid.zalan.do", Kind: "postgresql"}:
+		return g.Postgresql(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Cluster adds a CloudNativePG Cluster resource, its instance Pods, their
+// PersistentVolumeClaims, its Services and the Secrets which are generated by
+// the operator to the Graph.
+func (g *PostgresGraph) Cluster(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.clusters[key]; ok && g.graph.objects[n.UID] {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.clusters[key] = n
+	n.Attribute("status", readyStatus(unstr))
+	if phase, ok, _ := unstructured.NestedString(unstr.Object, "status", "phase"); ok {
+		n.Attribute("phase", phase)
+	}
+	if instances, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "instances"); ok {
+		n.Attribute("instances", strconv.FormatInt(instances, 10))
+	}
+	if ready, ok, _ := unstructured.NestedInt64(unstr.Object, "status", "readyInstances"); ok {
+		n.Attribute("readyInstances", strconv.FormatInt(ready, 10))
+	}
+	if primary, ok, _ := unstructured.NestedString(unstr.Object, "status", "currentPrimary"); ok {
+		n.Attribute("currentPrimary", primary)
+	}
+	if image, ok, _ := unstructured.NestedString(unstr.Object, "status", "image"); ok && len(image) != 0 {
+		if _, err := g.graph.CoreV1().image(n, "Image", image); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := g.instances(n, unstr.GetNamespace(), CNPGClusterLabel+"="+unstr.GetName(), CNPGInstanceRoleLabel); err != nil {
+		return nil, err
+	}
+
+	for _, suffix := range []string{"rw", "ro", "r"} {
+		s, err := g.graph.CoreV1().ServiceName(unstr.GetNamespace(), unstr.GetName()+"-"+suffix)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Service", s)
+	}
+
+	secrets := map[string]string{"app": unstr.GetName() + "-app"}
+	if secret, ok, _ := unstructured.NestedString(unstr.Object, "spec", "bootstrap", "initdb", "secret", "name"); ok {
+		secrets["app"] = secret
+	}
+	if superuser, _, _ := unstructured.NestedBool(unstr.Object, "spec", "enableSuperuserAccess"); superuser {
+		secrets["superuser"] = unstr.GetName() + "-superuser"
+		if secret, ok, _ := unstructured.NestedString(unstr.Object, "spec", "superuserSecret", "name"); ok {
+			secrets["superuser"] = secret
+		}
+	}
+	for key, field := range map[string]string{"ca": "serverCASecret", "server": "serverTLSSecret", "replication": "replicationTLSSecret", "client-ca": "clientCASecret"} {
+		if secret, ok, _ := unstructured.NestedString(unstr.Object, "status", "certificates", field); ok && len(secret) != 0 {
+			secrets[key] = secret
+		}
+	}
+	if secret, ok, _ := unstructured.NestedString(unstr.Object, "spec", "backup", "barmanObjectStore", "s3Credentials", "accessKeyId", "name"); ok {
+		secrets["backup"] = secret
+	}
+	g.secrets(n, unstr.GetNamespace(), secrets)
+
+	if destination, ok, _ := unstructured.NestedString(unstr.Object, "spec", "backup", "barmanObjectStore", "destinationPath"); ok {
+		if _, err := g.graph.CoreV1().URL(n, "ObjectStore", destination); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// ClusterName adds the CloudNativePG Cluster with the given name to the Graph.
+// A missing cluster is added as node without UID from the cluster.
+func (g *PostgresGraph) ClusterName(namespace string, name string) (*Node, error) {
+	if n, ok := g.clusters[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(cnpgV1.WithResource("clusters"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Cluster(unstr)
+	}
+
+	n := g.graph.Node(
+		cnpgV1.WithKind("Cluster"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "Cluster", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.clusters[path.Join(namespace, name)] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// Backup adds a CloudNativePG Backup or ScheduledBackup resource and the
+// relationship to its Cluster to the Graph.
+func (g *PostgresGraph) Backup(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	for _, field := range []string{"schedule", "method"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "spec", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+	if phase, ok, _ := unstructured.NestedString(unstr.Object, "status", "phase"); ok {
+		n.Attribute("phase", phase)
+		switch phase {
+		case "completed":
+			n.Attribute("status", JobComplete)
+		case "failed":
+			n.Attribute("status", JobFailed)
+		default:
+			n.Attribute("status", JobRunning)
+		}
+	}
+
+	if err := g.cluster(n, unstr); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Pooler adds a CloudNativePG Pooler resource, the relationship to its
+// Cluster and its PgBouncer Deployment and Service to the Graph.
+func (g *PostgresGraph) Pooler(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	if poolerType, ok, _ := unstructured.NestedString(unstr.Object, "spec", "type"); ok {
+		n.Attribute("type", poolerType)
+	}
+	if instances, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "instances"); ok {
+		n.Attribute("instances", strconv.FormatInt(instances, 10))
+	}
+
+	if err := g.cluster(n, unstr); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Postgresql adds a postgresql resource of the Zalando postgres operator,
+// its StatefulSet, its Pods, their PersistentVolumeClaims, its Services and
+// the credential Secrets of its users to the Graph.
+func (g *PostgresGraph) Postgresql(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	if status, ok, _ := unstructured.NestedString(unstr.Object, "status", "PostgresClusterStatus"); ok {
+		n.Attribute("phase", status)
+		switch {
+		case status == "Running":
+			n.Attribute("status", ConditionReady)
+		case strings.HasSuffix(status, "Failed"):
+			n.Attribute("status", ConditionNotReady)
+		default:
+			n.Attribute("status", ConditionUnknown)
+		}
+	}
+	if team, ok, _ := unstructured.NestedString(unstr.Object, "spec", "teamId"); ok {
+		n.Attribute("teamId", team)
+	}
+	if instances, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "numberOfInstances"); ok {
+		n.Attribute("instances", strconv.FormatInt(instances, 10))
+	}
+	if version, ok, _ := unstructured.NestedString(unstr.Object, "spec", "postgresql", "version"); ok {
+		n.Attribute("version", version)
+	}
+
+	if err := g.instances(n, unstr.GetNamespace(), SpiloClusterLabel+"="+unstr.GetName(), SpiloRoleLabel); err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{unstr.GetName(), unstr.GetName() + "-repl"} {
+		s, err := g.graph.CoreV1().ServiceName(unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Service", s)
+	}
+
+	// the credentials of the users are named "<username>.<cluster>.credentials.postgresql.acid.zalan.do"
+	users, _, _ := unstructured.NestedMap(unstr.Object, "spec", "users")
+	secrets := map[string]string{}
+	for _, user := range append([]string{"postgres", "standby"}, sortedKeys(users)...) {
+		secrets[user] = strings.ReplaceAll(user, "_", "-") + "." + unstr.GetName() + ".credentials.postgresql.acid.zalan.do"
+	}
+	g.secrets(n, unstr.GetNamespace(), secrets)
+
+	return n, nil
+}
+
+// cluster adds a relationship from the CloudNativePG Cluster referenced by
+// the "spec.cluster.name" field of a Backup, ScheduledBackup or Pooler to n.
+func (g *PostgresGraph) cluster(n *Node, unstr *unstructured.Unstructured) error {
+	name, ok, _ := unstructured.NestedString(unstr.Object, "spec", "cluster", "name")
+	if !ok {
+		return nil
+	}
+
+	c, err := g.ClusterName(unstr.GetNamespace(), name)
+	if err != nil {
+		return err
+	}
+	g.graph.Relationship(c, unstr.GetKind(), n)
+
+	return nil
+}
+
+// instances adds the StatefulSets, Pods and PersistentVolumeClaims with the
+// label selector of a PostgreSQL cluster and the relationships from n to
+// them to the Graph. Pods of a StatefulSet are added by their StatefulSet,
+// the relationships to the other Pods have the role of the instance as attribute.
+func (g *PostgresGraph) instances(n *Node, namespace string, selector string, roleLabel string) error {
+	options := metav1.ListOptions{LabelSelector: selector}
+
+	statefulSets, err := g.graph.clientset.AppsV1().StatefulSets(namespace).List(context.TODO(), options)
+	if err != nil {
+		return err
+	}
+	for i := range statefulSets.Items {
+		s, err := g.graph.AppsV1().StatefulSet(&statefulSets.Items[i])
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(n, "StatefulSet", s)
+	}
+
+	pods, err := g.graph.clientset.CoreV1().Pods(namespace).List(context.TODO(), options)
+	if err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		p, err := g.graph.CoreV1().Pod(&pods.Items[i])
+		if err != nil {
+			return err
+		}
+		if owner := metav1.GetControllerOf(&pods.Items[i]); owner != nil && owner.Kind == "StatefulSet" {
+			continue
+		}
+		r := g.graph.Relationship(n, "Pod", p)
+		if role, ok := pods.Items[i].GetLabels()[roleLabel]; ok {
+			r.Attribute("role", role)
+		}
+	}
+
+	claims, err := g.graph.clientset.CoreV1().PersistentVolumeClaims(namespace).List(context.TODO(), options)
+	if err != nil {
+		return err
+	}
+	for i := range claims.Items {
+		c, err := g.graph.CoreV1().PersistentVolumeClaim(&claims.Items[i])
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(n, "PersistentVolumeClaim", c)
+	}
+
+	return nil
+}
+
+// secrets adds the relationships from n to the Secrets of a PostgreSQL
+// cluster, the purpose of each Secret is added as attribute. The Secrets are
+// not read, because they contain the credentials of the database.
+func (g *PostgresGraph) secrets(n *Node, namespace string, secrets map[string]string) {
+	for _, purpose := range sortedKeys(secrets) {
+		s := g.graph.CoreV1().SecretName(namespace, secrets[purpose])
+		appendAttribute(g.graph.Relationship(n, "Secret", s), "purpose", purpose)
+	}
+}
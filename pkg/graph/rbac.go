@@ -0,0 +1,198 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strconv"
+
+	v1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RbacV1Graph is used to graph all rbac resources.
+type RbacV1Graph struct {
+	graph *Graph
+}
+
+// NewRbacV1Graph creates a new RbacV1Graph.
+func NewRbacV1Graph(g *Graph) *RbacV1Graph {
+	return &RbacV1Graph{
+		graph: g,
+	}
+}
+
+// RbacV1 retrieves the RbacV1Graph.
+func (g *Graph) RbacV1() *RbacV1Graph {
+	return g.rbacV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *RbacV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Role":
+		obj := &v1.Role{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.Role(obj)
+	case "ClusterRole":
+		obj := &v1.ClusterRole{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ClusterRole(obj)
+	case "RoleBinding":
+		obj := &v1.RoleBinding{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.RoleBinding(obj)
+	case "ClusterRoleBinding":
+		obj := &v1.ClusterRoleBinding{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.ClusterRoleBinding(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Role adds a v1.Role resource to the Graph.
+func (g *RbacV1Graph) Role(obj *v1.Role) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "Role"), obj)
+	n.Attribute("rules", strconv.Itoa(len(obj.Rules)))
+
+	return n, nil
+}
+
+// ClusterRole adds a v1.ClusterRole resource to the Graph.
+func (g *RbacV1Graph) ClusterRole(obj *v1.ClusterRole) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "ClusterRole"), obj)
+	n.Attribute("rules", strconv.Itoa(len(obj.Rules)))
+
+	return n, nil
+}
+
+// RoleBinding adds a v1.RoleBinding resource, its Role and its subjects to the Graph.
+func (g *RbacV1Graph) RoleBinding(obj *v1.RoleBinding) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "RoleBinding"), obj)
+
+	r, err := g.RoleRef(obj.RoleRef, obj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(n, r.Kind, r)
+
+	if err := g.subjects(n, obj.Subjects, obj.GetNamespace()); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// ClusterRoleBinding adds a v1.ClusterRoleBinding resource, its ClusterRole and its subjects to the Graph.
+func (g *RbacV1Graph) ClusterRoleBinding(obj *v1.ClusterRoleBinding) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "ClusterRoleBinding"), obj)
+
+	r, err := g.RoleRef(obj.RoleRef, "")
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Relationship(n, r.Kind, r)
+
+	if err := g.subjects(n, obj.Subjects, ""); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// RoleRef adds the Role or ClusterRole referenced by a binding to the Graph.
+// A missing role is added as node without UID from the cluster.
+func (g *RbacV1Graph) RoleRef(ref v1.RoleRef, namespace string) (*Node, error) {
+	options := metav1.GetOptions{}
+
+	switch ref.Kind {
+	case "Role":
+		role, err := g.graph.clientset.RbacV1().Roles(namespace).Get(context.TODO(), ref.Name, options)
+		if err == nil {
+			return g.Role(role)
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	case "ClusterRole":
+		namespace = ""
+		role, err := g.graph.clientset.RbacV1().ClusterRoles().Get(context.TODO(), ref.Name, options)
+		if err == nil {
+			return g.ClusterRole(role)
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), ref.Kind),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, ref.Kind, ref.Name),
+			Name:      ref.Name,
+			Namespace: namespace,
+		},
+	)
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// Subject adds a v1.Subject to the Graph. ServiceAccounts are resolved from
+// the cluster, Users and Groups are external and added as virtual nodes.
+func (g *RbacV1Graph) Subject(subject v1.Subject, namespace string) (*Node, error) {
+	if subject.Kind == v1.ServiceAccountKind {
+		if len(subject.Namespace) != 0 {
+			namespace = subject.Namespace
+		}
+
+		return g.graph.CoreV1().ServiceAccountName(namespace, subject.Name)
+	}
+
+	n := g.graph.Node(
+		schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), subject.Kind),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID(subject.Kind, subject.Name),
+			Name: subject.Name,
+		},
+	)
+
+	return n, nil
+}
+
+// subjects adds the subjects of a binding and the relationships to them to the Graph.
+func (g *RbacV1Graph) subjects(n *Node, subjects []v1.Subject, namespace string) error {
+	for _, subject := range subjects {
+		s, err := g.Subject(subject, namespace)
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(n, subject.Kind, s)
+	}
+
+	return nil
+}
@@ -0,0 +1,327 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// ObjectRefKindExtension is the OpenAPI vendor extension of a field with
+	// the kind of the object it refers to.
+	ObjectRefKindExtension = "x-kubernetes-object-ref-kind"
+	// ObjectRefAPIVersionExtension is the OpenAPI vendor extension of a field
+	// with the API version of the object it refers to.
+	ObjectRefAPIVersionExtension = "x-kubernetes-object-ref-api-version"
+)
+
+// referenceKinds contains the core kinds which are derived from the name of a
+// reference field, e.g. "tlsSecretRef" or "credentialsSecretName" refer to a
+// Secret. The more specific suffixes come first.
+var referenceKinds = []struct {
+	suffix string
+	kind   string
+}{
+	{"serviceaccount", "ServiceAccount"},
+	{"configmap", "ConfigMap"},
+	{"secret", "Secret"},
+	{"service", "Service"},
+	{"claim", "PersistentVolumeClaim"},
+}
+
+// schemaReference is a field of a custom resource which refers to another
+// object, found in the OpenAPI schema of its CustomResourceDefinition.
+type schemaReference struct {
+	// path contains the field names, "*" stands for the items of an array.
+	path []string
+	// apiVersion and kind of the referenced object, which may be
+	// overridden by the fields of an object reference.
+	apiVersion string
+	kind       string
+	// object is true for a reference with a name, kind and namespace field
+	// and false for a string with the name of the referenced object.
+	object bool
+}
+
+// pendingReference is a reference of a custom resource which is resolved
+// after all nodes are added to the Graph.
+type pendingReference struct {
+	from       *Node
+	field      string
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+}
+
+// References reads the OpenAPI schema of the CustomResourceDefinition of a
+// custom resource without built-in support and records the values of the
+// fields which refer to other objects. A field refers to another object if
+// it has the ObjectRefKindExtension, or if it is named like "secretRef",
+// "configMapName" or "serviceAccountName". The relationships are added by
+// ResolveReferences.
+func (g *Graph) References(n *Node, unstr *unstructured.Unstructured) error {
+	references, err := g.schemaReferences(unstr.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	for _, reference := range references {
+		for _, value := range fieldValues(unstr.Object, reference.path) {
+			pending := pendingReference{
+				from:       n,
+				field:      strings.Join(reference.path, "."),
+				apiVersion: reference.apiVersion,
+				kind:       reference.kind,
+				namespace:  unstr.GetNamespace(),
+			}
+
+			switch v := value.(type) {
+			case string:
+				pending.name = v
+			case map[string]interface{}:
+				if !reference.object {
+					continue
+				}
+				for field, target := range map[string]*string{"name": &pending.name, "namespace": &pending.namespace, "kind": &pending.kind, "apiVersion": &pending.apiVersion} {
+					if s, ok := v[field].(string); ok && len(s) != 0 {
+						*target = s
+					}
+				}
+			}
+			if len(pending.name) == 0 || len(pending.kind) == 0 {
+				continue
+			}
+
+			g.references = append(g.references, pending)
+		}
+	}
+
+	return nil
+}
+
+// ResolveReferences adds a relationship from each custom resource to the
+// objects referenced by its fields. The referenced objects are looked up in
+// the Graph first, missing objects are added as node without UID from the
+// cluster if their API version is known.
+func (g *Graph) ResolveReferences() {
+	nodes := make(map[string]*Node)
+	for _, node := range g.Nodes {
+		nodes[path.Join(node.GroupVersionKind().Group, node.Kind, node.GetNamespace(), node.GetName())] = node
+		nodes[path.Join("*", node.Kind, node.GetNamespace(), node.GetName())] = node
+	}
+
+	for _, reference := range g.references {
+		gv, err := schema.ParseGroupVersion(reference.apiVersion)
+		if err != nil {
+			continue
+		}
+		group := gv.Group
+		if len(reference.apiVersion) == 0 {
+			group = "*"
+		}
+
+		n, ok := nodes[path.Join(group, reference.kind, reference.namespace, reference.name)]
+		if !ok {
+			n = g.referenceNode(reference)
+		}
+		if n == nil {
+			continue
+		}
+
+		r := g.Relationship(reference.from, n.Kind, n)
+		fields := []string{}
+		if len(r.Attr["fields"]) != 0 {
+			fields = strings.Split(r.Attr["fields"], ",")
+		}
+		if !slices.Contains(fields, reference.field) {
+			r.Attribute("fields", strings.Join(append(fields, reference.field), ","))
+		}
+	}
+	g.references = nil
+}
+
+// referenceNode adds the missing object of a reference as node without UID
+// from the cluster to the Graph. It returns nil if the API version of the
+// object is unknown.
+func (g *Graph) referenceNode(reference pendingReference) *Node {
+	apiVersion := reference.apiVersion
+	for _, k := range referenceKinds {
+		if len(apiVersion) == 0 && k.kind == reference.kind {
+			apiVersion = "v1"
+		}
+	}
+	if len(apiVersion) == 0 {
+		return nil
+	}
+
+	return g.Node(
+		schema.FromAPIVersionAndKind(apiVersion, reference.kind),
+		&metav1.ObjectMeta{
+			UID:       g.ToUID(reference.namespace, reference.kind, reference.name),
+			Name:      reference.name,
+			Namespace: reference.namespace,
+		},
+	)
+}
+
+// schemaReferences returns the reference fields of the OpenAPI schema of a
+// custom resource. The CustomResourceDefinition is read once per kind, a
+// kind without definition has no reference fields.
+func (g *Graph) schemaReferences(gvk schema.GroupVersionKind) ([]schemaReference, error) {
+	if references, ok := g.schemas[gvk]; ok {
+		return references, nil
+	}
+	g.schemas[gvk] = nil
+
+	resources, err := g.clientset.Discovery().ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plural := ""
+	for _, resource := range resources.APIResources {
+		if resource.Kind == gvk.Kind && !strings.Contains(resource.Name, "/") {
+			plural = resource.Name
+		}
+	}
+	if len(plural) == 0 {
+		return nil, nil
+	}
+
+	gvr := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	crd, err := g.CustomResource(gvr, "", plural+"."+gvk.Group)
+	if err != nil {
+		return nil, err
+	}
+	// the kind is served by an aggregated API server
+	if crd == nil {
+		return nil, nil
+	}
+
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, version := range versions {
+		v, ok := version.(map[string]interface{})
+		if !ok || v["name"] != gvk.Version {
+			continue
+		}
+
+		properties, _, _ := unstructured.NestedMap(v, "schema", "openAPIV3Schema", "properties")
+		references := []schemaReference{}
+		for _, field := range sortedKeys(properties) {
+			if field == "metadata" {
+				continue
+			}
+			if s, ok := properties[field].(map[string]interface{}); ok {
+				references = append(references, schemaFieldReferences(s, []string{field})...)
+			}
+		}
+		g.schemas[gvk] = references
+	}
+
+	return g.schemas[gvk], nil
+}
+
+// schemaFieldReferences returns the reference fields of the OpenAPI schema s
+// of the field at the given path and of all its nested fields.
+func schemaFieldReferences(s map[string]interface{}, fields []string) []schemaReference {
+	reference := schemaReference{path: fields}
+	reference.kind, _ = s[ObjectRefKindExtension].(string)
+	reference.apiVersion, _ = s[ObjectRefAPIVersionExtension].(string)
+
+	name := strings.ToLower(fields[len(fields)-1])
+	switch s["type"] {
+	case "string":
+		if len(reference.kind) == 0 && strings.HasSuffix(name, "name") {
+			reference.kind = referenceKind(strings.TrimSuffix(name, "name"))
+		}
+		if len(reference.kind) != 0 {
+			return []schemaReference{reference}
+		}
+	case "array":
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			return schemaFieldReferences(items, append(fields[:len(fields):len(fields)], "*"))
+		}
+	case "object":
+		properties, _ := s["properties"].(map[string]interface{})
+		if _, ok := properties["name"]; ok {
+			_, hasKind := properties["kind"]
+			base := strings.TrimSuffix(strings.TrimSuffix(name, "reference"), "ref")
+			if len(reference.kind) == 0 && base != name {
+				reference.kind = referenceKind(base)
+			}
+			if len(reference.kind) != 0 || (hasKind && base != name) {
+				reference.object = true
+				return []schemaReference{reference}
+			}
+		}
+
+		references := []schemaReference{}
+		for _, field := range sortedKeys(properties) {
+			if p, ok := properties[field].(map[string]interface{}); ok {
+				references = append(references, schemaFieldReferences(p, append(fields[:len(fields):len(fields)], field))...)
+			}
+		}
+		return references
+	}
+
+	return nil
+}
+
+// referenceKind returns the kind of the object referred to by a field with
+// the given lower case name without the "name" or "ref" suffix.
+func referenceKind(name string) string {
+	for _, reference := range referenceKinds {
+		if strings.HasSuffix(name, reference.suffix) {
+			return reference.kind
+		}
+	}
+
+	return ""
+}
+
+// fieldValues returns the values of the field at the given path of obj,
+// "*" stands for all items of an array.
+func fieldValues(obj interface{}, fields []string) []interface{} {
+	if len(fields) == 0 {
+		return []interface{}{obj}
+	}
+
+	values := []interface{}{}
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		if fields[0] != "*" {
+			values = fieldValues(v[fields[0]], fields[1:])
+		}
+	case []interface{}:
+		if fields[0] == "*" {
+			for _, item := range v {
+				values = append(values, fieldValues(item, fields[1:])...)
+			}
+		}
+	}
+
+	return values
+}
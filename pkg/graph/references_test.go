@@ -0,0 +1,163 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchemaFieldReferences(t *testing.T) {
+	str := map[string]interface{}{"type": "string"}
+	ref := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": str,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		schema map[string]interface{}
+		want   []schemaReference
+	}{
+		{
+			name: "name suffix",
+			schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"serviceAccountName": str,
+					"image":              str,
+				},
+			},
+			want: []schemaReference{
+				{path: []string{"spec", "serviceAccountName"}, kind: "ServiceAccount"},
+			},
+		},
+		{
+			name: "object reference",
+			schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tlsSecretRef":    ref,
+					"configMapRef":    ref,
+					"unrelatedObject": ref,
+				},
+			},
+			want: []schemaReference{
+				{path: []string{"spec", "configMapRef"}, kind: "ConfigMap", object: true},
+				{path: []string{"spec", "tlsSecretRef"}, kind: "Secret", object: true},
+			},
+		},
+		{
+			name: "object reference with kind",
+			schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"targetRef": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"kind": str,
+							"name": str,
+						},
+					},
+				},
+			},
+			want: []schemaReference{
+				{path: []string{"spec", "targetRef"}, object: true},
+			},
+		},
+		{
+			name: "array items",
+			schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"volumes": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"claimName": str,
+							},
+						},
+					},
+				},
+			},
+			want: []schemaReference{
+				{path: []string{"spec", "volumes", "*", "claimName"}, kind: "PersistentVolumeClaim"},
+			},
+		},
+		{
+			name: "extension",
+			schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"issuer": map[string]interface{}{
+						"type":                       "string",
+						ObjectRefKindExtension:       "Issuer",
+						ObjectRefAPIVersionExtension: "cert-manager.io/v1",
+					},
+				},
+			},
+			want: []schemaReference{
+				{path: []string{"spec", "issuer"}, apiVersion: "cert-manager.io/v1", kind: "Issuer"},
+			},
+		},
+		{
+			name:   "no references",
+			schema: map[string]interface{}{"type": "object"},
+			want:   []schemaReference{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schemaFieldReferences(tt.schema, []string{"spec"})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schemaFieldReferences() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldValues(t *testing.T) {
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"secretName": "tls",
+			"volumes": []interface{}{
+				map[string]interface{}{"claimName": "data"},
+				map[string]interface{}{"claimName": "logs"},
+				map[string]interface{}{"emptyDir": map[string]interface{}{}},
+			},
+		},
+	}
+
+	tests := []struct {
+		fields []string
+		want   []interface{}
+	}{
+		{fields: []string{"spec", "secretName"}, want: []interface{}{"tls"}},
+		{fields: []string{"spec", "volumes", "*", "claimName"}, want: []interface{}{"data", "logs", nil}},
+		{fields: []string{"spec", "missing"}, want: []interface{}{nil}},
+		{fields: []string{"spec", "*"}, want: []interface{}{}},
+		{fields: []string{"spec", "secretName", "name"}, want: []interface{}{}},
+	}
+
+	for _, tt := range tests {
+		if got := fieldValues(obj, tt.fields); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fieldValues(%v) = %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
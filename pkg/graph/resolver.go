@@ -0,0 +1,86 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+// Types of external references which are passed to the resolvers.
+const (
+	// ExternalRefImage is a container image, e.g. "docker.io/library/nginx:latest".
+	ExternalRefImage string = "image"
+	// ExternalRefHost is a DNS name outside of the cluster, e.g. of an ExternalName service.
+	ExternalRefHost string = "host"
+	// ExternalRefIP is an IP address outside of the cluster, e.g. of a load balancer.
+	ExternalRefIP string = "ip"
+	// ExternalRefURL is a URL, e.g. of a git repository.
+	ExternalRefURL string = "url"
+)
+
+// ExternalRef is a reference from a resource to something outside of the cluster.
+type ExternalRef struct {
+	// Type is one of the ExternalRef* constants or a custom type.
+	Type string
+	// Value is the reference as written in the resource.
+	Value string
+	// From is the node which contains the reference.
+	From *Node
+}
+
+// Resolver turns external references into nodes, so external systems like
+// registries, git servers or cloud providers can be added to the Graph.
+type Resolver interface {
+	// Resolve returns the node for the reference or nil if the reference is
+	// not handled by this resolver. If the node has no UID, it is derived
+	// from the type and value of the reference.
+	Resolve(ref ExternalRef) (*Node, error)
+}
+
+// ResolverFunc is an adapter to use an ordinary function as Resolver.
+type ResolverFunc func(ref ExternalRef) (*Node, error)
+
+// Resolve calls f(ref).
+func (f ResolverFunc) Resolve(ref ExternalRef) (*Node, error) {
+	return f(ref)
+}
+
+// Resolve passes the reference to all resolvers of Options.Resolvers in order
+// and adds the node of the first one which handles it to the Graph, including
+// a relationship from the node which contains the reference. It returns nil
+// if no resolver handles the reference.
+func (g *Graph) Resolve(ref ExternalRef, label string) (*Node, error) {
+	for _, resolver := range g.Options.Resolvers {
+		resolved, err := resolver.Resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		if resolved == nil {
+			continue
+		}
+
+		if len(resolved.UID) == 0 {
+			resolved.UID = g.ToUID(ref.Type, ref.Value)
+		}
+
+		n := g.Node(resolved.GroupVersionKind(), &resolved.ObjectMeta)
+		for key, value := range resolved.Attr {
+			n.Attribute(key, value)
+		}
+		if ref.From != nil {
+			g.Relationship(ref.From, label, n)
+		}
+
+		return n, nil
+	}
+
+	return nil, nil
+}
@@ -15,22 +15,32 @@
 package graph
 
 import (
-	"context"
+	"fmt"
+	"strconv"
 
 	v1 "github.com/openshift/api/route/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// IngressControllerNamespace is the namespace of the OpenShift IngressControllers.
+const IngressControllerNamespace = "openshift-ingress-operator"
+
 // RouteV1Graph is used to graph all routing resources.
 type RouteV1Graph struct {
 	graph *Graph
+
+	// routers contains the IngressControllers by name, because they
+	// serve many routes.
+	routers map[string]*Node
 }
 
 // NewRouteV1Graph creates a new RouteV1Graph.
 func NewRouteV1Graph(g *Graph) *RouteV1Graph {
 	return &RouteV1Graph{
-		graph: g,
+		graph:   g,
+		routers: make(map[string]*Node),
 	}
 }
 
@@ -53,21 +63,107 @@ func (g *RouteV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, er
 	}
 }
 
-// Route adds a v1.Route resource to the Graph.
+// Route adds a v1.Route resource, its primary and alternate backends and the
+// routers which serve it to the Graph. The relationships to the backends have
+// their weight as attribute, the relationships from the routers have the
+// host and whether the route is admitted by the router as attributes.
 func (g *RouteV1Graph) Route(obj *v1.Route) (*Node, error) {
 	n := g.graph.Node(obj.GroupVersionKind(), obj)
+	n.Attribute("host", obj.Spec.Host)
+	if len(obj.Spec.Path) != 0 {
+		n.Attribute("path", obj.Spec.Path)
+	}
+	if obj.Spec.Port != nil {
+		n.Attribute("targetPort", obj.Spec.Port.TargetPort.String())
+	}
+	if obj.Spec.TLS != nil {
+		n.Attribute("termination", string(obj.Spec.TLS.Termination))
+		if len(obj.Spec.TLS.InsecureEdgeTerminationPolicy) != 0 {
+			n.Attribute("insecureEdgeTerminationPolicy", string(obj.Spec.TLS.InsecureEdgeTerminationPolicy))
+		}
+	}
 
-	options := metav1.GetOptions{}
-	service, err := g.graph.clientset.CoreV1().Services(obj.GetNamespace()).Get(context.TODO(), obj.Spec.To.Name, options)
-	if err != nil {
-		return nil, err
+	backends := append([]v1.RouteTargetReference{obj.Spec.To}, obj.Spec.AlternateBackends...)
+	for i, backend := range backends {
+		if len(backend.Kind) != 0 && backend.Kind != "Service" {
+			continue
+		}
+
+		s, err := g.graph.CoreV1().ServiceName(obj.GetNamespace(), backend.Name)
+		if err != nil {
+			return nil, err
+		}
+		r := g.graph.Relationship(n, "Route", s)
+		if i == 0 {
+			r.Attribute("backend", "primary")
+		} else {
+			r.Attribute("backend", "alternate")
+		}
+		if backend.Weight != nil {
+			r.Attribute("trafficWeight", strconv.Itoa(int(*backend.Weight)))
+		}
 	}
 
-	s, err := g.graph.CoreV1().Service(service)
+	for _, ingress := range obj.Status.Ingress {
+		if len(ingress.RouterName) == 0 {
+			continue
+		}
+		router, err := g.RouterName(ingress.RouterName)
+		if err != nil {
+			return nil, err
+		}
+
+		r := g.graph.Relationship(router, "Route", n)
+		r.Attribute("host", ingress.Host)
+		for _, condition := range ingress.Conditions {
+			if condition.Type == v1.RouteAdmitted {
+				r.Attribute("admitted", string(condition.Status))
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// RouterName adds the router with the given name to the Graph. The router is
+// the OpenShift IngressController with this name, other routers are added as
+// node without UID from the cluster.
+func (g *RouteV1Graph) RouterName(name string) (*Node, error) {
+	if n, ok := g.routers[name]; ok {
+		return n, nil
+	}
+
+	gvr := schema.GroupVersionResource{Group: "operator.openshift.io", Version: "v1", Resource: "ingresscontrollers"}
+	unstr, err := g.graph.CustomResource(gvr, IngressControllerNamespace, name)
 	if err != nil {
 		return nil, err
 	}
-	g.graph.Relationship(n, "Route", s)
+
+	var n *Node
+	if unstr != nil {
+		n = g.graph.Node(unstr.GroupVersionKind(), unstr)
+		conditions, _, _ := unstructured.NestedSlice(unstr.Object, "status", "conditions")
+		for _, condition := range conditions {
+			if c, ok := condition.(map[string]interface{}); ok && c["type"] == "Available" {
+				n.Attribute("available", fmt.Sprint(c["status"]))
+			}
+		}
+		if domain, ok, _ := unstructured.NestedString(unstr.Object, "status", "domain"); ok {
+			n.Attribute("domain", domain)
+		}
+		if replicas, ok, _ := unstructured.NestedInt64(unstr.Object, "status", "availableReplicas"); ok {
+			n.Attribute("availableReplicas", strconv.FormatInt(replicas, 10))
+		}
+	} else {
+		n = g.graph.Node(
+			schema.FromAPIVersionAndKind("kubectl-graph/v1", "Router"),
+			&metav1.ObjectMeta{
+				UID:  g.graph.ToUID("Router", name),
+				Name: name,
+			},
+		)
+	}
+	g.routers[name] = n
 
 	return n, nil
 }
@@ -0,0 +1,95 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"strconv"
+
+	v1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchedulingV1Graph is used to graph all scheduling resources.
+type SchedulingV1Graph struct {
+	graph *Graph
+
+	// priorityClasses contains the nodes by name, because they are
+	// referenced by many Pods. A nil node was not found.
+	priorityClasses map[string]*Node
+}
+
+// NewSchedulingV1Graph creates a new SchedulingV1Graph.
+func NewSchedulingV1Graph(g *Graph) *SchedulingV1Graph {
+	return &SchedulingV1Graph{
+		graph:           g,
+		priorityClasses: make(map[string]*Node),
+	}
+}
+
+// SchedulingV1 retrieves the SchedulingV1Graph.
+func (g *Graph) SchedulingV1() *SchedulingV1Graph {
+	return g.schedulingV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *SchedulingV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "PriorityClass":
+		obj := &v1.PriorityClass{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.PriorityClass(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// PriorityClass adds a v1.PriorityClass resource to the Graph.
+func (g *SchedulingV1Graph) PriorityClass(obj *v1.PriorityClass) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "PriorityClass"), obj)
+	n.Attribute("value", strconv.Itoa(int(obj.Value)))
+	n.Attribute("globalDefault", strconv.FormatBool(obj.GlobalDefault))
+	if obj.PreemptionPolicy != nil {
+		n.Attribute("preemptionPolicy", string(*obj.PreemptionPolicy))
+	}
+	g.priorityClasses[obj.GetName()] = n
+
+	return n, nil
+}
+
+// PriorityClassName adds the v1.PriorityClass with the given name to the Graph.
+// It returns nil if the PriorityClass does not exist.
+func (g *SchedulingV1Graph) PriorityClassName(name string) (*Node, error) {
+	if n, ok := g.priorityClasses[name]; ok {
+		return n, nil
+	}
+
+	options := metav1.GetOptions{}
+	obj, err := g.graph.clientset.SchedulingV1().PriorityClasses().Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		g.priorityClasses[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return g.PriorityClass(obj)
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+)
+
+// SchemaVersion is the version of the data model of the cypher output
+// format. It is increased whenever a node label, property or relationship
+// type is renamed or removed.
+const SchemaVersion = 1
+
+// Schema describes the node labels, properties and relationship types of
+// the cypher output format, so queries and dashboards can detect breaking
+// changes of the data model by comparing the schema of two graphs.
+type Schema struct {
+	Version       int                   `json:"version"`
+	Nodes         []*SchemaNode         `json:"nodes"`
+	Relationships []*SchemaRelationship `json:"relationships"`
+}
+
+// SchemaNode describes the properties of the nodes with a label.
+type SchemaNode struct {
+	Label      string   `json:"label"`
+	Properties []string `json:"properties"`
+}
+
+// SchemaRelationship describes a relationship type and the labels of the
+// nodes which it connects.
+type SchemaRelationship struct {
+	Type string   `json:"type"`
+	From []string `json:"from"`
+	To   []string `json:"to"`
+}
+
+// Schema returns the schema of the graph as it is written by the cypher
+// output format. All nodes have the additional label "k8s".
+func (g *Graph) Schema() *Schema {
+	schema := &Schema{
+		Version:       SchemaVersion,
+		Nodes:         []*SchemaNode{},
+		Relationships: []*SchemaRelationship{},
+	}
+
+	properties := make(map[string]map[string]bool)
+	for _, node := range g.NodeList() {
+		if _, ok := properties[node.Kind]; !ok {
+			properties[node.Kind] = map[string]bool{
+				"UID": true, "Name": true, "ts": true, "lastSeen": true, "batch": true,
+			}
+		}
+		if len(node.Namespace) != 0 {
+			properties[node.Kind]["Namespace"] = true
+		}
+		for key := range node.Annotations {
+			properties[node.Kind]["Annotation_"+underscore(key)] = true
+		}
+		for key := range node.Labels {
+			properties[node.Kind]["Label_"+underscore(key)] = true
+		}
+		for key := range node.Attr {
+			properties[node.Kind]["Attr_"+underscore(key)] = true
+		}
+	}
+	for _, kind := range sortedKeys(properties) {
+		schema.Nodes = append(schema.Nodes, &SchemaNode{
+			Label:      kind,
+			Properties: sortedKeys(properties[kind]),
+		})
+	}
+
+	relationships := make(map[string]*SchemaRelationship)
+	for _, relationship := range g.RelationshipList() {
+		from, ok := g.Nodes[relationship.From]
+		if !ok {
+			continue
+		}
+		to, ok := g.Nodes[relationship.To]
+		if !ok {
+			continue
+		}
+
+		r, ok := relationships[relationship.Label]
+		if !ok {
+			r = &SchemaRelationship{Type: relationship.Label, From: []string{}, To: []string{}}
+			relationships[relationship.Label] = r
+		}
+		if !slices.Contains(r.From, from.Kind) {
+			r.From = append(r.From, from.Kind)
+		}
+		if !slices.Contains(r.To, to.Kind) {
+			r.To = append(r.To, to.Kind)
+		}
+	}
+	for _, label := range sortedKeys(relationships) {
+		slices.Sort(relationships[label].From)
+		slices.Sort(relationships[label].To)
+		schema.Relationships = append(schema.Relationships, relationships[label])
+	}
+
+	return schema
+}
+
+// writeSchema writes the schema of the graph as JSON to w.
+func (g *Graph) writeSchema(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(g.Schema())
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"path"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// secretsStoreDriver is the name of the CSI driver of the Secrets Store.
+const secretsStoreDriver = "secrets-store.csi.k8s.io"
+
+// SecretsStoreCSIV1Graph is used to graph all secrets-store.csi.x-k8s.io resources.
+type SecretsStoreCSIV1Graph struct {
+	graph *Graph
+
+	// secretProviderClasses contains the nodes by namespace and name,
+	// because they are referenced by many Pods. A nil node was not found.
+	secretProviderClasses map[string]*Node
+}
+
+// NewSecretsStoreCSIV1Graph creates a new SecretsStoreCSIV1Graph.
+func NewSecretsStoreCSIV1Graph(g *Graph) *SecretsStoreCSIV1Graph {
+	return &SecretsStoreCSIV1Graph{
+		graph:                 g,
+		secretProviderClasses: make(map[string]*Node),
+	}
+}
+
+// SecretsStoreCSIV1 retrieves the SecretsStoreCSIV1Graph.
+func (g *Graph) SecretsStoreCSIV1() *SecretsStoreCSIV1Graph {
+	return g.secretsStoreCSIV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *SecretsStoreCSIV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "SecretProviderClass":
+		return g.SecretProviderClass(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// SecretProviderClass adds a SecretProviderClass resource, its external
+// secret provider and the synced Secrets to the Graph.
+func (g *SecretsStoreCSIV1Graph) SecretProviderClass(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.secretProviderClasses[path.Join(unstr.GetNamespace(), unstr.GetName())] = n
+
+	provider, _, _ := unstructured.NestedString(unstr.Object, "spec", "provider")
+	if len(provider) != 0 {
+		n.Attribute("provider", provider)
+		g.graph.Relationship(n, "SecretProvider", g.SecretProvider(provider))
+	}
+
+	secretObjects, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "secretObjects")
+	for _, secretObject := range secretObjects {
+		s, ok := secretObject.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(s, "secretName"); len(name) != 0 {
+			g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), name))
+		}
+	}
+
+	return n, nil
+}
+
+// SecretProviderClassName adds the SecretProviderClass with the given name to the Graph.
+// It returns nil if the SecretProviderClass does not exist.
+func (g *SecretsStoreCSIV1Graph) SecretProviderClassName(namespace string, name string) (*Node, error) {
+	if n, ok := g.secretProviderClasses[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	gvr := schema.GroupVersionResource{Group: "secrets-store.csi.x-k8s.io", Version: "v1", Resource: "secretproviderclasses"}
+	unstr, err := g.graph.CustomResource(gvr, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr == nil {
+		g.secretProviderClasses[path.Join(namespace, name)] = nil
+		return nil, nil
+	}
+
+	return g.SecretProviderClass(unstr)
+}
+
+// SecretProvider adds a node which represents an external secret provider,
+// e.g. "vault" or "azure", to the Graph.
+func (g *SecretsStoreCSIV1Graph) SecretProvider(name string) *Node {
+	return g.graph.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "SecretProvider"),
+		&metav1.ObjectMeta{
+			UID:  g.graph.ToUID("SecretProvider", name),
+			Name: name,
+		},
+	)
+}
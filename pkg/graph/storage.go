@@ -0,0 +1,192 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+
+	v1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// StorageV1Graph is used to graph all storage resources.
+type StorageV1Graph struct {
+	graph *Graph
+
+	// storageClasses and csiDrivers contain the nodes by name, because
+	// they are referenced by many volumes. A nil node was not found.
+	storageClasses map[string]*Node
+	csiDrivers     map[string]*Node
+}
+
+// NewStorageV1Graph creates a new StorageV1Graph.
+func NewStorageV1Graph(g *Graph) *StorageV1Graph {
+	return &StorageV1Graph{
+		graph:          g,
+		storageClasses: make(map[string]*Node),
+		csiDrivers:     make(map[string]*Node),
+	}
+}
+
+// StorageV1 retrieves the StorageV1Graph.
+func (g *Graph) StorageV1() *StorageV1Graph {
+	return g.storageV1
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *StorageV1Graph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "StorageClass":
+		obj := &v1.StorageClass{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.StorageClass(obj)
+	case "CSIDriver":
+		obj := &v1.CSIDriver{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.CSIDriver(obj)
+	case "VolumeAttachment":
+		obj := &v1.VolumeAttachment{}
+		if err := FromUnstructured(unstr, obj); err != nil {
+			return nil, err
+		}
+		return g.VolumeAttachment(obj)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// StorageClass adds a v1.StorageClass resource and the CSIDriver of its provisioner to the Graph.
+func (g *StorageV1Graph) StorageClass(obj *v1.StorageClass) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "StorageClass"), obj)
+	n.Attribute("provisioner", obj.Provisioner)
+	if obj.ReclaimPolicy != nil {
+		n.Attribute("reclaimPolicy", string(*obj.ReclaimPolicy))
+	}
+	if obj.VolumeBindingMode != nil {
+		n.Attribute("volumeBindingMode", string(*obj.VolumeBindingMode))
+	}
+	g.storageClasses[obj.GetName()] = n
+
+	// in-tree provisioners have no CSIDriver
+	d, err := g.CSIDriverName(obj.Provisioner)
+	if err != nil {
+		return nil, err
+	}
+	if d != nil {
+		g.graph.Relationship(n, "CSIDriver", d)
+	}
+
+	return n, nil
+}
+
+// StorageClassName adds the v1.StorageClass with the given name to the Graph.
+// It returns nil if the StorageClass does not exist.
+func (g *StorageV1Graph) StorageClassName(name string) (*Node, error) {
+	if n, ok := g.storageClasses[name]; ok {
+		return n, nil
+	}
+
+	options := metav1.GetOptions{}
+	obj, err := g.graph.clientset.StorageV1().StorageClasses().Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		g.storageClasses[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return g.StorageClass(obj)
+}
+
+// CSIDriver adds a v1.CSIDriver resource to the Graph.
+func (g *StorageV1Graph) CSIDriver(obj *v1.CSIDriver) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "CSIDriver"), obj)
+	g.csiDrivers[obj.GetName()] = n
+
+	return n, nil
+}
+
+// CSIDriverName adds the v1.CSIDriver with the given name to the Graph.
+// It returns nil if the CSIDriver does not exist.
+func (g *StorageV1Graph) CSIDriverName(name string) (*Node, error) {
+	if n, ok := g.csiDrivers[name]; ok {
+		return n, nil
+	}
+
+	options := metav1.GetOptions{}
+	obj, err := g.graph.clientset.StorageV1().CSIDrivers().Get(context.TODO(), name, options)
+	if apierrors.IsNotFound(err) {
+		g.csiDrivers[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return g.CSIDriver(obj)
+}
+
+// VolumeAttachment adds a v1.VolumeAttachment resource, its PersistentVolume,
+// Node and CSIDriver to the Graph.
+func (g *StorageV1Graph) VolumeAttachment(obj *v1.VolumeAttachment) (*Node, error) {
+	n := g.graph.Node(schema.FromAPIVersionAndKind(v1.SchemeGroupVersion.String(), "VolumeAttachment"), obj)
+	if obj.Status.Attached {
+		n.Attribute("status", "Attached")
+	} else {
+		n.Attribute("status", "Detached")
+	}
+
+	options := metav1.GetOptions{}
+
+	if obj.Spec.Source.PersistentVolumeName != nil {
+		volume, err := g.graph.clientset.CoreV1().PersistentVolumes().Get(context.TODO(), *obj.Spec.Source.PersistentVolumeName, options)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			v, err := g.graph.CoreV1().PersistentVolume(volume)
+			if err != nil {
+				return nil, err
+			}
+			g.graph.Relationship(v, "VolumeAttachment", n)
+		}
+	}
+
+	o, err := g.graph.CoreV1().NodeName(obj.Spec.NodeName)
+	if err != nil {
+		return nil, err
+	}
+	if o != nil {
+		g.graph.Relationship(n, "Node", o)
+	}
+
+	d, err := g.CSIDriverName(obj.Spec.Attacher)
+	if err != nil {
+		return nil, err
+	}
+	if d != nil {
+		g.graph.Relationship(n, "CSIDriver", d)
+	}
+
+	return n, nil
+}
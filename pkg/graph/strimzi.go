@@ -0,0 +1,463 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// strimziV1beta2 is the group version of the Strimzi resources which are read from the cluster.
+var strimziV1beta2 = schema.GroupVersion{Group: "kafka.strimzi.io", Version: "v1beta2"}
+
+const (
+	// StrimziClusterLabel is the label with the name of the Kafka or
+	// KafkaConnect cluster of a Strimzi resource.
+	StrimziClusterLabel = "strimzi.io/cluster"
+	// StrimziKindLabel is the label with the kind of the Strimzi resource
+	// which generated a resource.
+	StrimziKindLabel = "strimzi.io/kind"
+)
+
+// StrimziGraph is used to graph all kafka.strimzi.io resources.
+type StrimziGraph struct {
+	graph *Graph
+
+	// clusters contains the Kafka clusters by namespace and name, because
+	// they are referenced by all topics and users.
+	clusters map[string]*Node
+	// topics contains the KafkaTopics by namespace and topic name, because
+	// they are referenced by the ACLs of the users.
+	topics map[string]*Node
+	// listed contains the namespaces whose KafkaTopics are already listed.
+	listed map[string]bool
+}
+
+// NewStrimziGraph creates a new StrimziGraph.
+func NewStrimziGraph(g *Graph) *StrimziGraph {
+	return &StrimziGraph{
+		graph:    g,
+		clusters: make(map[string]*Node),
+		topics:   make(map[string]*Node),
+		listed:   make(map[string]bool),
+	}
+}
+
+// Strimzi retrieves the StrimziGraph.
+func (g *Graph) Strimzi() *StrimziGraph {
+	return g.strimzi
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *StrimziGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Kafka":
+		return g.Kafka(unstr)
+	case "KafkaNodePool":
+		return g.KafkaNodePool(unstr)
+	case "KafkaTopic":
+		return g.KafkaTopic(unstr)
+	case "KafkaUser":
+		return g.KafkaUser(unstr)
+	case "KafkaConnect":
+		return g.KafkaConnect(unstr)
+	case "KafkaConnector":
+		return g.KafkaConnector(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Kafka adds a Kafka resource, its node pools, the workloads and Pods which
+// are generated by the Cluster Operator and its CA Secrets to the Graph.
+func (g *StrimziGraph) Kafka(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.clusters[key]; ok && g.graph.objects[n.UID] {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.clusters[key] = n
+	n.Attribute("status", readyStatus(unstr))
+	if version, ok, _ := unstructured.NestedString(unstr.Object, "status", "kafkaVersion"); ok {
+		n.Attribute("kafkaVersion", version)
+	}
+	if replicas, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "kafka", "replicas"); ok {
+		n.Attribute("replicas", strconv.FormatInt(replicas, 10))
+	}
+
+	listeners, _, _ := unstructured.NestedSlice(unstr.Object, "status", "listeners")
+	names := []string{}
+	for _, listener := range listeners {
+		if l, ok := listener.(map[string]interface{}); ok {
+			if name, ok := l["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) != 0 {
+		n.Attribute("listeners", strings.Join(names, ","))
+	}
+
+	pools, err := g.graph.CustomResources(strimziV1beta2.WithResource("kafkanodepools"), unstr.GetNamespace(), StrimziClusterLabel+"="+unstr.GetName())
+	if err != nil {
+		return nil, err
+	}
+	for i := range pools {
+		p, err := g.KafkaNodePool(&pools[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "KafkaNodePool", p)
+	}
+
+	if err := g.generated(n, unstr); err != nil {
+		return nil, err
+	}
+
+	for _, suffix := range []string{"cluster-ca", "cluster-ca-cert", "clients-ca", "clients-ca-cert"} {
+		g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), unstr.GetName()+"-"+suffix))
+	}
+
+	return n, nil
+}
+
+// KafkaName adds the Kafka cluster with the given name to the Graph.
+// A missing cluster is added as node without UID from the cluster.
+func (g *StrimziGraph) KafkaName(namespace string, name string) (*Node, error) {
+	if n, ok := g.clusters[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(strimziV1beta2.WithResource("kafkas"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Kafka(unstr)
+	}
+
+	n := g.graph.Node(
+		strimziV1beta2.WithKind("Kafka"),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, "Kafka", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.clusters[path.Join(namespace, name)] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// KafkaNodePool adds a KafkaNodePool resource to the Graph.
+func (g *StrimziGraph) KafkaNodePool(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	if replicas, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "replicas"); ok {
+		n.Attribute("replicas", strconv.FormatInt(replicas, 10))
+	}
+	if roles, ok, _ := unstructured.NestedStringSlice(unstr.Object, "spec", "roles"); ok {
+		n.Attribute("roles", strings.Join(roles, ","))
+	}
+
+	return n, nil
+}
+
+// KafkaTopic adds a KafkaTopic resource and the relationship from its Kafka cluster to the Graph.
+func (g *StrimziGraph) KafkaTopic(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+	for _, field := range []string{"partitions", "replicas"} {
+		if value, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", field); ok {
+			n.Attribute(field, strconv.FormatInt(value, 10))
+		}
+	}
+
+	name := strimziTopicName(unstr)
+	n.Attribute("topicName", name)
+	g.topics[path.Join(unstr.GetNamespace(), name)] = n
+
+	if err := g.cluster(n, unstr); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// KafkaUser adds a KafkaUser resource, the relationship from its Kafka
+// cluster, its generated Secret and the KafkaTopics of its ACLs to the Graph.
+func (g *StrimziGraph) KafkaUser(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+	if authentication, ok, _ := unstructured.NestedString(unstr.Object, "spec", "authentication", "type"); ok {
+		n.Attribute("authentication", authentication)
+	}
+	if authorization, ok, _ := unstructured.NestedString(unstr.Object, "spec", "authorization", "type"); ok {
+		n.Attribute("authorization", authorization)
+	}
+
+	if err := g.cluster(n, unstr); err != nil {
+		return nil, err
+	}
+
+	if secret, ok, _ := unstructured.NestedString(unstr.Object, "status", "secret"); ok && len(secret) != 0 {
+		g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), secret))
+	}
+
+	acls, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "authorization", "acls")
+	for _, acl := range acls {
+		a, ok := acl.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resourceType, _, _ := unstructured.NestedString(a, "resource", "type")
+		patternType, _, _ := unstructured.NestedString(a, "resource", "patternType")
+		name, _, _ := unstructured.NestedString(a, "resource", "name")
+		if resourceType != "topic" || (len(patternType) != 0 && patternType != "literal") {
+			continue
+		}
+
+		t, err := g.KafkaTopicName(unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			continue
+		}
+		r := g.graph.Relationship(n, "ACL", t)
+		operations, _, _ := unstructured.NestedStringSlice(a, "operations")
+		if operation, ok, _ := unstructured.NestedString(a, "operation"); ok {
+			operations = append(operations, operation)
+		}
+		for _, operation := range operations {
+			appendAttribute(r, "operations", operation)
+		}
+	}
+
+	return n, nil
+}
+
+// KafkaTopicName adds the KafkaTopic of the topic with the given name to the
+// Graph. It returns nil if the topic is not managed by a KafkaTopic.
+func (g *StrimziGraph) KafkaTopicName(namespace string, name string) (*Node, error) {
+	key := path.Join(namespace, name)
+	if n, ok := g.topics[key]; ok {
+		return n, nil
+	}
+
+	// the topics are listed once per namespace, because the name of a topic
+	// may differ from the name of its KafkaTopic
+	if !g.listed[namespace] {
+		g.listed[namespace] = true
+
+		topics, err := g.graph.CustomResources(strimziV1beta2.WithResource("kafkatopics"), namespace, "")
+		if err != nil {
+			return nil, err
+		}
+		for i := range topics {
+			if _, err := g.KafkaTopic(&topics[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return g.topics[key], nil
+}
+
+// KafkaConnect adds a KafkaConnect resource, its bootstrap Service, its
+// connectors, the workloads and Pods which are generated by the Cluster
+// Operator and its referenced Secrets to the Graph.
+func (g *StrimziGraph) KafkaConnect(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+	if replicas, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "replicas"); ok {
+		n.Attribute("replicas", strconv.FormatInt(replicas, 10))
+	}
+	if url, ok, _ := unstructured.NestedString(unstr.Object, "status", "url"); ok {
+		n.Attribute("url", url)
+	}
+
+	servers, _, _ := unstructured.NestedString(unstr.Object, "spec", "bootstrapServers")
+	for _, server := range strings.Split(servers, ",") {
+		host, _, _ := strings.Cut(strings.TrimSpace(server), ":")
+		if len(host) == 0 {
+			continue
+		}
+
+		// the bootstrap Service of a Kafka cluster in the cluster, e.g. "my-cluster-kafka-bootstrap.kafka.svc"
+		namespace := unstr.GetNamespace()
+		name, rest, _ := strings.Cut(host, ".")
+		if len(rest) != 0 {
+			namespace, _, _ = strings.Cut(rest, ".")
+		}
+		if !strings.HasSuffix(name, "-kafka-bootstrap") {
+			if _, err := g.graph.CoreV1().External(n, "Bootstrap", host); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		s, err := g.graph.CoreV1().ServiceName(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Bootstrap", s)
+	}
+
+	if image, ok, _ := unstructured.NestedString(unstr.Object, "spec", "build", "output", "image"); ok {
+		if _, err := g.graph.CoreV1().image(n, "Image", image); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, fields := range [][]string{
+		{"spec", "authentication", "passwordSecret", "secretName"},
+		{"spec", "authentication", "certificateAndKey", "secretName"},
+	} {
+		if secret, ok, _ := unstructured.NestedString(unstr.Object, fields...); ok {
+			g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), secret))
+		}
+	}
+	certificates, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "tls", "trustedCertificates")
+	for _, certificate := range certificates {
+		if c, ok := certificate.(map[string]interface{}); ok {
+			if secret, ok := c["secretName"].(string); ok {
+				g.graph.Relationship(n, "Secret", g.graph.CoreV1().SecretName(unstr.GetNamespace(), secret))
+			}
+		}
+	}
+
+	connectors, err := g.graph.CustomResources(strimziV1beta2.WithResource("kafkaconnectors"), unstr.GetNamespace(), StrimziClusterLabel+"="+unstr.GetName())
+	if err != nil {
+		return nil, err
+	}
+	for i := range connectors {
+		c, err := g.KafkaConnector(&connectors[i])
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "KafkaConnector", c)
+	}
+
+	if err := g.generated(n, unstr); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// KafkaConnector adds a KafkaConnector resource to the Graph.
+func (g *StrimziGraph) KafkaConnector(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	n.Attribute("status", readyStatus(unstr))
+	if class, ok, _ := unstructured.NestedString(unstr.Object, "spec", "class"); ok {
+		n.Attribute("class", class)
+	}
+	if tasks, ok, _ := unstructured.NestedInt64(unstr.Object, "spec", "tasksMax"); ok {
+		n.Attribute("tasksMax", strconv.FormatInt(tasks, 10))
+	}
+	if state, ok, _ := unstructured.NestedString(unstr.Object, "status", "connectorStatus", "connector", "state"); ok {
+		n.Attribute("state", state)
+	}
+
+	return n, nil
+}
+
+// cluster adds a relationship from the Kafka cluster of a KafkaTopic or
+// KafkaUser to n. The cluster is referenced by the strimzi.io/cluster label.
+func (g *StrimziGraph) cluster(n *Node, unstr *unstructured.Unstructured) error {
+	name, ok := unstr.GetLabels()[StrimziClusterLabel]
+	if !ok {
+		g.graph.Warn(WarningInvalid, n, "missing %s label", StrimziClusterLabel)
+		return nil
+	}
+
+	c, err := g.KafkaName(unstr.GetNamespace(), name)
+	if err != nil {
+		return err
+	}
+	g.graph.Relationship(c, unstr.GetKind(), n)
+
+	return nil
+}
+
+// generated adds the StatefulSets, Deployments and Pods which are generated
+// by the Cluster Operator for a Kafka or KafkaConnect cluster to the Graph.
+// Pods of a StatefulSet are added by their StatefulSet, the other Pods are
+// managed by a StrimziPodSet and added directly.
+func (g *StrimziGraph) generated(n *Node, unstr *unstructured.Unstructured) error {
+	options := metav1.ListOptions{LabelSelector: StrimziClusterLabel + "=" + unstr.GetName() + "," + StrimziKindLabel + "=" + unstr.GetKind()}
+
+	statefulSets, err := g.graph.clientset.AppsV1().StatefulSets(unstr.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return err
+	}
+	for i := range statefulSets.Items {
+		s, err := g.graph.AppsV1().StatefulSet(&statefulSets.Items[i])
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(n, "StatefulSet", s)
+	}
+
+	deployments, err := g.graph.clientset.AppsV1().Deployments(unstr.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return err
+	}
+	for i := range deployments.Items {
+		d, err := g.graph.AppsV1().Deployment(&deployments.Items[i])
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(n, "Deployment", d)
+	}
+
+	pods, err := g.graph.clientset.CoreV1().Pods(unstr.GetNamespace()).List(context.TODO(), options)
+	if err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		if owner := metav1.GetControllerOf(&pods.Items[i]); owner == nil || owner.Kind != "StrimziPodSet" {
+			continue
+		}
+		p, err := g.graph.CoreV1().Pod(&pods.Items[i])
+		if err != nil {
+			return err
+		}
+		g.graph.Relationship(n, "Pod", p)
+	}
+
+	return nil
+}
+
+// strimziTopicName returns the name of the topic in Kafka, which defaults
+// to the name of the KafkaTopic.
+func strimziTopicName(unstr *unstructured.Unstructured) string {
+	if name, ok, _ := unstructured.NestedString(unstr.Object, "spec", "topicName"); ok && len(name) != 0 {
+		return name
+	}
+	if name, ok, _ := unstructured.NestedString(unstr.Object, "status", "topicName"); ok && len(name) != 0 {
+		return name
+	}
+
+	return unstr.GetName()
+}
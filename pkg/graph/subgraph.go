@@ -0,0 +1,196 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"sort"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Namespaces returns a sorted list of the namespaces of all nodes.
+// Cluster-scoped nodes are represented by an empty namespace.
+func (g *Graph) Namespaces() []string {
+	set := make(map[string]bool)
+	for _, node := range g.Nodes {
+		set[node.Namespace] = true
+	}
+
+	return sortedKeys(set)
+}
+
+// Subgraph returns a new graph which contains all nodes matching the filter,
+// their direct neighbours and all relationships between these nodes. The
+// nodes and relationships are shared with the original graph.
+func (g *Graph) Subgraph(filter func(*Node) bool) *Graph {
+	sub := &Graph{
+		Nodes:         make(map[types.UID]*Node),
+		Relationships: make(map[types.UID][]*Relationship),
+		Options:       g.Options,
+		clientset:     g.clientset,
+		warned:        make(map[Warning]bool),
+	}
+
+	for uid, node := range g.Nodes {
+		if filter(node) {
+			sub.Nodes[uid] = node
+		}
+	}
+
+	relationships := []*Relationship{}
+	for _, relationship := range g.relationships() {
+		_, from := sub.Nodes[relationship.From]
+		_, to := sub.Nodes[relationship.To]
+		if from || to {
+			relationships = append(relationships, relationship)
+		}
+	}
+
+	for _, relationship := range relationships {
+		for _, uid := range []types.UID{relationship.From, relationship.To} {
+			if node, ok := g.Nodes[uid]; ok {
+				sub.Nodes[uid] = node
+			}
+		}
+		sub.Relationships[relationship.To] = append(sub.Relationships[relationship.To], relationship)
+	}
+
+	// the warnings about nodes of the subgraph and the warnings which are
+	// not about a node of the graph, e.g. about failed discovery
+	nodes := make(map[Warning]bool)
+	for _, node := range g.Nodes {
+		w := Warning{Kind: node.Kind, Namespace: node.Namespace, Name: node.Name}
+		nodes[w] = nodes[w] || sub.Nodes[node.UID] != nil
+	}
+	for _, warning := range g.warnings {
+		kept, ok := nodes[Warning{Kind: warning.Kind, Namespace: warning.Namespace, Name: warning.Name}]
+		if kept || !ok {
+			sub.warned[warning] = true
+			sub.warnings = append(sub.warnings, warning)
+		}
+	}
+
+	return sub
+}
+
+// SplitByNamespace returns one subgraph per namespace, the key of the
+// cluster-scoped nodes is "cluster".
+func (g *Graph) SplitByNamespace() map[string]*Graph {
+	graphs := make(map[string]*Graph)
+
+	for _, namespace := range g.Namespaces() {
+		name := namespace
+		if len(name) == 0 {
+			name = "cluster"
+		}
+
+		graphs[name] = g.Subgraph(func(node *Node) bool {
+			return node.Namespace == namespace
+		})
+	}
+
+	return graphs
+}
+
+// Paginate splits the graph into pages of about size nodes and returns the
+// pages and an index graph. The nodes are grouped by the root of their owner
+// references, so a workload is never split across pages, even if it exceeds
+// the size on its own. Each page contains the direct neighbours of its nodes.
+// The index contains one Page node per page, named by the output name of the
+// page, and a relationship to each root node on the page.
+func (g *Graph) Paginate(size int, name func(page int) string) ([]*Graph, *Graph) {
+	// the relationships as stored in the graph, because the direction of
+	// inverted relationships doesn't reflect the owner
+	relationships := g.relationships()
+	sort.Slice(relationships, func(i, j int) bool {
+		return g.lessUID(relationships[i].From, relationships[j].From)
+	})
+
+	owner := make(map[types.UID]types.UID)
+	for _, relationship := range relationships {
+		if relationship.Weight() != OwnershipWeight {
+			continue
+		}
+		if _, ok := owner[relationship.To]; !ok {
+			owner[relationship.To] = relationship.From
+		}
+	}
+
+	root := func(uid types.UID) types.UID {
+		visited := map[types.UID]bool{uid: true}
+		for {
+			parent, ok := owner[uid]
+			if !ok || visited[parent] {
+				return uid
+			}
+			if _, ok := g.Nodes[parent]; !ok {
+				return uid
+			}
+			visited[parent] = true
+			uid = parent
+		}
+	}
+
+	roots := []*Node{}
+	groups := make(map[types.UID][]types.UID)
+	for _, node := range g.NodeList() {
+		uid := root(node.UID)
+		if _, ok := groups[uid]; !ok {
+			roots = append(roots, g.Nodes[uid])
+		}
+		groups[uid] = append(groups[uid], node.UID)
+	}
+
+	pages := [][]*Node{}
+	members := []map[types.UID]bool{}
+	for _, r := range roots {
+		last := len(members) - 1
+		if last < 0 || (len(members[last]) != 0 && len(members[last])+len(groups[r.UID]) > size) {
+			pages = append(pages, []*Node{})
+			members = append(members, make(map[types.UID]bool))
+			last++
+		}
+		pages[last] = append(pages[last], r)
+		for _, uid := range groups[r.UID] {
+			members[last][uid] = true
+		}
+	}
+
+	graphs := make([]*Graph, len(pages))
+	index := NewEmptyGraph(g.clientset, g.Options)
+	for i := range pages {
+		graphs[i] = g.Subgraph(func(node *Node) bool {
+			return members[i][node.UID]
+		})
+
+		p := index.Node(
+			schema.FromAPIVersionAndKind("kubectl-graph/v1", "Page"),
+			&metav1.ObjectMeta{
+				UID:  g.ToUID("Page", i+1),
+				Name: name(i + 1),
+			},
+		)
+		p.Attribute("nodes", strconv.Itoa(len(graphs[i].Nodes)))
+		for _, r := range pages[i] {
+			index.Nodes[r.UID] = r
+			index.Relationship(p, r.Kind, r)
+		}
+	}
+
+	return graphs, index
+}
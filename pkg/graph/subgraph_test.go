@@ -0,0 +1,146 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// newPaginateGraph returns a graph with a Deployment which owns a ReplicaSet
+// with two Pods and two Services without owner. The UID of each node is its name.
+func newPaginateGraph() *Graph {
+	g := NewEmptyGraph(nil, nil)
+
+	owned := func(apiVersion string, kind string, name string, owner *Node) *Node {
+		obj := &metav1.ObjectMeta{UID: types.UID(name), Name: name, Namespace: "default"}
+		if owner != nil {
+			obj.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: owner.APIVersion,
+				Kind:       owner.Kind,
+				Name:       owner.Name,
+				UID:        owner.UID,
+			}}
+		}
+		return g.Node(schema.FromAPIVersionAndKind(apiVersion, kind), obj)
+	}
+
+	deployment := owned("apps/v1", "Deployment", "deployment", nil)
+	replicaset := owned("apps/v1", "ReplicaSet", "replicaset", deployment)
+	owned("v1", "Pod", "pod-a", replicaset)
+	owned("v1", "Pod", "pod-b", replicaset)
+	owned("v1", "Service", "service-a", nil)
+	owned("v1", "Service", "service-b", nil)
+
+	return g
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		pages [][]types.UID
+	}{
+		{
+			name: "workload exceeds size",
+			size: 1,
+			pages: [][]types.UID{
+				{"deployment", "pod-a", "pod-b", "replicaset"},
+				{"service-a"},
+				{"service-b"},
+			},
+		},
+		{
+			name: "groups share a page",
+			size: 2,
+			pages: [][]types.UID{
+				{"deployment", "pod-a", "pod-b", "replicaset"},
+				{"service-a", "service-b"},
+			},
+		},
+		{
+			name: "single page",
+			size: 100,
+			pages: [][]types.UID{
+				{"deployment", "pod-a", "pod-b", "replicaset", "service-a", "service-b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newPaginateGraph()
+			pages, index := g.Paginate(tt.size, strconv.Itoa)
+
+			var got [][]types.UID
+			for _, page := range pages {
+				uids := []types.UID{}
+				for uid := range page.Nodes {
+					uids = append(uids, uid)
+				}
+				sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+				got = append(got, uids)
+			}
+			if !reflect.DeepEqual(got, tt.pages) {
+				t.Errorf("pages = %v, want %v", got, tt.pages)
+			}
+
+			for i := range pages {
+				uid := g.ToUID("Page", i+1)
+				p, ok := index.Nodes[uid]
+				if !ok {
+					t.Fatalf("index has no Page %d", i+1)
+				}
+				if p.Name != strconv.Itoa(i+1) {
+					t.Errorf("Page %d name = %q, want %q", i+1, p.Name, strconv.Itoa(i+1))
+				}
+			}
+		})
+	}
+}
+
+func TestPaginateRoots(t *testing.T) {
+	g := newPaginateGraph()
+	_, index := g.Paginate(1, strconv.Itoa)
+
+	roots := map[types.UID]types.UID{}
+	for _, relationship := range index.RelationshipList() {
+		roots[relationship.To] = relationship.From
+	}
+
+	tests := []struct {
+		uid  types.UID
+		page int
+	}{
+		{uid: "deployment", page: 1},
+		{uid: "service-a", page: 2},
+		{uid: "service-b", page: 3},
+	}
+
+	for _, tt := range tests {
+		if got, want := roots[tt.uid], g.ToUID("Page", tt.page); got != want {
+			t.Errorf("root %s on %s, want Page %d", tt.uid, got, tt.page)
+		}
+	}
+	if len(roots) != len(tests) {
+		t.Errorf("index links %d roots, want %d", len(roots), len(tests))
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"context"
+	"path"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// tektonV1 is the group version of the Tekton resources which are read from the cluster.
+var tektonV1 = schema.GroupVersion{Group: "tekton.dev", Version: "v1"}
+
+// TektonGraph is used to graph all tekton.dev resources.
+type TektonGraph struct {
+	graph *Graph
+
+	// definitions contains the Pipelines and Tasks by kind, namespace and
+	// name, because they are referenced by many runs. A nil node was not found.
+	definitions map[string]*Node
+	// taskRuns contains the nodes by namespace and name, because they are
+	// referenced by their PipelineRun. A nil node was not found.
+	taskRuns map[string]*Node
+}
+
+// NewTektonGraph creates a new TektonGraph.
+func NewTektonGraph(g *Graph) *TektonGraph {
+	return &TektonGraph{
+		graph:       g,
+		definitions: make(map[string]*Node),
+		taskRuns:    make(map[string]*Node),
+	}
+}
+
+// Tekton retrieves the TektonGraph.
+func (g *Graph) Tekton() *TektonGraph {
+	return g.tekton
+}
+
+// Unstructured adds an unstructured node to the Graph.
+func (g *TektonGraph) Unstructured(unstr *unstructured.Unstructured) (*Node, error) {
+	switch unstr.GetKind() {
+	case "Pipeline":
+		return g.Pipeline(unstr)
+	case "PipelineRun":
+		return g.PipelineRun(unstr)
+	case "Task", "ClusterTask":
+		return g.Task(unstr)
+	case "TaskRun":
+		return g.TaskRun(unstr)
+	default:
+		return g.graph.Node(unstr.GroupVersionKind(), unstr), nil
+	}
+}
+
+// Pipeline adds a Pipeline resource and the Tasks of its pipeline tasks to the Graph.
+func (g *TektonGraph) Pipeline(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.definitions[path.Join(unstr.GetKind(), unstr.GetNamespace(), unstr.GetName())] = n
+
+	for _, field := range []string{"tasks", "finally"} {
+		tasks, _, _ := unstructured.NestedSlice(unstr.Object, "spec", field)
+		for _, task := range tasks {
+			t, ok := task.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			r, err := g.taskRef(n, unstr.GetNamespace(), t)
+			if err != nil {
+				return nil, err
+			}
+			if name, ok, _ := unstructured.NestedString(t, "name"); ok && r != nil {
+				appendAttribute(r, "pipelineTasks", name)
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Task adds a Task or ClusterTask resource to the Graph.
+func (g *TektonGraph) Task(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.definitions[path.Join(unstr.GetKind(), unstr.GetNamespace(), unstr.GetName())] = n
+
+	steps, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "steps")
+	n.Attribute("steps", strconv.Itoa(len(steps)))
+
+	return n, nil
+}
+
+// PipelineRun adds a PipelineRun resource, its Pipeline, its TaskRuns and
+// the PersistentVolumeClaims of its workspaces to the Graph.
+func (g *TektonGraph) PipelineRun(unstr *unstructured.Unstructured) (*Node, error) {
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	tektonRunAttributes(n, unstr)
+
+	if name, ok, _ := unstructured.NestedString(unstr.Object, "spec", "pipelineRef", "name"); ok {
+		p, err := g.DefinitionName("Pipeline", unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		g.graph.Relationship(n, "Pipeline", p)
+	}
+	if resolver, ok, _ := unstructured.NestedString(unstr.Object, "spec", "pipelineRef", "resolver"); ok {
+		n.Attribute("resolver", resolver)
+	}
+
+	children, _, _ := unstructured.NestedSlice(unstr.Object, "status", "childReferences")
+	for _, child := range children {
+		c, ok := child.(map[string]interface{})
+		if !ok || c["kind"] != "TaskRun" {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(c, "name")
+		t, err := g.TaskRunName(unstr.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			continue
+		}
+		r := g.graph.Relationship(n, "TaskRun", t)
+		if task, ok, _ := unstructured.NestedString(c, "pipelineTaskName"); ok {
+			r.Attribute("pipelineTask", task)
+		}
+	}
+
+	if err := g.workspaces(n, unstr); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// TaskRun adds a TaskRun resource, its Task, its Pod and the
+// PersistentVolumeClaims of its workspaces to the Graph.
+func (g *TektonGraph) TaskRun(unstr *unstructured.Unstructured) (*Node, error) {
+	key := path.Join(unstr.GetNamespace(), unstr.GetName())
+	if n, ok := g.taskRuns[key]; ok && n != nil {
+		return n, nil
+	}
+
+	n := g.graph.Node(unstr.GroupVersionKind(), unstr)
+	g.taskRuns[key] = n
+	tektonRunAttributes(n, unstr)
+
+	if ref, ok, _ := unstructured.NestedMap(unstr.Object, "spec", "taskRef"); ok {
+		if _, err := g.taskRef(n, unstr.GetNamespace(), map[string]interface{}{"taskRef": ref}); err != nil {
+			return nil, err
+		}
+	}
+
+	if name, ok, _ := unstructured.NestedString(unstr.Object, "status", "podName"); ok && len(name) != 0 {
+		options := metav1.GetOptions{}
+		pod, err := g.graph.clientset.CoreV1().Pods(unstr.GetNamespace()).Get(context.TODO(), name, options)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		// the Pod of a completed TaskRun may be pruned
+		if err == nil {
+			p, err := g.graph.CoreV1().Pod(pod)
+			if err != nil {
+				return nil, err
+			}
+			g.graph.Relationship(n, "Pod", p)
+		}
+	}
+
+	if err := g.workspaces(n, unstr); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// TaskRunName adds the TaskRun with the given name to the Graph.
+// It returns nil if the TaskRun does not exist.
+func (g *TektonGraph) TaskRunName(namespace string, name string) (*Node, error) {
+	if n, ok := g.taskRuns[path.Join(namespace, name)]; ok {
+		return n, nil
+	}
+
+	unstr, err := g.graph.CustomResource(tektonV1.WithResource("taskruns"), namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr == nil {
+		g.taskRuns[path.Join(namespace, name)] = nil
+		return nil, nil
+	}
+
+	return g.TaskRun(unstr)
+}
+
+// DefinitionName adds the Pipeline, Task or ClusterTask with the given name to the Graph.
+// A missing definition is added as node without UID from the cluster.
+func (g *TektonGraph) DefinitionName(kind string, namespace string, name string) (*Node, error) {
+	if kind == "ClusterTask" {
+		namespace = ""
+	}
+	key := path.Join(kind, namespace, name)
+	if n, ok := g.definitions[key]; ok {
+		return n, nil
+	}
+
+	resources := map[string]string{"Pipeline": "pipelines", "Task": "tasks", "ClusterTask": "clustertasks"}
+	gvr := tektonV1.WithResource(resources[kind])
+	if kind == "ClusterTask" {
+		gvr.Version = "v1beta1"
+	}
+	unstr, err := g.graph.CustomResource(gvr, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if unstr != nil {
+		return g.Unstructured(unstr)
+	}
+
+	n := g.graph.Node(
+		gvr.GroupVersion().WithKind(kind),
+		&metav1.ObjectMeta{
+			UID:       g.graph.ToUID(namespace, kind, name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+	g.definitions[key] = n
+	g.graph.Warn(WarningNotFound, n, "referenced, but does not exist")
+
+	return n, nil
+}
+
+// taskRef adds a relationship from n to the Task referenced by the "taskRef"
+// field of obj. Tasks which are embedded or resolved remotely are skipped.
+func (g *TektonGraph) taskRef(n *Node, namespace string, obj map[string]interface{}) (*Relationship, error) {
+	name, _, _ := unstructured.NestedString(obj, "taskRef", "name")
+	if len(name) == 0 {
+		return nil, nil
+	}
+	kind, _, _ := unstructured.NestedString(obj, "taskRef", "kind")
+	if len(kind) == 0 {
+		kind = "Task"
+	}
+	if kind != "Task" && kind != "ClusterTask" {
+		return nil, nil
+	}
+
+	t, err := g.DefinitionName(kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.graph.Relationship(n, kind, t), nil
+}
+
+// workspaces adds relationships from a run to the PersistentVolumeClaims
+// which are bound to its workspaces.
+func (g *TektonGraph) workspaces(n *Node, unstr *unstructured.Unstructured) error {
+	workspaces, _, _ := unstructured.NestedSlice(unstr.Object, "spec", "workspaces")
+	for _, workspace := range workspaces {
+		w, ok := workspace.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		claim, ok, _ := unstructured.NestedString(w, "persistentVolumeClaim", "claimName")
+		if !ok {
+			continue
+		}
+
+		c, err := g.graph.CoreV1().PersistentVolumeClaimName(unstr.GetNamespace(), claim)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			g.graph.Warn(WarningNotFound, n, "PersistentVolumeClaim %s not found", claim)
+			continue
+		}
+		r := g.graph.Relationship(n, "Workspace", c)
+		if name, ok, _ := unstructured.NestedString(w, "name"); ok {
+			appendAttribute(r, "workspaces", name)
+		}
+	}
+
+	return nil
+}
+
+// tektonRunAttributes adds the status of a PipelineRun or TaskRun by its
+// Succeeded condition, its reason and its start and completion time as attributes.
+func tektonRunAttributes(n *Node, unstr *unstructured.Unstructured) {
+	conditions, _, _ := unstructured.NestedSlice(unstr.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok || c["type"] != "Succeeded" {
+			continue
+		}
+
+		switch c["status"] {
+		case string(v1.ConditionTrue):
+			n.Attribute("status", JobComplete)
+		case string(v1.ConditionFalse):
+			n.Attribute("status", JobFailed)
+		default:
+			n.Attribute("status", JobRunning)
+		}
+		if reason, ok := c["reason"].(string); ok {
+			n.Attribute("reason", reason)
+		}
+	}
+
+	for _, field := range []string{"startTime", "completionTime"} {
+		if value, ok, _ := unstructured.NestedString(unstr.Object, "status", field); ok {
+			n.Attribute(field, value)
+		}
+	}
+}
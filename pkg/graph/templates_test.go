@@ -0,0 +1,108 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGraphviz(t *testing.T) {
+	options := NewOptions()
+	options.Title = `<b>"prod" & co</b>`
+	options.Legend = true
+	options.Theme.ColorBy = ColorByNamespace
+
+	g := NewEmptyGraph(nil, options)
+	g.Node(schema.FromAPIVersionAndKind("v1", "Pod"), &metav1.ObjectMeta{UID: "pod", Namespace: `a"b`, Name: "web"})
+
+	b := &strings.Builder{}
+	if err := g.Write(b, "graphviz"); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	tests := []string{
+		`label="<b>\"prod\" & co</b>" labelloc="t"`,
+		`"legend_a\"b" [`,
+		`label="a\"b"];`,
+	}
+	for _, want := range tests {
+		if !strings.Contains(out, want) {
+			t.Errorf("graphviz output doesn't contain %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `\u003c`) {
+		t.Errorf("graphviz output contains JSON escape sequences:\n%s", out)
+	}
+}
+
+func TestGraphvizRelationshipAttributes(t *testing.T) {
+	g := NewEmptyGraph(nil, nil)
+	service := g.Node(schema.FromAPIVersionAndKind("v1", "Service"), &metav1.ObjectMeta{UID: "service", Name: "web"})
+	pod := g.Node(schema.FromAPIVersionAndKind("v1", "Pod"), &metav1.ObjectMeta{UID: "pod", Name: "web"})
+	r := g.Relationship(service, "ROUTES_TO", pod)
+	r.Attribute("color", "#34a853")
+	r.Attribute("weight", "10")
+	r.Attribute("target", "_blank")
+
+	b := &strings.Builder{}
+	if err := g.Write(b, "graphviz"); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	tests := []struct {
+		s        string
+		contains bool
+	}{
+		{s: ` color="#34a853"`, contains: true},
+		{s: `labeltooltip="Service[web] ->\nPod[web]\ntarget: _blank\nweight: 10"`, contains: true},
+		{s: ` weight=`, contains: false},
+		{s: ` target=`, contains: false},
+	}
+	for _, tt := range tests {
+		if strings.Contains(out, tt.s) != tt.contains {
+			t.Errorf("graphviz output contains %s = %v, want %v:\n%s", tt.s, !tt.contains, tt.contains, out)
+		}
+	}
+}
+
+func TestMermaidClassNames(t *testing.T) {
+	options := NewOptions()
+	options.Theme.ColorBy = ColorByNamespace
+
+	g := NewEmptyGraph(nil, options)
+	g.Node(schema.FromAPIVersionAndKind("v1", "Pod"), &metav1.ObjectMeta{UID: "pod", Namespace: "kube-system", Name: "dns"})
+
+	b := &strings.Builder{}
+	if err := g.Write(b, "mermaid"); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	tests := []string{
+		`pod((dns)):::kube_system`,
+		`classDef kube_system fill:`,
+	}
+	for _, want := range tests {
+		if !strings.Contains(out, want) {
+			t.Errorf("mermaid output doesn't contain %s:\n%s", want, out)
+		}
+	}
+}
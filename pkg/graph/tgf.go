@@ -0,0 +1,88 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// writeTGF writes the graph in Trivial Graph Format to w. Node IDs are
+// replaced by sequential numbers, because TGF doesn't allow whitespace in IDs.
+func (g *Graph) writeTGF(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	nodes := g.NodeList()
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Namespace != nodes[j].Namespace {
+			return nodes[i].Namespace < nodes[j].Namespace
+		}
+		return g.less(nodes[i], nodes[j])
+	})
+
+	ids := make(map[types.UID]int, len(nodes))
+	for idx, node := range nodes {
+		ids[node.UID] = idx + 1
+
+		label := fmt.Sprintf("%s %s", node.Kind, node.Name)
+		if len(node.Namespace) != 0 {
+			label = fmt.Sprintf("%s %s/%s", node.Kind, node.Namespace, node.Name)
+		}
+		fmt.Fprintf(b, "%d %s\n", ids[node.UID], tgfLabel(label))
+	}
+
+	fmt.Fprintln(b, "#")
+
+	relationships := g.RelationshipList()
+	sort.SliceStable(relationships, func(i, j int) bool {
+		if ids[relationships[i].From] != ids[relationships[j].From] {
+			return ids[relationships[i].From] < ids[relationships[j].From]
+		}
+		return ids[relationships[i].To] < ids[relationships[j].To]
+	})
+
+	for _, relationship := range relationships {
+		from, ok := ids[relationship.From]
+		if !ok {
+			continue
+		}
+		to, ok := ids[relationship.To]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(b, "%d %d %s\n", from, to, tgfLabel(relationship.Label))
+	}
+
+	return b.Flush()
+}
+
+// tgfLabel replaces all control characters and line breaks in s with spaces,
+// because a TGF label must not span multiple lines.
+func tgfLabel(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '\u2028' || r == '\u2029' {
+			return ' '
+		}
+		return r
+	}, s)
+
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ColorByKind colors the nodes by their kind.
+	ColorByKind string = "kind"
+	// ColorByNamespace colors the nodes by their namespace.
+	ColorByNamespace string = "namespace"
+	// ColorByStatus colors the nodes by their "status" attribute.
+	ColorByStatus string = "status"
+)
+
+// Theme represents the colors which are used by all output formats.
+type Theme struct {
+	// Dark is true if the background is dark.
+	Dark bool `json:"dark"`
+	// Background is the background color of the graph.
+	Background string `json:"background"`
+	// Foreground is the color of text and node borders.
+	Foreground string `json:"foreground"`
+	// Edge is the default color of relationships.
+	Edge string `json:"edge"`
+	// ColorBy selects the property of a node which determines its color.
+	ColorBy string `json:"colorBy"`
+	// Colors maps a kind, namespace or status to a fixed color.
+	Colors map[string]string `json:"colors"`
+	// Palette contains the colors which are assigned to all other values by
+	// their hash. If empty, the color is derived from the hash itself.
+	Palette []string `json:"palette"`
+}
+
+// Themes contains the built-in themes.
+var Themes = map[string]*Theme{
+	"light": {
+		Background: "#ffffff",
+		Foreground: "#000000",
+		Edge:       "#9e9e9e",
+		ColorBy:    ColorByKind,
+	},
+	"dark": {
+		Dark:       true,
+		Background: "#1e1e1e",
+		Foreground: "#e0e0e0",
+		Edge:       "#757575",
+		ColorBy:    ColorByKind,
+	},
+}
+
+// DefaultTheme returns a copy of the light theme.
+func DefaultTheme() *Theme {
+	theme := *Themes["light"]
+	return &theme
+}
+
+// LoadTheme returns the built-in theme with the given name or reads a theme
+// from a YAML or JSON file. Missing values of a file are taken from the
+// built-in theme which matches its "dark" value.
+func LoadTheme(name string) (*Theme, error) {
+	if theme, ok := Themes[name]; ok {
+		copy := *theme
+		return &copy, nil
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load theme %q: %v", name, err)
+	}
+
+	theme := &Theme{}
+	if err := yaml.UnmarshalStrict(b, theme); err != nil {
+		return nil, fmt.Errorf("failed to load theme %q: %v", name, err)
+	}
+
+	base := Themes["light"]
+	if theme.Dark {
+		base = Themes["dark"]
+	}
+	if len(theme.Background) == 0 {
+		theme.Background = base.Background
+	}
+	if len(theme.Foreground) == 0 {
+		theme.Foreground = base.Foreground
+	}
+	if len(theme.Edge) == 0 {
+		theme.Edge = base.Edge
+	}
+	if len(theme.ColorBy) == 0 {
+		theme.ColorBy = base.ColorBy
+	}
+
+	return theme, theme.Validate()
+}
+
+// Validate checks the values of the theme.
+func (t *Theme) Validate() error {
+	switch t.ColorBy {
+	case ColorByKind, ColorByNamespace, ColorByStatus:
+		return nil
+	}
+
+	return fmt.Errorf("invalid color by: %q, allowed values are: %s|%s|%s", t.ColorBy, ColorByKind, ColorByNamespace, ColorByStatus)
+}
+
+// Color returns the color for the given kind, namespace or status. Keys
+// without a configured color get a color derived from their hash.
+func (t *Theme) Color(key string, hash func([]byte) []byte) string {
+	if c, ok := t.Colors[key]; ok {
+		return c
+	}
+
+	sum := hash([]byte(key))
+	if len(t.Palette) != 0 {
+		return t.Palette[binary.BigEndian.Uint32(sum[:4])%uint32(len(t.Palette))]
+	}
+
+	return fmt.Sprintf("#%x", sum[:3])
+}
+
+// ColorKey returns the value of node which determines its color.
+func (t *Theme) ColorKey(node *Node) string {
+	switch t.ColorBy {
+	case ColorByNamespace:
+		return node.Namespace
+	case ColorByStatus:
+		if status, ok := node.Attr["status"]; ok {
+			return status
+		}
+		return "Unknown"
+	}
+
+	return node.Kind
+}
+
+// Color returns the color of node according to the theme of the graph.
+func (g *Graph) Color(node *Node) string {
+	return g.Options.Theme.Color(g.Options.Theme.ColorKey(node), g.hash)
+}
+
+// LegendEntry represents a color and its meaning.
+type LegendEntry struct {
+	Key   string
+	Label string
+	Color string
+}
+
+// LegendEntries returns the colors of all nodes sorted by their key.
+func (g *Graph) LegendEntries() []LegendEntry {
+	set := make(map[string]bool)
+	for _, node := range g.Nodes {
+		set[g.Options.Theme.ColorKey(node)] = true
+	}
+
+	entries := []LegendEntry{}
+	for _, key := range sortedKeys(set) {
+		label := key
+		if len(label) == 0 {
+			label = "(none)"
+		}
+		entries = append(entries, LegendEntry{Key: key, Label: label, Color: g.Options.Theme.Color(key, g.hash)})
+	}
+
+	return entries
+}
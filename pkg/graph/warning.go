@@ -0,0 +1,114 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Reasons of the warnings which are collected while the Graph is built.
+const (
+	// WarningNotFound is used if a referenced object does not exist.
+	WarningNotFound = "NotFound"
+	// WarningUnresolvedOwner is used if the owner of an object was not
+	// found, although objects of its kind were added to the Graph.
+	WarningUnresolvedOwner = "UnresolvedOwner"
+	// WarningConflict is used if a heuristic has multiple candidates.
+	WarningConflict = "Conflict"
+	// WarningUnavailable is used if an aggregated API is not available, so
+	// the discovery of its group fails and its resources may be missing.
+	WarningUnavailable = "Unavailable"
+	// WarningInvalid is used if an annotation or a field can not be parsed,
+	// so the relationships which are defined by it are missing.
+	WarningInvalid = "Invalid"
+)
+
+// Warning is a recoverable oddity which was found while the Graph was built.
+// In contrast to an error the Graph is complete, but may lack relationships.
+type Warning struct {
+	Reason    string `json:"reason"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Message   string `json:"message,omitempty"`
+}
+
+// String returns the warning in a human readable format.
+func (w Warning) String() string {
+	name := w.Name
+	if len(w.Namespace) != 0 {
+		name = w.Namespace + "/" + w.Name
+	}
+
+	return fmt.Sprintf("%s %s %s: %s", w.Reason, w.Kind, name, w.Message)
+}
+
+// Warn adds a warning about the node to the Graph. Duplicates are ignored.
+func (g *Graph) Warn(reason string, n *Node, format string, args ...interface{}) {
+	w := Warning{
+		Reason:    reason,
+		Kind:      n.Kind,
+		Namespace: n.Namespace,
+		Name:      n.Name,
+		Message:   fmt.Sprintf(format, args...),
+	}
+	if g.warned[w] {
+		return
+	}
+	g.warned[w] = true
+	g.warnings = append(g.warnings, w)
+}
+
+// warnUnresolvedOwners adds a warning for each owner reference to an object
+// which was not added to the Graph, although objects of its kind were added.
+func (g *Graph) warnUnresolvedOwners() {
+	for _, node := range g.NodeList() {
+		for _, owner := range g.owners[node.UID] {
+			o, ok := g.Nodes[owner]
+			if !ok || g.objects[owner] || !g.objectKinds[o.APIVersion+"/"+o.Kind] {
+				continue
+			}
+			g.Warn(WarningUnresolvedOwner, node, "owner %s %s not found", o.Kind, o.Name)
+		}
+	}
+}
+
+// WarnDiscoveryFailed adds a warning for each group version which failed
+// during discovery. The warnings refer to the APIService of the group
+// version, because usually an aggregated API is not available.
+func (g *Graph) WarnDiscoveryFailed(groups map[schema.GroupVersion]error) {
+	gvs := make([]schema.GroupVersion, 0, len(groups))
+	for gv := range groups {
+		gvs = append(gvs, gv)
+	}
+	sort.Slice(gvs, func(i, j int) bool {
+		return gvs[i].String() < gvs[j].String()
+	})
+
+	for _, gv := range gvs {
+		n := &Node{}
+		n.Kind = "APIService"
+		n.Name = gv.Version + "." + gv.Group
+		g.Warn(WarningUnavailable, n, "discovery failed: %v", groups[gv])
+	}
+}
+
+// Warnings returns all warnings in the order they were found.
+func (g *Graph) Warnings() []Warning {
+	return append([]Warning{}, g.warnings...)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestWarn(t *testing.T) {
+	g := NewEmptyGraph(nil, nil)
+	pod := g.Node(schema.FromAPIVersionAndKind("v1", "Pod"), &metav1.ObjectMeta{UID: "pod", Namespace: "default", Name: "web"})
+
+	g.Warn(WarningNotFound, pod, "Node %s not found", "worker-1")
+	g.Warn(WarningNotFound, pod, "Node %s not found", "worker-1")
+	g.Warn(WarningNotFound, pod, "Node %s not found", "worker-2")
+
+	want := []string{
+		"NotFound Pod default/web: Node worker-1 not found",
+		"NotFound Pod default/web: Node worker-2 not found",
+	}
+	got := []string{}
+	for _, warning := range g.Warnings() {
+		got = append(got, warning.String())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Warnings() = %v, want %v", got, want)
+	}
+}
+
+func TestSubgraphWarnings(t *testing.T) {
+	g := NewEmptyGraph(nil, nil)
+	node := func(namespace string, name string) *Node {
+		return g.Node(
+			schema.FromAPIVersionAndKind("v1", "Pod"),
+			&metav1.ObjectMeta{UID: types.UID(namespace + "/" + name), Namespace: namespace, Name: name},
+		)
+	}
+
+	g.Warn(WarningNotFound, node("default", "web"), "web")
+	g.Warn(WarningNotFound, node("kube-system", "dns"), "dns")
+	g.WarnDiscoveryFailed(map[schema.GroupVersion]error{{Group: "metrics.k8s.io", Version: "v1beta1"}: nil})
+
+	tests := []struct {
+		namespace string
+		want      []string
+	}{
+		{namespace: "default", want: []string{"web", "discovery failed: <nil>"}},
+		{namespace: "kube-system", want: []string{"dns", "discovery failed: <nil>"}},
+		{namespace: "monitoring", want: []string{"discovery failed: <nil>"}},
+	}
+
+	for _, tt := range tests {
+		sub := g.Subgraph(func(n *Node) bool {
+			return n.Namespace == tt.namespace
+		})
+
+		got := []string{}
+		for _, warning := range sub.Warnings() {
+			got = append(got, warning.Message)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Subgraph(%s).Warnings() = %v, want %v", tt.namespace, got, tt.want)
+		}
+
+		// the warnings of a subgraph are deduplicated as well
+		sub.Warn(WarningNotFound, &Node{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}, TypeMeta: metav1.TypeMeta{Kind: "Pod"}}, "web")
+		if tt.namespace == "default" && len(sub.Warnings()) != len(tt.want) {
+			t.Errorf("Subgraph(%s).Warn() added a duplicate", tt.namespace)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// workloadKinds contains the kinds which are wrapped by a Workload node.
+var workloadKinds = map[string]bool{
+	"DaemonSet":             true,
+	"Deployment":            true,
+	"ReplicationController": true,
+	"Rollout":               true,
+	"StatefulSet":           true,
+}
+
+// rolloutSeverity orders the rollout status values from healthy to unhealthy,
+// a Workload has the most severe status of its workloads.
+var rolloutSeverity = map[string]int{
+	RolloutComplete:    0,
+	RolloutPaused:      1,
+	RolloutProgressing: 2,
+	RolloutFailed:      3,
+}
+
+// Workloads wraps all Deployments, StatefulSets, DaemonSets, Rollouts and
+// ReplicationControllers under a generic Workload node per namespace and
+// name, so an application keeps its node if it is migrated to another
+// workload type. The replica counts of the workloads are summed up and the
+// most severe rollout status is added to the Workload node.
+func (g *Graph) Workloads() {
+	for _, node := range g.NodeList() {
+		if !workloadKinds[node.Kind] || len(node.GetNamespace()) == 0 {
+			continue
+		}
+
+		w := g.Workload(node.GetNamespace(), node.GetName())
+		g.Relationship(w, node.Kind, node)
+
+		kinds := []string{}
+		if len(w.Attr["kinds"]) != 0 {
+			kinds = strings.Split(w.Attr["kinds"], ",")
+		}
+		if !slices.Contains(kinds, node.Kind) {
+			w.Attribute("kinds", strings.Join(append(kinds, node.Kind), ","))
+		}
+
+		for _, key := range []string{"replicas", "readyReplicas", "availableReplicas"} {
+			if value, ok := node.Attr[key]; ok {
+				sum, _ := strconv.Atoi(w.Attr[key])
+				add, _ := strconv.Atoi(value)
+				w.Attribute(key, strconv.Itoa(sum+add))
+			}
+		}
+
+		status, ok := node.Attr["status"]
+		if !ok {
+			continue
+		}
+		if current, ok := w.Attr["status"]; !ok || rolloutSeverity[status] > rolloutSeverity[current] {
+			w.Attribute("status", status)
+		}
+	}
+}
+
+// Workload adds a node which represents an application independent of its workload type to the Graph.
+func (g *Graph) Workload(namespace string, name string) *Node {
+	return g.Node(
+		schema.FromAPIVersionAndKind("kubectl-graph/v1", "Workload"),
+		&metav1.ObjectMeta{
+			UID:       g.ToUID(namespace, "Workload", name),
+			Name:      name,
+			Namespace: namespace,
+		},
+	)
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileSink writes outputs to the local filesystem.
+type FileSink struct {
+	path string
+}
+
+// NewFileSink creates a new FileSink.
+func NewFileSink(path string) *FileSink {
+	return &FileSink{
+		path: path,
+	}
+}
+
+// Open creates or truncates the file for the named output. The output is
+// compressed with gzip if the path ends with ".gz".
+func (s *FileSink) Open(name string) (io.WriteCloser, error) {
+	path := Name(s.path, name)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, gzipExt) {
+		return Gzip(f), nil
+	}
+
+	return f, nil
+}
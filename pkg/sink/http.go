@@ -0,0 +1,87 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// HTTPSink sends outputs to a webhook via HTTP POST.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink creates a new HTTPSink.
+func NewHTTPSink(url string, client *http.Client) *HTTPSink {
+	return &HTTPSink{
+		url:    url,
+		client: client,
+	}
+}
+
+// Open returns a buffered writer which is posted to the webhook on close.
+// A non-empty name is passed as "name" query parameter.
+func (s *HTTPSink) Open(name string) (io.WriteCloser, error) {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(name) != 0 {
+		query := u.Query()
+		query.Set("name", name)
+		u.RawQuery = query.Encode()
+	}
+
+	return &httpWriter{sink: s, url: u.String()}, nil
+}
+
+type httpWriter struct {
+	bytes.Buffer
+
+	sink *HTTPSink
+	url  string
+}
+
+func (w *httpWriter) Close() error {
+	req, err := http.NewRequest(http.MethodPost, w.url, &w.Buffer)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	return do(w.sink.client, req)
+}
+
+// do sends the request and returns an error for non-2xx responses.
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: unexpected status %q: %s", req.Method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,313 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Sink uploads outputs to an S3 compatible object storage. The credentials
+// are read from the standard AWS_* environment variables. Without static keys
+// the web identity token of AWS_WEB_IDENTITY_TOKEN_FILE is exchanged for
+// temporary credentials of AWS_ROLE_ARN, e.g. in a Pod with IAM roles for
+// service accounts (IRSA).
+type S3Sink struct {
+	bucket   string
+	key      string
+	region   string
+	endpoint string
+	client   *http.Client
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	roleARN              string
+	roleSessionName      string
+	webIdentityTokenFile string
+	stsEndpoint          string
+	expiration           time.Time
+}
+
+// NewS3Sink creates a new S3Sink.
+func NewS3Sink(bucket string, key string, client *http.Client) (*S3Sink, error) {
+	if len(bucket) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("invalid sink: s3 URL must be in the form s3://bucket/key")
+	}
+
+	s := &S3Sink{
+		bucket:          bucket,
+		key:             key,
+		region:          getenv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		endpoint:        getenv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		client:          client,
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+
+		roleARN:              os.Getenv("AWS_ROLE_ARN"),
+		roleSessionName:      getenv("AWS_ROLE_SESSION_NAME"),
+		webIdentityTokenFile: os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"),
+		stsEndpoint:          getenv("AWS_ENDPOINT_URL_STS", "AWS_ENDPOINT_URL"),
+	}
+
+	if len(s.region) == 0 {
+		s.region = "us-east-1"
+	}
+	if len(s.roleSessionName) == 0 {
+		s.roleSessionName = "kubectl-graph"
+	}
+	if len(s.stsEndpoint) == 0 {
+		s.stsEndpoint = fmt.Sprintf("https://sts.%s.amazonaws.com", s.region)
+	}
+
+	static := len(s.accessKeyID) != 0 && len(s.secretAccessKey) != 0
+	webIdentity := len(s.roleARN) != 0 && len(s.webIdentityTokenFile) != 0
+	if !static && !webIdentity {
+		return nil, fmt.Errorf("invalid sink: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE must be set")
+	}
+	if static {
+		s.webIdentityTokenFile = ""
+	}
+
+	return s, nil
+}
+
+// Open returns a buffered writer which is uploaded to the bucket on close.
+// The output is compressed with gzip if the key ends with ".gz".
+func (s *S3Sink) Open(name string) (io.WriteCloser, error) {
+	w := &s3Writer{sink: s, key: Name(s.key, name)}
+	if strings.HasSuffix(w.key, gzipExt) {
+		return Gzip(w), nil
+	}
+
+	return w, nil
+}
+
+type s3Writer struct {
+	bytes.Buffer
+
+	sink *S3Sink
+	key  string
+}
+
+func (w *s3Writer) Close() error {
+	s := w.sink
+
+	now := time.Now().UTC()
+	if err := s.refresh(now); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, s.url(w.key), bytes.NewReader(w.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if strings.HasSuffix(w.key, gzipExt) {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	s.sign(req, w.Bytes(), now)
+
+	return do(s.client, req)
+}
+
+// url returns the URL of the object with the given key. Virtual-hosted-style
+// URLs are used for AWS, except for bucket names with dots which don't match
+// the wildcard certificate, and path-style URLs for custom endpoints.
+func (s *S3Sink) url(key string) string {
+	if len(s.endpoint) == 0 && !strings.Contains(s.bucket, ".") {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com%s", s.bucket, s.region, uriEncode("/"+key))
+	}
+
+	endpoint := strings.TrimSuffix(s.endpoint, "/")
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.region)
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	return endpoint + uriEncode("/"+s.bucket+"/"+key)
+}
+
+// refresh exchanges the web identity token for temporary credentials, if
+// they are used and expire within the next minutes. The token is read on
+// every refresh, because it is rotated by the kubelet.
+func (s *S3Sink) refresh(now time.Time) error {
+	if len(s.webIdentityTokenFile) == 0 || now.Add(5*time.Minute).Before(s.expiration) {
+		return nil
+	}
+
+	token, err := os.ReadFile(s.webIdentityTokenFile)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithWebIdentity")
+	query.Set("Version", "2011-06-15")
+	query.Set("RoleArn", s.roleARN)
+	query.Set("RoleSessionName", s.roleSessionName)
+	query.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(s.stsEndpoint, "/")+"/", strings.NewReader(query.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("assume role %s: unexpected status %q: %s", s.roleARN, resp.Status, bytes.TrimSpace(body))
+	}
+
+	result := struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}{}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("assume role %s: %v", s.roleARN, err)
+	}
+	if len(result.Credentials.AccessKeyID) == 0 {
+		return fmt.Errorf("assume role %s: no credentials returned", s.roleARN)
+	}
+
+	s.accessKeyID = result.Credentials.AccessKeyID
+	s.secretAccessKey = result.Credentials.SecretAccessKey
+	s.sessionToken = result.Credentials.SessionToken
+	s.expiration = result.Credentials.Expiration
+
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 authorization header to the request.
+func (s *S3Sink) sign(req *http.Request, payload []byte, now time.Time) {
+	date := now.Format("20060102")
+	datetime := now.Format("20060102T150405Z")
+	hash := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(hash[:])
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", datetime)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if len(s.sessionToken) != 0 {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	names := []string{}
+	headers := map[string]string{}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		names = append(names, name)
+		headers[name] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := &strings.Builder{}
+	for _, name := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.region)
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		datetime,
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + s.secretAccessKey)
+	for _, part := range []string{date, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode encodes all characters except the unreserved ones and "/" as
+// required by the AWS Signature Version 4 canonical URI.
+func uriEncode(path string) string {
+	b := &strings.Builder{}
+	for _, c := range []byte(path) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+			b.WriteByte(c)
+		case c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// getenv returns the value of the first non-empty environment variable.
+func getenv(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); len(value) != 0 {
+			return value
+		}
+	}
+
+	return ""
+}
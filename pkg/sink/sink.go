@@ -0,0 +1,131 @@
+// Copyright 2020 Steve Teuber
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// gzipExt is the file extension which enables compression.
+	gzipExt = ".gz"
+	// DefaultTimeout limits the time of a single upload to an HTTP or S3 sink,
+	// so a stalled endpoint can't block the command forever.
+	DefaultTimeout = 5 * time.Minute
+)
+
+// Sink represents a destination for the rendered graph.
+type Sink interface {
+	// Open returns a writer for the named output. An empty name refers to the
+	// default output of the sink. The output is flushed when the writer is closed.
+	Open(name string) (io.WriteCloser, error)
+}
+
+// New returns a Sink for the given destination. Supported destinations are
+// "-" or "" for stdout, a file path or file:// URL, an http:// or https:// URL
+// which receives the output via POST and an s3://bucket/key URL.
+func New(dest string, stdout io.Writer) (Sink, error) {
+	if dest == "" || dest == "-" {
+		return NewStdoutSink(stdout), nil
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink %q: %v", dest, err)
+	}
+
+	switch u.Scheme {
+	case "":
+		return NewFileSink(dest), nil
+	case "file":
+		return NewFileSink(u.Path), nil
+	case "http", "https":
+		return NewHTTPSink(dest, &http.Client{Timeout: DefaultTimeout}), nil
+	case "s3":
+		return NewS3Sink(u.Host, strings.TrimPrefix(u.Path, "/"), &http.Client{Timeout: DefaultTimeout})
+	}
+
+	return nil, fmt.Errorf("invalid sink %q: unsupported scheme %q", dest, u.Scheme)
+}
+
+// StdoutSink writes all outputs to the same writer.
+type StdoutSink struct {
+	out io.Writer
+}
+
+// NewStdoutSink creates a new StdoutSink.
+func NewStdoutSink(out io.Writer) *StdoutSink {
+	return &StdoutSink{
+		out: out,
+	}
+}
+
+// Open returns a writer to stdout, the name is ignored.
+func (s *StdoutSink) Open(name string) (io.WriteCloser, error) {
+	return nopCloser{s.out}, nil
+}
+
+// Name inserts the output name before the file extension of path,
+// e.g. "graph.dot" and "kube-system" results in "graph-kube-system.dot".
+// A trailing ".gz" extension is kept, e.g. "graph-kube-system.dot.gz".
+func Name(path string, name string) string {
+	if len(name) == 0 {
+		return path
+	}
+	if strings.HasSuffix(path, "/") {
+		return path + name
+	}
+	if strings.HasSuffix(path, gzipExt) {
+		return Name(strings.TrimSuffix(path, gzipExt), name) + gzipExt
+	}
+
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), name, ext)
+}
+
+// Gzip returns a writer which compresses all data written to w. Closing
+// the returned writer closes w as well.
+func Gzip(w io.WriteCloser) io.WriteCloser {
+	return &gzipWriter{Writer: gzip.NewWriter(w), out: w}
+}
+
+type gzipWriter struct {
+	*gzip.Writer
+	out io.WriteCloser
+}
+
+func (w *gzipWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		w.out.Close()
+		return err
+	}
+
+	return w.out.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}